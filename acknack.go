@@ -1,7 +1,7 @@
 package lasr
 
 import (
-	"sync/atomic"
+	"time"
 
 	bolt "go.etcd.io/bbolt"
 )
@@ -10,20 +10,19 @@ func (q *Q) ack(id []byte) error {
 	q.mu.RLock()
 	defer q.mu.RUnlock()
 	var wake bool
-	err := q.db.Update(func(tx *bolt.Tx) error {
-		var err error
-		wake, err = q.stopWaitingOn(tx, id)
-		if err != nil {
-			return err
-		}
-		bucket, err := q.bucket(tx, q.keys.unacked)
-		if err != nil {
-			return err
-		}
-		return bucket.Delete(id)
+	err := q.db.Update(func(tx *bolt.Tx) (err error) {
+		wake, err = q.ackTx(tx, id)
+		return err
 	})
+	if err == ErrQClosed {
+		// q was shut down before the message could be acked.
+		q.doneInFlight()
+		return err
+	}
 	if err == nil {
-		q.inFlight.Done()
+		q.doneInFlight()
+		q.signalSpace()
+		q.onAck(id)
 	}
 	if wake && !q.isClosed() {
 		q.waker.Wake()
@@ -31,52 +30,203 @@ func (q *Q) ack(id []byte) error {
 	return err
 }
 
-func (q *Q) nack(id []byte, retry bool) error {
+// ackTx acks id in tx. It reports whether any waiting messages became Ready.
+func (q *Q) ackTx(tx *bolt.Tx, id []byte) (bool, error) {
+	if q.isAbandoned() {
+		return false, ErrQClosed
+	}
+	wake, err := q.stopWaitingOn(tx, id)
+	if err != nil {
+		return false, err
+	}
+	bucket, err := q.bucket(tx, q.keys.unacked)
+	if err != nil {
+		return false, err
+	}
+	if v := bucket.Get(id); v != nil {
+		if err := q.archive(tx, id, v, time.Now()); err != nil {
+			return false, err
+		}
+	}
+	if err := q.recordProcessed(tx, id, time.Now()); err != nil {
+		return false, err
+	}
+	if err := bucket.Delete(id); err != nil {
+		return false, err
+	}
+	q.touch(tx, id)
+	if err := q.deleteMeta(tx, id); err != nil {
+		return false, err
+	}
+	if err := q.adjustDepth(tx, -1); err != nil {
+		return false, err
+	}
+	if err := q.auditID(tx, AuditAck, id); err != nil {
+		return false, err
+	}
+	return wake, q.incrCounter(tx, ackedCounter)
+}
+
+// nack nacks id. If retry is true, the message waits for delay before it is
+// Ready again, or for the delay chosen by the retry policy if delay is
+// negative. A non-empty cause is recorded as the error it failed with, see
+// NackError.
+func (q *Q) nack(id []byte, retry bool, delay time.Duration, cause string) error {
 	q.mu.RLock()
 	defer q.mu.RUnlock()
-	var wake bool
-	err := q.db.Update(func(tx *bolt.Tx) (rerr error) {
-		bucket, err := q.bucket(tx, q.keys.unacked)
-		if err != nil {
-			return err
-		}
-		if retry {
-			wake = true
-			val := bucket.Get(id)
-			ready, err := q.bucket(tx, q.keys.ready)
-			if err != nil {
+	var result nackResult
+	err := q.db.Update(func(tx *bolt.Tx) (err error) {
+		if cause != "" {
+			if err := q.recordError(tx, id, cause); err != nil {
 				return err
 			}
-			return ready.Put(id, val)
 		}
-		wake, err = q.stopWaitingOn(tx, id)
+		result, err = q.nackTx(tx, id, retry, delay)
+		return err
+	})
+	if err == ErrQClosed {
+		// q was shut down before the message could be nacked.
+		q.doneInFlight()
+		return err
+	}
+	if err != nil {
+		return err
+	}
+	q.doneInFlight()
+	q.nacked(id, retry, result)
+	return nil
+}
+
+// nackResult is what nackTx did with a message, for nacked to act on once it
+// is committed.
+type nackResult struct {
+	wake         bool
+	deadLettered bool
+	expired      bool
+	due          time.Time
+}
+
+// nackTx nacks id in tx.
+func (q *Q) nackTx(tx *bolt.Tx, id []byte, retry bool, delay time.Duration) (result nackResult, err error) {
+	if q.isAbandoned() {
+		return result, ErrQClosed
+	}
+	bucket, err := q.bucket(tx, q.keys.unacked)
+	if err != nil {
+		return result, err
+	}
+	q.touch(tx, id)
+	if err := q.incrCounter(tx, nackedCounter); err != nil {
+		return result, err
+	}
+	if err := q.auditID(tx, AuditNack, id); err != nil {
+		return result, err
+	}
+	if retry {
+		md, err := q.getMeta(tx, id)
 		if err != nil {
-			return err
+			return result, err
 		}
-		if len(q.keys.returned) > 0 {
-			val := bucket.Get(id)
-			returned, err := q.bucket(tx, q.keys.returned)
-			if err != nil {
-				return err
+		if q.expired(md, time.Now()) {
+			// The message isn't worth retrying.
+			result.expired = true
+			result.deadLettered = len(q.keys.returned) > 0
+			result.wake, err = q.expire(tx, bucket, id)
+			return result, err
+		}
+		val := bucket.Get(id)
+		delay, err := q.retryDelay(tx, id, delay)
+		if err != nil {
+			return result, err
+		}
+		if delay > 0 {
+			result.due = time.Now().Add(delay)
+			if err := q.scheduleRetry(tx, id, val, result.due); err != nil {
+				return result, err
 			}
-			return returned.Put(id, val)
+			return result, bucket.Delete(id)
 		}
-		return bucket.Delete(id)
-	})
+		result.wake = true
+		ready, err := q.bucket(tx, q.keys.ready)
+		if err != nil {
+			return result, err
+		}
+		if err := ready.Put(id, val); err != nil {
+			return result, err
+		}
+		return result, bucket.Delete(id)
+	}
+	result.wake, err = q.stopWaitingOn(tx, id)
 	if err != nil {
-		return err
+		return result, err
 	}
-	q.inFlight.Done()
-	if wake && !q.isClosed() {
-		q.waker.Wake()
+	if len(q.deadLetterQueue) > 0 {
+		if err := q.forwardDeadLetter(tx, bucket, id, DeadLetterNacked); err != nil {
+			return result, err
+		}
+		result.deadLettered = true
+	} else if len(q.keys.returned) > 0 {
+		val := bucket.Get(id)
+		returned, err := q.bucket(tx, q.keys.returned)
+		if err != nil {
+			return result, err
+		}
+		if err := returned.Put(id, val); err != nil {
+			return result, err
+		}
+		if err := q.stampDeadLetter(tx, id, time.Now(), DeadLetterNacked); err != nil {
+			return result, err
+		}
+		if err := q.retire(tx, id); err != nil {
+			return result, err
+		}
+		if err := q.incrCounter(tx, deadLetteredCounter); err != nil {
+			return result, err
+		}
+		result.deadLettered = true
+	} else if err := q.deleteMeta(tx, id); err != nil {
+		return result, err
+	}
+	if err := q.adjustDepth(tx, -1); err != nil {
+		return result, err
+	}
+	return result, bucket.Delete(id)
+}
+
+// nacked runs the hooks and wakeups for id, once the nack has been committed.
+func (q *Q) nacked(id []byte, retry bool, result nackResult) {
+	if !retry || result.expired {
+		q.signalSpace()
+	}
+	q.onNack(id, retry && !result.expired)
+	if result.deadLettered {
+		q.logger().Debug("lasr: dead-lettered message", "id", hexID(id))
+		q.onDeadLetter(id)
+	}
+	if !q.isClosed() {
+		if result.wake {
+			q.waker.Wake()
+		}
+		if !result.due.IsZero() {
+			q.waker.WakeAt(result.due)
+		}
 	}
-	return nil
 }
 
 // Ack acknowledges successful receipt and processing of the Message.
-func (m *Message) Ack() (err error) {
-	if !atomic.CompareAndSwapInt32(&m.once, 0, 1) {
-		return ErrAckNack
+func (m *Message) Ack() error {
+	if m.q == nil || len(m.q.interceptors) == 0 {
+		return m.ack()
+	}
+	return m.q.interceptAck(m, m.ack)
+}
+
+func (m *Message) ack() error {
+	if err := m.finish(); err != nil {
+		if err == ErrLeaseExpired && m.q != nil {
+			m.q.recordLateAck(m.ID)
+		}
+		return err
 	}
 	if m.q == nil {
 		// If a user has constructed a Message outside of this package, ie,
@@ -90,9 +240,20 @@ func (m *Message) Ack() (err error) {
 // Nack negatively acknowledges successful receipt and processing of the
 // Message. If Nack is called with retry True, then the Message will be
 // placed back in the queue in its original position.
-func (m *Message) Nack(retry bool) (err error) {
-	if !atomic.CompareAndSwapInt32(&m.once, 0, 1) {
-		return ErrAckNack
+func (m *Message) Nack(retry bool) error {
+	nack := func() error {
+		return m.nack(retry, -1, "")
+	}
+	if m.q == nil || len(m.q.interceptors) == 0 {
+		return nack()
+	}
+	return m.q.interceptNack(m, retry, nack)
+}
+
+// nack nacks m, see nack on Q.
+func (m *Message) nack(retry bool, delay time.Duration, cause string) error {
+	if err := m.finish(); err != nil {
+		return err
 	}
 	if m.q == nil {
 		// If a user has constructed a Message outside of this package, ie,
@@ -100,7 +261,24 @@ func (m *Message) Nack(retry bool) (err error) {
 		// instead of dereferencing the underlying Q and causing a panic.
 		return nil
 	}
-	return m.q.nack(m.ID, retry)
+	return m.q.nack(m.ID, retry, delay, cause)
+}
+
+// NackDelay negatively acknowledges the Message, and places it back in the
+// queue in its original position once d has passed, regardless of the retry
+// policy of the Q. While it waits, the Message is counted as Retrying. If d is
+// not positive, the Message is Ready again immediately.
+func (m *Message) NackDelay(d time.Duration) error {
+	if d < 0 {
+		d = 0
+	}
+	nack := func() error {
+		return m.nack(true, d, "")
+	}
+	if m.q == nil || len(m.q.interceptors) == 0 {
+		return nack()
+	}
+	return m.q.interceptNack(m, true, nack)
 }
 
 // stopWaitingOn causes all messages waiting on id to not wait on id.
@@ -110,6 +288,10 @@ func (q *Q) stopWaitingOn(tx *bolt.Tx, id []byte) (bool, error) {
 	// blocking -> x blocking y
 	// blockedOn -> x blocked on y
 	wake := false
+	if len(q.keys.blocking) == 0 {
+		// dead-letter queues do not track waiting messages
+		return wake, nil
+	}
 	blocking, err := q.bucket(tx, q.keys.blocking)
 	if err != nil {
 		return wake, err
@@ -131,6 +313,7 @@ func (q *Q) stopWaitingOn(tx *bolt.Tx, id []byte) (bool, error) {
 		if err := blockedMsg.Delete(id); err != nil {
 			return wake, err
 		}
+		q.touch(tx, k)
 		c = blockedMsg.Cursor()
 		if k, _ := c.First(); k != nil {
 			// There are still messages blocking the release of blockedMsg
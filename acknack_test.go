@@ -4,6 +4,7 @@ import (
 	"context"
 	"sync"
 	"testing"
+	"time"
 
 	bolt "go.etcd.io/bbolt"
 )
@@ -140,3 +141,81 @@ func TestNackDeletesMessage_GH5(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func TestAckDeadLetter(t *testing.T) {
+	q, cleanup := newQ(t, WithDeadLetters())
+	defer cleanup()
+
+	if _, err := q.Send([]byte("foo")); err != nil {
+		t.Fatal(err)
+	}
+	msg, err := q.Receive(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := msg.Nack(false); err != nil {
+		t.Fatal(err)
+	}
+
+	dl, err := DeadLetters(q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dl.Close()
+	msg, err = dl.Receive(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := msg.Ack(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestNackRemovesUnacked(t *testing.T) {
+	q, cleanup := newQ(t, WithDeadLetters())
+	defer cleanup()
+
+	for _, body := range []string{"retried", "dead"} {
+		if _, err := q.Send([]byte(body)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	msg, err := q.Receive(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := msg.Nack(true); err != nil {
+		t.Fatal(err)
+	}
+	for _, retry := range []bool{false, false} {
+		msg, err := q.Receive(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(msg.Body) == "retried" {
+			if err := msg.Ack(); err != nil {
+				t.Fatal(err)
+			}
+			continue
+		}
+		if err := msg.Nack(retry); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := q.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Neither message may come back: one was acked after its retry, and
+	// the other was dead-lettered.
+	reopened, err := NewQ(q.db, "testing", WithDeadLetters())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reopened.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if msg, err := reopened.Receive(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("nacked message returned to ready: %v %v", msg, err)
+	}
+}
@@ -0,0 +1,81 @@
+package lasr
+
+import bolt "go.etcd.io/bbolt"
+
+// AckAndSend acks m and sends bodies to the Q that m was received from, in a
+// single transaction. Either the ack and all of the sends take effect, or
+// none of them do. It returns the IDs of the new messages, in order.
+//
+// If the Q was created with WithMaxDepth, and the new messages would not fit
+// even after m is acked, AckAndSend returns ErrQueueFull without blocking,
+// and m remains Unacked.
+func (m *Message) AckAndSend(bodies ...[]byte) ([]ID, error) {
+	if err := m.finish(); err != nil {
+		return nil, err
+	}
+	if m.q == nil {
+		// As with Ack, messages constructed outside of this package are
+		// not backed by a Q.
+		return nil, nil
+	}
+	ids, err := m.q.ackAndSend(m.ID, bodies)
+	if err != nil && err != ErrQClosed {
+		// Nothing was committed, so m can still be acked or nacked.
+		m.reopen()
+	}
+	return ids, err
+}
+
+func (q *Q) ackAndSend(id []byte, bodies [][]byte) ([]ID, error) {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	var (
+		wake bool
+		ids  = make([]ID, 0, len(bodies))
+	)
+	err := q.db.Update(func(tx *bolt.Tx) (err error) {
+		wake, err = q.ackTx(tx, id)
+		if err != nil {
+			return err
+		}
+		if q.maxDepth > 0 && q.counter(tx, depthCounter)+uint64(len(bodies)) > q.maxDepth {
+			return ErrQueueFull
+		}
+		for _, body := range bodies {
+			newID, err := q.nextSequence(tx)
+			if err != nil {
+				return err
+			}
+			if err := q.send(newID, body, &metadata{}, tx); err != nil {
+				return err
+			}
+			ids = append(ids, newID)
+		}
+		return nil
+	})
+	if err == ErrQClosed {
+		// q was shut down before the message could be acked.
+		q.doneInFlight()
+		return nil, err
+	}
+	if err != nil {
+		return nil, err
+	}
+	q.doneInFlight()
+	if len(bodies) == 0 {
+		q.signalSpace()
+	}
+	q.onAck(id)
+	for _, newID := range ids {
+		q.onSend(newID)
+	}
+	if !q.isClosed() {
+		if wake || len(bodies) > 0 {
+			q.waker.Wake()
+		}
+		if len(bodies) > 0 {
+			q.wakeSubscriptions()
+		}
+	}
+	return ids, nil
+}
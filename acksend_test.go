@@ -0,0 +1,79 @@
+package lasr
+
+import (
+	"context"
+	"testing"
+)
+
+func TestAckAndSend(t *testing.T) {
+	q, cleanup := newQ(t)
+	defer cleanup()
+
+	if _, err := q.Send([]byte("foo")); err != nil {
+		t.Fatal(err)
+	}
+	m, err := q.Receive(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids, err := m.AckAndSend([]byte("bar"), []byte("baz"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(ids), 2; got != want {
+		t.Fatalf("bad id count: got %d, want %d", got, want)
+	}
+	if err := m.Ack(); err != ErrAckNack {
+		t.Errorf("bad error: got %v, want %v", err, ErrAckNack)
+	}
+
+	for _, want := range []string{"bar", "baz"} {
+		m, err := q.Receive(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := string(m.Body); got != want {
+			t.Errorf("bad body: got %q, want %q", got, want)
+		}
+		if err := m.Ack(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	stats, err := q.Stats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := stats.Acked, uint64(3); got != want {
+		t.Errorf("bad acked count: got %d, want %d", got, want)
+	}
+	if got, want := stats.Sent, uint64(3); got != want {
+		t.Errorf("bad sent count: got %d, want %d", got, want)
+	}
+}
+
+func TestAckAndSendFull(t *testing.T) {
+	q, cleanup := newQ(t, WithMaxDepth(2))
+	defer cleanup()
+
+	if _, err := q.Send([]byte("foo")); err != nil {
+		t.Fatal(err)
+	}
+	m, err := q.Receive(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.AckAndSend([]byte("a"), []byte("b"), []byte("c")); err != ErrQueueFull {
+		t.Fatalf("bad error: got %v, want %v", err, ErrQueueFull)
+	}
+	stats, err := q.Stats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := stats.Unacked, 1; got != want {
+		t.Errorf("bad unacked count: got %d, want %d", got, want)
+	}
+	if _, err := m.AckAndSend([]byte("a"), []byte("b")); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,110 @@
+package lasr
+
+import (
+	"bytes"
+	"sort"
+	"sync/atomic"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// AckUpTo acks every message received from q that is not acked or nacked yet,
+// and whose ID is less than or equal to id, in a single transaction. It
+// returns the number of messages acked. Consumers that process messages in
+// order can ack a run of them at once, instead of one transaction each. If an
+// interceptor fails, its error is returned with the number of messages that
+// were acked anyway, see WithInterceptor.
+//
+// Only messages that Receive or one of its variants returned are acked;
+// messages that are buffered for Receive are not. The acked messages behave
+// as if Ack had been called on them: calling Ack or Nack on them afterwards
+// returns ErrAckNack.
+func (q *Q) AckUpTo(id []byte) (int, error) {
+	if q.readOnly {
+		return 0, ErrReadOnly
+	}
+	msgs := q.finishReceived(func(msg *Message) bool {
+		return bytes.Compare(msg.ID, id) <= 0
+	})
+	var n int
+	err := q.interceptBatch(msgs, q.interceptAck, func(msgs []*Message) error {
+		if err := q.ackMessages(msgs); err != nil {
+			return err
+		}
+		n = len(msgs)
+		return nil
+	})
+	return n, err
+}
+
+// finishReceived marks the received messages that are still open and match
+// as acked, and returns them in order of their IDs.
+func (q *Q) finishReceived(match func(*Message) bool) []*Message {
+	var msgs []*Message
+	q.leaseMu.Lock()
+	defer q.leaseMu.Unlock()
+	for msg := range q.leases {
+		if !match(msg) {
+			continue
+		}
+		if atomic.CompareAndSwapInt32(&msg.once, messageOpen, messageDone) {
+			delete(q.leases, msg)
+			msg.released()
+			msgs = append(msgs, msg)
+		}
+	}
+	sortMessages(msgs)
+	return msgs
+}
+
+// ackMessages acks msgs, which were finished by finishReceived, in a single
+// transaction. If nothing was committed, msgs can be acked or nacked again.
+func (q *Q) ackMessages(msgs []*Message) error {
+	if len(msgs) == 0 {
+		return nil
+	}
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	var wake bool
+	err := q.db.Update(func(tx *bolt.Tx) error {
+		wake = false
+		for _, msg := range msgs {
+			woke, err := q.ackTx(tx, msg.ID)
+			if err != nil {
+				return err
+			}
+			wake = wake || woke
+		}
+		return nil
+	})
+	if err == ErrQClosed {
+		// q was shut down before the messages could be acked.
+		for range msgs {
+			q.doneInFlight()
+		}
+		return err
+	}
+	if err != nil {
+		for _, msg := range msgs {
+			msg.reopen()
+		}
+		return err
+	}
+	for range msgs {
+		q.doneInFlight()
+	}
+	q.signalSpace()
+	for _, msg := range msgs {
+		q.onAck(msg.ID)
+	}
+	if wake && !q.isClosed() {
+		q.waker.Wake()
+	}
+	return nil
+}
+
+func sortMessages(msgs []*Message) {
+	sort.Slice(msgs, func(i, j int) bool {
+		return bytes.Compare(msgs[i].ID, msgs[j].ID) < 0
+	})
+}
@@ -0,0 +1,53 @@
+package lasr
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestAckUpTo(t *testing.T) {
+	q, cleanup := newQ(t)
+	defer cleanup()
+	sendBodies(t, q, "a", "b", "c", "d")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	var msgs []*Message
+	for i := 0; i < 3; i++ {
+		msg, err := q.Receive(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		msgs = append(msgs, msg)
+	}
+	// "c" is acked on its own, so only "a" and "b" are left to ack.
+	if err := msgs[2].Ack(); err != nil {
+		t.Fatal(err)
+	}
+	n, err := q.AckUpTo(msgs[2].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := n, 2; got != want {
+		t.Errorf("bad ack count: got %d, want %d", got, want)
+	}
+	for _, msg := range msgs {
+		if err := msg.Ack(); err != ErrAckNack {
+			t.Errorf("got %v, want ErrAckNack", err)
+		}
+	}
+	stats, err := q.Stats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Unacked != 0 || stats.Ready != 1 {
+		t.Errorf("bad stats: %+v", stats)
+	}
+	if got := receiveBodies(t, q, 1); got[0] != "d" {
+		t.Errorf("bad body: got %q, want %q", got[0], "d")
+	}
+	if n, err := q.AckUpTo(msgs[2].ID); err != nil || n != 0 {
+		t.Errorf("AckUpTo: got %d, %v, want 0, nil", n, err)
+	}
+}
@@ -0,0 +1,66 @@
+package lasr
+
+import (
+	"fmt"
+	"sync/atomic"
+)
+
+// AdaptiveBuffer configures a message buffer that resizes itself, see
+// WithAdaptiveBuffer.
+type AdaptiveBuffer struct {
+	// Min and Max bound the buffer size, with the same meaning as the size
+	// passed to WithMessageBufferSize.
+	Min, Max int
+
+	// MaxBytes, if positive, is the total size of message bodies that the
+	// buffer aims to stay under.
+	MaxBytes int
+}
+
+// WithAdaptiveBuffer is like WithMessageBufferSize, but the buffer size is
+// adjusted each time the buffer is refilled. It starts at b.Min, and doubles
+// when the buffer filled up and the consumers have acked or nacked most of
+// the messages they received, up to b.Max. It halves when the buffered bodies
+// take more than b.MaxBytes, or when consumers hold more unacked messages
+// than the buffer size.
+func WithAdaptiveBuffer(b AdaptiveBuffer) Option {
+	return func(q *Q) error {
+		if q.optsApplied {
+			return ErrOptionsApplied
+		}
+		if b.Min < 0 || b.Max < b.Min || b.MaxBytes < 0 {
+			return fmt.Errorf("lasr: invalid adaptive buffer: %+v", b)
+		}
+		q.messages = newFifo(b.Max + 1)
+		q.messages.SetLimit(b.Min + 1)
+		q.adaptive = &b
+		return nil
+	}
+}
+
+// adaptBuffer resizes the message buffer of q after it has been refilled. It
+// is called with the buffer locked.
+func (q *Q) adaptBuffer() {
+	b := q.adaptive
+	if b == nil {
+		return
+	}
+	limit := q.messages.Limit()
+	switch {
+	case b.MaxBytes > 0 && q.messages.Bytes() > b.MaxBytes,
+		int(atomic.LoadInt32(&q.outstanding)) > limit:
+		limit /= 2
+	case q.messages.Len() >= limit:
+		limit *= 2
+	}
+	if limit < b.Min+1 {
+		limit = b.Min + 1
+	}
+	q.messages.SetLimit(limit)
+}
+
+// doneInFlight records that a received message was acked or nacked.
+func (q *Q) doneInFlight() {
+	atomic.AddInt32(&q.outstanding, -1)
+	q.inFlight.Done()
+}
@@ -0,0 +1,116 @@
+package lasr
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+)
+
+func bufferLimit(q *Q) int {
+	q.messages.Lock()
+	defer q.messages.Unlock()
+	return q.messages.Limit()
+}
+
+func TestAdaptiveBufferGrows(t *testing.T) {
+	q, cleanup := newQ(t, WithAdaptiveBuffer(AdaptiveBuffer{Min: 0, Max: 7}))
+	defer cleanup()
+
+	for i := 0; i < 30; i++ {
+		if _, err := q.Send([]byte("foo")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	for i := 0; i < 30; i++ {
+		m, err := q.Receive(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := m.Ack(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got, want := bufferLimit(q), 8; got != want {
+		t.Errorf("bad buffer limit: got %d, want %d", got, want)
+	}
+}
+
+func TestAdaptiveBufferLargeMessages(t *testing.T) {
+	q, cleanup := newQ(t, WithAdaptiveBuffer(AdaptiveBuffer{Min: 0, Max: 7, MaxBytes: 100}))
+	defer cleanup()
+
+	body := bytes.Repeat([]byte{'x'}, 60)
+	for i := 0; i < 30; i++ {
+		if _, err := q.Send(body); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	for i := 0; i < 30; i++ {
+		m, err := q.Receive(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := m.Ack(); err != nil {
+			t.Fatal(err)
+		}
+		if limit := bufferLimit(q); limit > 2 {
+			t.Fatalf("buffer limit grew to %d with large messages", limit)
+		}
+	}
+}
+
+func TestAdaptiveBufferSlowAcks(t *testing.T) {
+	q, cleanup := newQ(t, WithAdaptiveBuffer(AdaptiveBuffer{Min: 0, Max: 7}))
+	defer cleanup()
+
+	for i := 0; i < 40; i++ {
+		if _, err := q.Send([]byte("foo")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	// Grow the buffer while acks keep up.
+	for i := 0; i < 15; i++ {
+		m, err := q.Receive(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := m.Ack(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	grown := bufferLimit(q)
+	if grown <= 1 {
+		t.Fatalf("buffer did not grow: limit %d", grown)
+	}
+	// Hold on to every message from now on.
+	var held []*Message
+	for i := 0; i < 20; i++ {
+		m, err := q.Receive(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		held = append(held, m)
+	}
+	if got := bufferLimit(q); got >= grown {
+		t.Errorf("buffer did not shrink with slow acks: limit %d, was %d", got, grown)
+	}
+	for _, m := range held {
+		if err := m.Ack(); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestAdaptiveBufferInvalid(t *testing.T) {
+	q := &Q{}
+	if err := WithAdaptiveBuffer(AdaptiveBuffer{Min: 4, Max: 2})(q); err == nil {
+		t.Error("expected an error")
+	}
+}
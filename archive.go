@@ -0,0 +1,234 @@
+package lasr
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// archiveSizeCounter counts the messages in the archive of a Q.
+var archiveSizeCounter = []byte("archive")
+
+// replayBatch is how many archived messages Replay reads per transaction.
+const replayBatch = 1000
+
+// archived is an acked message, as stored in the archive. Archived messages
+// are keyed by when they were acked, in nanoseconds since the epoch, followed
+// by their ID, so the oldest comes first.
+type archived struct {
+	Body    []byte            `json:"body"`
+	Headers map[string][]byte `json:"headers,omitempty"`
+}
+
+// WithArchive makes q move acked messages to an archive instead of deleting
+// them, so that they can be sent again with Replay. The archive is unbounded,
+// unless WithArchiveLimit or WithArchiveTTL is used as well.
+//
+// Bodies are archived whole, encrypted if q uses WithEncryption, and without
+// the rest of their metadata. Messages whose bodies can't be read when they
+// are acked are not archived.
+func WithArchive() Option {
+	return func(q *Q) error {
+		if q.optsApplied {
+			return ErrOptionsApplied
+		}
+		q.keys.archive = []byte("archive")
+		return nil
+	}
+}
+
+// WithArchiveLimit makes q keep at most n messages in its archive, deleting
+// the oldest ones to make room. It requires WithArchive.
+func WithArchiveLimit(n int) Option {
+	return func(q *Q) error {
+		if q.optsApplied {
+			return ErrOptionsApplied
+		}
+		if n <= 0 {
+			return fmt.Errorf("lasr: invalid archive limit: %d", n)
+		}
+		q.archiveLimit = uint64(n)
+		return nil
+	}
+}
+
+// WithArchiveTTL makes q delete archived messages once they were acked longer
+// than d ago. It requires WithArchive. The archive is pruned as messages are
+// acked, and when q is created.
+func WithArchiveTTL(d time.Duration) Option {
+	return func(q *Q) error {
+		if q.optsApplied {
+			return ErrOptionsApplied
+		}
+		if d <= 0 {
+			return fmt.Errorf("lasr: invalid archive TTL: %s", d)
+		}
+		q.archiveTTL = d
+		return nil
+	}
+}
+
+// archive stores the message id, whose stored value is v, in the archive, if
+// q has one, as acked at now.
+func (q *Q) archive(tx *bolt.Tx, id, v []byte, now time.Time) error {
+	if len(q.keys.archive) == 0 {
+		return nil
+	}
+	body, err := q.openBody(tx, id, v)
+	if err != nil {
+		q.logger().Warn("lasr: couldn't archive message", "id", hexID(id), "error", err)
+		return nil
+	}
+	rec := archived{}
+	if rec.Body, err = q.seal(id, body); err != nil {
+		return err
+	}
+	md, err := q.getMeta(tx, id)
+	if err != nil {
+		return err
+	}
+	if md != nil {
+		rec.Headers = md.Headers
+	}
+	value, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	bucket, err := q.bucket(tx, q.keys.archive)
+	if err != nil {
+		return err
+	}
+	key := make([]byte, 8, 8+len(id))
+	binary.BigEndian.PutUint64(key, uint64(now.UnixNano()))
+	if err := bucket.Put(append(key, id...), value); err != nil {
+		return err
+	}
+	if err := q.incrCounter(tx, archiveSizeCounter); err != nil {
+		return err
+	}
+	return q.pruneArchiveTx(tx, now)
+}
+
+// pruneArchive deletes the archived messages of q that are over its limits.
+func (q *Q) pruneArchive() error {
+	if len(q.keys.archive) == 0 || q.readOnly {
+		return nil
+	}
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	return q.db.Update(func(tx *bolt.Tx) error {
+		return q.pruneArchiveTx(tx, time.Now())
+	})
+}
+
+func (q *Q) pruneArchiveTx(tx *bolt.Tx, now time.Time) error {
+	if q.archiveLimit == 0 && q.archiveTTL == 0 {
+		return nil
+	}
+	bucket := q.readBucket(tx, q.keys.archive)
+	if bucket == nil {
+		return nil
+	}
+	size := q.counter(tx, archiveSizeCounter)
+	var cutoff []byte
+	if q.archiveTTL > 0 {
+		cutoff = make([]byte, 8)
+		binary.BigEndian.PutUint64(cutoff, uint64(now.Add(-q.archiveTTL).UnixNano()))
+	}
+	cur := bucket.Cursor()
+	var pruned uint64
+	for k, _ := cur.First(); k != nil; k, _ = cur.First() {
+		full := q.archiveLimit > 0 && size-pruned > q.archiveLimit
+		old := cutoff != nil && bytes.Compare(k[:8], cutoff) <= 0
+		if !full && !old {
+			break
+		}
+		if err := bucket.Delete(k); err != nil {
+			return err
+		}
+		pruned++
+	}
+	if pruned == 0 {
+		return nil
+	}
+	if pruned > size {
+		pruned = size
+	}
+	return q.setCounter(tx, archiveSizeCounter, size-pruned)
+}
+
+// Replay sends the archived messages of q that were acked at or after from
+// again, in the order they were acked, as new messages with the same bodies
+// and headers, and returns how many it sent. Replayed messages stay in the
+// archive, so replaying them twice sends them twice. It requires
+// WithArchive.
+func (q *Q) Replay(from time.Time) (int, error) {
+	if q.isClosed() {
+		return 0, ErrQClosed
+	}
+	if q.readOnly {
+		return 0, ErrReadOnly
+	}
+	if len(q.keys.archive) == 0 {
+		return 0, errors.New("lasr: archive not available")
+	}
+	next := make([]byte, 8)
+	if from.After(time.Unix(0, 0)) {
+		binary.BigEndian.PutUint64(next, uint64(from.UnixNano()))
+	}
+	var n int
+	for next != nil {
+		var batch []archived
+		var err error
+		batch, next, err = q.readArchive(next)
+		if err != nil {
+			return n, fmt.Errorf("lasr: couldn't replay archive: %s", err)
+		}
+		for _, rec := range batch {
+			if _, err := q.sendWithHeaders(rec.Body, rec.Headers); err != nil {
+				return n, err
+			}
+			n++
+		}
+	}
+	return n, nil
+}
+
+// readArchive returns up to replayBatch archived messages, unsealed, from
+// the key from on, and the key to read the next ones from, if there are any.
+func (q *Q) readArchive(from []byte) ([]archived, []byte, error) {
+	var batch []archived
+	var next []byte
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	err := q.db.View(func(tx *bolt.Tx) error {
+		bucket := q.readBucket(tx, q.keys.archive)
+		if bucket == nil {
+			return nil
+		}
+		cur := bucket.Cursor()
+		for k, v := cur.Seek(from); k != nil; k, v = cur.Next() {
+			if len(batch) == replayBatch {
+				next = cloneBytes(k)
+				return nil
+			}
+			var rec archived
+			if err := json.Unmarshal(v, &rec); err != nil {
+				return fmt.Errorf("error reading archived message %x: %s", k[8:], err)
+			}
+			body, err := q.unseal(k[8:], rec.Body)
+			if err != nil {
+				return err
+			}
+			rec.Body = body
+			batch = append(batch, rec)
+		}
+		return nil
+	})
+	return batch, next, err
+}
@@ -0,0 +1,103 @@
+package lasr
+
+import (
+	"testing"
+	"time"
+)
+
+func TestArchive(t *testing.T) {
+	q, cleanup := newQ(t, WithArchive(), WithEncryption(testKey))
+	defer cleanup()
+
+	start := time.Now()
+	if _, err := q.SendWithHeaders([]byte("a"), map[string][]byte{"k": []byte("v")}); err != nil {
+		t.Fatal(err)
+	}
+	sendBodies(t, q, "b")
+	for _, msg := range receiveN(t, q, 2) {
+		if err := msg.Ack(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	stats, err := q.Stats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Ready != 0 || stats.Archived != 2 {
+		t.Fatalf("got %d ready and %d archived messages, want 0 and 2", stats.Ready, stats.Archived)
+	}
+
+	if n, err := q.Replay(time.Now().Add(time.Hour)); err != nil || n != 0 {
+		t.Fatalf("got %d, %v replaying the future, want 0, nil", n, err)
+	}
+	n, err := q.Replay(start)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Fatalf("replayed %d messages, want 2", n)
+	}
+	msgs := receiveN(t, q, 2)
+	if string(msgs[0].Body) != "a" || string(msgs[0].Headers["k"]) != "v" || string(msgs[1].Body) != "b" {
+		t.Errorf("bad replayed messages: %+v, %+v", msgs[0], msgs[1])
+	}
+	for _, msg := range msgs {
+		if err := msg.Ack(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if stats, err := q.Stats(); err != nil {
+		t.Fatal(err)
+	} else if stats.Archived != 4 {
+		t.Errorf("got %d archived messages, want 4", stats.Archived)
+	}
+}
+
+func TestArchiveLimits(t *testing.T) {
+	q, cleanup := newQ(t, WithArchive(), WithArchiveLimit(2))
+	defer cleanup()
+
+	sendBodies(t, q, "a", "b", "c")
+	for _, msg := range receiveN(t, q, 3) {
+		if err := msg.Ack(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n, err := q.Replay(time.Time{}); err != nil || n != 2 {
+		t.Fatalf("got %d, %v, want 2, nil", n, err)
+	}
+	msgs := receiveN(t, q, 2)
+	if string(msgs[0].Body) != "b" || string(msgs[1].Body) != "c" {
+		t.Errorf("got %q and %q, want the newest archived messages", msgs[0].Body, msgs[1].Body)
+	}
+	for _, msg := range msgs {
+		if err := msg.Nack(false); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ttl, err := NewQ(q.db, "ttl", WithArchive(), WithArchiveTTL(time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ttl.Close()
+	sendBodies(t, ttl, "a")
+	for _, msg := range receiveN(t, ttl, 1) {
+		if err := msg.Ack(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	time.Sleep(5 * time.Millisecond)
+	if err := ttl.pruneArchive(); err != nil {
+		t.Fatal(err)
+	}
+	if stats, err := ttl.Stats(); err != nil {
+		t.Fatal(err)
+	} else if stats.Archived != 0 {
+		t.Errorf("got %d archived messages after their TTL, want 0", stats.Archived)
+	}
+
+	if _, err := NewQ(q.db, "other", WithArchiveLimit(1)); err == nil {
+		t.Error("expected an error without WithArchive")
+	}
+}
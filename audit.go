@@ -0,0 +1,130 @@
+package lasr
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// auditBucket holds the audit log of a Q, keyed by sequence, in its root
+// bucket, see WithAuditLog.
+var auditBucket = []byte("audit")
+
+// The operations that are recorded in the audit log, see WithAuditLog.
+const (
+	AuditSend    = "send"
+	AuditAck     = "ack"
+	AuditNack    = "nack"
+	AuditRedrive = "redrive"
+	AuditPurge   = "purge"
+	AuditDelete  = "delete"
+)
+
+// AuditEntry is an operation on a Q, as recorded in its audit log and written
+// by ExportAudit, as a single line of JSON.
+type AuditEntry struct {
+	Time  time.Time `json:"time"`
+	Actor string    `json:"actor,omitempty"`
+	Op    string    `json:"op"`
+
+	// ID is the ID of the message that the operation concerns, if it
+	// concerns a single one.
+	ID []byte `json:"id,omitempty"`
+
+	// State and Count are the state that was purged, and how many messages
+	// were, for AuditPurge.
+	State string `json:"state,omitempty"`
+	Count int    `json:"count,omitempty"`
+}
+
+// WithAuditLog makes q record who sent, acked, nacked, redrove, purged and
+// deleted messages, and when, in an audit log that is kept in the database
+// alongside q, and can be read with ExportAudit. actor says who operates q,
+// like a service or host name, and is recorded with every entry.
+//
+// Entries are written in the transactions of the operations they record, and
+// are never deleted by lasr. Messages that are sent to subscriptions are
+// recorded once, for q.
+func WithAuditLog(actor string) Option {
+	return func(q *Q) error {
+		if q.optsApplied {
+			return ErrOptionsApplied
+		}
+		q.auditing = true
+		q.auditActor = actor
+		return nil
+	}
+}
+
+// audit records e in the audit log of q, if q has one.
+func (q *Q) audit(tx *bolt.Tx, e AuditEntry) error {
+	if !q.auditing {
+		return nil
+	}
+	e.Time = time.Now()
+	e.Actor = q.auditActor
+	v, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	bucket, err := q.bucket(tx, auditBucket)
+	if err != nil {
+		return err
+	}
+	seq, err := bucket.NextSequence()
+	if err != nil {
+		return err
+	}
+	var k [8]byte
+	binary.BigEndian.PutUint64(k[:], seq)
+	return bucket.Put(k[:], v)
+}
+
+// auditID records op on the message id in the audit log of q.
+func (q *Q) auditID(tx *bolt.Tx, op string, id []byte) error {
+	return q.audit(tx, AuditEntry{Op: op, ID: id})
+}
+
+// countSent counts the message id that was sent to q, and records it in the
+// audit log.
+func (q *Q) countSent(tx *bolt.Tx, id []byte) error {
+	if err := q.incrCounter(tx, sentCounter); err != nil {
+		return err
+	}
+	return q.auditID(tx, AuditSend, id)
+}
+
+// ExportAudit writes the audit log of q to w, oldest entry first, as
+// newline-delimited JSON, one AuditEntry per line, see WithAuditLog.
+func (q *Q) ExportAudit(w io.Writer) error {
+	if q.isClosed() {
+		return ErrQClosed
+	}
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	bw := bufio.NewWriter(w)
+	err := q.db.View(func(tx *bolt.Tx) error {
+		bucket := q.readBucket(tx, auditBucket)
+		if bucket == nil {
+			return nil
+		}
+		return bucket.ForEach(func(_, v []byte) error {
+			if _, err := bw.Write(v); err != nil {
+				return err
+			}
+			return bw.WriteByte('\n')
+		})
+	})
+	if err == nil {
+		err = bw.Flush()
+	}
+	if err != nil {
+		return fmt.Errorf("lasr: error exporting audit log: %s", err)
+	}
+	return nil
+}
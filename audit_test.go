@@ -0,0 +1,60 @@
+package lasr
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestAuditLog(t *testing.T) {
+	q, cleanup := newQ(t, WithDeadLetters(), WithAuditLog("tester"))
+	defer cleanup()
+
+	sendBodies(t, q, "a", "b")
+	msgs := receiveN(t, q, 2)
+	if err := msgs[0].Ack(); err != nil {
+		t.Fatal(err)
+	}
+	if err := msgs[1].Nack(false); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := q.RedriveDeadLetters(0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := q.Purge(Ready); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := q.ExportAudit(&buf); err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	dec := json.NewDecoder(&buf)
+	for dec.More() {
+		var e AuditEntry
+		if err := dec.Decode(&e); err != nil {
+			t.Fatal(err)
+		}
+		if e.Actor != "tester" || time.Since(e.Time) > time.Minute {
+			t.Errorf("bad entry: %+v", e)
+		}
+		switch e.Op {
+		case AuditPurge:
+			if e.State != "ready" || e.Count != 1 {
+				t.Errorf("bad purge entry: %+v", e)
+			}
+		default:
+			if len(e.ID) != 8 {
+				t.Errorf("bad %s entry: %+v", e.Op, e)
+			}
+		}
+		got = append(got, e.Op)
+	}
+	want := []string{AuditSend, AuditSend, AuditAck, AuditNack, AuditRedrive, AuditPurge}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
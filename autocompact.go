@@ -0,0 +1,78 @@
+package lasr
+
+import (
+	"fmt"
+	"time"
+)
+
+// autoCompactInterval is how often queues created with WithAutoCompact check
+// their disk usage.
+var autoCompactInterval = time.Minute
+
+// WithAutoCompact makes q compact its database in the background, when more
+// than threshold bytes could be reclaimed, see DiskStats. Disk usage is
+// checked every minute.
+//
+// Like Compact, compaction makes q and the other queues sharing its database
+// wait until it is done, and then carries on with the compacted database:
+// sends, receives and acks that arrive in the meantime are delayed, not
+// failed. Errors are logged, see WithLogger, and compaction is tried again at
+// the next check.
+func WithAutoCompact(threshold int64) Option {
+	return func(q *Q) error {
+		if q.optsApplied {
+			return ErrOptionsApplied
+		}
+		if threshold <= 0 {
+			return fmt.Errorf("lasr: invalid auto compaction threshold: %d", threshold)
+		}
+		q.autoCompact = threshold
+		return nil
+	}
+}
+
+// startAutoCompact starts checking whether q should be compacted.
+func (q *Q) startAutoCompact() {
+	if q.autoCompact == 0 || q.readOnly {
+		return
+	}
+	q.autoCompactDone = make(chan struct{})
+	go q.autoCompactLoop(autoCompactInterval)
+}
+
+func (q *Q) autoCompactLoop(interval time.Duration) {
+	defer close(q.autoCompactDone)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			q.maybeCompact()
+		case <-q.closed:
+			return
+		}
+	}
+}
+
+// maybeCompact compacts the database of q if enough space can be reclaimed.
+func (q *Q) maybeCompact() {
+	stats, err := q.DiskStats()
+	if err != nil {
+		q.logger().Warn("lasr: couldn't check disk usage", "error", err)
+		return
+	}
+	if stats.Reclaimable <= q.autoCompact {
+		return
+	}
+	if err := q.Compact(); err != nil {
+		q.logger().Warn("lasr: automatic compaction failed", "error", err)
+	}
+}
+
+// stopAutoCompact waits for the compaction loop of q to stop, once q is
+// closed.
+func (q *Q) stopAutoCompact() {
+	if q.autoCompactDone != nil {
+		<-q.autoCompactDone
+	}
+}
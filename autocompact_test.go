@@ -0,0 +1,56 @@
+package lasr
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestAutoCompact(t *testing.T) {
+	defer func(d time.Duration) { autoCompactInterval = d }(autoCompactInterval)
+	autoCompactInterval = 10 * time.Millisecond
+
+	q, cleanup := newQ(t, WithAutoCompact(64<<10))
+	defer cleanup()
+	body := bytes.Repeat([]byte("x"), 4096)
+	for i := 0; i < 256; i++ {
+		if _, err := q.Send(body); err != nil {
+			t.Fatal(err)
+		}
+	}
+	full, err := q.DiskStats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Keep using q while it is compacted.
+	for i := 0; i < 256; i++ {
+		msg := receiveN(t, q, 1)[0]
+		if err := msg.Ack(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		stats, err := q.DiskStats()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stats.Size < full.Size && stats.Reclaimable <= 64<<10 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("not compacted: before %+v, now %+v", full, stats)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	sendBodies(t, q, "a")
+	if got := receiveBodies(t, q, 1); got[0] != "a" {
+		t.Errorf("bad body after compacting: %q", got[0])
+	}
+}
+
+func TestAutoCompactInvalid(t *testing.T) {
+	if err := WithAutoCompact(0)(&Q{}); err == nil {
+		t.Error("expected an error")
+	}
+}
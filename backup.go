@@ -0,0 +1,94 @@
+package lasr
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// Backup writes a consistent snapshot of the database underlying q to w, and
+// returns the number of bytes written. Producers and consumers can keep using
+// q while the backup is taken.
+//
+// The snapshot contains every queue in the database, not only q. It can be
+// turned back into a database file with RestoreQ.
+func (q *Q) Backup(w io.Writer) (int64, error) {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	var n int64
+	err := q.db.View(func(tx *bolt.Tx) (err error) {
+		n, err = tx.WriteTo(w)
+		return err
+	})
+	if err != nil {
+		return n, fmt.Errorf("lasr: error backing up queue: %s", err)
+	}
+	return n, nil
+}
+
+// RestoreQ writes a backup taken with Q.Backup to a new database file at
+// path, which can then be opened with bolt and passed to NewQ. RestoreQ
+// returns an error if path already exists, or if r does not contain a valid
+// database. The file is only created once the backup has been verified.
+func RestoreQ(r io.Reader, path string) (rerr error) {
+	if _, err := os.Stat(path); err == nil {
+		return fmt.Errorf("lasr: error restoring queue: %s already exists", path)
+	} else if !os.IsNotExist(err) {
+		return fmt.Errorf("lasr: error restoring queue: %s", err)
+	}
+	f, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".restore")
+	if err != nil {
+		return fmt.Errorf("lasr: error restoring queue: %s", err)
+	}
+	defer func() {
+		if rerr != nil {
+			os.Remove(f.Name())
+		}
+	}()
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return fmt.Errorf("lasr: error restoring queue: %s", err)
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return fmt.Errorf("lasr: error restoring queue: %s", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("lasr: error restoring queue: %s", err)
+	}
+	if err := verifyBackup(f.Name()); err != nil {
+		return fmt.Errorf("lasr: error restoring queue: %s", err)
+	}
+	if err := os.Rename(f.Name(), path); err != nil {
+		return fmt.Errorf("lasr: error restoring queue: %s", err)
+	}
+	return nil
+}
+
+// verifyBackup checks that the database at path can be opened and read.
+func verifyBackup(path string) error {
+	db, err := bolt.Open(path, 0600, &bolt.Options{ReadOnly: true, Timeout: time.Second})
+	if err != nil {
+		return err
+	}
+	err = db.View(func(tx *bolt.Tx) error {
+		// Drain the channel, so the check can finish, but report only
+		// the first problem.
+		var first error
+		for err := range tx.Check() {
+			if first == nil {
+				first = err
+			}
+		}
+		return first
+	})
+	if cerr := db.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
@@ -0,0 +1,87 @@
+package lasr
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+func TestBackupRestore(t *testing.T) {
+	q, cleanup := newQ(t)
+	defer cleanup()
+
+	for _, body := range []string{"a", "b"} {
+		if _, err := q.Send([]byte(body)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var buf bytes.Buffer
+	n, err := q.Backup(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != int64(buf.Len()) {
+		t.Errorf("bad byte count: got %d, want %d", n, buf.Len())
+	}
+
+	td, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(td)
+	path := filepath.Join(td, "restored.db")
+	if err := RestoreQ(bytes.NewReader(buf.Bytes()), path); err != nil {
+		t.Fatal(err)
+	}
+	if err := RestoreQ(bytes.NewReader(buf.Bytes()), path); err == nil {
+		t.Error("expected error restoring over an existing file")
+	}
+
+	db, err := bolt.Open(path, 0600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	restored, err := NewQ(db, "testing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer restored.Close()
+	for _, want := range []string{"a", "b"} {
+		m, err := restored.Receive(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := string(m.Body); got != want {
+			t.Errorf("bad body: got %q, want %q", got, want)
+		}
+		if err := m.Ack(); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestRestoreInvalid(t *testing.T) {
+	td, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(td)
+	path := filepath.Join(td, "restored.db")
+	if err := RestoreQ(bytes.NewReader([]byte("not a database")), path); err == nil {
+		t.Fatal("expected error restoring garbage")
+	}
+	entries, err := ioutil.ReadDir(td)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("restore left %d files behind", len(entries))
+	}
+}
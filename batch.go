@@ -0,0 +1,92 @@
+package lasr
+
+import bolt "go.etcd.io/bbolt"
+
+// AckBatch acks the received messages with the given IDs in a single
+// transaction, instead of one transaction each. Every ID must be that of a
+// message returned by Receive or one of its variants, that has not been
+// acked or nacked yet; otherwise AckBatch returns ErrNotFound, and acks none
+// of them.
+func (q *Q) AckBatch(ids ...[]byte) error {
+	if q.readOnly {
+		return ErrReadOnly
+	}
+	msgs, err := q.finishBatch(ids)
+	if err != nil {
+		return err
+	}
+	return q.interceptBatch(msgs, q.interceptAck, q.ackMessages)
+}
+
+// NackBatch is like AckBatch, but nacks the messages, as Nack does.
+func (q *Q) NackBatch(ids [][]byte, retry bool) error {
+	if q.readOnly {
+		return ErrReadOnly
+	}
+	msgs, err := q.finishBatch(ids)
+	if err != nil {
+		return err
+	}
+	intercept := func(msg *Message, next func() error) error {
+		return q.interceptNack(msg, retry, next)
+	}
+	return q.interceptBatch(msgs, intercept, func(msgs []*Message) error {
+		return q.nackMessages(msgs, retry)
+	})
+}
+
+// nackMessages nacks msgs, which were finished by finishReceived, in a single
+// transaction. If nothing was committed, msgs can be acked or nacked again.
+func (q *Q) nackMessages(msgs []*Message, retry bool) error {
+	if len(msgs) == 0 {
+		return nil
+	}
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	results := make([]nackResult, len(msgs))
+	err := q.db.Update(func(tx *bolt.Tx) (err error) {
+		for i, msg := range msgs {
+			if results[i], err = q.nackTx(tx, msg.ID, retry, -1); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err == ErrQClosed {
+		// q was shut down before the messages could be nacked.
+		for range msgs {
+			q.doneInFlight()
+		}
+		return err
+	}
+	if err != nil {
+		for _, msg := range msgs {
+			msg.reopen()
+		}
+		return err
+	}
+	for i, msg := range msgs {
+		q.doneInFlight()
+		q.nacked(msg.ID, retry, results[i])
+	}
+	return nil
+}
+
+// finishBatch finishes the received messages with the given IDs, or none of
+// them if any is missing.
+func (q *Q) finishBatch(ids [][]byte) ([]*Message, error) {
+	want := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		want[string(id)] = true
+	}
+	msgs := q.finishReceived(func(msg *Message) bool {
+		return want[string(msg.ID)]
+	})
+	if len(msgs) < len(want) {
+		for _, msg := range msgs {
+			msg.reopen()
+		}
+		return nil, ErrNotFound
+	}
+	return msgs, nil
+}
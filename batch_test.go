@@ -0,0 +1,73 @@
+package lasr
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func receiveN(t *testing.T, q *Q, n int) []*Message {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	var msgs []*Message
+	for i := 0; i < n; i++ {
+		msg, err := q.Receive(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		msgs = append(msgs, msg)
+	}
+	return msgs
+}
+
+func TestAckBatch(t *testing.T) {
+	q, cleanup := newQ(t)
+	defer cleanup()
+	sendBodies(t, q, "a", "b", "c")
+	msgs := receiveN(t, q, 3)
+
+	if err := q.AckBatch(msgs[0].ID, []byte("missing")); err != ErrNotFound {
+		t.Fatalf("got %v, want ErrNotFound", err)
+	}
+	if err := q.AckBatch(msgs[0].ID, msgs[2].ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := msgs[0].Ack(); err != ErrAckNack {
+		t.Errorf("got %v, want ErrAckNack", err)
+	}
+	if err := msgs[1].Ack(); err != nil {
+		t.Fatal(err)
+	}
+	stats, err := q.Stats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Unacked != 0 || stats.Ready != 0 {
+		t.Errorf("bad stats: %+v", stats)
+	}
+}
+
+func TestNackBatch(t *testing.T) {
+	q, cleanup := newQ(t, WithDeadLetters())
+	defer cleanup()
+	sendBodies(t, q, "a", "b", "c", "d")
+	msgs := receiveN(t, q, 4)
+
+	if err := q.NackBatch([][]byte{msgs[0].ID, msgs[1].ID}, true); err != nil {
+		t.Fatal(err)
+	}
+	if err := q.NackBatch([][]byte{msgs[2].ID, msgs[3].ID}, false); err != nil {
+		t.Fatal(err)
+	}
+	stats, err := q.Stats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Unacked != 0 || stats.Ready != 2 || stats.Returned != 2 {
+		t.Errorf("bad stats: %+v", stats)
+	}
+	if got := receiveBodies(t, q, 2); got[0] != "a" || got[1] != "b" {
+		t.Errorf("bad bodies: got %q, want [a b]", got)
+	}
+}
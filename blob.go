@@ -0,0 +1,177 @@
+package lasr
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// blobsBucket maps the IDs of the messages whose bodies are in the blob store
+// of a Q to their keys in the store.
+var blobsBucket = []byte("blobs")
+
+// BlobStore stores message bodies outside of the bolt database, see
+// WithBlobStore. Keys are made of printable characters, and may contain
+// slashes. A BlobStore must be safe for concurrent use.
+type BlobStore interface {
+	// Put stores data under key, replacing what was there. The data must
+	// be durable when Put returns.
+	Put(key string, data []byte) error
+
+	// Get returns the data stored under key.
+	Get(key string) ([]byte, error)
+
+	// Delete deletes the data under key. Deleting a key that does not exist
+	// is not an error.
+	Delete(key string) error
+}
+
+// WithBlobStore stores the bodies of messages that are larger than threshold
+// bytes in store, and keeps only a reference to them in the database. Blobs
+// are fetched when the messages are read, and deleted once the messages are
+// acked, or otherwise leave q. Dead letters keep their blobs until they are
+// acked in turn.
+//
+// Blobs are written in the transaction that stores the message, and deleted
+// after the transaction that removes it is committed. If the transaction that
+// stores a message fails, its blob is left behind in store. Encrypted bodies
+// are sealed before they are written to store.
+//
+// Every Q that can read the messages, like subscriptions of q, must use the
+// same store.
+func WithBlobStore(store BlobStore, threshold int) Option {
+	return func(q *Q) error {
+		if q.optsApplied {
+			return ErrOptionsApplied
+		}
+		if store == nil {
+			return errors.New("lasr: nil blob store")
+		}
+		if threshold < 0 {
+			return fmt.Errorf("lasr: invalid blob threshold: %d", threshold)
+		}
+		q.blobs = store
+		q.blobThreshold = threshold
+		return nil
+	}
+}
+
+// blobKey returns the key of the body of message id in the blob store.
+func (q *Q) blobKey(id []byte) string {
+	return fmt.Sprintf("%s/%s", q.name, hex.EncodeToString(id))
+}
+
+// storeBlob writes body to the blob store of q, if it is larger than the
+// threshold, and reports whether it did.
+func (q *Q) storeBlob(tx *bolt.Tx, id, body []byte) (bool, error) {
+	if q.blobs == nil || len(body) <= q.blobThreshold {
+		return false, nil
+	}
+	blobs, err := q.bucket(tx, blobsBucket)
+	if err != nil {
+		return false, err
+	}
+	key := q.blobKey(id)
+	if err := q.blobs.Put(key, body); err != nil {
+		return false, fmt.Errorf("lasr: couldn't store blob: %s", err)
+	}
+	return true, blobs.Put(id, []byte(key))
+}
+
+// loadBlob returns the body of message id from the blob store, or nil if it
+// isn't stored there.
+func (q *Q) loadBlob(tx *bolt.Tx, id []byte) ([]byte, error) {
+	blobs := q.readBucket(tx, blobsBucket)
+	if blobs == nil {
+		return nil, nil
+	}
+	key := blobs.Get(id)
+	if key == nil {
+		return nil, nil
+	}
+	if q.blobs == nil {
+		return nil, errors.New("lasr: message body is in a blob store, but Q has none")
+	}
+	body, err := q.blobs.Get(string(key))
+	if err != nil {
+		return nil, fmt.Errorf("lasr: couldn't load blob: %s", err)
+	}
+	return body, nil
+}
+
+// deleteBlob deletes the body of message id from the blob store once tx is
+// committed, if it is stored there.
+func (q *Q) deleteBlob(tx *bolt.Tx, id []byte) error {
+	blobs := q.readBucket(tx, blobsBucket)
+	if blobs == nil {
+		return nil
+	}
+	key := blobs.Get(id)
+	if key == nil {
+		return nil
+	}
+	key = cloneBytes(key)
+	if err := blobs.Delete(id); err != nil {
+		return err
+	}
+	store := q.blobs
+	if store == nil {
+		q.logger().Warn("lasr: can't delete blob without a blob store", "key", string(key))
+		return nil
+	}
+	tx.OnCommit(func() {
+		if err := store.Delete(string(key)); err != nil {
+			q.logger().Warn("lasr: couldn't delete blob", "key", string(key), "error", err)
+		}
+	})
+	return nil
+}
+
+// DirBlobStore is a BlobStore that keeps each blob in a file of its own, in
+// the directory it names.
+type DirBlobStore string
+
+func (d DirBlobStore) path(key string) string {
+	return filepath.Join(string(d), url.PathEscape(key))
+}
+
+// Put writes data to a temporary file and renames it into place, so that
+// readers never see a partial blob.
+func (d DirBlobStore) Put(key string, data []byte) error {
+	if err := os.MkdirAll(string(d), 0700); err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(string(d), ".tmp-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), d.path(key))
+}
+
+func (d DirBlobStore) Get(key string) ([]byte, error) {
+	return os.ReadFile(d.path(key))
+}
+
+func (d DirBlobStore) Delete(key string) error {
+	if err := os.Remove(d.path(key)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+package lasr
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"strings"
+	"testing"
+)
+
+// blobFiles returns the blobs in store.
+func blobFiles(t *testing.T, store DirBlobStore) []string {
+	t.Helper()
+	entries, err := os.ReadDir(string(store))
+	if err != nil && !os.IsNotExist(err) {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, e := range entries {
+		if !strings.HasPrefix(e.Name(), ".tmp-") {
+			names = append(names, e.Name())
+		}
+	}
+	return names
+}
+
+func TestBlobStore(t *testing.T) {
+	store := DirBlobStore(t.TempDir())
+	q, cleanup := newQ(t, WithBlobStore(store, 8), WithDeadLetters())
+	defer cleanup()
+
+	large := bytes.Repeat([]byte("x"), 1000)
+	sendBodies(t, q, string(large), "small", "dead letter")
+	if got := len(blobFiles(t, store)); got != 2 {
+		t.Fatalf("got %d blobs, want 2", got)
+	}
+
+	msgs := receiveN(t, q, 3)
+	if !bytes.Equal(msgs[0].Body, large) {
+		t.Errorf("bad body: got %d bytes, want %d", len(msgs[0].Body), len(large))
+	}
+	if got := string(msgs[1].Body); got != "small" {
+		t.Errorf("got %q, want %q", got, "small")
+	}
+	for _, msg := range msgs[:2] {
+		if err := msg.Ack(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := msgs[2].Nack(false); err != nil {
+		t.Fatal(err)
+	}
+	if got := len(blobFiles(t, store)); got != 1 {
+		t.Fatalf("got %d blobs after ack, want 1", got)
+	}
+
+	dl, err := DeadLetters(q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dl.Close()
+	msg, err := dl.Receive(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(msg.Body); got != "dead letter" {
+		t.Errorf("got %q, want %q", got, "dead letter")
+	}
+	if err := msg.Ack(); err != nil {
+		t.Fatal(err)
+	}
+	if got := blobFiles(t, store); len(got) != 0 {
+		t.Errorf("blobs left after dead letter ack: %q", got)
+	}
+}
+
+func TestBlobStoreSubscription(t *testing.T) {
+	store := DirBlobStore(t.TempDir())
+	q, cleanup := newQ(t, WithBlobStore(store, 0))
+	defer cleanup()
+	sub, err := q.Subscribe("sub")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sub.Close()
+	sendBodies(t, q, "hello")
+	msg, err := sub.Receive(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(msg.Body); got != "hello" {
+		t.Errorf("got %q, want %q", got, "hello")
+	}
+	if err := msg.Ack(); err != nil {
+		t.Fatal(err)
+	}
+	if got := blobFiles(t, store); len(got) != 0 {
+		t.Errorf("blobs left after ack: %q", got)
+	}
+}
@@ -0,0 +1,107 @@
+package lasr
+
+import (
+	"encoding/binary"
+	"hash/crc32"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// checksumsBucket holds the checksums of message bodies, keyed by message ID.
+// Like chunks, it is always looked up, so that every Q that reads a message
+// verifies it.
+var checksumsBucket = []byte("checksums")
+
+var checksumTable = crc32.MakeTable(crc32.Castagnoli)
+
+// WithChecksums stores a CRC-32C checksum of the body of every message that
+// is stored in q, and verifies it whenever the body is read. A body that
+// doesn't match its checksum makes Receive, Peek, Scan and Export return
+// ErrCorruptMessage, unless WithCorruptDeadLetters is used as well. Messages
+// that were stored without a checksum are read as before.
+//
+// Streamed bodies, see Message.Open, are verified once they are read to the
+// end.
+func WithChecksums() Option {
+	return func(q *Q) error {
+		if q.optsApplied {
+			return ErrOptionsApplied
+		}
+		q.checksums = true
+		return nil
+	}
+}
+
+// WithCorruptDeadLetters makes Receive dead-letter the messages whose bodies
+// don't match their checksums, instead of returning ErrCorruptMessage, so
+// that one damaged message doesn't stop the queue. It requires
+// WithDeadLetters.
+func WithCorruptDeadLetters() Option {
+	return func(q *Q) error {
+		if q.optsApplied {
+			return ErrOptionsApplied
+		}
+		q.corruptDeadLetters = true
+		return nil
+	}
+}
+
+func checksum(body []byte) uint32 {
+	return crc32.Checksum(body, checksumTable)
+}
+
+// putChecksum stores the checksum sum for the body of message id, if q uses
+// checksums.
+func (q *Q) putChecksum(tx *bolt.Tx, id []byte, sum uint32) error {
+	if !q.checksums {
+		return nil
+	}
+	bucket, err := q.bucket(tx, checksumsBucket)
+	if err != nil {
+		return err
+	}
+	var v [4]byte
+	binary.BigEndian.PutUint32(v[:], sum)
+	return bucket.Put(id, v[:])
+}
+
+// verify checks body against the checksum of message id, if it has one.
+func (q *Q) verify(tx *bolt.Tx, id, body []byte) error {
+	want, ok := q.getChecksum(tx, id)
+	if !ok || checksum(body) == want {
+		return nil
+	}
+	q.logger().Error("lasr: message doesn't match its checksum", "id", hexID(id))
+	return ErrCorruptMessage
+}
+
+func (q *Q) getChecksum(tx *bolt.Tx, id []byte) (uint32, bool) {
+	bucket := q.readBucket(tx, checksumsBucket)
+	if bucket == nil {
+		return 0, false
+	}
+	v := bucket.Get(id)
+	if len(v) != 4 {
+		return 0, false
+	}
+	return binary.BigEndian.Uint32(v), true
+}
+
+func (q *Q) deleteChecksum(tx *bolt.Tx, id []byte) error {
+	bucket := q.readBucket(tx, checksumsBucket)
+	if bucket == nil {
+		return nil
+	}
+	return bucket.Delete(id)
+}
+
+// deadLetterCorrupt moves the message stored at k in bucket, whose body is
+// corrupt, to the dead letters.
+func (q *Q) deadLetterCorrupt(tx *bolt.Tx, bucket *bolt.Bucket, k []byte) error {
+	k = cloneBytes(k)
+	if err := q.deadLetter(tx, bucket, k, DeadLetterCorrupt); err != nil {
+		return err
+	}
+	q.logger().Warn("lasr: dead-lettered corrupt message", "id", hexID(k))
+	return q.adjustDepth(tx, -1)
+}
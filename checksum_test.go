@@ -0,0 +1,92 @@
+package lasr
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// corrupt overwrites the stored body of the Ready message id.
+func corrupt(t *testing.T, q *Q, id ID) {
+	t.Helper()
+	key, err := id.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = q.db.Update(func(tx *bolt.Tx) error {
+		return q.readBucket(tx, q.keys.ready).Put(key, []byte("bit rot"))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestChecksums(t *testing.T) {
+	q, cleanup := newQ(t, WithChecksums())
+	defer cleanup()
+	id, err := q.Send([]byte("hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	corrupt(t, q, id)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if _, err := q.Receive(ctx); err != ErrCorruptMessage {
+		t.Fatalf("got %v, want ErrCorruptMessage", err)
+	}
+	if _, err := q.Peek(1); err != ErrCorruptMessage {
+		t.Errorf("Peek: got %v, want ErrCorruptMessage", err)
+	}
+}
+
+func TestCorruptDeadLetters(t *testing.T) {
+	q, cleanup := newQ(t, WithChecksums(), WithDeadLetters(), WithCorruptDeadLetters())
+	defer cleanup()
+	id, err := q.Send([]byte("hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sendBodies(t, q, "world")
+	corrupt(t, q, id)
+
+	msg := receiveN(t, q, 1)[0]
+	if got := string(msg.Body); got != "world" {
+		t.Errorf("got %q, want %q", got, "world")
+	}
+	if err := msg.Ack(); err != nil {
+		t.Fatal(err)
+	}
+	stats, err := q.Stats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Returned != 1 || stats.Ready != 0 {
+		t.Errorf("bad stats: %+v", stats)
+	}
+}
+
+func TestChecksumsStreamed(t *testing.T) {
+	q, cleanup := newQ(t, WithChecksums(), WithChunking(4), WithStreamedBodies())
+	defer cleanup()
+	id, err := q.Send([]byte("0123456789"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, _ := id.MarshalBinary()
+	err = q.db.Update(func(tx *bolt.Tx) error {
+		chunks := q.readBucket(tx, chunksBucket).Bucket(key)
+		return chunks.Put([]byte{0, 0, 0, 1}, []byte("xxxx"))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg := receiveN(t, q, 1)[0]
+	defer msg.Ack()
+	if _, err := io.ReadAll(msg.Open()); err != ErrCorruptMessage {
+		t.Errorf("got %v, want ErrCorruptMessage", err)
+	}
+}
@@ -0,0 +1,148 @@
+package lasr
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// chunksBucket holds the bodies that are stored in chunks, in a bucket per
+// message. It is always looked up, so that queues that don't chunk bodies
+// themselves can still read the ones that were chunked for them, like
+// subscriptions and dead letters.
+var chunksBucket = []byte("chunks")
+
+// WithChunking stores the bodies of messages that are larger than size bytes
+// in chunks of size bytes, each in its own bolt key, instead of in a single
+// value. This keeps bolt from allocating a run of contiguous pages for every
+// large message, which fragments the database and grows its mmap. Chunked
+// bodies are reassembled when messages are received, and are transparent to
+// the rest of the API.
+//
+// Encrypted bodies are chunked after they are sealed, so size applies to the
+// sealed body. Messages that were stored before chunking was enabled can
+// still be read, and so can chunked messages once it is disabled again.
+func WithChunking(size int) Option {
+	return func(q *Q) error {
+		if q.optsApplied {
+			return ErrOptionsApplied
+		}
+		if size < 1 {
+			return fmt.Errorf("lasr: invalid chunk size: %d", size)
+		}
+		q.chunkSize = size
+		return nil
+	}
+}
+
+// storeBody returns the value to store body under for the message id. If body
+// goes to the blob store of q, or needs chunking, the value is empty.
+func (q *Q) storeBody(tx *bolt.Tx, id, body []byte) ([]byte, error) {
+	if stored, err := q.storeBlob(tx, id, body); err != nil {
+		return nil, err
+	} else if stored {
+		return []byte{}, nil
+	}
+	if q.chunkSize == 0 || len(body) <= q.chunkSize {
+		return body, nil
+	}
+	chunks, err := q.bucket(tx, chunksBucket)
+	if err != nil {
+		return nil, err
+	}
+	if err := chunks.DeleteBucket(id); err != nil && err != bolt.ErrBucketNotFound {
+		return nil, err
+	}
+	bucket, err := chunks.CreateBucket(id)
+	if err != nil {
+		return nil, err
+	}
+	// Chunks are written in order, so they can fill their pages.
+	bucket.FillPercent = 1
+	var key [4]byte
+	for i := 0; len(body) > 0; i++ {
+		n := q.chunkSize
+		if n > len(body) {
+			n = len(body)
+		}
+		binary.BigEndian.PutUint32(key[:], uint32(i))
+		if err := bucket.Put(key[:], body[:n]); err != nil {
+			return nil, err
+		}
+		body = body[n:]
+	}
+	return []byte{}, nil
+}
+
+// loadBody returns the stored body of the message id, whose value is v,
+// reassembling it from its chunks, or fetching it from the blob store. Only
+// an empty value can stand for either, so other values are returned as is.
+func (q *Q) loadBody(tx *bolt.Tx, id, v []byte) ([]byte, error) {
+	if len(v) > 0 {
+		return v, nil
+	}
+	if body, err := q.loadBlob(tx, id); body != nil || err != nil {
+		return body, err
+	}
+	chunks := q.readBucket(tx, chunksBucket)
+	if chunks == nil {
+		return v, nil
+	}
+	bucket := chunks.Bucket(id)
+	if bucket == nil {
+		return v, nil
+	}
+	var body []byte
+	err := bucket.ForEach(func(_, chunk []byte) error {
+		body = append(body, chunk...)
+		return nil
+	})
+	return body, err
+}
+
+// deleteBody deletes the chunks or the blob of the message id, if it has
+// any, and its checksum.
+func (q *Q) deleteBody(tx *bolt.Tx, id []byte) error {
+	if err := q.deleteBlob(tx, id); err != nil {
+		return err
+	}
+	if err := q.deleteChecksum(tx, id); err != nil {
+		return err
+	}
+	chunks := q.readBucket(tx, chunksBucket)
+	if chunks == nil {
+		return nil
+	}
+	if err := chunks.DeleteBucket(id); err != nil && err != bolt.ErrBucketNotFound {
+		return err
+	}
+	return nil
+}
+
+// sealBody seals body for the message id, and returns the value to store it
+// under, see storeBody.
+func (q *Q) sealBody(tx *bolt.Tx, id, body []byte) ([]byte, error) {
+	sealed, err := q.seal(id, body)
+	if err != nil {
+		return nil, err
+	}
+	if err := q.putChecksum(tx, id, checksum(body)); err != nil {
+		return nil, err
+	}
+	return q.storeBody(tx, id, sealed)
+}
+
+// openBody returns the body of the message id that was stored as v by
+// sealBody, once it is verified.
+func (q *Q) openBody(tx *bolt.Tx, id, v []byte) ([]byte, error) {
+	v, err := q.loadBody(tx, id, v)
+	if err != nil {
+		return nil, err
+	}
+	body, err := q.unseal(id, v)
+	if err != nil {
+		return nil, err
+	}
+	return body, q.verify(tx, id, body)
+}
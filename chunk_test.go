@@ -0,0 +1,94 @@
+package lasr
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// chunkCount returns the number of messages in q with chunked bodies.
+func chunkCount(t *testing.T, q *Q) int {
+	t.Helper()
+	var n int
+	err := q.db.View(func(tx *bolt.Tx) error {
+		if chunks := q.readBucket(tx, chunksBucket); chunks != nil {
+			n = chunks.Stats().BucketN - 1
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+func TestChunking(t *testing.T) {
+	q, cleanup := newQ(t, WithChunking(64), WithDeadLetters(), WithEncryption(testKey))
+	defer cleanup()
+
+	large := bytes.Repeat([]byte("0123456789"), 100)
+	sendBodies(t, q, string(large), "small", string(large[:500]))
+	if got := chunkCount(t, q); got != 2 {
+		t.Fatalf("got %d chunked messages, want 2", got)
+	}
+
+	msgs := receiveN(t, q, 3)
+	if !bytes.Equal(msgs[0].Body, large) {
+		t.Errorf("bad body: got %d bytes, want %d", len(msgs[0].Body), len(large))
+	}
+	if got := string(msgs[1].Body); got != "small" {
+		t.Errorf("got %q, want %q", got, "small")
+	}
+	if err := msgs[0].Ack(); err != nil {
+		t.Fatal(err)
+	}
+	if err := msgs[1].Ack(); err != nil {
+		t.Fatal(err)
+	}
+	if err := msgs[2].Nack(false); err != nil {
+		t.Fatal(err)
+	}
+	if got := chunkCount(t, q); got != 1 {
+		t.Fatalf("got %d chunked messages after ack, want 1", got)
+	}
+
+	// Dead letters keep their chunks until they are acked.
+	dl, err := DeadLetters(q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dl.Close()
+	msg, err := dl.Receive(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(msg.Body, large[:500]) {
+		t.Errorf("bad dead letter: got %d bytes, want 500", len(msg.Body))
+	}
+	if err := msg.Ack(); err != nil {
+		t.Fatal(err)
+	}
+	if got := chunkCount(t, q); got != 0 {
+		t.Errorf("got %d chunked messages after dead letter ack, want 0", got)
+	}
+}
+
+func TestChunkingPurge(t *testing.T) {
+	q, cleanup := newQ(t, WithChunking(4))
+	defer cleanup()
+	sendBodies(t, q, "abcdefgh", "ijklmnop")
+	if _, err := q.Purge(Ready); err != nil {
+		t.Fatal(err)
+	}
+	if got := chunkCount(t, q); got != 0 {
+		t.Errorf("got %d chunked messages after purge, want 0", got)
+	}
+}
+
+func TestChunkingInvalid(t *testing.T) {
+	if _, err := configure("testing", []Option{WithChunking(0)}); err == nil {
+		t.Fatal("expected an error")
+	}
+}
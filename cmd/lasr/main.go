@@ -0,0 +1,335 @@
+// Command lasr is an administration tool for lasr queue files.
+//
+// Usage:
+//
+//	lasr -db <path> -q <name> [flags] <command> [arguments]
+//
+// The commands are:
+//
+//	stats              print message counts and counters
+//	peek [n]           print the next n Ready messages (default 10)
+//	get <id>           print the message with the hex encoded ID, in any state
+//	delete <id>        delete the message with the hex encoded ID
+//	purge <state>      delete every message in a state
+//	redrive [n]        return up to n dead letters to Ready (default all)
+//	compact            compact the database file in place
+//	export             write the queue to stdout as newline-delimited JSON
+//	import             read newline-delimited JSON from stdin into the queue
+//	merge <path> [q]   copy the Ready messages and dead letters of the queue q,
+//	                   by default of the same name, in the database at path
+//	audit              write the audit log of the queue to stdout
+//	dlq dump           write the dead letters of the queue to stdout as
+//	                   newline-delimited JSON, with why they were dead-lettered
+//	verify             check the queue for corruption and inconsistencies
+//	repair             fix the inconsistencies that verify reports as fixable
+//
+// Queues are opened with the options they were created with, apart from
+// encryption. The -dead-letters flag is only needed for queues that were
+// created before lasr recorded the options of queues.
+//
+// The -key-file flag names a file holding the queue's encryption key, hex
+// encoded. Leading and trailing whitespace is ignored.
+//
+// The database must not be open in another process. Opening a queue returns
+// its Unacked messages to Ready, as when a program using lasr restarts.
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/sensu/lasr"
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	dbPath      = flag.String("db", "", "Path to the queue database")
+	qName       = flag.String("q", "", "Name of the queue")
+	deadLetters = flag.Bool("dead-letters", false, "Open the queue with dead-lettering enabled, if it was created before lasr recorded the options of queues")
+	keyFile     = flag.String("key-file", "", "File containing the queue's hex encoded encryption key")
+)
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: lasr -db <path> -q <name> [flags] <command> [arguments]")
+	fmt.Fprintln(os.Stderr, "commands: stats, peek, get, delete, purge, redrive, compact, export, import, merge, audit, dlq, verify, repair")
+	flag.PrintDefaults()
+	os.Exit(2)
+}
+
+func main() {
+	flag.Usage = usage
+	flag.Parse()
+	if *dbPath == "" || *qName == "" || flag.NArg() < 1 {
+		usage()
+	}
+	if err := run(flag.Arg(0), flag.Args()[1:]); err != nil {
+		fmt.Fprintf(os.Stderr, "lasr: %s\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(cmd string, args []string) (rerr error) {
+	if _, err := os.Stat(*dbPath); err != nil {
+		return err
+	}
+	db, err := bolt.Open(*dbPath, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return fmt.Errorf("couldn't open %s: %s", *dbPath, err)
+	}
+	defer func() {
+		if err := db.Close(); rerr == nil {
+			rerr = err
+		}
+	}()
+	options := []lasr.Option{lasr.WithStoredConfig()}
+	if *deadLetters {
+		options = append(options, lasr.WithDeadLetters())
+	}
+	if *keyFile != "" {
+		key, err := readKey(*keyFile)
+		if err != nil {
+			return err
+		}
+		options = append(options, lasr.WithEncryption(key))
+	}
+	q, err := lasr.NewQ(db, *qName, options...)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := q.Close(); rerr == nil {
+			rerr = err
+		}
+	}()
+
+	switch cmd {
+	case "stats":
+		return stats(q)
+	case "peek":
+		n, err := intArg(args, 10)
+		if err != nil {
+			return err
+		}
+		return peek(q, n)
+	case "get":
+		if len(args) != 1 {
+			return fmt.Errorf("get requires an ID")
+		}
+		return get(q, args[0])
+	case "delete":
+		if len(args) != 1 {
+			return fmt.Errorf("delete requires an ID")
+		}
+		id, err := hex.DecodeString(args[0])
+		if err != nil {
+			return fmt.Errorf("invalid ID: %s", args[0])
+		}
+		return q.Delete(id)
+	case "purge":
+		if len(args) != 1 {
+			return fmt.Errorf("purge requires a state")
+		}
+		return purge(q, args[0])
+	case "redrive":
+		n, err := intArg(args, 0)
+		if err != nil {
+			return err
+		}
+		moved, err := q.RedriveDeadLetters(n)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("redrove %d messages\n", moved)
+		return nil
+	case "compact":
+		return q.Compact()
+	case "export":
+		return q.Export(os.Stdout)
+	case "import":
+		return q.Import(os.Stdin)
+	case "merge":
+		if len(args) < 1 || len(args) > 2 {
+			return fmt.Errorf("merge requires a database path")
+		}
+		return merge(q, args, options)
+	case "audit":
+		return q.ExportAudit(os.Stdout)
+	case "dlq":
+		if len(args) != 1 || args[0] != "dump" {
+			return fmt.Errorf("usage: dlq dump")
+		}
+		return q.DumpDeadLetters(os.Stdout)
+	case "verify":
+		return verify(q.Verify, false)
+	case "repair":
+		return verify(q.Repair, true)
+	default:
+		return fmt.Errorf("unknown command: %s", cmd)
+	}
+}
+
+func intArg(args []string, def int) (int, error) {
+	if len(args) == 0 {
+		return def, nil
+	}
+	n, err := strconv.Atoi(args[0])
+	if err != nil {
+		return 0, fmt.Errorf("invalid count: %s", args[0])
+	}
+	return n, nil
+}
+
+func stats(q *lasr.Q) error {
+	s, err := q.Stats()
+	if err != nil {
+		return err
+	}
+	fmt.Printf("ready:         %d\n", s.Ready)
+	fmt.Printf("unacked:       %d\n", s.Unacked)
+	fmt.Printf("delayed:       %d\n", s.Delayed)
+	fmt.Printf("waiting:       %d\n", s.Waiting)
+	fmt.Printf("retrying:      %d\n", s.Retrying)
+	fmt.Printf("returned:      %d\n", s.Returned)
+	fmt.Printf("sent:          %d\n", s.Sent)
+	fmt.Printf("acked:         %d\n", s.Acked)
+	fmt.Printf("nacked:        %d\n", s.Nacked)
+	fmt.Printf("dead-lettered: %d\n", s.DeadLettered)
+	fmt.Printf("expired:       %d\n", s.Expired)
+	return nil
+}
+
+func peek(q *lasr.Q, n int) error {
+	msgs, err := q.Peek(n)
+	if err != nil {
+		return err
+	}
+	for _, m := range msgs {
+		fmt.Printf("%x\t%q\n", m.ID, m.Body)
+	}
+	return nil
+}
+
+func get(q *lasr.Q, arg string) error {
+	id, err := hex.DecodeString(arg)
+	if err != nil {
+		return fmt.Errorf("invalid ID: %s", arg)
+	}
+	m, err := q.Get(id)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("state:         %s\n", m.State)
+	fmt.Printf("attempts:      %d\n", m.Attempts)
+	for _, t := range []struct {
+		name string
+		at   time.Time
+	}{
+		{"enqueued", m.EnqueuedAt},
+		{"delivered", m.LastDeliveredAt},
+		{"due", m.Due},
+		{"deadline", m.Deadline},
+		{"dead-lettered", m.DeadLetteredAt},
+	} {
+		if !t.at.IsZero() {
+			fmt.Printf("%-14s %s\n", t.name+":", t.at.Format(time.RFC3339Nano))
+		}
+	}
+	if m.DeadLetterReason != "" {
+		fmt.Printf("reason:        %s\n", m.DeadLetterReason)
+	}
+	if m.DeadLetterError != "" {
+		fmt.Printf("error:         %s\n", m.DeadLetterError)
+	}
+	for k, v := range m.Headers {
+		fmt.Printf("header:        %s=%q\n", k, v)
+	}
+	fmt.Printf("body:          %q\n", m.Body)
+	return nil
+}
+
+// merge copies the queue named by args from another database into q. The
+// other queue is opened read-only, with the same options as q.
+func merge(q *lasr.Q, args []string, options []lasr.Option) error {
+	name := *qName
+	if len(args) == 2 {
+		name = args[1]
+	}
+	other, err := lasr.OpenReadOnly(args[0], name, options...)
+	if err != nil {
+		return err
+	}
+	n, err := q.ImportFrom(other)
+	if cerr := other.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Printf("merged %d messages\n", n)
+	return nil
+}
+
+func purge(q *lasr.Q, state string) error {
+	states := map[string]lasr.Status{
+		lasr.Ready.String():    lasr.Ready,
+		lasr.Returned.String(): lasr.Returned,
+		lasr.Delayed.String():  lasr.Delayed,
+		lasr.Waiting.String():  lasr.Waiting,
+		lasr.Retrying.String(): lasr.Retrying,
+	}
+	s, ok := states[state]
+	if !ok {
+		return fmt.Errorf("invalid state: %s", state)
+	}
+	n, err := q.Purge(s)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("purged %d messages\n", n)
+	return nil
+}
+
+// verify prints the problems found by check. Verifying fails if there are any
+// problems, and repairing if some of them couldn't be fixed.
+func verify(check func() ([]lasr.Problem, error), repair bool) error {
+	problems, err := check()
+	if err != nil {
+		return err
+	}
+	var left int
+	for _, p := range problems {
+		switch {
+		case !p.Fixable:
+			fmt.Println(p)
+			left++
+		case repair:
+			fmt.Printf("%s (fixed)\n", p)
+		default:
+			fmt.Printf("%s (fixable)\n", p)
+			left++
+		}
+	}
+	if left > 0 {
+		return fmt.Errorf("%d problems left", left)
+	}
+	return nil
+}
+
+// readKey reads a hex encoded key from path.
+func readKey(path string) ([]byte, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	key, err := hex.DecodeString(string(bytes.TrimSpace(b)))
+	if err != nil {
+		return nil, fmt.Errorf("couldn't read key from %s: %s", path, err)
+	}
+	return key, nil
+}
@@ -0,0 +1,111 @@
+package lasr
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+)
+
+// ContentTypeHeader is the header that records which Codec encoded the body
+// of a message. Messages are only decoded by a Codec with the same content
+// type, so that a body is never silently misdecoded as something else.
+const ContentTypeHeader = "lasr-content-type"
+
+// Codec encodes values into message bodies, and decodes them back.
+type Codec interface {
+	// ContentType names the encoding, for example "application/json".
+	ContentType() string
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+}
+
+var (
+	// JSON encodes values with encoding/json. It is the default Codec.
+	JSON Codec = jsonCodec{}
+
+	// Gob encodes values with encoding/gob.
+	Gob Codec = gobCodec{}
+)
+
+type jsonCodec struct{}
+
+func (jsonCodec) ContentType() string {
+	return "application/json"
+}
+
+func (jsonCodec) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (jsonCodec) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
+
+type gobCodec struct{}
+
+func (gobCodec) ContentType() string {
+	return "application/x-gob"
+}
+
+func (gobCodec) Marshal(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (gobCodec) Unmarshal(data []byte, v interface{}) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(v)
+}
+
+// WithCodec sets the Codec that TypedQs of q use by default.
+func WithCodec(codec Codec) Option {
+	return func(q *Q) error {
+		if q.optsApplied {
+			return ErrOptionsApplied
+		}
+		q.codec = codec
+		return nil
+	}
+}
+
+// SendJSON encodes v as JSON and sends it.
+func (q *Q) SendJSON(v interface{}) (ID, error) {
+	return q.sendValue(JSON, v)
+}
+
+// ReceiveJSON receives a message like Receive, and decodes its body as JSON
+// into v. If the body can't be decoded, ReceiveJSON returns the message along
+// with the error, so that it can be nacked.
+func (q *Q) ReceiveJSON(ctx context.Context, v interface{}) (*Message, error) {
+	msg, err := q.Receive(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return msg, decode(JSON, msg, v)
+}
+
+func (q *Q) sendValue(codec Codec, v interface{}) (ID, error) {
+	body, err := codec.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("lasr: couldn't encode message: %s", err)
+	}
+	return q.SendWithHeaders(body, map[string][]byte{
+		ContentTypeHeader: []byte(codec.ContentType()),
+	})
+}
+
+// decode decodes the body of msg into v with codec. Messages without a
+// content type are decoded as is.
+func decode(codec Codec, msg *Message, v interface{}) error {
+	if ct, ok := msg.Headers[ContentTypeHeader]; ok && string(ct) != codec.ContentType() {
+		return fmt.Errorf("lasr: message %x has content type %q, not %q", msg.ID, ct, codec.ContentType())
+	}
+	if err := codec.Unmarshal(msg.Body, v); err != nil {
+		return fmt.Errorf("lasr: couldn't decode message %x: %s", msg.ID, err)
+	}
+	return nil
+}
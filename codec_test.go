@@ -0,0 +1,103 @@
+package lasr
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCodecs(t *testing.T) {
+	for _, codec := range []Codec{JSON, Gob} {
+		t.Run(codec.ContentType(), func(t *testing.T) {
+			q, cleanup := newQ(t)
+			defer cleanup()
+			tq := NewTypedQ[point](q, codec)
+
+			if _, err := tq.Send(point{X: 1, Y: 2}); err != nil {
+				t.Fatal(err)
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			msg, err := tq.Receive(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got, want := msg.Value, (point{X: 1, Y: 2}); got != want {
+				t.Errorf("bad value: got %v, want %v", got, want)
+			}
+			if got, want := string(msg.Headers[ContentTypeHeader]), codec.ContentType(); got != want {
+				t.Errorf("bad content type: got %q, want %q", got, want)
+			}
+			if err := msg.Ack(); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestWithCodec(t *testing.T) {
+	q, cleanup := newQ(t, WithCodec(Gob))
+	defer cleanup()
+	tq := NewTypedQ[point](q, nil)
+
+	if _, err := tq.Send(point{X: 1}); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	msg, err := q.Receive(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(msg.Headers[ContentTypeHeader]), Gob.ContentType(); got != want {
+		t.Errorf("bad content type: got %q, want %q", got, want)
+	}
+	if err := msg.Ack(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestContentTypeMismatch(t *testing.T) {
+	q, cleanup := newQ(t)
+	defer cleanup()
+
+	if _, err := NewTypedQ[point](q, Gob).Send(point{X: 1}); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	var p point
+	msg, err := q.ReceiveJSON(ctx, &p)
+	if err == nil || !strings.Contains(err.Error(), "content type") {
+		t.Errorf("bad error: %v", err)
+	}
+	if msg == nil {
+		t.Fatal("expected the message")
+	}
+	if err := msg.Nack(false); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSendJSON(t *testing.T) {
+	q, cleanup := newQ(t)
+	defer cleanup()
+
+	if _, err := q.SendJSON(point{X: 3, Y: 4}); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	var p point
+	msg, err := q.ReceiveJSON(ctx, &p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := p, (point{X: 3, Y: 4}); got != want {
+		t.Errorf("bad value: got %v, want %v", got, want)
+	}
+	if err := msg.Ack(); err != nil {
+		t.Fatal(err)
+	}
+}
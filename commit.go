@@ -0,0 +1,97 @@
+package lasr
+
+import (
+	"errors"
+	"sync"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// committer coalesces concurrent writes to a Q into shared transactions.
+//
+// A write that arrives while no transaction is running is committed right
+// away, on its own. Writes that arrive while a transaction is running wait
+// for it, and are then committed together by one of them. Unlike bolt's
+// DB.Batch, this never delays a write that has no company.
+type committer struct {
+	mu      sync.Mutex
+	pending []*write
+	busy    bool
+}
+
+type write struct {
+	fn   func(tx *bolt.Tx) error
+	done chan error
+}
+
+// errLead tells a waiting write to commit the pending writes.
+var errLead = errors.New("lead")
+
+// commit runs fn in a read-write transaction, which may be shared with other
+// calls to commit. fn may be called more than once, and must not have side
+// effects outside of tx that are unsafe to repeat.
+func (q *Q) commit(fn func(tx *bolt.Tx) error) error {
+	if q.readOnly {
+		return ErrReadOnly
+	}
+	w := &write{fn: fn, done: make(chan error, 1)}
+	c := &q.writes
+	c.mu.Lock()
+	c.pending = append(c.pending, w)
+	if c.busy {
+		c.mu.Unlock()
+		if err := <-w.done; err != errLead {
+			return err
+		}
+		c.mu.Lock()
+	}
+	c.busy = true
+	batch := c.pending
+	c.pending = nil
+	c.mu.Unlock()
+
+	q.commitBatch(batch)
+
+	c.mu.Lock()
+	if len(c.pending) > 0 {
+		c.pending[0].done <- errLead
+	} else {
+		c.busy = false
+	}
+	c.mu.Unlock()
+	return <-w.done
+}
+
+// commitBatch commits batch in a single transaction and reports the result to
+// each write. A write that fails is retried in a transaction of its own, so
+// that its error does not depend on the other writes, and the rest of the
+// batch is committed without it.
+func (q *Q) commitBatch(batch []*write) {
+	for len(batch) > 0 {
+		failed := -1
+		q.mu.RLock()
+		err := q.db.Update(func(tx *bolt.Tx) error {
+			for i, w := range batch {
+				if err := w.fn(tx); err != nil {
+					failed = i
+					return err
+				}
+			}
+			return nil
+		})
+		q.mu.RUnlock()
+		if failed < 0 || len(batch) == 1 {
+			for _, w := range batch {
+				w.done <- err
+			}
+			return
+		}
+		w := batch[failed]
+		q.logger().Warn("lasr: write failed in a shared transaction, retrying it alone",
+			"batch", len(batch), "error", err)
+		q.mu.RLock()
+		w.done <- q.db.Update(w.fn)
+		q.mu.RUnlock()
+		batch = append(batch[:failed:failed], batch[failed+1:]...)
+	}
+}
@@ -0,0 +1,116 @@
+package lasr
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+func TestConcurrentSends(t *testing.T) {
+	q, cleanup := newQ(t, WithMaxDepth(50))
+	defer cleanup()
+
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		ids  = make(map[Uint64ID]bool)
+		full int
+	)
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			id, err := q.Send([]byte("foo"))
+			mu.Lock()
+			defer mu.Unlock()
+			if err == ErrQueueFull {
+				full++
+				return
+			} else if err != nil {
+				t.Error(err)
+				return
+			}
+			if ids[id.(Uint64ID)] {
+				t.Errorf("duplicate id: %d", id)
+			}
+			ids[id.(Uint64ID)] = true
+		}()
+	}
+	wg.Wait()
+	if got, want := len(ids), 50; got != want {
+		t.Errorf("bad send count: got %d, want %d", got, want)
+	}
+	if got, want := full, 50; got != want {
+		t.Errorf("bad full count: got %d, want %d", got, want)
+	}
+	stats, err := q.Stats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := stats.Ready, 50; got != want {
+		t.Errorf("bad ready count: got %d, want %d", got, want)
+	}
+}
+
+func TestCommitFailureIsolated(t *testing.T) {
+	q, cleanup := newQ(t)
+	defer cleanup()
+
+	// Hold a transaction open, so that the writes below are batched.
+	started := make(chan struct{})
+	release := make(chan struct{})
+	go q.commit(func(tx *bolt.Tx) error {
+		close(started)
+		<-release
+		return nil
+	})
+	<-started
+
+	errFail := errors.New("fail")
+	results := make(chan error, 3)
+	for _, fail := range []bool{false, true, false} {
+		fail := fail
+		go func() {
+			results <- q.commit(func(tx *bolt.Tx) error {
+				if fail {
+					return errFail
+				}
+				_, err := q.nextSequence(tx)
+				return err
+			})
+		}()
+	}
+	for {
+		q.writes.mu.Lock()
+		n := len(q.writes.pending)
+		q.writes.mu.Unlock()
+		if n == 3 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	close(release)
+	var failed int
+	for i := 0; i < 3; i++ {
+		if err := <-results; err == errFail {
+			failed++
+		} else if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if failed != 1 {
+		t.Errorf("bad failure count: got %d, want 1", failed)
+	}
+	err := q.db.View(func(tx *bolt.Tx) error {
+		if got, want := tx.Bucket(q.seqName).Sequence(), uint64(2); got != want {
+			t.Errorf("bad sequence: got %d, want %d", got, want)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
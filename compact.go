@@ -9,26 +9,50 @@ import (
 )
 
 // Compact performs compaction on the queue. All messages will be copied from
-// the underlying database into another.
+// the underlying database into another, which then replaces it.
 //
-// The queue is unavailable while compaction is occurring.
+// The queue is unavailable while compaction is occurring. To compact without
+// interrupting the queue, use CompactTo and swap the files while the queue
+// is not in use.
 //
-// Compaction causes the underlying bolt database to be replaced, so callers
-// should be aware that any other queues relying on the database may be
-// invalidated.
+// The compacted database keeps the file mode and bolt settings of the
+// original, such as the freelist type and sync behaviour.
+//
+// Compaction causes the underlying bolt database to be replaced. Other queues
+// sharing the database are unavailable during compaction as well, and are
+// switched over to the compacted database along with q. The *bolt.DB that was
+// passed to NewQ is closed, and must not be used by callers afterwards.
 func (q *Q) Compact() (rerr error) {
-	tempPath := filepath.Join(filepath.Dir(q.db.Path()), ".lasr.temp.db")
-	newDB, err := bolt.Open(tempPath, 0600, nil)
-	if err != nil {
-		return fmt.Errorf("error compacting queue: %s", err)
+	if q.readOnly {
+		return ErrReadOnly
 	}
+	q.shared.compacting.Lock()
+	defer q.shared.compacting.Unlock()
+	others := q.shared.others(q)
 	q.mu.Lock()
 	defer q.mu.Unlock()
+	for _, other := range others {
+		other.mu.Lock()
+		defer other.mu.Unlock()
+	}
+	dbPath := q.db.Path()
+	fi, err := os.Stat(dbPath)
+	if err != nil {
+		return fmt.Errorf("error compacting queue: %s", err)
+	}
+	mode := fi.Mode().Perm()
+	size := fi.Size()
+	opts := q.reopenOptions()
+	tempPath := filepath.Join(filepath.Dir(dbPath), ".lasr.temp.db")
+	newDB, err := bolt.Open(tempPath, mode, opts)
+	if err != nil {
+		return fmt.Errorf("error compacting queue: %s", err)
+	}
 	if err := compact(newDB, q.db); err != nil {
 		return fmt.Errorf("error compacting queue: %s", err)
 	}
-	dbPath := q.db.Path()
-	if err := q.db.Close(); err != nil {
+	oldDB := q.db
+	if err := oldDB.Close(); err != nil {
 		return fmt.Errorf("error compacting queue: %s", err)
 	}
 	if err := newDB.Close(); err != nil {
@@ -37,15 +61,72 @@ func (q *Q) Compact() (rerr error) {
 	if err := os.Rename(tempPath, dbPath); err != nil {
 		return fmt.Errorf("error compacting queue: %s", err)
 	}
-	q.db, err = bolt.Open(dbPath, 0644, nil)
+	q.db, err = bolt.Open(dbPath, mode, opts)
 	if err != nil {
 		return fmt.Errorf("error compacting queue: %s", err)
 	}
+	q.db.MaxBatchSize = oldDB.MaxBatchSize
+	q.db.MaxBatchDelay = oldDB.MaxBatchDelay
+	q.db.AllocSize = oldDB.AllocSize
+	for _, other := range others {
+		other.db = q.db
+	}
+	q.shared.replace(q.db)
+	if fi, err := os.Stat(dbPath); err == nil {
+		q.logger().Debug("lasr: compacted database", "path", dbPath, "before", size, "after", fi.Size())
+	}
 	return nil
 }
 
+// CompactTo writes a compacted copy of the database underlying q to a new
+// file at path, which must not already exist. Unlike Compact, the database is
+// not replaced, and q remains available while the copy is written: the copy is
+// a consistent snapshot of the database as of the start of CompactTo.
+//
+// The copy includes every queue in the database, and can be opened with
+// bolt.Open and NewQ in place of the original.
+func (q *Q) CompactTo(path string) error {
+	if _, err := os.Stat(path); err == nil {
+		return fmt.Errorf("error compacting queue: %s already exists", path)
+	} else if !os.IsNotExist(err) {
+		return fmt.Errorf("error compacting queue: %s", err)
+	}
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	fi, err := os.Stat(q.db.Path())
+	if err != nil {
+		return fmt.Errorf("error compacting queue: %s", err)
+	}
+	dst, err := bolt.Open(path, fi.Mode().Perm(), dbOptions(q.db))
+	if err != nil {
+		return fmt.Errorf("error compacting queue: %s", err)
+	}
+	if err := compact(dst, q.db); err != nil {
+		dst.Close()
+		os.Remove(path)
+		return fmt.Errorf("error compacting queue: %s", err)
+	}
+	if err := dst.Close(); err != nil {
+		return fmt.Errorf("error compacting queue: %s", err)
+	}
+	q.logger().Debug("lasr: compacted database", "path", q.db.Path(), "to", path)
+	return nil
+}
+
+// dbOptions returns the options that db was opened with, so that compaction
+// does not silently reset them to bolt's defaults.
+func dbOptions(db *bolt.DB) *bolt.Options {
+	return &bolt.Options{
+		NoGrowSync:     db.NoGrowSync,
+		NoFreelistSync: db.NoFreelistSync,
+		FreelistType:   db.FreelistType,
+		NoSync:         db.NoSync,
+		MmapFlags:      db.MmapFlags,
+	}
+}
+
 // walk walks recursively the bolt database db, calling walkFn for each key it finds.
-// copied from https://github.com/boltdb/bolt/blob/master/cmd/bolt/main.go#L1693
+// copied from https://github.com/etcd-io/bbolt/blob/master/cmd/bbolt/main.go
 func walk(db *bolt.DB, walkFn walkFunc) error {
 	return db.View(func(tx *bolt.Tx) error {
 		return tx.ForEach(func(name []byte, b *bolt.Bucket) error {
@@ -54,7 +135,7 @@ func walk(db *bolt.DB, walkFn walkFunc) error {
 	})
 }
 
-// copied from https://github.com/boltdb/bolt/blob/master/cmd/bolt/main.go#L1702
+// copied from https://github.com/etcd-io/bbolt/blob/master/cmd/bbolt/main.go
 func walkBucket(b *bolt.Bucket, keypath [][]byte, k, v []byte, seq uint64, fn walkFunc) error {
 	// Execute callback.
 	if err := fn(keypath, k, v, seq); err != nil {
@@ -83,7 +164,7 @@ func compact(dst, src *bolt.DB) error {
 	// 1 MB per transaction
 	var txMaxSize int64 = 1048576
 
-	// copied with minor modifications from boltdb repo (https://github.com/boltdb/bolt/blob/master/cmd/bolt/main.go#L1620)
+	// copied with minor modifications from bbolt repo (https://github.com/etcd-io/bbolt/blob/master/cmd/bbolt/main.go)
 	// commit regularly, or we'll run out of memory for large datasets if using one transaction.
 	var size int64
 	tx, err := dst.Begin(true)
@@ -3,7 +3,12 @@ package lasr
 import (
 	"context"
 	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"testing"
+
+	bolt "go.etcd.io/bbolt"
 )
 
 func TestCompactDB(t *testing.T) {
@@ -43,3 +48,119 @@ func TestCompactDB(t *testing.T) {
 		}
 	}
 }
+
+func TestCompactKeepsOptions(t *testing.T) {
+	td, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(td)
+	fp := filepath.Join(td, "lasr.db")
+	db, err := bolt.Open(fp, 0600, &bolt.Options{
+		FreelistType:   bolt.FreelistMapType,
+		NoFreelistSync: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	q, err := NewQ(db, "testing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := q.Send([]byte("foo")); err != nil {
+		t.Fatal(err)
+	}
+	if err := q.Compact(); err != nil {
+		t.Fatal(err)
+	}
+	defer q.db.Close()
+	if got, want := q.db.FreelistType, bolt.FreelistMapType; got != want {
+		t.Errorf("bad freelist type: got %q, want %q", got, want)
+	}
+	if !q.db.NoFreelistSync {
+		t.Error("NoFreelistSync not preserved")
+	}
+	fi, err := os.Stat(fp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := fi.Mode().Perm(), os.FileMode(0600); got != want {
+		t.Errorf("bad file mode: got %v, want %v", got, want)
+	}
+}
+
+func TestCompactTo(t *testing.T) {
+	q, cleanup := newQ(t)
+	defer cleanup()
+	for i := 0; i < 1000; i++ {
+		if _, err := q.Send([]byte(fmt.Sprintf("%d", i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := 0; i < 990; i++ {
+		m, err := q.Receive(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := m.Ack(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	td, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(td)
+	dst := filepath.Join(td, "compacted.db")
+	if err := q.CompactTo(dst); err != nil {
+		t.Fatal(err)
+	}
+	if err := q.CompactTo(dst); err == nil {
+		t.Error("expected error compacting to an existing file")
+	}
+
+	// q is untouched, and still usable
+	if _, err := q.Send([]byte("after")); err != nil {
+		t.Fatal(err)
+	}
+	srcInfo, err := os.Stat(q.db.Path())
+	if err != nil {
+		t.Fatal(err)
+	}
+	dstInfo, err := os.Stat(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dstInfo.Size() >= srcInfo.Size() {
+		t.Errorf("compacted file not smaller: %d >= %d", dstInfo.Size(), srcInfo.Size())
+	}
+
+	db, err := bolt.Open(dst, 0600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	copied, err := NewQ(db, "testing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer copied.Close()
+	stats, err := copied.Stats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := stats.Ready, 10; got != want {
+		t.Errorf("bad ready count in copy: got %d, want %d", got, want)
+	}
+	m, err := copied.Receive(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(m.Body), "990"; got != want {
+		t.Errorf("bad body: got %q, want %q", got, want)
+	}
+	if err := m.Ack(); err != nil {
+		t.Fatal(err)
+	}
+}
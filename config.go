@@ -0,0 +1,173 @@
+package lasr
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// configKey holds the configuration of a queue, in its root bucket.
+var configKey = []byte("config")
+
+// config is the part of the options of a queue that decides how its messages
+// are stored and delivered. It is recorded when the queue is created, and a Q
+// must be opened with the same configuration, see ErrConfigMismatch. A codec
+// is only recorded once WithCodec is given, so that one can be added later.
+type config struct {
+	DeadLetters     bool   `json:"deadLetters,omitempty"`
+	DeadLetterQueue string `json:"deadLetterQueue,omitempty"`
+	Encrypted       bool   `json:"encrypted,omitempty"`
+	Codec           string `json:"codec,omitempty"`
+	LIFO            bool   `json:"lifo,omitempty"`
+	GroupHeader     string `json:"groupHeader,omitempty"`
+	Partitions      int    `json:"partitions,omitempty"`
+
+	DeadLetterTTL time.Duration `json:"deadLetterTTL,omitempty"`
+	RetryPolicy   string        `json:"retryPolicy,omitempty"`
+}
+
+// WithReconfigure makes NewQ record the options of q as the configuration of
+// the queue, instead of failing with ErrConfigMismatch when they differ from
+// the ones it was created with. Messages that are already stored are left as
+// they are, so they may be unreadable, for example after the encryption key is
+// removed.
+func WithReconfigure() Option {
+	return func(q *Q) error {
+		if q.optsApplied {
+			return ErrOptionsApplied
+		}
+		q.reconfigure = true
+		return nil
+	}
+}
+
+// WithStoredConfig makes NewQ adopt the dead-letter, LIFO, message group and
+// partition settings, and the JSON or Gob codec, of the configuration the
+// queue was created with, for tools that open queues they don't know the
+// options of. The encryption key, the retry policy and other codecs still have
+// to be given.
+func WithStoredConfig() Option {
+	return func(q *Q) error {
+		if q.optsApplied {
+			return ErrOptionsApplied
+		}
+		q.storedConfig = true
+		return nil
+	}
+}
+
+// adopt applies the settings of c to q, see WithStoredConfig.
+func (q *Q) adopt(c config) {
+	if c.DeadLetters {
+		q.keys.returned = []byte("deadletters")
+	} else {
+		q.keys.returned = nil
+	}
+	q.deadLetterQueue = nil
+	if c.DeadLetterQueue != "" {
+		q.deadLetterQueue = []byte(c.DeadLetterQueue)
+	}
+	q.deadLetterTTL = c.DeadLetterTTL
+	q.lifo = c.LIFO
+	q.partitions = c.Partitions
+	q.groupHeader = c.GroupHeader
+	q.keys.groups = nil
+	if c.GroupHeader != "" {
+		q.keys.groups = []byte("groups")
+	}
+	for _, codec := range []Codec{JSON, Gob} {
+		if codec.ContentType() == c.Codec {
+			q.codec = codec
+		}
+	}
+}
+
+// config returns the configuration that q was opened with.
+func (q *Q) config() config {
+	c := config{
+		DeadLetters:     len(q.keys.returned) > 0,
+		DeadLetterQueue: string(q.deadLetterQueue),
+		Encrypted:       q.aead != nil,
+		LIFO:            q.lifo,
+		GroupHeader:     q.groupHeader,
+		Partitions:      q.partitions,
+		DeadLetterTTL:   q.deadLetterTTL,
+		RetryPolicy:     describeRetryPolicy(q.retryPolicy),
+	}
+	if q.codec != nil {
+		c.Codec = q.codec.ContentType()
+	}
+	return c
+}
+
+// describeRetryPolicy returns how policy is recorded in the configuration of a
+// queue. The backoffs of lasr are recorded with their delays, other policies
+// only by their type.
+func describeRetryPolicy(policy RetryPolicy) string {
+	switch p := policy.(type) {
+	case nil:
+		return ""
+	case FixedBackoff:
+		return fmt.Sprintf("%T(%s)", p, time.Duration(p))
+	case ExponentialBackoff:
+		return fmt.Sprintf("%T{%s, %s}", p, p.Initial, p.Max)
+	}
+	return fmt.Sprintf("%T", policy)
+}
+
+// reconcileConfig checks the configuration of q against the one that is
+// recorded for the queue, and records it if there is none yet, or if q uses
+// WithReconfigure. It runs before q is started, so that adopting the stored
+// configuration doesn't race with anything.
+func (q *Q) reconcileConfig() error {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	var want config
+	check := func(tx *bolt.Tx) (bool, error) {
+		want = q.config()
+		root := tx.Bucket(q.name)
+		if root == nil || root.Get(configKey) == nil {
+			return false, nil
+		}
+		var got config
+		if err := json.Unmarshal(root.Get(configKey), &got); err != nil {
+			return false, fmt.Errorf("lasr: bad queue configuration: %s", err)
+		}
+		if q.storedConfig {
+			q.adopt(got)
+			want = q.config()
+		}
+		stored := got
+		if got.Codec == "" {
+			// A codec may be added, and is recorded from now on.
+			got.Codec = want.Codec
+		}
+		if got != want && !q.reconfigure {
+			q.logger().Error("lasr: options don't match the queue", "created", stored, "opened", want)
+			return false, ErrConfigMismatch
+		}
+		return stored == want, nil
+	}
+	if q.readOnly {
+		return q.db.View(func(tx *bolt.Tx) error {
+			_, err := check(tx)
+			return err
+		})
+	}
+	return q.db.Update(func(tx *bolt.Tx) error {
+		if same, err := check(tx); err != nil || same {
+			return err
+		}
+		root, err := tx.CreateBucketIfNotExists(q.name)
+		if err != nil {
+			return err
+		}
+		v, err := json.Marshal(want)
+		if err != nil {
+			return err
+		}
+		return root.Put(configKey, v)
+	})
+}
@@ -0,0 +1,124 @@
+package lasr
+
+import (
+	"testing"
+	"time"
+)
+
+func TestConfigMismatch(t *testing.T) {
+	q, cleanup := newQ(t, WithDeadLetters(), WithLIFO())
+	defer cleanup()
+	db := q.db
+	if err := q.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	mismatched := [][]Option{
+		{WithLIFO()},
+		{WithDeadLetters()},
+		{WithDeadLetters(), WithLIFO(), WithEncryption(testKey)},
+		{WithDeadLetters(), WithLIFO(), WithMessageGroups("group")},
+		{WithDeadLetters(), WithLIFO(), WithDeadLetterTTL(time.Hour)},
+		{WithDeadLetters(), WithLIFO(), WithRetryPolicy(FixedBackoff(time.Second))},
+	}
+	for i, options := range mismatched {
+		if _, err := NewQ(db, "testing", options...); err != ErrConfigMismatch {
+			t.Errorf("%d: got %v, want ErrConfigMismatch", i, err)
+		}
+	}
+
+	// A codec can be added, and then has to be given.
+	q, err := NewQ(db, "testing", WithDeadLetters(), WithLIFO(), WithCodec(Gob))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := q.Close(); err != nil {
+		t.Fatal(err)
+	}
+	for i, options := range [][]Option{
+		{WithDeadLetters(), WithLIFO()},
+		{WithDeadLetters(), WithLIFO(), WithCodec(JSON)},
+	} {
+		if _, err := NewQ(db, "testing", options...); err != ErrConfigMismatch {
+			t.Errorf("%d: got %v after adding a codec, want ErrConfigMismatch", i, err)
+		}
+	}
+
+	q, err = NewQ(db, "testing", WithReconfigure())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := q.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewQ(db, "testing", WithDeadLetters()); err != ErrConfigMismatch {
+		t.Errorf("got %v after reconfiguring, want ErrConfigMismatch", err)
+	}
+	q, err = NewQ(db, "testing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := q.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestStoredConfig(t *testing.T) {
+	q, cleanup := newQ(t, WithDeadLetters(), WithDeadLetterTTL(time.Hour), WithMessageGroups("group"), WithCodec(Gob))
+	defer cleanup()
+	db := q.db
+	if err := q.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	q, err := NewQ(db, "testing", WithStoredConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := q.config(); got != (config{DeadLetters: true, Codec: Gob.ContentType(), GroupHeader: "group", DeadLetterTTL: time.Hour}) {
+		t.Errorf("got %+v", got)
+	}
+	if _, err := DeadLetters(q); err != nil {
+		t.Error(err)
+	}
+	if err := q.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The key isn't stored.
+	q, err = NewQ(db, "encrypted", WithEncryption(testKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := q.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewQ(db, "encrypted", WithStoredConfig()); err != ErrConfigMismatch {
+		t.Errorf("got %v, want ErrConfigMismatch", err)
+	}
+}
+
+func TestConfigRetryPolicy(t *testing.T) {
+	policy := ExponentialBackoff{Initial: time.Second, Max: time.Minute}
+	q, cleanup := newQ(t, WithRetryPolicy(policy))
+	defer cleanup()
+	db := q.db
+	if err := q.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	q, err := NewQ(db, "testing", WithRetryPolicy(policy))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := q.Close(); err != nil {
+		t.Fatal(err)
+	}
+	policy.Max = time.Hour
+	if _, err := NewQ(db, "testing", WithRetryPolicy(policy)); err != ErrConfigMismatch {
+		t.Errorf("got %v for another backoff, want ErrConfigMismatch", err)
+	}
+	if _, err := NewQ(db, "testing", WithRetryPolicy(FixedBackoff(time.Second))); err != ErrConfigMismatch {
+		t.Errorf("got %v for another policy, want ErrConfigMismatch", err)
+	}
+}
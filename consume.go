@@ -0,0 +1,142 @@
+package lasr
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// HandlerPolicy decides what Consume does with a message whose handler
+// returned an error or panicked.
+type HandlerPolicy int
+
+const (
+	// HandlerRetry nacks the message for retry, so that it waits for the
+	// backoff of the retry policy of its Q, if it has one, see
+	// WithRetryPolicy. It is the default.
+	HandlerRetry HandlerPolicy = iota
+
+	// HandlerDeadLetter nacks the message with NackError, so that it is
+	// dead-lettered along with the error. It requires WithDeadLetters.
+	HandlerDeadLetter
+
+	// HandlerStop nacks the message for retry, and stops Consume, which
+	// returns the error, once the other handlers have returned.
+	HandlerStop
+)
+
+// WithHandlerPolicy sets what Consume does with the messages whose handlers
+// return an error, onError, and with the ones whose handlers panic, onPanic.
+// By default, both are HandlerRetry.
+func WithHandlerPolicy(onError, onPanic HandlerPolicy) Option {
+	return func(q *Q) error {
+		if q.optsApplied {
+			return ErrOptionsApplied
+		}
+		for _, p := range []HandlerPolicy{onError, onPanic} {
+			if p < HandlerRetry || p > HandlerStop {
+				return fmt.Errorf("lasr: invalid handler policy: %d", p)
+			}
+		}
+		q.onHandlerError, q.onHandlerPanic = onError, onPanic
+		return nil
+	}
+}
+
+// HandlerError is returned by Consume when a handler failed, and the handler
+// policy of the Q is HandlerStop, see WithHandlerPolicy.
+type HandlerError struct {
+	// ID is the ID of the message that the handler failed on.
+	ID []byte
+
+	// Err is the error that the handler returned, or that describes its
+	// panic.
+	Err error
+
+	// Panicked reports whether the handler panicked.
+	Panicked bool
+}
+
+func (e *HandlerError) Error() string {
+	return fmt.Sprintf("lasr: handler failed on %x: %s", e.ID, e.Err)
+}
+
+// Consume receives messages from q with concurrency workers, and passes each
+// one to handler. A message is acked when handler returns nil. When handler
+// returns an error or panics, the message is nacked according to the handler
+// policy of q, see WithHandlerPolicy. Panics are recovered, and logged along
+// with errors, see WithLogger. Handlers may ack or nack messages themselves.
+//
+// Consume returns once ctx is done or q is closed, and every handler that was
+// running has returned, with ctx.Err() or ErrQClosed, or with a *HandlerError
+// if a handler stopped it.
+func (q *Q) Consume(ctx context.Context, concurrency int, handler func(*Message) error) error {
+	if concurrency < 1 {
+		return fmt.Errorf("lasr: invalid concurrency: %d", concurrency)
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var wg sync.WaitGroup
+	var once sync.Once
+	var stopped error
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := q.consume(ctx, handler)
+			once.Do(func() {
+				stopped = err
+				cancel()
+			})
+		}()
+	}
+	wg.Wait()
+	return stopped
+}
+
+// consume is a worker of Consume.
+func (q *Q) consume(ctx context.Context, handler func(*Message) error) error {
+	for {
+		msg, err := q.Receive(ctx)
+		if err != nil {
+			return err
+		}
+		err, panicked := handle(msg, handler)
+		if err == nil {
+			q.finishHandled(msg, msg.Ack())
+			continue
+		}
+		q.logger().Warn("lasr: handler failed", "id", hexID(msg.ID), "attempts", msg.Attempts, "error", err)
+		policy := q.onHandlerError
+		if panicked {
+			policy = q.onHandlerPanic
+		}
+		if policy == HandlerDeadLetter {
+			q.finishHandled(msg, msg.NackError(err))
+		} else {
+			q.finishHandled(msg, msg.Nack(true))
+		}
+		if policy == HandlerStop {
+			return &HandlerError{ID: msg.ID, Err: err, Panicked: panicked}
+		}
+	}
+}
+
+// handle calls handler with msg, and returns the panic it raises as an
+// error.
+func handle(msg *Message, handler func(*Message) error) (err error, panicked bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			err, panicked = fmt.Errorf("panic: %v", r), true
+		}
+	}()
+	return handler(msg), false
+}
+
+// finishHandled logs the error of acking or nacking msg after its handler
+// returned, unless the handler already acked or nacked it.
+func (q *Q) finishHandled(msg *Message, err error) {
+	if err != nil && err != ErrAckNack {
+		q.logger().Warn("lasr: couldn't finish handled message", "id", hexID(msg.ID), "error", err)
+	}
+}
@@ -0,0 +1,129 @@
+package lasr
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestConsume(t *testing.T) {
+	q, cleanup := newQ(t)
+	defer cleanup()
+	sendBodies(t, q, "ok", "error", "panic", "acked", "ok")
+
+	var mu sync.Mutex
+	handled := make(map[string]int)
+	var active, maxActive int32
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan error)
+	go func() {
+		done <- q.Consume(ctx, 3, func(msg *Message) error {
+			n := atomic.AddInt32(&active, 1)
+			defer atomic.AddInt32(&active, -1)
+			for {
+				max := atomic.LoadInt32(&maxActive)
+				if n <= max || atomic.CompareAndSwapInt32(&maxActive, max, n) {
+					break
+				}
+			}
+			time.Sleep(time.Millisecond)
+			body := string(msg.Body)
+			mu.Lock()
+			handled[body]++
+			first := handled[body] == 1
+			mu.Unlock()
+			switch {
+			case body == "error" && first:
+				return errors.New("failed")
+			case body == "panic" && first:
+				panic("oops")
+			case body == "acked":
+				return msg.Ack()
+			}
+			return nil
+		})
+	}()
+
+	for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(5 * time.Millisecond) {
+		stats, err := q.Stats()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stats.Acked == 5 {
+			if stats.Nacked != 2 {
+				t.Errorf("got %d nacks, want 2", stats.Nacked)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("messages weren't handled: %+v", stats)
+		}
+	}
+	cancel()
+	if err := <-done; err != context.Canceled {
+		t.Errorf("got %v, want context.Canceled", err)
+	}
+	if handled["error"] != 2 || handled["panic"] != 2 || handled["ok"] != 2 || handled["acked"] != 1 {
+		t.Errorf("bad handling: %v", handled)
+	}
+	if max := atomic.LoadInt32(&maxActive); max > 3 {
+		t.Errorf("got %d concurrent handlers, want at most 3", max)
+	}
+
+	if err := q.Consume(context.Background(), 0, nil); err == nil {
+		t.Error("expected an error for zero concurrency")
+	}
+}
+
+func TestConsumeHandlerPolicy(t *testing.T) {
+	q, cleanup := newQ(t, WithDeadLetters(), WithHandlerPolicy(HandlerDeadLetter, HandlerStop))
+	defer cleanup()
+	sendBodies(t, q, "error", "panic")
+
+	var handled int32
+	err := q.Consume(context.Background(), 1, func(msg *Message) error {
+		atomic.AddInt32(&handled, 1)
+		if string(msg.Body) == "panic" {
+			panic("oops")
+		}
+		return errors.New("failed")
+	})
+	herr, ok := err.(*HandlerError)
+	if !ok {
+		t.Fatalf("got %v, want a *HandlerError", err)
+	}
+	if !herr.Panicked || herr.Err.Error() != "panic: oops" {
+		t.Errorf("bad handler error: %+v", herr)
+	}
+	if got := atomic.LoadInt32(&handled); got != 2 {
+		t.Errorf("got %d handled messages, want 2", got)
+	}
+	stats, err := q.Stats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Returned != 1 || stats.Ready != 1 {
+		t.Errorf("got %d dead letters and %d ready messages, want 1 and 1", stats.Returned, stats.Ready)
+	}
+	msg, err := q.TryReceive()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(msg.Body); got != "panic" {
+		t.Errorf("got %q, want %q", got, "panic")
+	}
+	if err := msg.Ack(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := NewQ(q.db, "other", WithHandlerPolicy(HandlerDeadLetter, HandlerRetry)); err == nil {
+		t.Error("expected an error for HandlerDeadLetter without dead letters")
+	}
+	if _, err := NewQ(q.db, "other", WithHandlerPolicy(HandlerStop+1, HandlerRetry)); err == nil {
+		t.Error("expected an error for an invalid policy")
+	}
+}
@@ -1,12 +1,40 @@
 package lasr
 
-import "errors"
+import (
+	"errors"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// The reasons that messages are dead-lettered for, see Message.DeadLetterReason.
+const (
+	// DeadLetterNacked is the reason of messages that were nacked without
+	// retry.
+	DeadLetterNacked = "nacked"
+
+	// DeadLetterExpired is the reason of messages whose deadline passed,
+	// see SendWithDeadline.
+	DeadLetterExpired = "expired"
+
+	// DeadLetterCorrupt is the reason of messages that didn't match their
+	// checksums, see WithCorruptDeadLetters.
+	DeadLetterCorrupt = "corrupt"
+
+	// DeadLetterRecovered is the reason of messages that were unacked when
+	// their Q was last closed, see RecoverDeadLetter.
+	DeadLetterRecovered = "recovered"
+)
 
 // If dead-lettering is enabled on q, DeadLetters will return a dead-letter
 // queue that is named the same as q, but will emit dead-letters on Receive.
 // The dead-letter queue itself does not support dead-lettering; nacked
 // messages that are not retried will be deleted.
 //
+// The messages received from the dead-letter queue say why and when they were
+// dead-lettered, in DeadLetterReason and DeadLetteredAt, and the error they
+// were nacked with in DeadLetterError.
+//
 // If dead-lettering is not enabled on q, an error will be returned.
 func DeadLetters(q *Q) (*Q, error) {
 	if len(q.keys.returned) == 0 {
@@ -14,18 +42,129 @@ func DeadLetters(q *Q) (*Q, error) {
 	}
 	closed := make(chan struct{})
 	d := &Q{
-		db:   q.db,
-		name: q.name,
-		seq:  q.seq,
+		name:    q.name,
+		seqName: q.seqName,
+		seq:     q.seq,
+		aead:    q.aead,
+		keyring: q.keyring,
+		keyID:   q.keyID,
 		keys: bucketKeys{
-			ready:   q.keys.returned,
-			unacked: []byte("deadletters-unacked"),
+			ready:    q.keys.returned,
+			unacked:  []byte("deadletters-unacked"),
+			counters: []byte("deadletters-counters"),
+			meta:     q.keys.meta,
 		},
 		waker:  newWaker(closed),
 		closed: closed,
+		leases: make(map[*Message]time.Time),
+		repl:   q.repl,
+		blobs:  q.blobs,
+
+		auditing:   q.auditing,
+		auditActor: q.auditActor,
 	}
+	q.mu.RLock()
+	d.db = q.db
+	d.shared = register(d, q.db)
+	q.mu.RUnlock()
 	if err := d.init(); err != nil {
+		d.shared.unregister(d)
 		return nil, err
 	}
 	return d, nil
 }
+
+// stampDeadLetter records that the message id was dead-lettered at now, for
+// reason, unless reason is empty.
+func (q *Q) stampDeadLetter(tx *bolt.Tx, id []byte, now time.Time, reason string) error {
+	md, err := q.getMeta(tx, id)
+	if err != nil {
+		return err
+	}
+	if md == nil {
+		md = &metadata{}
+	}
+	md.DeadLettered = now.UnixNano()
+	if reason != "" {
+		md.Reason = reason
+	}
+	return q.putMeta(tx, id, md)
+}
+
+// isDeadLetters reports whether q is the dead-letter queue of another Q, see
+// DeadLetters.
+func (q *Q) isDeadLetters() bool {
+	// Dead-letter queues do not track waiting messages.
+	return len(q.keys.blocking) == 0
+}
+
+// RedriveDeadLetters moves up to n dead-lettered messages back into the Ready
+// state, oldest first, and returns the number of messages moved. If n is less
+// than 1, every dead letter is moved. The messages keep their original IDs,
+// and so their original position in the queue.
+//
+// Dead letters that are currently being received from the dead-letter queue
+// are not moved.
+func (q *Q) RedriveDeadLetters(n int) (int, error) {
+	if len(q.keys.returned) == 0 {
+		return 0, errors.New("lasr: dead-letters not available")
+	}
+	if q.isClosed() {
+		return 0, ErrQClosed
+	}
+	if q.readOnly {
+		return 0, ErrReadOnly
+	}
+	var moved int
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	err := q.db.Update(func(tx *bolt.Tx) error {
+		returned, err := q.bucket(tx, q.keys.returned)
+		if err != nil {
+			return err
+		}
+		ready, err := q.bucket(tx, q.keys.ready)
+		if err != nil {
+			return err
+		}
+		var keys [][]byte
+		cur := returned.Cursor()
+		for k, _ := cur.First(); k != nil && (n < 1 || len(keys) < n); k, _ = cur.Next() {
+			keys = append(keys, k)
+		}
+		for _, k := range keys {
+			if err := ready.Put(k, returned.Get(k)); err != nil {
+				return err
+			}
+			if err := returned.Delete(k); err != nil {
+				return err
+			}
+			q.touch(tx, k)
+			if err := q.auditID(tx, AuditRedrive, k); err != nil {
+				return err
+			}
+			md, err := q.getMeta(tx, k)
+			if err != nil {
+				return err
+			}
+			if md != nil {
+				md.Reason, md.DeadLettered, md.Error = "", 0, ""
+				if err := q.putMeta(tx, k, md); err != nil {
+					return err
+				}
+				if err := q.index(tx, k, md.Headers); err != nil {
+					return err
+				}
+			}
+		}
+		moved = len(keys)
+		return q.adjustDepth(tx, moved)
+	})
+	if err != nil {
+		return 0, err
+	}
+	if moved > 0 && !q.isClosed() {
+		q.waker.Wake()
+	}
+	return moved, nil
+}
@@ -5,6 +5,8 @@ import (
 	"context"
 	"testing"
 	"time"
+
+	bolt "go.etcd.io/bbolt"
 )
 
 func TestDeadLetters(t *testing.T) {
@@ -50,3 +52,164 @@ func TestDeadLetters(t *testing.T) {
 		t.Errorf("bad id: got %v, want %v", got, want)
 	}
 }
+
+func TestRedriveDeadLetters(t *testing.T) {
+	q, cleanup := newQ(t, WithDeadLetters())
+	defer cleanup()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	for _, body := range []string{"a", "b", "c"} {
+		if _, err := q.SendWithHeaders([]byte(body), map[string][]byte{"body": []byte(body)}); err != nil {
+			t.Fatal(err)
+		}
+		m, err := q.Receive(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := m.Nack(false); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	n, err := q.RedriveDeadLetters(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Errorf("bad redrive count: got %d, want 2", n)
+	}
+	for _, want := range []string{"a", "b"} {
+		m, err := q.Receive(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := string(m.Body); got != want {
+			t.Errorf("bad body: got %q, want %q", got, want)
+		}
+		if got := string(m.Headers["body"]); got != want {
+			t.Errorf("bad header: got %q, want %q", got, want)
+		}
+		if err := m.Ack(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	n, err = q.RedriveDeadLetters(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Errorf("bad redrive count: got %d, want 1", n)
+	}
+	stats, err := q.Stats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := stats.Returned, 0; got != want {
+		t.Errorf("bad dead-letter count: got %d, want %d", got, want)
+	}
+	if got, want := stats.Ready, 1; got != want {
+		t.Errorf("bad ready count: got %d, want %d", got, want)
+	}
+}
+
+func TestRedriveDeadLettersDisabled(t *testing.T) {
+	q, cleanup := newQ(t)
+	defer cleanup()
+
+	if _, err := q.RedriveDeadLetters(1); err == nil {
+		t.Error("expected error")
+	}
+}
+
+func TestDeadLetterReasons(t *testing.T) {
+	q, cleanup := newQ(t, WithDeadLetters(), WithChecksums(), WithCorruptDeadLetters())
+	defer cleanup()
+
+	start := time.Now()
+	sendBodies(t, q, "recovered")
+	crash(t, q, 1)
+	q, err := NewQ(q.db, "testing", WithDeadLetters(), WithChecksums(), WithCorruptDeadLetters(),
+		WithRecovery(RecoverDeadLetter))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer q.Close()
+	if _, err := q.SendWithDeadline([]byte("expired"), time.Now().Add(-time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	sendBodies(t, q, "nacked")
+	msg := receiveN(t, q, 1)[0]
+	if err := msg.Nack(false); err != nil {
+		t.Fatal(err)
+	}
+	id, err := q.Send([]byte("corrupt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	corrupt(t, q, id)
+	if _, err := q.ReceiveTimeout(20 * time.Millisecond); err != ErrNoMessages {
+		t.Fatalf("got %v, want ErrNoMessages", err)
+	}
+
+	check := func(reason string, at time.Time) {
+		t.Helper()
+		if at.Before(start) || at.After(time.Now()) {
+			t.Errorf("%s: bad dead-letter time: %s", reason, at)
+		}
+	}
+	err = q.db.View(func(tx *bolt.Tx) error {
+		key, _ := id.MarshalBinary()
+		md, err := q.getMeta(tx, key)
+		if err != nil {
+			return err
+		}
+		if md.Reason != DeadLetterCorrupt {
+			t.Errorf("got reason %q, want %q", md.Reason, DeadLetterCorrupt)
+		}
+		check(md.Reason, time.Unix(0, md.DeadLettered))
+
+		returned := q.readBucket(tx, q.keys.returned)
+		k, v := returned.Cursor().First()
+		rec, err := q.exportRecord(tx, Returned, k, v)
+		if err != nil {
+			return err
+		}
+		if rec.DeadLetterReason != DeadLetterRecovered || rec.DeadLetteredAt == nil {
+			t.Errorf("bad exported dead letter: %+v", rec)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d, err := DeadLetters(q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	want := []string{DeadLetterRecovered, DeadLetterExpired, DeadLetterNacked}
+	for i, msg := range receiveN(t, d, len(want)) {
+		if msg.DeadLetterReason != want[i] {
+			t.Errorf("%d: got reason %q, want %q", i, msg.DeadLetterReason, want[i])
+		}
+		check(msg.DeadLetterReason, msg.DeadLetteredAt)
+		if err := msg.Nack(true); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Redriven messages are no longer dead letters.
+	if _, err := q.RedriveDeadLetters(1); err != nil {
+		t.Fatal(err)
+	}
+	msg = receiveN(t, q, 1)[0]
+	if msg.DeadLetterReason != "" || !msg.DeadLetteredAt.IsZero() {
+		t.Errorf("redriven message is still a dead letter: %q, %s", msg.DeadLetterReason, msg.DeadLetteredAt)
+	}
+	if err := msg.Ack(); err != nil {
+		t.Fatal(err)
+	}
+}
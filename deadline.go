@@ -0,0 +1,99 @@
+package lasr
+
+import (
+	"errors"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var expiredCounter = []byte("expired")
+
+// errExpired is returned by deliver when, instead of delivering the message,
+// it expired it, so that the caller moves on to the next one.
+var errExpired = errors.New("lasr: message expired")
+
+// SendWithDeadline is like Send, but the message is only worth processing
+// until deadline. A message that would be delivered after its deadline, or
+// that is nacked for retry after it, is dead-lettered with the reason
+// DeadLetterExpired instead, or deleted if q does not use WithDeadLetters. Either way,
+// it is counted as Expired in Stats.
+//
+// A zero deadline means that the message does not expire.
+func (q *Q) SendWithDeadline(message []byte, deadline time.Time) (ID, error) {
+	if deadline.IsZero() {
+		return q.Send(message)
+	}
+	if q.isClosed() {
+		return nil, ErrQClosed
+	}
+	var id ID
+	err := q.admit(func(tx *bolt.Tx) (err error) {
+		id, err = q.nextSequence(tx)
+		if err != nil {
+			return err
+		}
+		return q.send(id, message, &metadata{Deadline: deadline.UnixNano()}, tx)
+	})
+	if err == nil {
+		q.waker.Wake()
+		q.wakeSubscriptions()
+		q.onSend(id)
+	}
+	return id, err
+}
+
+// expired reports whether the message with md expired by now. Dead letters
+// don't expire, so that they can still be inspected.
+func (q *Q) expired(md *metadata, now time.Time) bool {
+	if q.isDeadLetters() {
+		return false
+	}
+	return md != nil && md.Deadline != 0 && now.UnixNano() > md.Deadline
+}
+
+// expire moves the message id, stored in bucket, whose deadline passed, to the
+// dead letters, or deletes it. It reports whether any waiting messages became
+// Ready.
+func (q *Q) expire(tx *bolt.Tx, bucket *bolt.Bucket, id []byte) (bool, error) {
+	id = cloneBytes(id)
+	if err := q.incrCounter(tx, expiredCounter); err != nil {
+		return false, err
+	}
+	if err := q.adjustDepth(tx, -1); err != nil {
+		return false, err
+	}
+	q.logger().Debug("lasr: message expired", "id", hexID(id))
+	wake, err := q.stopWaitingOn(tx, id)
+	if err != nil {
+		return false, err
+	}
+	if len(q.keys.returned) > 0 {
+		return wake, q.deadLetter(tx, bucket, id, DeadLetterExpired)
+	}
+	q.touch(tx, id)
+	if err := q.deleteMeta(tx, id); err != nil {
+		return false, err
+	}
+	return wake, bucket.Delete(id)
+}
+
+// expireOnDelivery expires the message id that was about to be delivered, and
+// runs the hooks and wakeups once tx is committed.
+func (q *Q) expireOnDelivery(tx *bolt.Tx, bucket *bolt.Bucket, id []byte) error {
+	wake, err := q.expire(tx, bucket, id)
+	if err != nil {
+		return err
+	}
+	id = cloneBytes(id)
+	tx.OnCommit(func() {
+		q.signalSpace()
+		if len(q.keys.returned) > 0 {
+			q.onDeadLetter(id)
+		}
+		if wake && !q.isClosed() {
+			q.waker.Wake()
+		}
+	})
+	return errExpired
+}
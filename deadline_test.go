@@ -0,0 +1,98 @@
+package lasr
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestSendWithDeadline(t *testing.T) {
+	q, cleanup := newQ(t, WithDeadLetters())
+	defer cleanup()
+
+	if _, err := q.SendWithDeadline([]byte("late"), time.Now().Add(-time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(time.Hour)
+	if _, err := q.SendWithDeadline([]byte("on time"), deadline); err != nil {
+		t.Fatal(err)
+	}
+	msg := receiveN(t, q, 1)[0]
+	if got, want := string(msg.Body), "on time"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if !msg.Deadline.Equal(deadline) {
+		t.Errorf("got deadline %s, want %s", msg.Deadline, deadline)
+	}
+	if err := msg.Ack(); err != nil {
+		t.Fatal(err)
+	}
+	stats, err := q.Stats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Expired != 1 || stats.Returned != 1 || stats.Ready != 0 {
+		t.Errorf("bad stats: %+v", stats)
+	}
+
+	// Dead letters are delivered although they expired.
+	d, err := DeadLetters(q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	if got, want := receiveBodies(t, d, 1)[0], "late"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestDeadlineWithoutDeadLetters(t *testing.T) {
+	q, cleanup := newQ(t)
+	defer cleanup()
+
+	if _, err := q.SendWithDeadline([]byte("late"), time.Now().Add(-time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := q.TryReceive(); err != ErrEmpty {
+		t.Fatalf("got %v, want ErrEmpty", err)
+	}
+	stats, err := q.Stats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Expired != 1 || stats.Ready != 0 {
+		t.Errorf("bad stats: %+v", stats)
+	}
+	if problems, err := q.Verify(); err != nil {
+		t.Fatal(err)
+	} else if len(problems) > 0 {
+		t.Errorf("got problems %v", problems)
+	}
+}
+
+func TestDeadlineOnRetry(t *testing.T) {
+	var deadLettered [][]byte
+	q, cleanup := newQ(t, WithDeadLetters(), WithHooks(Hooks{
+		OnDeadLetter: func(id []byte) { deadLettered = append(deadLettered, id) },
+	}))
+	defer cleanup()
+
+	id, err := q.SendWithDeadline([]byte("slow"), time.Now().Add(20*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg := receiveN(t, q, 1)[0]
+	time.Sleep(30 * time.Millisecond)
+	if err := msg.Nack(true); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := q.Receive(ctx); err != context.DeadlineExceeded {
+		t.Errorf("got %v, want context.DeadlineExceeded", err)
+	}
+	key, _ := id.MarshalBinary()
+	if len(deadLettered) != 1 || string(deadLettered[0]) != string(key) {
+		t.Errorf("got dead letters %x, want %x", deadLettered, key)
+	}
+}
@@ -0,0 +1,194 @@
+package lasr
+
+import (
+	"encoding/binary"
+	"fmt"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// DefaultDedupWindow is how long SendDedup remembers a key, unless the Q was
+// created with WithDedupWindow.
+const DefaultDedupWindow = 10 * time.Minute
+
+// WithDedupWindow sets how long SendDedup remembers a key after the message
+// was first sent. Sending the same key again within the window is a no-op.
+func WithDedupWindow(d time.Duration) Option {
+	return func(q *Q) error {
+		if q.optsApplied {
+			return ErrOptionsApplied
+		}
+		if d <= 0 {
+			return fmt.Errorf("lasr: invalid dedup window: %s", d)
+		}
+		q.dedupWindow = d
+		return nil
+	}
+}
+
+// SendDedup sends a message to q, unless a message with the same key was
+// sent with SendDedup within the dedup window. In that case, the message is
+// not sent again, and SendDedup returns the ID of the original message. With
+// the default sequencer, that ID is a Uint64ID. With WithSequencer, it is an
+// ID that marshals to the same binary form as the original ID, but it may
+// not have the sequencer's type.
+//
+// Producers that retry sends after ambiguous failures can use SendDedup with
+// a key that identifies the message, to avoid creating duplicates.
+//
+// Keys are remembered for the dedup window even if the original message is
+// acked in the meantime. Expired keys are removed when they are next used,
+// and when q is opened or closed.
+func (q *Q) SendDedup(key, body []byte) (ID, error) {
+	return q.sendDedup(key, body, time.Now())
+}
+
+func (q *Q) sendDedup(key, body []byte, now time.Time) (ID, error) {
+	if q.isClosed() {
+		return nil, ErrQClosed
+	}
+	if len(key) == 0 {
+		return nil, fmt.Errorf("lasr: dedup key required")
+	}
+	var (
+		id   ID
+		sent bool
+	)
+	// Check for a duplicate before admitting the message, so that duplicates
+	// are recognized even when q is full.
+	q.mu.RLock()
+	err := q.db.View(func(tx *bolt.Tx) error {
+		bucket := q.readBucket(tx, q.keys.dedup)
+		if bucket == nil {
+			return nil
+		}
+		if v := bucket.Get(key); v != nil {
+			expires, original, err := decodeDedup(v)
+			if err != nil {
+				return err
+			}
+			if now.Before(expires) {
+				id, err = q.originalID(original)
+			}
+			return err
+		}
+		return nil
+	})
+	q.mu.RUnlock()
+	if err != nil || id != nil {
+		return id, err
+	}
+	err = q.admit(func(tx *bolt.Tx) (err error) {
+		// The transaction may be retried.
+		sent = false
+		bucket, err := q.bucket(tx, q.keys.dedup)
+		if err != nil {
+			return err
+		}
+		if v := bucket.Get(key); v != nil {
+			// Another send with the same key may have raced with us.
+			expires, original, err := decodeDedup(v)
+			if err != nil {
+				return err
+			}
+			if now.Before(expires) {
+				id, err = q.originalID(original)
+				return err
+			}
+		}
+		id, err = q.nextSequence(tx)
+		if err != nil {
+			return err
+		}
+		if err := q.send(id, body, &metadata{}, tx); err != nil {
+			return err
+		}
+		v, err := encodeDedup(now.Add(q.dedupTTL()), id)
+		if err != nil {
+			return err
+		}
+		sent = true
+		return bucket.Put(key, v)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if sent {
+		q.waker.Wake()
+		q.wakeSubscriptions()
+		q.onSend(id)
+	}
+	return id, nil
+}
+
+func (q *Q) dedupTTL() time.Duration {
+	if q.dedupWindow > 0 {
+		return q.dedupWindow
+	}
+	return DefaultDedupWindow
+}
+
+// pruneDedup removes the dedup keys that have expired by now.
+func (q *Q) pruneDedup(tx *bolt.Tx, now time.Time) error {
+	bucket := q.readBucket(tx, q.keys.dedup)
+	if bucket == nil {
+		return nil
+	}
+	var expired [][]byte
+	cur := bucket.Cursor()
+	for k, v := cur.First(); k != nil; k, v = cur.Next() {
+		expires, _, err := decodeDedup(v)
+		if err != nil {
+			return err
+		}
+		if !now.Before(expires) {
+			expired = append(expired, k)
+		}
+	}
+	for _, k := range expired {
+		if err := bucket.Delete(k); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// dedup entries are the expiry time, in nanoseconds since the epoch, followed
+// by the binary representation of the original message's ID.
+func encodeDedup(expires time.Time, id ID) ([]byte, error) {
+	idb, err := id.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	v := make([]byte, 8, 8+len(idb))
+	binary.BigEndian.PutUint64(v, uint64(expires.UnixNano()))
+	return append(v, idb...), nil
+}
+
+func decodeDedup(v []byte) (time.Time, []byte, error) {
+	if len(v) < 8 {
+		return time.Time{}, nil, fmt.Errorf("lasr: invalid dedup entry: %x", v)
+	}
+	expires := time.Unix(0, int64(binary.BigEndian.Uint64(v[:8])))
+	return expires, cloneBytes(v[8:]), nil
+}
+
+// originalID converts the binary ID of a dedup entry back to an ID.
+func (q *Q) originalID(b []byte) (ID, error) {
+	if q.seq == nil && len(b) == 8 {
+		var id Uint64ID
+		if err := id.UnmarshalBinary(b); err != nil {
+			return nil, err
+		}
+		return id, nil
+	}
+	return rawID(b), nil
+}
+
+// rawID is an ID that was read back from the database, in its binary form.
+type rawID []byte
+
+func (id rawID) MarshalBinary() ([]byte, error) {
+	return []byte(id), nil
+}
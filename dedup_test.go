@@ -0,0 +1,61 @@
+package lasr
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+)
+
+func TestSendDedup(t *testing.T) {
+	q, cleanup := newQ(t, WithDedupWindow(time.Minute))
+	defer cleanup()
+
+	first, err := q.SendDedup([]byte("key"), []byte("foo"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := q.SendDedup([]byte("key"), []byte("foo"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := second, first; got != want {
+		t.Errorf("bad original id: got %#v, want %#v", got, want)
+	}
+	a, _ := first.MarshalBinary()
+	b, _ := second.MarshalBinary()
+	if !bytes.Equal(a, b) {
+		t.Errorf("duplicate send got a new ID: %x != %x", a, b)
+	}
+	if _, err := q.SendDedup([]byte("other"), []byte("bar")); err != nil {
+		t.Fatal(err)
+	}
+
+	stats, err := q.Stats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := stats.Ready, 2; got != want {
+		t.Errorf("bad ready count: got %d, want %d", got, want)
+	}
+
+	m, err := q.Receive(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(m.ID, a) {
+		t.Errorf("bad id: got %x, want %x", m.ID, a)
+	}
+	if err := m.Ack(); err != nil {
+		t.Fatal(err)
+	}
+
+	third, err := q.sendDedup([]byte("key"), []byte("foo"), time.Now().Add(2*time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, _ := third.MarshalBinary()
+	if bytes.Equal(a, c) {
+		t.Error("key was not forgotten after the dedup window")
+	}
+}
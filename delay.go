@@ -21,37 +21,99 @@ func (q *Q) Delay(message []byte, when time.Time) (ID, error) {
 	if when.After(MaxDelayTime) {
 		return nil, fmt.Errorf("time out of range: %s", when.Format(time.RFC3339))
 	}
-	if when.Before(time.Now()) {
-		when = time.Now()
+	now := time.Now()
+	if when.Before(now) {
+		when = now
 	}
 	id := Uint64ID(when.UnixNano())
 	key, err := id.MarshalBinary()
 	if err != nil {
 		return nil, err
 	}
-	err = q.db.Update(func(tx *bolt.Tx) error {
-		bucket, err := q.bucket(tx, q.keys.delayed)
+	var subscribed bool
+	err = q.admit(func(tx *bolt.Tx) error {
+		targets, err := q.subscribers(tx)
 		if err != nil {
 			return err
 		}
+		subscribed = len(targets) > 0
+		partition := -1
+		if !subscribed {
+			targets = []*Q{q}
+			if q.partitions > 0 {
+				partition = q.partitionOf(key, nil)
+				targets = []*Q{q.partitionTarget(partition)}
+			}
+		}
 		// Reserve a spot for the message. If its exact time in unix
 		// nanoseconds has already been reserved, pick the next spot,
-		// ad-infinitum.
-		for {
-			k, _ := bucket.Cursor().Seek(key)
-			if !bytes.Equal(k, key) {
-				break
-			}
+		// ad-infinitum. Subscriptions all get the same ID, so the spot
+		// must be free in each of them.
+		for reserved(tx, targets, key) {
 			id++
 			key, err = id.MarshalBinary()
 			if err != nil {
 				return err
 			}
 		}
-		return bucket.Put(key, message)
+		md := &metadata{Enqueued: now.UnixNano()}
+		for _, t := range targets {
+			if err := t.delay(tx, key, message, md); err != nil {
+				return err
+			}
+		}
+		if partition >= 0 {
+			q.wakePartition(tx, partition, time.Unix(0, int64(id)))
+		}
+		if subscribed || partition >= 0 {
+			return q.countSent(tx, key)
+		}
+		return nil
 	})
 	if err == nil {
-		q.waker.WakeAt(time.Unix(0, int64(id)))
+		if subscribed {
+			q.wakeSubscriptionsAt(time.Unix(0, int64(id)))
+		} else {
+			q.waker.WakeAt(time.Unix(0, int64(id)))
+		}
+		q.onSend(id)
 	}
 	return id, err
 }
+
+// reserved reports whether key is in use by a delayed message of any of qs.
+func reserved(tx *bolt.Tx, qs []*Q, key []byte) bool {
+	for _, q := range qs {
+		bucket := q.readBucket(tx, q.keys.delayed)
+		if bucket == nil {
+			continue
+		}
+		if k, _ := bucket.Cursor().Seek(key); bytes.Equal(k, key) {
+			return true
+		}
+	}
+	return false
+}
+
+// delay stores a delayed message under key, which must not be in use.
+func (q *Q) delay(tx *bolt.Tx, key, message []byte, md *metadata) error {
+	bucket, err := q.bucket(tx, q.keys.delayed)
+	if err != nil {
+		return err
+	}
+	sealed, err := q.sealBody(tx, key, message)
+	if err != nil {
+		return err
+	}
+	if err := bucket.Put(key, sealed); err != nil {
+		return err
+	}
+	q.touch(tx, key)
+	if err := q.putMeta(tx, key, md); err != nil {
+		return err
+	}
+	if err := q.adjustDepth(tx, 1); err != nil {
+		return err
+	}
+	return q.countSent(tx, key)
+}
@@ -0,0 +1,46 @@
+package lasr
+
+import bolt "go.etcd.io/bbolt"
+
+// Delete removes the message id from q, whatever its state, or returns
+// ErrNotFound if it isn't in q, and ErrLeased if it is Unacked. Messages that
+// are waiting on it enter the Ready state, as if it had been nacked, as with
+// Purge.
+func (q *Q) Delete(id []byte) error {
+	if q.isClosed() {
+		return ErrQClosed
+	}
+	if q.readOnly {
+		return ErrReadOnly
+	}
+	var wake bool
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	err := q.db.Update(func(tx *bolt.Tx) error {
+		state, v := q.find(tx, id)
+		if v == nil {
+			return ErrNotFound
+		}
+		if state == Unacked {
+			return ErrLeased
+		}
+		released, err := q.stopWaitingOn(tx, id)
+		if err != nil {
+			return err
+		}
+		wake = released
+		q.touch(tx, id)
+		if err := q.auditID(tx, AuditDelete, id); err != nil {
+			return err
+		}
+		return q.forget(tx, id, false)
+	})
+	if err != nil {
+		return err
+	}
+	if wake && !q.isClosed() {
+		q.waker.Wake()
+	}
+	q.signalSpace()
+	return nil
+}
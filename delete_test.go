@@ -0,0 +1,80 @@
+package lasr
+
+import (
+	"context"
+	"testing"
+)
+
+func TestDelete(t *testing.T) {
+	q, cleanup := newQ(t)
+	defer cleanup()
+
+	blocker, err := q.Send([]byte("blocker"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	leased, err := q.Send([]byte("leased"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	waiting, err := q.Wait([]byte("waiting"), blocker)
+	if err != nil {
+		t.Fatal(err)
+	}
+	blockerKey, _ := blocker.MarshalBinary()
+	leasedKey, _ := leased.MarshalBinary()
+	waitingKey, _ := waiting.MarshalBinary()
+
+	if err := q.Delete(blockerKey); err != nil {
+		t.Fatal(err)
+	}
+	if err := q.Delete(blockerKey); err != ErrNotFound {
+		t.Errorf("got %v for a deleted message, want ErrNotFound", err)
+	}
+	msg, err := q.Receive(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(msg.Body) != "leased" {
+		t.Fatalf("got %q, want %q", msg.Body, "leased")
+	}
+	if err := q.Delete(leasedKey); err != ErrLeased {
+		t.Errorf("got %v for an unacked message, want ErrLeased", err)
+	}
+	blocked, err := q.Wait([]byte("blocked"), leased)
+	if err != nil {
+		t.Fatal(err)
+	}
+	blockedKey, _ := blocked.MarshalBinary()
+	if err := q.Delete(blockedKey); err != nil {
+		t.Fatal(err)
+	}
+	if err := msg.Ack(); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := q.Get(waitingKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.State != Ready {
+		t.Errorf("got %s for the message waiting on a deleted one, want ready", got.State)
+	}
+	if err := q.Delete(waitingKey); err != nil {
+		t.Fatal(err)
+	}
+	stats, err := q.Stats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Ready != 0 || stats.Waiting != 0 {
+		t.Errorf("got %d ready and %d waiting messages, want none", stats.Ready, stats.Waiting)
+	}
+	problems, err := q.Verify()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(problems) > 0 {
+		t.Errorf("got problems after delete: %v", problems)
+	}
+}
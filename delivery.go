@@ -0,0 +1,87 @@
+package lasr
+
+import (
+	"errors"
+	"fmt"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// DeliveryMode decides when received messages leave a Q.
+type DeliveryMode int
+
+const (
+	// AtLeastOnce keeps received messages Unacked until they are acked,
+	// and returns them to Ready if they are nacked, or if the Q is closed
+	// or the process stops before they are. It is the default.
+	AtLeastOnce DeliveryMode = iota
+
+	// AtMostOnce deletes messages in the transaction that receives them,
+	// as if they were acked at once, so they are never delivered twice,
+	// and are lost if their consumer fails. Ack and Nack have no effect on
+	// them, and the Q never holds Unacked messages.
+	AtMostOnce
+)
+
+// WithDeliveryMode sets the delivery mode of q. AtMostOnce can't be used
+// with a message buffer, see WithMessageBufferSize, since the buffered
+// messages would be lost when q is closed.
+func WithDeliveryMode(mode DeliveryMode) Option {
+	return func(q *Q) error {
+		if q.optsApplied {
+			return ErrOptionsApplied
+		}
+		if mode < AtLeastOnce || mode > AtMostOnce {
+			return fmt.Errorf("lasr: invalid delivery mode: %d", mode)
+		}
+		q.atMostOnce = mode == AtMostOnce
+		return nil
+	}
+}
+
+// checkDeliveryMode returns an error if the options of q don't go with its
+// delivery mode.
+func (q *Q) checkDeliveryMode() error {
+	if q.atMostOnce && (q.adaptive != nil || (q.messages != nil && q.messages.Limit() > 1)) {
+		return errors.New("AtMostOnce can't be used with a message buffer")
+	}
+	return nil
+}
+
+// consumeAtOnce acks msg in tx, which hands it out, if q delivers at most
+// once. msg no longer belongs to q then, so acking or nacking it has no
+// effect.
+func (q *Q) consumeAtOnce(tx *bolt.Tx, msg *Message) error {
+	if !q.atMostOnce {
+		return nil
+	}
+	wake, err := q.ackTx(tx, msg.ID)
+	if err != nil {
+		return err
+	}
+	id := msg.ID
+	tx.OnCommit(func() {
+		q.signalSpace()
+		q.onAck(id)
+		if wake && !q.isClosed() {
+			q.waker.Wake()
+		}
+	})
+	msg.q = nil
+	return nil
+}
+
+// consumeBuffered acks msg, which Receive is about to hand out, if q delivers
+// at most once. Messages are only buffered Unacked until then, so that the
+// ones that are never handed out, because Receive gave up waiting for its turn
+// or q was closed, are returned to Ready.
+func (q *Q) consumeBuffered(msg *Message) error {
+	if !q.atMostOnce {
+		return nil
+	}
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	return q.db.Update(func(tx *bolt.Tx) error {
+		return q.consumeAtOnce(tx, msg)
+	})
+}
@@ -0,0 +1,86 @@
+package lasr
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+func TestAtMostOnce(t *testing.T) {
+	q, cleanup := newQ(t, WithDeliveryMode(AtMostOnce))
+	defer cleanup()
+
+	first, err := q.Send([]byte("a"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := q.Wait([]byte("b"), first); err != nil {
+		t.Fatal(err)
+	}
+	msg, err := q.Receive(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(msg.Body) != "a" {
+		t.Fatalf("got %q, want %q", msg.Body, "a")
+	}
+	stats, err := q.Stats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Unacked != 0 || stats.Ready != 1 || stats.Waiting != 0 || stats.Acked != 1 {
+		t.Errorf("bad stats after receive: %+v", stats)
+	}
+	if err := msg.Nack(true); err != nil {
+		t.Errorf("got %v nacking, want nil", err)
+	}
+
+	// The second message is left unacked when q is closed, which doesn't
+	// wait for it.
+	msg, err = q.TryReceive()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(msg.Body) != "b" {
+		t.Fatalf("got %q, want %q", msg.Body, "b")
+	}
+	if _, err := q.TryReceive(); err != ErrEmpty {
+		t.Errorf("got %v, want ErrEmpty", err)
+	}
+
+	if _, err := NewQ(q.db, "other", WithDeliveryMode(AtMostOnce), WithMessageBufferSize(10)); err == nil {
+		t.Error("expected an error with a message buffer")
+	}
+}
+
+func TestAtMostOnceGiveUp(t *testing.T) {
+	q, cleanup := newQ(t, WithDeliveryMode(AtMostOnce), WithDeliveryRate(rate.Every(time.Hour), 1))
+	defer cleanup()
+	sendBodies(t, q, "a", "b")
+	if got := receiveBodies(t, q, 1); got[0] != "a" {
+		t.Fatalf("got %q, want a", got[0])
+	}
+	// b is read into the buffer, but Receive gives up waiting for its turn.
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := q.Receive(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("got %v, want context.DeadlineExceeded", err)
+	}
+	if err := q.Close(); err != nil {
+		t.Fatal(err)
+	}
+	q, err := NewQ(q.db, "testing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer q.Close()
+	stats, err := q.Stats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Ready != 1 || stats.Unacked != 0 {
+		t.Errorf("got %d ready and %d unacked, want 1 and 0", stats.Ready, stats.Unacked)
+	}
+}
@@ -0,0 +1,120 @@
+package lasr
+
+import (
+	"fmt"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var depthCounter = []byte("depth")
+
+// WithMaxDepth limits the number of messages that q can hold to n. Messages
+// count towards the limit from the time they are sent until they are acked,
+// or nacked without retry, regardless of whether they are Ready, Unacked,
+// Delayed or Waiting. Dead letters do not count towards the limit.
+//
+// When q is full, Send, SendWithHeaders, Delay and Wait return ErrQueueFull,
+// unless WithBlockWhenFull is also used.
+func WithMaxDepth(n int) Option {
+	return func(q *Q) error {
+		if q.optsApplied {
+			return ErrOptionsApplied
+		}
+		if n < 1 {
+			return fmt.Errorf("lasr: invalid max depth: %d", n)
+		}
+		q.maxDepth = uint64(n)
+		return nil
+	}
+}
+
+// WithBlockWhenFull causes sends to a full Q to block until there is room for
+// the message, instead of returning ErrQueueFull. If the Q is closed while a
+// send is blocked, the send returns ErrQClosed.
+//
+// WithBlockWhenFull has no effect without WithMaxDepth.
+func WithBlockWhenFull() Option {
+	return func(q *Q) error {
+		if q.optsApplied {
+			return ErrOptionsApplied
+		}
+		q.blockWhenFull = true
+		return nil
+	}
+}
+
+// admit runs fn in a read-write transaction, once q has room for another
+// message. The transaction may be shared with concurrent sends, see commit.
+func (q *Q) admit(fn func(tx *bolt.Tx) error) error {
+	for {
+		space := q.spaceAvailable()
+		err := q.commit(func(tx *bolt.Tx) error {
+			if q.maxDepth > 0 && q.counter(tx, depthCounter) >= q.maxDepth {
+				return ErrQueueFull
+			}
+			return fn(tx)
+		})
+		if err != ErrQueueFull || !q.blockWhenFull {
+			return err
+		}
+		select {
+		case <-space:
+		case <-q.closed:
+			return ErrQClosed
+		}
+	}
+}
+
+// adjustDepth records that delta messages were added to q, or removed from it
+// if delta is negative.
+func (q *Q) adjustDepth(tx *bolt.Tx, delta int) error {
+	depth := int64(q.counter(tx, depthCounter)) + int64(delta)
+	if depth < 0 {
+		depth = 0
+	}
+	q.watchReady(tx)
+	return q.setCounter(tx, depthCounter, uint64(depth))
+}
+
+// resetDepth counts the messages in q to correct its depth, in case it was
+// created by a version of lasr that did not keep track.
+//
+// resetDepth runs in the same transaction that returns unacked messages to
+// Ready, and Bucket.Stats only counts committed pages, so the keys are counted
+// with cursors instead.
+func (q *Q) resetDepth(tx *bolt.Tx) error {
+	var depth int
+	for _, key := range [][]byte{q.keys.ready, q.keys.unacked, q.keys.delayed, q.keys.waiting, q.keys.retrying} {
+		bucket := q.readBucket(tx, key)
+		if bucket == nil {
+			continue
+		}
+		cur := bucket.Cursor()
+		for k, _ := cur.First(); k != nil; k, _ = cur.Next() {
+			depth++
+		}
+	}
+	q.watchReady(tx)
+	return q.setCounter(tx, depthCounter, uint64(depth))
+}
+
+// spaceAvailable returns a channel that is closed the next time messages are
+// removed from q.
+func (q *Q) spaceAvailable() <-chan struct{} {
+	q.spaceMu.Lock()
+	defer q.spaceMu.Unlock()
+	if q.space == nil {
+		q.space = make(chan struct{})
+	}
+	return q.space
+}
+
+// signalSpace wakes up sends that are blocked on a full q.
+func (q *Q) signalSpace() {
+	q.spaceMu.Lock()
+	defer q.spaceMu.Unlock()
+	if q.space != nil {
+		close(q.space)
+		q.space = nil
+	}
+}
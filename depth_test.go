@@ -0,0 +1,170 @@
+package lasr
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+func TestMaxDepth(t *testing.T) {
+	q, cleanup := newQ(t, WithMaxDepth(2), WithDeadLetters())
+	defer cleanup()
+
+	if _, err := q.Send([]byte("foo")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := q.Delay([]byte("bar"), time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := q.Send([]byte("baz")); err != ErrQueueFull {
+		t.Fatalf("bad error: got %v, want %v", err, ErrQueueFull)
+	}
+	if _, err := q.Wait([]byte("baz")); err != ErrQueueFull {
+		t.Fatalf("bad error: got %v, want %v", err, ErrQueueFull)
+	}
+
+	m, err := q.Receive(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Nack(true); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := q.Send([]byte("baz")); err != ErrQueueFull {
+		t.Fatalf("retried message should still count: got %v", err)
+	}
+
+	m, err = q.Receive(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Nack(false); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := q.Send([]byte("baz")); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestBlockWhenFull(t *testing.T) {
+	q, cleanup := newQ(t, WithMaxDepth(1), WithBlockWhenFull())
+	defer cleanup()
+
+	if _, err := q.Send([]byte("foo")); err != nil {
+		t.Fatal(err)
+	}
+
+	errs := make(chan error, 1)
+	go func() {
+		_, err := q.Send([]byte("bar"))
+		errs <- err
+	}()
+
+	select {
+	case err := <-errs:
+		t.Fatalf("send did not block: %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	m, err := q.Receive(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Ack(); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case err := <-errs:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("send still blocked after ack")
+	}
+
+	go func() {
+		_, err := q.Send([]byte("baz"))
+		errs <- err
+	}()
+	time.Sleep(10 * time.Millisecond)
+	if err := q.Close(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err := <-errs:
+		if err != ErrQClosed {
+			t.Fatalf("bad error: got %v, want %v", err, ErrQClosed)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("send still blocked after close")
+	}
+}
+
+func TestPurgeFreesSpace(t *testing.T) {
+	q, cleanup := newQ(t, WithMaxDepth(2))
+	defer cleanup()
+
+	if _, err := q.Send([]byte("foo")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := q.Send([]byte("bar")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := q.Purge(Ready); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := q.Send([]byte("baz")); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDepthAfterCrash(t *testing.T) {
+	td, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(td)
+	db, err := bolt.Open(filepath.Join(td, "lasr.db"), 0600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	// q is never closed, since its messages are never acked.
+	q, err := NewQ(db, "testing")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 3; i++ {
+		if _, err := q.Send([]byte("foo")); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := q.Receive(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Open the queue again without closing it, as after a crash with the
+	// messages in flight.
+	reopened, err := NewQ(db, "testing", WithMaxDepth(3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reopened.Close()
+	stats, err := reopened.Stats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := stats.Ready, 3; got != want {
+		t.Fatalf("bad ready count: got %d, want %d", got, want)
+	}
+	if _, err := reopened.Send([]byte("bar")); err != ErrQueueFull {
+		t.Fatalf("bad error: got %v, want %v", err, ErrQueueFull)
+	}
+}
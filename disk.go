@@ -0,0 +1,105 @@
+package lasr
+
+import (
+	"fmt"
+	"os"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// DiskStats describes the space used by the bolt database that backs a Q, as
+// returned by Q.DiskStats. The database is shared by every queue in it, so
+// queues sharing a database report the same DiskStats.
+type DiskStats struct {
+	// Size is the size of the database file, in bytes.
+	Size int64
+
+	// FreePages is the number of pages that bolt has freed, and will reuse
+	// before growing the file. Pages that are only pending until open read
+	// transactions end are included.
+	FreePages int
+
+	// FreelistSize is the size of the freelist itself, in bytes.
+	FreelistSize int
+
+	// Reclaimable estimates how many bytes Compact would give back: the free
+	// pages, and the space that bolt preallocated at the end of the file
+	// but has not used yet.
+	Reclaimable int64
+}
+
+// DiskStats returns the space used by the database of q, so that operators
+// can decide when to compact it.
+func (q *Q) DiskStats() (DiskStats, error) {
+	var stats DiskStats
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	fi, err := os.Stat(q.db.Path())
+	if err != nil {
+		return stats, fmt.Errorf("lasr: couldn't get disk stats: %s", err)
+	}
+	stats.Size = fi.Size()
+	dbStats := q.db.Stats()
+	stats.FreePages = dbStats.FreePageN + dbStats.PendingPageN
+	stats.FreelistSize = dbStats.FreelistInuse
+	var used int64
+	err = q.db.View(func(tx *bolt.Tx) error {
+		used = tx.Size()
+		return nil
+	})
+	if err != nil {
+		return stats, err
+	}
+	stats.Reclaimable = int64(dbStats.FreeAlloc)
+	if unused := stats.Size - used; unused > 0 {
+		stats.Reclaimable += unused
+	}
+	return stats, nil
+}
+
+// StorageStats holds the statistics that bolt keeps about the database of a
+// Q, and about the buckets of the Q, as returned by Q.StorageStats.
+type StorageStats struct {
+	// DB holds the transaction and freelist statistics of the database,
+	// which is shared by every queue in it. Its TxStats count page
+	// allocations, splits, rebalances, cursors and the time spent on them
+	// since the database was opened; use Sub for the difference between
+	// two StorageStats.
+	DB bolt.Stats
+
+	// Root holds the page statistics of the bucket of the Q, which
+	// include those of every bucket in it.
+	Root bolt.BucketStats
+
+	// Buckets holds the page statistics of each bucket of the Q, by name,
+	// like "ready", "unacked" and "meta".
+	Buckets map[string]bolt.BucketStats
+}
+
+// StorageStats returns the statistics that bolt keeps about the database of
+// q, and about the buckets of q, for performance investigations. Collecting
+// the bucket statistics walks every page of q, so it is slow on large queues.
+func (q *Q) StorageStats() (StorageStats, error) {
+	if q.isClosed() {
+		return StorageStats{}, ErrQClosed
+	}
+	stats := StorageStats{Buckets: make(map[string]bolt.BucketStats)}
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	err := q.db.View(func(tx *bolt.Tx) error {
+		root := tx.Bucket(q.name)
+		if root == nil {
+			return nil
+		}
+		stats.Root = root.Stats()
+		return root.ForEachBucket(func(k []byte) error {
+			stats.Buckets[string(k)] = root.Bucket(k).Stats()
+			return nil
+		})
+	})
+	if err != nil {
+		return StorageStats{}, fmt.Errorf("lasr: couldn't get storage stats: %s", err)
+	}
+	stats.DB = q.db.Stats()
+	return stats, nil
+}
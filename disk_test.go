@@ -0,0 +1,85 @@
+package lasr
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestDiskStats(t *testing.T) {
+	q, cleanup := newQ(t)
+	defer cleanup()
+
+	body := bytes.Repeat([]byte("x"), 4096)
+	for i := 0; i < 256; i++ {
+		if _, err := q.Send(body); err != nil {
+			t.Fatal(err)
+		}
+	}
+	before, err := q.DiskStats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if before.Size <= 0 {
+		t.Errorf("bad size: %d", before.Size)
+	}
+	msgs := receiveN(t, q, 256)
+	if err := q.AckBatch(messageIDs(msgs)...); err != nil {
+		t.Fatal(err)
+	}
+
+	after, err := q.DiskStats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if after.FreePages == 0 || after.Reclaimable <= before.Reclaimable {
+		t.Errorf("expected free space after acking: before %+v, after %+v", before, after)
+	}
+	if err := q.Compact(); err != nil {
+		t.Fatal(err)
+	}
+	compacted, err := q.DiskStats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if compacted.Size >= after.Size {
+		t.Errorf("expected a smaller file after compacting: before %d, after %d", after.Size, compacted.Size)
+	}
+}
+
+func messageIDs(msgs []*Message) [][]byte {
+	ids := make([][]byte, len(msgs))
+	for i, msg := range msgs {
+		ids[i] = msg.ID
+	}
+	return ids
+}
+
+func TestStorageStats(t *testing.T) {
+	q, cleanup := newQ(t)
+	defer cleanup()
+
+	before, err := q.StorageStats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	body := bytes.Repeat([]byte("x"), 512)
+	for i := 0; i < 64; i++ {
+		if _, err := q.Send(body); err != nil {
+			t.Fatal(err)
+		}
+	}
+	after, err := q.StorageStats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := after.Buckets["ready"].KeyN; got != 64 {
+		t.Errorf("got %d ready keys, want 64", got)
+	}
+	if after.Root.KeyN < 64 {
+		t.Errorf("got %d keys in the root bucket, want at least 64", after.Root.KeyN)
+	}
+	diff := after.DB.Sub(&before.DB)
+	if diff.TxStats.GetWrite() == 0 || diff.TxStats.GetPageAlloc() == 0 {
+		t.Errorf("expected writes and page allocations: %+v", diff.TxStats)
+	}
+}
@@ -0,0 +1,124 @@
+package lasr
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// WithDeadLetterQueue is like WithDeadLetters, but sends the dead letters of q
+// to the queue named name, in the same database, instead of keeping them in q.
+// Several queues can share a dead-letter queue by naming the same one, or each
+// have their own.
+//
+// A dead letter is moved in the same transaction that dead-letters it, and
+// becomes a Ready message of the dead-letter queue, with a new ID from it. It
+// keeps its headers, and its DeadLetterReason, DeadLetteredAt and
+// DeadLetterError are set when it is received from there. The dead-letter
+// queue is opened with NewQ as usual, with the same encryption key as q, if
+// any. DeadLetters, RedriveDeadLetters and the other methods that work on the
+// dead letters of q find none.
+//
+// Dead letters are sent the way the dead-letter queue stores messages, to its
+// partitions if it has any. While it is open in this process, they get their
+// IDs from its Sequencer, so a dead-letter queue with a Sequencer of its own,
+// or a WAL, must be open whenever q dead-letters messages.
+func WithDeadLetterQueue(name string) Option {
+	return func(q *Q) error {
+		if q.optsApplied {
+			return ErrOptionsApplied
+		}
+		if name == "" {
+			return errors.New("empty dead-letter queue name")
+		}
+		q.keys.returned = []byte("deadletters")
+		q.deadLetterQueue = []byte(name)
+		return nil
+	}
+}
+
+// forwardDeadLetter moves the message id, stored in bucket, to the dead-letter
+// queue of q, see WithDeadLetterQueue.
+func (q *Q) forwardDeadLetter(tx *bolt.Tx, bucket *bolt.Bucket, id []byte, reason string) error {
+	// Corrupt messages are dead-lettered as they are.
+	body, err := q.openBody(tx, id, bucket.Get(id))
+	if err != nil && err != ErrCorruptMessage {
+		return err
+	}
+	md, err := q.getMeta(tx, id)
+	if err != nil {
+		return err
+	}
+	forwarded := &metadata{DeadLettered: time.Now().UnixNano(), Reason: reason}
+	if md != nil {
+		forwarded.Headers = md.Headers
+		forwarded.Enqueued = md.Enqueued
+		forwarded.Attempts = md.Attempts
+		forwarded.Error = md.Error
+	}
+	dst, err := q.deadLetterTarget(tx)
+	if err != nil {
+		return err
+	}
+	newID, err := dst.nextSequence(tx)
+	if err != nil {
+		return err
+	}
+	if err := dst.send(newID, body, forwarded, tx); err != nil {
+		return err
+	}
+	if _, err := q.stopWaitingOn(tx, id); err != nil {
+		return err
+	}
+	q.touch(tx, id)
+	if err := q.deleteMeta(tx, id); err != nil {
+		return err
+	}
+	if err := q.incrCounter(tx, deadLetteredCounter); err != nil {
+		return err
+	}
+	tx.OnCommit(func() {
+		q.wakeQueue(q.deadLetterQueue)
+	})
+	return bucket.Delete(id)
+}
+
+// deadLetterTarget returns the Q that sends dead letters to the dead-letter
+// queue of q. That is the dead-letter queue itself if it is open on the
+// database of q, so that its IDs come from its own sequencer, or else one that
+// stores them in the partitions it was created with, if any.
+func (q *Q) deadLetterTarget(tx *bolt.Tx) (*Q, error) {
+	for _, other := range q.shared.others(q) {
+		if string(other.name) == string(q.deadLetterQueue) && !other.isDeadLetters() && !other.isClosed() {
+			return other, nil
+		}
+	}
+	root, err := tx.CreateBucketIfNotExists(q.deadLetterQueue)
+	if err != nil {
+		return nil, err
+	}
+	dst := q.storageFor(q.deadLetterQueue)
+	dst.seqName = q.deadLetterQueue
+	if v := root.Get(configKey); v != nil {
+		var c config
+		if err := json.Unmarshal(v, &c); err != nil {
+			return nil, fmt.Errorf("lasr: bad configuration of dead-letter queue: %s", err)
+		}
+		dst.partitions = c.Partitions
+	}
+	return dst, nil
+}
+
+// wakeQueue wakes the queue named name, and its subscriptions, if it is open
+// on the database of q.
+func (q *Q) wakeQueue(name []byte) {
+	for _, other := range q.shared.others(q) {
+		if string(other.name) == string(name) && !other.isClosed() {
+			other.waker.Wake()
+			other.wakeSubscriptions()
+		}
+	}
+}
@@ -0,0 +1,191 @@
+package lasr
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+)
+
+func TestDeadLetterQueue(t *testing.T) {
+	q, cleanup := newQ(t, WithDeadLetterQueue("dlq"))
+	defer cleanup()
+	other, err := NewQ(q.db, "other", WithDeadLetterQueue("dlq"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer other.Close()
+	dlq, err := NewQ(q.db, "dlq")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dlq.Close()
+
+	received := make(chan *Message, 2)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		for i := 0; i < 2; i++ {
+			msg, err := dlq.Receive(ctx)
+			if err != nil {
+				close(received)
+				return
+			}
+			received <- msg
+		}
+	}()
+
+	if _, err := q.SendWithHeaders([]byte("foo"), map[string][]byte{"k": []byte("v")}); err != nil {
+		t.Fatal(err)
+	}
+	sendBodies(t, other, "bar")
+	for _, src := range []*Q{q, other} {
+		msg := receiveN(t, src, 1)[0]
+		if err := msg.Nack(false); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var bodies []string
+	for msg := range received {
+		bodies = append(bodies, string(msg.Body))
+		if msg.DeadLetterReason != DeadLetterNacked || msg.DeadLetteredAt.IsZero() {
+			t.Errorf("%q: got reason %q at %v", msg.Body, msg.DeadLetterReason, msg.DeadLetteredAt)
+		}
+		if string(msg.Body) == "foo" && string(msg.Headers["k"]) != "v" {
+			t.Errorf("got headers %q, want k=v", msg.Headers)
+		}
+		if err := msg.Ack(); err != nil {
+			t.Fatal(err)
+		}
+		if len(bodies) == 2 {
+			break
+		}
+	}
+	if len(bodies) != 2 || bodies[0] != "foo" || bodies[1] != "bar" {
+		t.Fatalf("got dead letters %q, want [foo bar]", bodies)
+	}
+
+	for _, src := range []*Q{q, other} {
+		stats, err := src.Stats()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stats.Ready != 0 || stats.Returned != 0 {
+			t.Errorf("%s: got %d ready and %d returned, want none", src.name, stats.Ready, stats.Returned)
+		}
+	}
+}
+
+func TestDeadLetterQueueExpired(t *testing.T) {
+	q, cleanup := newQ(t, WithDeadLetterQueue("dlq"))
+	defer cleanup()
+	if _, err := q.SendWithDeadline([]byte("late"), time.Now().Add(-time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	sendBodies(t, q, "on time")
+	if got := receiveBodies(t, q, 1); got[0] != "on time" {
+		t.Fatalf("got %q, want on time", got[0])
+	}
+	dlq, err := NewQ(q.db, "dlq")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dlq.Close()
+	msg := receiveN(t, dlq, 1)[0]
+	if string(msg.Body) != "late" || msg.DeadLetterReason != DeadLetterExpired || !msg.Deadline.IsZero() {
+		t.Errorf("got %q for %q, deadline %v", msg.Body, msg.DeadLetterReason, msg.Deadline)
+	}
+	if err := msg.Ack(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDeadLetterQueueConfig(t *testing.T) {
+	q, cleanup := newQ(t, WithDeadLetterQueue("dlq"))
+	defer cleanup()
+	if _, err := NewQ(q.db, "testing", WithDeadLetters()); err != ErrConfigMismatch {
+		t.Errorf("got %v, want ErrConfigMismatch", err)
+	}
+	if _, err := NewQ(q.db, "itself", WithDeadLetterQueue("itself")); err == nil {
+		t.Error("expected an error for a queue that dead-letters to itself")
+	}
+}
+
+func TestDeadLetterQueueSequencer(t *testing.T) {
+	q, cleanup := newQ(t, WithDeadLetterQueue("dlq"))
+	defer cleanup()
+	dlq, err := NewQ(q.db, "dlq", WithSequencer(&mockSeq{id: 100}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dlq.Close()
+	sendBodies(t, q, "foo")
+	if err := receiveN(t, q, 1)[0].Nack(false); err != nil {
+		t.Fatal(err)
+	}
+	id, err := dlq.Send([]byte("bar"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id != Uint64ID(101) {
+		t.Errorf("got ID %v after the dead letter, want 101", id)
+	}
+	msgs := receiveN(t, dlq, 2)
+	if !bytes.Equal(msgs[0].ID, []byte{0, 0, 0, 0, 0, 0, 0, 100}) || string(msgs[0].Body) != "foo" {
+		t.Errorf("got dead letter %x %q, want ID 100", msgs[0].ID, msgs[0].Body)
+	}
+	for _, msg := range msgs {
+		if err := msg.Ack(); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestDeadLetterQueuePartitioned(t *testing.T) {
+	q, cleanup := newQ(t, WithDeadLetterQueue("dlq"))
+	defer cleanup()
+	dlq, err := NewQ(q.db, "dlq", WithPartitions(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Dead letters go to the partitions whether or not the queue is open.
+	for _, open := range []bool{true, false} {
+		if !open {
+			if err := dlq.Close(); err != nil {
+				t.Fatal(err)
+			}
+		}
+		sendBodies(t, q, "foo")
+		if err := receiveN(t, q, 1)[0].Nack(false); err != nil {
+			t.Fatal(err)
+		}
+		if !open {
+			if dlq, err = NewQ(q.db, "dlq", WithPartitions(2)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		var found int
+		for i := 0; i < dlq.Partitions(); i++ {
+			p, err := dlq.Partition(i)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if msg, err := p.TryReceive(); err == nil {
+				found++
+				if err := msg.Ack(); err != nil {
+					t.Fatal(err)
+				}
+			} else if err != ErrEmpty {
+				t.Fatal(err)
+			}
+			if err := p.Close(); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if found != 1 {
+			t.Errorf("open %v: found %d dead letters in the partitions, want 1", open, found)
+		}
+	}
+	dlq.Close()
+}
@@ -0,0 +1,119 @@
+package lasr
+
+import (
+	"fmt"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// deadLetterSweepBatch is how many dead letters a sweep deletes per
+// transaction.
+const deadLetterSweepBatch = 1000
+
+// WithDeadLetterTTL makes q delete dead letters once they have been dead
+// letters for longer than d. It requires WithDeadLetters. Set the
+// OnDeadLetterExpired hook to archive them before they are deleted, see
+// WithHooks.
+//
+// Dead letters are swept in the background, every tenth of d, or every minute
+// if that is sooner, see WithMaintenanceInterval. Dead letters from before
+// lasr recorded when messages were dead-lettered are kept for d from the first
+// sweep.
+func WithDeadLetterTTL(d time.Duration) Option {
+	return func(q *Q) error {
+		if q.optsApplied {
+			return ErrOptionsApplied
+		}
+		if d <= 0 {
+			return fmt.Errorf("lasr: invalid dead letter TTL: %s", d)
+		}
+		q.deadLetterTTL = d
+		return nil
+	}
+}
+
+// sweepDeadLetters deletes the dead letters that expired by now, and returns
+// how many it deleted.
+func (q *Q) sweepDeadLetters(now time.Time) (int, error) {
+	var swept int
+	for {
+		n, more, err := q.sweepDeadLetterBatch(now)
+		swept += n
+		if err != nil || !more {
+			return swept, err
+		}
+	}
+}
+
+// sweepDeadLetterBatch deletes up to deadLetterSweepBatch expired dead
+// letters in one transaction, and reports whether there may be more.
+func (q *Q) sweepDeadLetterBatch(now time.Time) (int, bool, error) {
+	if q.isClosed() {
+		return 0, false, ErrQClosed
+	}
+	var expired []*ExportRecord
+	var n int
+	var more bool
+	cutoff := now.Add(-q.deadLetterTTL).UnixNano()
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	err := q.db.Update(func(tx *bolt.Tx) error {
+		returned := q.readBucket(tx, q.keys.returned)
+		if returned == nil {
+			return nil
+		}
+		var keys [][]byte
+		var unstamped [][]byte
+		cur := returned.Cursor()
+		for k, _ := cur.First(); k != nil; k, _ = cur.Next() {
+			if len(keys)+len(unstamped) >= deadLetterSweepBatch {
+				more = true
+				break
+			}
+			md, err := q.getMeta(tx, k)
+			if err != nil {
+				return err
+			}
+			if md == nil || md.DeadLettered == 0 {
+				unstamped = append(unstamped, cloneBytes(k))
+			} else if md.DeadLettered <= cutoff {
+				keys = append(keys, cloneBytes(k))
+			}
+		}
+		for _, k := range unstamped {
+			if err := q.stampDeadLetter(tx, k, now, ""); err != nil {
+				return err
+			}
+		}
+		for _, k := range keys {
+			if q.hooks.OnDeadLetterExpired != nil {
+				rec, err := q.exportRecord(tx, Returned, k, returned.Get(k))
+				if err != nil {
+					q.logger().Warn("lasr: couldn't read expired dead letter", "id", hexID(k), "error", err)
+				} else {
+					expired = append(expired, rec)
+				}
+			}
+			if err := returned.Delete(k); err != nil {
+				return err
+			}
+			q.touch(tx, k)
+			if err := q.deleteMeta(tx, k); err != nil {
+				return err
+			}
+		}
+		n = len(keys)
+		return nil
+	})
+	if err != nil {
+		return 0, false, err
+	}
+	if n > 0 {
+		q.logger().Debug("lasr: deleted expired dead letters", "count", n)
+	}
+	for _, rec := range expired {
+		q.hooks.OnDeadLetterExpired(*rec)
+	}
+	return n, more, nil
+}
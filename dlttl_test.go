@@ -0,0 +1,118 @@
+package lasr
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+func deadLetterBodies(t *testing.T, q *Q, bodies ...string) {
+	t.Helper()
+	sendBodies(t, q, bodies...)
+	for _, msg := range receiveN(t, q, len(bodies)) {
+		if err := msg.Nack(false); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestSweepDeadLetters(t *testing.T) {
+	var expired []string
+	q, cleanup := newQ(t, WithDeadLetters(), WithDeadLetterTTL(time.Hour), WithHooks(Hooks{
+		OnDeadLetterExpired: func(rec ExportRecord) {
+			expired = append(expired, string(rec.Body))
+		},
+	}))
+	defer cleanup()
+	deadLetterBodies(t, q, "a", "b")
+
+	now := time.Now()
+	if n, err := q.sweepDeadLetters(now); err != nil {
+		t.Fatal(err)
+	} else if n != 0 {
+		t.Errorf("swept %d dead letters, want 0", n)
+	}
+	if n, err := q.sweepDeadLetters(now.Add(2 * time.Hour)); err != nil {
+		t.Fatal(err)
+	} else if n != 2 {
+		t.Errorf("swept %d dead letters, want 2", n)
+	}
+	if len(expired) != 2 || expired[0] != "a" || expired[1] != "b" {
+		t.Errorf("got expired dead letters %q", expired)
+	}
+	stats, err := q.Stats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Returned != 0 {
+		t.Errorf("got %d dead letters, want 0", stats.Returned)
+	}
+	if problems, err := q.Verify(); err != nil {
+		t.Fatal(err)
+	} else if len(problems) > 0 {
+		t.Errorf("got problems %v", problems)
+	}
+}
+
+func TestSweepUnstampedDeadLetters(t *testing.T) {
+	q, cleanup := newQ(t, WithDeadLetters(), WithDeadLetterTTL(time.Hour))
+	defer cleanup()
+	deadLetterBodies(t, q, "a")
+	err := q.db.Update(func(tx *bolt.Tx) error {
+		return q.readBucket(tx, q.keys.meta).ForEach(func(k, _ []byte) error {
+			md, err := q.getMeta(tx, k)
+			if err != nil {
+				return err
+			}
+			md.DeadLettered = 0
+			return q.putMeta(tx, k, md)
+		})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	later := time.Now().Add(2 * time.Hour)
+	if n, err := q.sweepDeadLetters(later); err != nil {
+		t.Fatal(err)
+	} else if n != 0 {
+		t.Errorf("swept %d unstamped dead letters, want 0", n)
+	}
+	if n, err := q.sweepDeadLetters(later.Add(2 * time.Hour)); err != nil {
+		t.Fatal(err)
+	} else if n != 1 {
+		t.Errorf("swept %d dead letters, want 1", n)
+	}
+}
+
+func TestDeadLetterTTL(t *testing.T) {
+	var mu sync.Mutex
+	var expired int
+	q, cleanup := newQ(t, WithDeadLetters(), WithDeadLetterTTL(20*time.Millisecond), WithHooks(Hooks{
+		OnDeadLetterExpired: func(ExportRecord) {
+			mu.Lock()
+			expired++
+			mu.Unlock()
+		},
+	}))
+	defer cleanup()
+	deadLetterBodies(t, q, "a")
+	for deadline := time.Now().Add(5 * time.Second); ; {
+		mu.Lock()
+		n := expired
+		mu.Unlock()
+		if n == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("dead letter wasn't swept")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	if _, err := NewQ(q.db, "other", WithDeadLetterTTL(time.Hour)); err == nil {
+		t.Error("expected an error without WithDeadLetters")
+	}
+}
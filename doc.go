@@ -1,4 +1,4 @@
-// Package lasr implements a persistent message queue backed by BoltDB. This queue is useful when the producers and consumers can live in the same process.
+// Package lasr implements a persistent message queue backed by bbolt (go.etcd.io/bbolt), the maintained fork of BoltDB. This queue is useful when the producers and consumers can live in the same process.
 //
 // Goals:
 // * Data integrity over performance.
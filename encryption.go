@@ -0,0 +1,130 @@
+package lasr
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// WithEncryption will cause message bodies to be encrypted with AES-GCM before
+// they are written to the database, and decrypted on Receive. The key must be
+// 16, 24 or 32 bytes long, selecting AES-128, AES-192 or AES-256.
+//
+// Only message bodies are encrypted. Headers and IDs are stored in the clear,
+// but the ID of each message is authenticated with its body, so a body can't
+// be moved to another message without failing to decrypt.
+//
+// A Q that uses encryption must always be opened with the same key; messages
+// that cannot be decrypted cause Receive to fail. To change keys, see
+// WithEncryptionKeys.
+func WithEncryption(key []byte) Option {
+	return WithEncryptionKeys(0, map[uint32][]byte{0: key})
+}
+
+// WithEncryptionKeys is like WithEncryption, but with several versions of the
+// key, by ID. Bodies are encrypted with the key current, and the ID of the key
+// is stored with each of them, so that they are decrypted with the key they
+// were encrypted with, for as long as it is in keys.
+//
+// Bodies encrypted with key 0 are stored as with WithEncryption, so a queue
+// that uses WithEncryption can be given a new key by opening it with its old
+// key as key 0. To rotate keys, open q with the new key as current, and the
+// old ones, and then re-encrypt its messages with Rewrap, after which the old
+// keys can be dropped.
+func WithEncryptionKeys(current uint32, keys map[uint32][]byte) Option {
+	return func(q *Q) error {
+		if q.optsApplied {
+			return ErrOptionsApplied
+		}
+		if _, ok := keys[current]; !ok {
+			return fmt.Errorf("lasr: no encryption key with ID %d", current)
+		}
+		keyring := make(map[uint32]cipher.AEAD, len(keys))
+		for id, key := range keys {
+			block, err := aes.NewCipher(key)
+			if err != nil {
+				return fmt.Errorf("lasr: invalid encryption key %d: %s", id, err)
+			}
+			aead, err := cipher.NewGCM(block)
+			if err != nil {
+				return fmt.Errorf("lasr: invalid encryption key %d: %s", id, err)
+			}
+			keyring[id] = aead
+		}
+		q.aead = keyring[current]
+		q.keyID = current
+		q.keyring = keyring
+		return nil
+	}
+}
+
+// seal returns the form of the body of message id that is written to the
+// database. Bodies sealed with a key other than key 0 are prefixed with the ID
+// of the key.
+func (q *Q) seal(id, body []byte) ([]byte, error) {
+	if q.aead == nil {
+		return body, nil
+	}
+	var prefix int
+	if q.keyID != 0 {
+		prefix = 4
+	}
+	sealed := make([]byte, prefix+q.aead.NonceSize(), prefix+q.aead.NonceSize()+len(body)+q.aead.Overhead())
+	binary.BigEndian.PutUint32(sealed, q.keyID)
+	nonce := sealed[prefix:]
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	return q.aead.Seal(sealed, nonce, body, id), nil
+}
+
+// unseal returns a copy of the body of message id that was stored as v.
+func (q *Q) unseal(id, v []byte) ([]byte, error) {
+	body, _, err := q.unsealKey(id, v)
+	return body, err
+}
+
+// unsealKey is like unseal, but also returns the ID of the key that v was
+// sealed with. A prefix that names a key is only trusted if the body opens
+// with it, since the nonce of a body sealed with key 0 can start with the ID
+// of another key.
+func (q *Q) unsealKey(id, v []byte) ([]byte, uint32, error) {
+	if q.aead == nil {
+		return cloneBytes(v), 0, nil
+	}
+	err := errors.New("no key")
+	if len(v) >= 4 {
+		keyID := binary.BigEndian.Uint32(v)
+		if aead, ok := q.keyring[keyID]; ok && keyID != 0 {
+			var body []byte
+			if body, err = open(aead, id, v[4:]); err == nil {
+				return body, keyID, nil
+			}
+		}
+	}
+	if aead, ok := q.keyring[0]; ok {
+		var body []byte
+		if body, err = open(aead, id, v); err == nil {
+			return body, 0, nil
+		}
+	}
+	return nil, 0, fmt.Errorf("lasr: couldn't decrypt message: %s", err)
+}
+
+// open opens the body of message id that was sealed as v with aead. Bodies
+// that were sealed before IDs were authenticated are still accepted.
+func open(aead cipher.AEAD, id, v []byte) ([]byte, error) {
+	if len(v) < aead.NonceSize() {
+		return nil, errors.New("too short")
+	}
+	nonce, ciphertext := v[:aead.NonceSize()], v[aead.NonceSize():]
+	body, err := aead.Open(nil, nonce, ciphertext, id)
+	if err != nil {
+		body, err = aead.Open(nil, nonce, ciphertext, nil)
+	}
+	return body, err
+}
@@ -0,0 +1,167 @@
+package lasr
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var testKey = []byte("0123456789abcdef0123456789abcdef")
+
+func TestEncryption(t *testing.T) {
+	q, cleanup := newQ(t, WithEncryption(testKey), WithDeadLetters())
+	defer cleanup()
+
+	secret := []byte("hunter2")
+	id, err := q.Send(secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := q.Wait(secret, id); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := q.Delay(secret, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+
+	err = q.db.View(func(tx *bolt.Tx) error {
+		return walk(q.db, func(keys [][]byte, k, v []byte, seq uint64) error {
+			if bytes.Contains(v, secret) {
+				t.Errorf("plaintext body found in bucket %q", keys)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	for i := 0; i < 3; i++ {
+		m, err := q.Receive(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(m.Body, secret) {
+			t.Errorf("bad body: got %q, want %q", m.Body, secret)
+		}
+		if i == 0 {
+			if err := m.Nack(false); err != nil {
+				t.Fatal(err)
+			}
+			continue
+		}
+		if err := m.Ack(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	d, err := DeadLetters(q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := d.Receive(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(m.Body, secret) {
+		t.Errorf("bad dead letter: got %q, want %q", m.Body, secret)
+	}
+}
+
+func TestEncryptionWrongKey(t *testing.T) {
+	q, cleanup := newQ(t, WithEncryption(testKey))
+	defer cleanup()
+
+	if _, err := q.Send([]byte("foo")); err != nil {
+		t.Fatal(err)
+	}
+	wrongKey := bytes.Repeat([]byte{'x'}, 32)
+	q, err := NewQ(q.db, "testing", WithEncryption(wrongKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if _, err := q.Receive(ctx); err == nil {
+		t.Fatal("expected an error")
+	}
+}
+
+func TestEncryptionBadKey(t *testing.T) {
+	q, err := NewTempQ("testing", WithEncryption([]byte("short")))
+	if err == nil {
+		q.Close()
+		t.Fatal("expected an error")
+	}
+}
+
+func TestEncryptionSwappedBody(t *testing.T) {
+	q, cleanup := newQ(t, WithEncryption(testKey))
+	defer cleanup()
+
+	a, err := q.Send([]byte("foo"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := q.Send([]byte("bar"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ka, _ := a.MarshalBinary()
+	kb, _ := b.MarshalBinary()
+	err = q.db.Update(func(tx *bolt.Tx) error {
+		ready, err := q.bucket(tx, q.keys.ready)
+		if err != nil {
+			return err
+		}
+		va, vb := cloneBytes(ready.Get(ka)), cloneBytes(ready.Get(kb))
+		if err := ready.Put(ka, vb); err != nil {
+			return err
+		}
+		return ready.Put(kb, va)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if _, err := q.Receive(ctx); err == nil {
+		t.Fatal("expected an error")
+	}
+}
+
+func TestEncryptionWithoutID(t *testing.T) {
+	q, cleanup := newQ(t, WithEncryption(testKey))
+	defer cleanup()
+
+	// Bodies sealed before IDs were authenticated have no additional data.
+	nonce := make([]byte, q.aead.NonceSize())
+	v := q.aead.Seal(nonce, nonce, []byte("foo"), nil)
+	key, _ := Uint64ID(1).MarshalBinary()
+	err := q.db.Update(func(tx *bolt.Tx) error {
+		ready, err := q.bucket(tx, q.keys.ready)
+		if err != nil {
+			return err
+		}
+		return ready.Put(key, v)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	q.waker.Wake()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	m, err := q.Receive(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Ack()
+	if got, want := string(m.Body), "foo"; got != want {
+		t.Errorf("bad body: got %q, want %q", got, want)
+	}
+}
@@ -13,4 +13,58 @@ var (
 	// ErrOptionsApplied is called when an Option is applied to a Q after NewQ
 	// has already returned.
 	ErrOptionsApplied = errors.New("lasr: options cannot be applied after New")
+
+	// ErrQueueFull is returned when a message is sent to a Q that already
+	// holds as many messages as allowed by WithMaxDepth.
+	ErrQueueFull = errors.New("lasr: Q is full")
+
+	// ErrNotFound is returned when a message that is referred to by its ID
+	// does not exist in the expected state.
+	ErrNotFound = errors.New("lasr: message not found")
+
+	// ErrNoSelector is returned when a header that is not a selector is used
+	// as one, see WithSelector.
+	ErrNoSelector = errors.New("lasr: no such selector")
+
+	// ErrLeaseExpired is returned by Ack, Nack and Touch when the message was
+	// nacked because its ack timeout passed, see WithAckTimeout.
+	ErrLeaseExpired = errors.New("lasr: ack timeout expired")
+
+	// ErrReadOnly is returned by the methods that would modify a Q that was
+	// opened read-only, see OpenReadOnly.
+	ErrReadOnly = errors.New("lasr: Q is read-only")
+
+	// ErrNoMessages is returned by ReceiveTimeout when no message arrived in
+	// time.
+	ErrNoMessages = errors.New("lasr: no messages")
+
+	// ErrEmpty is returned by TryReceive when no message is available.
+	ErrEmpty = errors.New("lasr: Q is empty")
+
+	// ErrCorruptMessage is returned when the body of a message doesn't match
+	// its checksum, see WithChecksums.
+	ErrCorruptMessage = errors.New("lasr: message is corrupt")
+
+	// ErrConfigMismatch is returned by NewQ when the options that decide how
+	// messages are stored and delivered, like WithDeadLetters,
+	// WithDeadLetterTTL, WithRetryPolicy, WithEncryption, WithCodec,
+	// WithLIFO, WithMessageGroups and WithPartitions, differ from the ones
+	// the queue was created with. The differences are logged, see WithLogger
+	// and WithReconfigure.
+	ErrConfigMismatch = errors.New("lasr: options don't match the queue")
+
+	// ErrPaused is returned by TryReceive while the Q is paused, see Pause.
+	ErrPaused = errors.New("lasr: Q is paused")
+
+	// ErrLeased is returned by Delete for a message that is Unacked, since
+	// it belongs to the consumer that may still Ack or Nack it.
+	ErrLeased = errors.New("lasr: message is leased")
+
+	// ErrRateLimited is returned by TryReceive when a message can't be
+	// handed out yet, see WithDeliveryRate.
+	ErrRateLimited = errors.New("lasr: delivery rate exceeded")
+
+	// ErrDuplicateID is returned by SendWithID when a message with the same
+	// ID is already in the Q.
+	ErrDuplicateID = errors.New("lasr: duplicate message ID")
 )
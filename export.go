@@ -0,0 +1,325 @@
+package lasr
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// ExportRecord is a message as written by Export and read by Import. Each
+// record is encoded as a single line of JSON:
+//
+//	{"id":"AAAAAAAAAAE=","state":"ready","body":"aGVsbG8=","headers":{"k":"dg=="}}
+//
+// ID, Body, header values and WaitingOn are base64 encoded, as is standard
+// for []byte in encoding/json. State is the String form of the message's
+// Status. WaitingOn holds the IDs of the messages that a Waiting message is
+// still waiting on, and is omitted for messages in any other state.
+// DeadLetterReason and DeadLetteredAt say why and when a dead letter was
+// dead-lettered, if lasr recorded it, and DeadLetterError the error it was
+// nacked with, see NackError.
+type ExportRecord struct {
+	ID               []byte            `json:"id"`
+	State            string            `json:"state"`
+	Body             []byte            `json:"body"`
+	Headers          map[string][]byte `json:"headers,omitempty"`
+	WaitingOn        [][]byte          `json:"waiting_on,omitempty"`
+	DeadLetterReason string            `json:"dead_letter_reason,omitempty"`
+	DeadLetteredAt   *time.Time        `json:"dead_lettered_at,omitempty"`
+	DeadLetterError  string            `json:"dead_letter_error,omitempty"`
+}
+
+// exportStates are the states that Export writes, in order.
+var exportStates = []Status{Ready, Unacked, Delayed, Waiting, Returned}
+
+// Export writes every message in q to w as newline-delimited JSON, one
+// ExportRecord per message, from a consistent snapshot of q. Bodies are
+// written decrypted, even if q was created with WithEncryption.
+func (q *Q) Export(w io.Writer) error {
+	if q.isClosed() {
+		return ErrQClosed
+	}
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	bw := bufio.NewWriter(w)
+	enc := json.NewEncoder(bw)
+	err := q.db.View(func(tx *bolt.Tx) error {
+		for _, state := range exportStates {
+			if err := q.exportState(tx, enc, state); err != nil {
+				return err
+			}
+		}
+		// Messages waiting to be retried are exported as Ready, which
+		// is the state they will return to.
+		if bucket := q.readBucket(tx, q.keys.retrying); bucket != nil {
+			return bucket.ForEach(func(k, v []byte) error {
+				rec, err := q.exportRecord(tx, Ready, k[8:], v)
+				if err != nil {
+					return err
+				}
+				return enc.Encode(rec)
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("lasr: error exporting queue: %s", err)
+	}
+	return bw.Flush()
+}
+
+// DumpDeadLetters writes the dead letters of q to w, in ID order, like
+// Export does, so that they can be handed on, or sent to another Q with
+// Import. Each record has the reason the message was dead-lettered, and when,
+// if lasr recorded it. q must use WithDeadLetters.
+func (q *Q) DumpDeadLetters(w io.Writer) error {
+	if q.isClosed() {
+		return ErrQClosed
+	}
+	if len(q.keys.returned) == 0 {
+		return errors.New("lasr: dead-letters not available")
+	}
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	bw := bufio.NewWriter(w)
+	err := q.db.View(func(tx *bolt.Tx) error {
+		return q.exportState(tx, json.NewEncoder(bw), Returned)
+	})
+	if err != nil {
+		return fmt.Errorf("lasr: error dumping dead letters: %s", err)
+	}
+	return bw.Flush()
+}
+
+// exportState encodes the messages of q in state to enc, as ExportRecords.
+func (q *Q) exportState(tx *bolt.Tx, enc *json.Encoder, state Status) error {
+	bucket := q.readBucket(tx, q.stateBucketKey(state))
+	if bucket == nil {
+		return nil
+	}
+	return bucket.ForEach(func(k, v []byte) error {
+		rec, err := q.exportRecord(tx, state, k, v)
+		if err != nil {
+			return err
+		}
+		return enc.Encode(rec)
+	})
+}
+
+func (q *Q) exportRecord(tx *bolt.Tx, state Status, k, v []byte) (*ExportRecord, error) {
+	body, err := q.openBody(tx, k, v)
+	if err != nil {
+		return nil, err
+	}
+	md, err := q.getMeta(tx, k)
+	if err != nil {
+		return nil, err
+	}
+	rec := &ExportRecord{
+		ID:    cloneBytes(k),
+		State: state.String(),
+		Body:  body,
+	}
+	if md != nil {
+		rec.Headers = md.Headers
+		if state == Returned {
+			rec.DeadLetterReason = md.Reason
+			rec.DeadLetterError = md.Error
+			if md.DeadLettered != 0 {
+				at := time.Unix(0, md.DeadLettered)
+				rec.DeadLetteredAt = &at
+			}
+		}
+	}
+	if state == Waiting {
+		if blockedOn := q.readBucket(tx, q.keys.blockedOn); blockedOn != nil {
+			if on := blockedOn.Bucket(k); on != nil {
+				err := on.ForEach(func(id, _ []byte) error {
+					rec.WaitingOn = append(rec.WaitingOn, cloneBytes(id))
+					return nil
+				})
+				if err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+	return rec, nil
+}
+
+// Import reads messages written by Export from r and adds them to q, in a
+// single transaction. Messages keep their IDs and states, except that
+// Unacked messages are imported as Ready. Import fails without importing
+// anything if any of the IDs is already in use in q.
+//
+// Imported messages do not count towards the Sent counter, and are not
+// subject to WithMaxDepth.
+func (q *Q) Import(r io.Reader) error {
+	if q.isClosed() {
+		return ErrQClosed
+	}
+	if q.readOnly {
+		return ErrReadOnly
+	}
+	var recs []*ExportRecord
+	dec := json.NewDecoder(r)
+	for {
+		var rec ExportRecord
+		if err := dec.Decode(&rec); err == io.EOF {
+			break
+		} else if err != nil {
+			return fmt.Errorf("lasr: error importing queue: %s", err)
+		}
+		recs = append(recs, &rec)
+	}
+	var (
+		wake    bool
+		delayed []time.Time
+	)
+	q.mu.RLock()
+	err := q.db.Update(func(tx *bolt.Tx) error {
+		for _, rec := range recs {
+			state, err := q.importRecord(tx, rec)
+			if err != nil {
+				return fmt.Errorf("message %x: %s", rec.ID, err)
+			}
+			switch state {
+			case Ready:
+				wake = true
+			case Delayed:
+				var when Uint64ID
+				if err := when.UnmarshalBinary(rec.ID); err != nil {
+					return fmt.Errorf("message %x: %s", rec.ID, err)
+				}
+				delayed = append(delayed, time.Unix(0, int64(when)))
+			}
+		}
+		return nil
+	})
+	q.mu.RUnlock()
+	if err != nil {
+		return fmt.Errorf("lasr: error importing queue: %s", err)
+	}
+	if !q.isClosed() {
+		if wake {
+			q.waker.Wake()
+		}
+		for _, when := range delayed {
+			q.waker.WakeAt(when)
+		}
+	}
+	return nil
+}
+
+func (q *Q) importRecord(tx *bolt.Tx, rec *ExportRecord) (Status, error) {
+	state, err := parseStatus(rec.State)
+	if err != nil {
+		return state, err
+	}
+	if state == Unacked {
+		state = Ready
+	}
+	if len(rec.ID) == 0 {
+		return state, fmt.Errorf("id required")
+	}
+	key, err := q.stateKey(state)
+	if err != nil {
+		return state, err
+	}
+	for _, s := range exportStates {
+		if b := q.readBucket(tx, q.stateBucketKey(s)); b != nil && b.Get(rec.ID) != nil {
+			return state, fmt.Errorf("id already in use")
+		}
+	}
+	bucket, err := q.bucket(tx, key)
+	if err != nil {
+		return state, err
+	}
+	body, err := q.sealBody(tx, rec.ID, rec.Body)
+	if err != nil {
+		return state, err
+	}
+	if err := bucket.Put(rec.ID, body); err != nil {
+		return state, err
+	}
+	q.touch(tx, rec.ID)
+	md := &metadata{Headers: rec.Headers}
+	if state == Returned {
+		md.Reason = rec.DeadLetterReason
+		md.Error = rec.DeadLetterError
+		if rec.DeadLetteredAt != nil {
+			md.DeadLettered = rec.DeadLetteredAt.UnixNano()
+		}
+	}
+	if err := q.putMeta(tx, rec.ID, md); err != nil {
+		return state, err
+	}
+	if state == Waiting {
+		if err := q.importWaitingOn(tx, rec.ID, rec.WaitingOn); err != nil {
+			return state, err
+		}
+	}
+	if state != Returned {
+		if err := q.adjustDepth(tx, 1); err != nil {
+			return state, err
+		}
+	}
+	if state != Delayed && q.seq == nil && len(rec.ID) == 8 {
+		// Make sure the default sequencer doesn't hand out the
+		// imported ID again.
+		var id Uint64ID
+		if err := id.UnmarshalBinary(rec.ID); err != nil {
+			return state, err
+		}
+		seq := tx.Bucket(q.seqName)
+		if uint64(id) > seq.Sequence() {
+			if err := seq.SetSequence(uint64(id)); err != nil {
+				return state, err
+			}
+		}
+	}
+	return state, nil
+}
+
+func (q *Q) importWaitingOn(tx *bolt.Tx, id []byte, on [][]byte) error {
+	blockedOn, err := q.bucket(tx, q.keys.blockedOn)
+	if err != nil {
+		return err
+	}
+	blockedMsg, err := blockedOn.CreateBucketIfNotExists(id)
+	if err != nil {
+		return err
+	}
+	blocking, err := q.bucket(tx, q.keys.blocking)
+	if err != nil {
+		return err
+	}
+	for _, blocker := range on {
+		if err := blockedMsg.Put(blocker, nil); err != nil {
+			return err
+		}
+		blockerMsg, err := blocking.CreateBucketIfNotExists(blocker)
+		if err != nil {
+			return err
+		}
+		if err := blockerMsg.Put(id, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// stateBucketKey is like stateKey, but returns nil for states that q doesn't
+// have.
+func (q *Q) stateBucketKey(s Status) []byte {
+	key, err := q.stateKey(s)
+	if err != nil {
+		return nil
+	}
+	return key
+}
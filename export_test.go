@@ -0,0 +1,173 @@
+package lasr
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestExportImport(t *testing.T) {
+	src, cleanup := newQ(t, WithDeadLetters(), WithEncryption(testKey))
+	defer cleanup()
+
+	blocker, err := src.SendWithHeaders([]byte("ready"), map[string][]byte{"k": []byte("v")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := src.Send([]byte("unacked")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := src.Send([]byte("returned")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := src.Wait([]byte("waiting"), blocker); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := src.Delay([]byte("delayed"), time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	// Receive everything up to the message that gets dead-lettered, then
+	// nack the blocker for retry, so that it is Ready again.
+	var received []*Message
+	for i := 0; i < 3; i++ {
+		m, err := src.Receive(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		received = append(received, m)
+	}
+	if err := received[0].Nack(true); err != nil {
+		t.Fatal(err)
+	}
+	if err := received[2].Nack(false); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := src.Export(&buf); err != nil {
+		t.Fatal(err)
+	}
+	states := map[string]string{}
+	scanner := bufio.NewScanner(bytes.NewReader(buf.Bytes()))
+	for scanner.Scan() {
+		var rec ExportRecord
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			t.Fatal(err)
+		}
+		states[string(rec.Body)] = rec.State
+	}
+	want := map[string]string{
+		"ready":    "ready",
+		"unacked":  "unacked",
+		"returned": "returned",
+		"waiting":  "waiting",
+		"delayed":  "delayed",
+	}
+	for body, state := range want {
+		if got := states[body]; got != state {
+			t.Errorf("bad state for %q: got %q, want %q", body, got, state)
+		}
+	}
+
+	dst, cleanup2 := newQ(t, WithDeadLetters())
+	defer cleanup2()
+	if err := dst.Import(bytes.NewReader(buf.Bytes())); err != nil {
+		t.Fatal(err)
+	}
+	if err := dst.Import(bytes.NewReader(buf.Bytes())); err == nil {
+		t.Error("expected error importing the same IDs twice")
+	}
+	stats, err := dst.Stats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Ready != 2 || stats.Delayed != 1 || stats.Waiting != 1 || stats.Returned != 1 {
+		t.Errorf("bad stats after import: %+v", stats)
+	}
+
+	m, err := dst.Receive(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(m.Headers["k"]), "v"; got != want {
+		t.Errorf("bad header: got %q, want %q", got, want)
+	}
+	if err := m.Ack(); err != nil {
+		t.Fatal(err)
+	}
+	stats, err = dst.Stats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Waiting != 0 {
+		t.Error("acking the blocker did not release the waiting message")
+	}
+
+	id, err := dst.Send([]byte("new"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id.(Uint64ID) <= blocker.(Uint64ID) {
+		t.Errorf("sequence reused an imported ID: %d", id)
+	}
+
+	for _, m := range received[1:2] {
+		if err := m.Ack(); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestDumpDeadLetters(t *testing.T) {
+	q, cleanup := newQ(t, WithDeadLetters())
+	defer cleanup()
+	sendBodies(t, q, "a", "b", "ready")
+	for _, msg := range receiveN(t, q, 2) {
+		if err := msg.Nack(false); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := q.DumpDeadLetters(&buf); err != nil {
+		t.Fatal(err)
+	}
+	dump := buf.Bytes()
+	var bodies []string
+	dec := json.NewDecoder(&buf)
+	for dec.More() {
+		var rec ExportRecord
+		if err := dec.Decode(&rec); err != nil {
+			t.Fatal(err)
+		}
+		if rec.State != Returned.String() || rec.DeadLetterReason != DeadLetterNacked || rec.DeadLetteredAt == nil {
+			t.Errorf("bad record: %+v", rec)
+		}
+		bodies = append(bodies, string(rec.Body))
+	}
+	if len(bodies) != 2 || bodies[0] != "a" || bodies[1] != "b" {
+		t.Errorf("got %q, want the dead letters", bodies)
+	}
+
+	other, cleanup2 := newQ(t, WithDeadLetters())
+	defer cleanup2()
+	if err := other.Import(bytes.NewReader(dump)); err != nil {
+		t.Fatal(err)
+	}
+	stats, err := other.Stats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Returned != 2 || stats.Ready != 0 {
+		t.Errorf("imported %d dead letters and %d ready messages, want 2 and 0", stats.Returned, stats.Ready)
+	}
+
+	plain, cleanup3 := newQ(t)
+	defer cleanup3()
+	if err := plain.DumpDeadLetters(&buf); err == nil {
+		t.Error("expected an error without dead letters")
+	}
+}
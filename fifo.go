@@ -1,17 +1,93 @@
 package lasr
 
-import "sync"
+import (
+	"context"
+	"sync"
+)
 
-// fifo is for buffering received messages
+// fifo is for buffering received messages. It is filled up to its limit,
+// which is at most its capacity.
+//
+// fifo is also the lock that Receive holds while it waits for messages. The
+// lock is handed to the goroutines that wait for it in the order they started
+// waiting, so that none of them starves, which a sync.Mutex doesn't promise.
 type fifo struct {
-	data []*Message
-	sync.Mutex
+	data  []*Message
+	limit int
+
+	mu      sync.Mutex
+	held    bool
+	waiters []chan struct{}
 }
 
 func newFifo(size int) *fifo {
 	return &fifo{
-		data: make([]*Message, 0, size),
+		data:  make([]*Message, 0, size),
+		limit: size,
+	}
+}
+
+// Lock waits for the lock of f.
+func (f *fifo) Lock() {
+	f.LockWait(context.Background(), nil)
+}
+
+// LockWait waits for the lock of f, until ctx is done or closed is closed.
+func (f *fifo) LockWait(ctx context.Context, closed <-chan struct{}) error {
+	f.mu.Lock()
+	if !f.held {
+		f.held = true
+		f.mu.Unlock()
+		return nil
 	}
+	turn := make(chan struct{})
+	f.waiters = append(f.waiters, turn)
+	f.mu.Unlock()
+	var err error
+	select {
+	case <-turn:
+		return nil
+	case <-ctx.Done():
+		err = ctx.Err()
+	case <-closed:
+		err = ErrQClosed
+	}
+	f.mu.Lock()
+	for i, w := range f.waiters {
+		if w == turn {
+			f.waiters = append(f.waiters[:i], f.waiters[i+1:]...)
+			f.mu.Unlock()
+			return err
+		}
+	}
+	f.mu.Unlock()
+	// The lock was handed over in the meantime, so pass it on.
+	f.Unlock()
+	return err
+}
+
+// TryLock takes the lock of f if it is free, and reports whether it did.
+func (f *fifo) TryLock() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.held {
+		return false
+	}
+	f.held = true
+	return true
+}
+
+// Unlock hands the lock of f to the goroutine that has waited for it the
+// longest, if any.
+func (f *fifo) Unlock() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if len(f.waiters) == 0 {
+		f.held = false
+		return
+	}
+	close(f.waiters[0])
+	f.waiters = f.waiters[1:]
 }
 
 func (f *fifo) Pop() *Message {
@@ -35,6 +111,30 @@ func (f *fifo) Cap() int {
 	return cap(f.data)
 }
 
+func (f *fifo) Limit() int {
+	return f.limit
+}
+
+// SetLimit sets the limit of f, which is clamped to [1, f.Cap()].
+func (f *fifo) SetLimit(n int) {
+	if n > cap(f.data) {
+		n = cap(f.data)
+	}
+	if n < 1 {
+		n = 1
+	}
+	f.limit = n
+}
+
+// Bytes returns the total size of the bodies of the messages in f.
+func (f *fifo) Bytes() int {
+	var n int
+	for _, m := range f.data {
+		n += len(m.Body)
+	}
+	return n
+}
+
 func (f *fifo) SetError(err error) {
 	for i := range f.data {
 		f.data[i].err = err
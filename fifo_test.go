@@ -1,6 +1,10 @@
 package lasr
 
-import "testing"
+import (
+	"context"
+	"testing"
+	"time"
+)
 
 func TestFifo(t *testing.T) {
 	f := newFifo(5)
@@ -39,3 +43,77 @@ func TestFifoPanicOnFull(t *testing.T) {
 	}()
 	f.Push(nil)
 }
+
+// waiting returns how many goroutines wait for the lock of f.
+func waiting(f *fifo) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.waiters)
+}
+
+func held(f *fifo) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.held
+}
+
+// waitFor waits until the lock of f is held, and n goroutines wait for it.
+func waitFor(t *testing.T, f *fifo, n int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !held(f) || waiting(f) != n {
+		if time.Now().After(deadline) {
+			t.Fatalf("got %d waiters, want %d", waiting(f), n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestFifoLockOrder(t *testing.T) {
+	f := newFifo(1)
+	f.Lock()
+	if f.TryLock() {
+		t.Fatal("TryLock took a held lock")
+	}
+	order := make(chan int, 5)
+	for i := 0; i < 5; i++ {
+		go func(i int) {
+			f.Lock()
+			order <- i
+			f.Unlock()
+		}(i)
+		waitFor(t, f, i+1)
+	}
+	f.Unlock()
+	for i := 0; i < 5; i++ {
+		if got := <-order; got != i {
+			t.Errorf("waiter %d got the lock in turn %d", got, i)
+		}
+	}
+	if !f.TryLock() {
+		t.Fatal("TryLock didn't take a free lock")
+	}
+	f.Unlock()
+}
+
+func TestFifoLockWaitCanceled(t *testing.T) {
+	f := newFifo(1)
+	f.Lock()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := f.LockWait(ctx, nil); err != context.DeadlineExceeded {
+		t.Errorf("got %v, want context.DeadlineExceeded", err)
+	}
+	closed := make(chan struct{})
+	close(closed)
+	if err := f.LockWait(context.Background(), closed); err != ErrQClosed {
+		t.Errorf("got %v, want ErrQClosed", err)
+	}
+	if got := waiting(f); got != 0 {
+		t.Errorf("got %d waiters left, want 0", got)
+	}
+	f.Unlock()
+	if !f.TryLock() {
+		t.Error("lock wasn't released")
+	}
+}
@@ -0,0 +1,179 @@
+package lasr
+
+import (
+	"bytes"
+	"context"
+	"sync/atomic"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// ReceiveWhere is like Receive, but only receives a message whose headers
+// match. Messages that don't match are left in place, in order, for other
+// consumers. If no message matches by the time ctx is done, ReceiveWhere
+// returns ctx.Err().
+//
+// ReceiveWhere scans the Ready messages in order each time it looks for a
+// match, so it is slower than Receive when many messages don't match. It does
+// not see messages that have already been buffered for Receive, see
+// WithMessageBufferSize.
+func (q *Q) ReceiveWhere(ctx context.Context, match func(headers map[string][]byte) bool) (*Message, error) {
+	return q.receiveBy(ctx, q.firstMatch(match))
+}
+
+// firstMatch returns a take function for the first due delayed or Ready
+// message whose headers match.
+func (q *Q) firstMatch(match func(map[string][]byte) bool) func(tx *bolt.Tx, now time.Time) (*Message, error) {
+	return func(tx *bolt.Tx, now time.Time) (*Message, error) {
+		for _, key := range [][]byte{q.keys.delayed, q.keys.ready} {
+			if len(key) == 0 {
+				continue
+			}
+			msg, err := q.takeFirst(tx, key, now, match)
+			if err != nil || msg != nil {
+				return msg, err
+			}
+		}
+		return nil, nil
+	}
+}
+
+// receiveBy receives the message that take moves to the Unacked state,
+// waiting for changes to q until take finds one.
+func (q *Q) receiveBy(ctx context.Context, take func(tx *bolt.Tx, now time.Time) (*Message, error)) (*Message, error) {
+	if q.readOnly {
+		return nil, ErrReadOnly
+	}
+	// The turn to hand out a message is taken up front, since take can't
+	// wait for it once it has moved the message to Unacked.
+	if err := q.pace(ctx); err != nil {
+		return nil, err
+	}
+	for {
+		if err := q.waitResumed(ctx); err != nil {
+			return nil, err
+		}
+		changed := q.waker.Changed()
+		msg, err := q.take(take)
+		if err != nil || msg != nil {
+			return msg, err
+		}
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-q.closed:
+			return nil, ErrQClosed
+		}
+	}
+}
+
+// take runs fn in a transaction, after releasing the retries that are due, and
+// returns the message it moved to the Unacked state, if any.
+func (q *Q) take(fn func(tx *bolt.Tx, now time.Time) (*Message, error)) (*Message, error) {
+	// Count the message as in flight before taking it, so that Close waits
+	// for it, like the messages handed out by Receive.
+	q.closeMu.Lock()
+	if q.isClosed() {
+		q.closeMu.Unlock()
+		return nil, ErrQClosed
+	}
+	q.inFlight.Add(1)
+	q.closeMu.Unlock()
+
+	var msg *Message
+	q.mu.RLock()
+	err := q.db.Update(func(tx *bolt.Tx) (err error) {
+		now := time.Now()
+		if _, err := q.releaseRetries(tx, now); err != nil {
+			return err
+		}
+		if msg, err = fn(tx, now); err != nil || msg == nil {
+			return err
+		}
+		return q.consumeAtOnce(tx, msg)
+	})
+	q.mu.RUnlock()
+	if err != nil || msg == nil || q.atMostOnce {
+		q.inFlight.Done()
+		return msg, err
+	}
+	atomic.AddInt32(&q.outstanding, 1)
+	q.lease(msg)
+	return msg, nil
+}
+
+// takeFirst moves the first message in the bucket at key whose headers match
+// to the Unacked state. Delayed messages are only considered once they are
+// due by now.
+func (q *Q) takeFirst(tx *bolt.Tx, key []byte, now time.Time, match func(map[string][]byte) bool) (*Message, error) {
+	bucket := q.readBucket(tx, key)
+	if bucket == nil {
+		return nil, nil
+	}
+	var until []byte
+	if bytes.Equal(key, q.keys.delayed) {
+		until, _ = Uint64ID(now.UnixNano()).MarshalBinary()
+	}
+	cur := q.cursor(bucket, key)
+	for k, v := cur.First(); k != nil; k, v = cur.Next() {
+		if until != nil && bytes.Compare(k, until) > 0 {
+			return nil, nil
+		}
+		md, err := q.getMeta(tx, k)
+		if err != nil {
+			return nil, err
+		}
+		var headers map[string][]byte
+		if md != nil {
+			headers = md.Headers
+		}
+		if !match(headers) {
+			continue
+		}
+		if busy, err := q.groupBusy(tx, k); err != nil {
+			return nil, err
+		} else if !busy {
+			msg, err := q.deliver(tx, bucket, k, v, now)
+			if err == errExpired {
+				continue
+			}
+			return msg, err
+		}
+	}
+	return nil, nil
+}
+
+// deliver moves the message stored at k in bucket to the Unacked state, and
+// returns it. If the deadline of the message passed, it expires it instead,
+// and returns errExpired.
+func (q *Q) deliver(tx *bolt.Tx, bucket *bolt.Bucket, k, v []byte, now time.Time) (*Message, error) {
+	msg, md, err := q.readMessage(tx, k, v)
+	if err != nil {
+		return nil, err
+	}
+	if q.expired(md, now) {
+		return nil, q.expireOnDelivery(tx, bucket, k)
+	}
+	msg.possiblyDuplicate = q.wasProcessed(tx, msg.ID, now)
+	if err := q.recordDelivery(tx, msg, md, now); err != nil {
+		return nil, err
+	}
+	if err := q.holdGroup(tx, msg.ID, md); err != nil {
+		return nil, err
+	}
+	unacked, err := q.bucket(tx, q.keys.unacked)
+	if err != nil {
+		return nil, err
+	}
+	if err := unacked.Put(msg.ID, v); err != nil {
+		return nil, err
+	}
+	if err := bucket.Delete(msg.ID); err != nil {
+		return nil, err
+	}
+	q.touch(tx, msg.ID)
+	msg.q = q
+	return msg, nil
+}
@@ -0,0 +1,136 @@
+package lasr
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func kindIs(kind string) func(map[string][]byte) bool {
+	return func(headers map[string][]byte) bool {
+		return string(headers["kind"]) == kind
+	}
+}
+
+func TestReceiveWhere(t *testing.T) {
+	q, cleanup := newQ(t)
+	defer cleanup()
+
+	for _, kind := range []string{"a", "b", "a", "b"} {
+		if _, err := q.SendWithHeaders([]byte(kind), map[string][]byte{"kind": []byte(kind)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := q.Send([]byte("none")); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	for i := 0; i < 2; i++ {
+		msg, err := q.ReceiveWhere(ctx, kindIs("b"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := string(msg.Body), "b"; got != want {
+			t.Errorf("bad body: got %q, want %q", got, want)
+		}
+		if got, want := msg.Attempts, 1; got != want {
+			t.Errorf("bad attempts: got %d, want %d", got, want)
+		}
+		if err := msg.Ack(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The messages that did not match are still Ready, in order.
+	for _, want := range []string{"a", "a", "none"} {
+		msg, err := q.Receive(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := string(msg.Body); got != want {
+			t.Errorf("bad body: got %q, want %q", got, want)
+		}
+		if err := msg.Ack(); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestReceiveWhereWaits(t *testing.T) {
+	q, cleanup := newQ(t)
+	defer cleanup()
+
+	if _, err := q.SendWithHeaders([]byte("a"), map[string][]byte{"kind": []byte("a")}); err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		if _, err := q.SendWithHeaders([]byte("b"), map[string][]byte{"kind": []byte("b")}); err != nil {
+			t.Error(err)
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	msg, err := q.ReceiveWhere(ctx, kindIs("b"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(msg.Body), "b"; got != want {
+		t.Errorf("bad body: got %q, want %q", got, want)
+	}
+	if err := msg.Ack(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReceiveWhereDelayed(t *testing.T) {
+	q, cleanup := newQ(t)
+	defer cleanup()
+
+	if _, err := q.Delay([]byte("b"), time.Now().Add(10*time.Millisecond)); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	msg, err := q.ReceiveWhere(ctx, func(map[string][]byte) bool { return true })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(msg.Body), "b"; got != want {
+		t.Errorf("bad body: got %q, want %q", got, want)
+	}
+	if err := msg.Ack(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReceiveWhereTimeout(t *testing.T) {
+	q, cleanup := newQ(t)
+	defer cleanup()
+
+	if _, err := q.Send([]byte("a")); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := q.ReceiveWhere(ctx, kindIs("b")); err != context.DeadlineExceeded {
+		t.Errorf("bad error: got %v, want %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestReceiveWhereClosed(t *testing.T) {
+	q, cleanup := newQ(t)
+	defer cleanup()
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		q.Close()
+	}()
+	if _, err := q.ReceiveWhere(context.Background(), kindIs("b")); err != ErrQClosed {
+		t.Errorf("bad error: got %v, want %v", err, ErrQClosed)
+	}
+}
@@ -0,0 +1,97 @@
+package lasr
+
+import (
+	"bytes"
+	"encoding/binary"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// StoredMessage is a message as it is stored in a Q, returned by Get.
+type StoredMessage struct {
+	ID      []byte
+	State   Status
+	Body    []byte
+	Headers map[string][]byte
+
+	// Attempts is the number of times the message was delivered, and
+	// LastDeliveredAt is when it last was.
+	Attempts        int
+	EnqueuedAt      time.Time
+	LastDeliveredAt time.Time
+
+	// Due is when a Delayed or Retrying message becomes Ready.
+	Due time.Time
+
+	Deadline         time.Time
+	DeadLetterReason string
+	DeadLetteredAt   time.Time
+	DeadLetterError  string
+}
+
+// Get returns the message id, whatever its state, or ErrNotFound if it isn't
+// in q. Its body is read as a whole, even with WithStreamedBodies. Timestamps
+// that lasr didn't record are zero.
+func (q *Q) Get(id []byte) (*StoredMessage, error) {
+	if q.isClosed() {
+		return nil, ErrQClosed
+	}
+	var msg *StoredMessage
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	err := q.db.View(func(tx *bolt.Tx) error {
+		state, v := q.find(tx, id)
+		if v == nil {
+			return ErrNotFound
+		}
+		body, err := q.openBody(tx, id, v)
+		if err != nil {
+			return err
+		}
+		msg = &StoredMessage{ID: cloneBytes(id), State: state, Body: body}
+		switch state {
+		case Delayed:
+			msg.Due = time.Unix(0, int64(binary.BigEndian.Uint64(id)))
+		case Retrying:
+			msg.Due = q.retryDue(tx, id)
+		}
+		md, err := q.getMeta(tx, id)
+		if err != nil || md == nil {
+			return err
+		}
+		msg.Headers = md.Headers
+		msg.Attempts = md.Attempts
+		msg.EnqueuedAt = unixTime(md.Enqueued)
+		msg.LastDeliveredAt = unixTime(md.Delivered)
+		msg.Deadline = unixTime(md.Deadline)
+		msg.DeadLetterReason = md.Reason
+		msg.DeadLetteredAt = unixTime(md.DeadLettered)
+		msg.DeadLetterError = md.Error
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+// retryDue returns when the Retrying message id is due, from its key.
+func (q *Q) retryDue(tx *bolt.Tx, id []byte) time.Time {
+	cur := q.readBucket(tx, q.keys.retrying).Cursor()
+	for k, _ := cur.First(); k != nil; k, _ = cur.Next() {
+		if bytes.Equal(k[8:], id) {
+			return time.Unix(0, int64(binary.BigEndian.Uint64(k[:8])))
+		}
+	}
+	return time.Time{}
+}
+
+// unixTime returns the time of ns nanoseconds since the epoch, or the zero
+// time for 0, which stands for a time that wasn't recorded.
+func unixTime(ns int64) time.Time {
+	if ns == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, ns)
+}
@@ -0,0 +1,71 @@
+package lasr
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestGet(t *testing.T) {
+	q, cleanup := newQ(t, WithEncryption(testKey), WithRetryPolicy(FixedBackoff(time.Hour)))
+	defer cleanup()
+
+	ready, err := q.SendWithHeaders([]byte("ready"), map[string][]byte{"k": []byte("v")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	when := time.Now().Add(time.Hour)
+	delayed, err := q.Delay([]byte("delayed"), when)
+	if err != nil {
+		t.Fatal(err)
+	}
+	retrying, err := q.Send([]byte("retrying"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	readyKey, _ := ready.MarshalBinary()
+	delayedKey, _ := delayed.MarshalBinary()
+	retryingKey, _ := retrying.MarshalBinary()
+
+	msg, err := q.Get(readyKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.State != Ready || string(msg.Body) != "ready" || string(msg.Headers["k"]) != "v" || msg.EnqueuedAt.IsZero() {
+		t.Errorf("bad ready message: %+v", msg)
+	}
+	msg, err = q.Get(delayedKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.State != Delayed || string(msg.Body) != "delayed" || msg.Due.UnixNano() != when.UnixNano() {
+		t.Errorf("bad delayed message: %+v", msg)
+	}
+
+	for {
+		received, err := q.Receive(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(received.Body) == "retrying" {
+			if err := received.Nack(true); err != nil {
+				t.Fatal(err)
+			}
+			break
+		}
+		if err := received.Nack(false); err != nil {
+			t.Fatal(err)
+		}
+	}
+	msg, err = q.Get(retryingKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.State != Retrying || msg.Attempts != 1 || msg.LastDeliveredAt.IsZero() || msg.Due.Before(time.Now().Add(time.Minute)) {
+		t.Errorf("bad retrying message: %+v", msg)
+	}
+
+	if _, err := q.Get(readyKey); err != ErrNotFound {
+		t.Errorf("got %v for a nacked message, want ErrNotFound", err)
+	}
+}
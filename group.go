@@ -0,0 +1,106 @@
+package lasr
+
+import (
+	"bytes"
+	"errors"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// WithMessageGroups groups the messages of q by the value of their header.
+// The messages of a group are delivered one at a time, in order: a message is
+// not delivered while an earlier message of its group is unacked or waiting
+// to be retried. Messages of different groups, and messages without the
+// header, are delivered as usual.
+//
+// Messages that are held back for their group stay Ready, and are skipped by
+// Receive until the group is free. Peek does not skip them.
+func WithMessageGroups(header string) Option {
+	return func(q *Q) error {
+		if q.optsApplied {
+			return ErrOptionsApplied
+		}
+		if header == "" {
+			return errors.New("lasr: group header must not be empty")
+		}
+		q.groupHeader = header
+		q.keys.groups = []byte("groups")
+		return nil
+	}
+}
+
+// groupOf returns the group of the message with md, or nil if it has none.
+func (q *Q) groupOf(md *metadata) []byte {
+	if q.groupHeader == "" || md == nil {
+		return nil
+	}
+	group, ok := md.Headers[q.groupHeader]
+	if !ok {
+		return nil
+	}
+	// Bucket keys can't be empty, so groups are prefixed.
+	return append([]byte{0}, group...)
+}
+
+// groupBusy reports whether the message stored at id belongs to a group that
+// another message holds.
+func (q *Q) groupBusy(tx *bolt.Tx, id []byte) (bool, error) {
+	if q.groupHeader == "" {
+		return false, nil
+	}
+	bucket := q.readBucket(tx, q.keys.groups)
+	if bucket == nil {
+		return false, nil
+	}
+	md, err := q.getMeta(tx, id)
+	if err != nil {
+		return false, err
+	}
+	group := q.groupOf(md)
+	if group == nil {
+		return false, nil
+	}
+	holder := bucket.Get(group)
+	return holder != nil && !bytes.Equal(holder, id), nil
+}
+
+// holdGroup makes the message id, which is about to be delivered, the holder
+// of its group.
+func (q *Q) holdGroup(tx *bolt.Tx, id []byte, md *metadata) error {
+	group := q.groupOf(md)
+	if group == nil {
+		return nil
+	}
+	bucket, err := q.bucket(tx, q.keys.groups)
+	if err != nil {
+		return err
+	}
+	return bucket.Put(group, id)
+}
+
+// freeGroup frees the group that id holds, if any, and wakes q so that the
+// next message of the group can be delivered. It must be called before the
+// metadata of id is deleted.
+func (q *Q) freeGroup(tx *bolt.Tx, id []byte) error {
+	bucket := q.readBucket(tx, q.keys.groups)
+	if bucket == nil {
+		return nil
+	}
+	md, err := q.getMeta(tx, id)
+	if err != nil {
+		return err
+	}
+	group := q.groupOf(md)
+	if group == nil || !bytes.Equal(bucket.Get(group), id) {
+		return nil
+	}
+	if err := bucket.Delete(group); err != nil {
+		return err
+	}
+	// Receives wait for this transaction to commit before they look for
+	// messages, so q can be woken up already.
+	if !q.isClosed() {
+		q.waker.Wake()
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+package lasr
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func sendGroup(t *testing.T, q *Q, group, body string) {
+	t.Helper()
+	if _, err := q.SendWithHeaders([]byte(body), map[string][]byte{"group": []byte(group)}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMessageGroups(t *testing.T) {
+	q, cleanup := newQ(t, WithMessageGroups("group"), WithMessageBufferSize(10))
+	defer cleanup()
+
+	sendGroup(t, q, "a", "a1")
+	sendGroup(t, q, "a", "a2")
+	sendGroup(t, q, "b", "b1")
+	if _, err := q.Send([]byte("none")); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	var got []*Message
+	for i := 0; i < 3; i++ {
+		msg, err := q.Receive(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, msg)
+	}
+	for i, want := range []string{"a1", "b1", "none"} {
+		if string(got[i].Body) != want {
+			t.Errorf("bad body %d: got %q, want %q", i, got[i].Body, want)
+		}
+	}
+
+	// a2 is held back until a1 is done with, even when a1 is retried.
+	short, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := q.Receive(short); err != context.DeadlineExceeded {
+		t.Fatalf("bad error: got %v, want %v", err, context.DeadlineExceeded)
+	}
+	if err := got[0].Nack(true); err != nil {
+		t.Fatal(err)
+	}
+	msg, err := q.Receive(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(msg.Body), "a1"; got != want {
+		t.Errorf("bad body: got %q, want %q", got, want)
+	}
+	if err := msg.Ack(); err != nil {
+		t.Fatal(err)
+	}
+	msg, err = q.Receive(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(msg.Body), "a2"; got != want {
+		t.Errorf("bad body: got %q, want %q", got, want)
+	}
+	for _, msg := range append(got[1:], msg) {
+		if err := msg.Ack(); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestMessageGroupsDeadLetter(t *testing.T) {
+	q, cleanup := newQ(t, WithMessageGroups("group"), WithDeadLetters())
+	defer cleanup()
+
+	sendGroup(t, q, "a", "a1")
+	sendGroup(t, q, "a", "a2")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	msg, err := q.Receive(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := msg.Nack(false); err != nil {
+		t.Fatal(err)
+	}
+	msg, err = q.ReceiveWhere(ctx, func(map[string][]byte) bool { return true })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(msg.Body), "a2"; got != want {
+		t.Errorf("bad body: got %q, want %q", got, want)
+	}
+	if err := msg.Ack(); err != nil {
+		t.Fatal(err)
+	}
+}
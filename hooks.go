@@ -0,0 +1,89 @@
+package lasr
+
+// Hooks are called when messages change state, after the change has been
+// committed. They are called by the goroutine that made the change, so they
+// should return quickly, and must not wait for q.
+//
+// Each hook is optional, and receives the ID of the message, apart from
+// OnDeadLetterExpired and OnSweep.
+type Hooks struct {
+	// OnSend is called when a message is sent, delayed or made to wait.
+	// It is not called for duplicates that SendDedup discards.
+	OnSend func(id []byte)
+
+	// OnAck is called when a message is acked.
+	OnAck func(id []byte)
+
+	// OnNack is called when a message is nacked, and reports whether it
+	// will be retried.
+	OnNack func(id []byte, retry bool)
+
+	// OnDeadLetter is called when a message is moved to the dead letters,
+	// after OnNack.
+	OnDeadLetter func(id []byte)
+
+	// OnExpire is called when the ack timeout of a received message passes,
+	// before the message is nacked for retry and OnNack is called, see
+	// WithAckTimeout.
+	OnExpire func(id []byte)
+
+	// OnDeadLetterExpired is called with each dead letter that was deleted
+	// because it outlived the TTL set by WithDeadLetterTTL, so that it can
+	// be archived. Dead letters whose bodies can't be read are deleted
+	// without it.
+	OnDeadLetterExpired func(rec ExportRecord)
+
+	// OnSweep is called after each run of a background task of q, from
+	// the goroutine that runs them, see WithMaintenanceInterval.
+	OnSweep func(s Sweep)
+}
+
+// WithHooks sets the hooks that q calls when messages change state.
+func WithHooks(hooks Hooks) Option {
+	return func(q *Q) error {
+		if q.optsApplied {
+			return ErrOptionsApplied
+		}
+		q.hooks = hooks
+		return nil
+	}
+}
+
+func (q *Q) onSend(id ID) {
+	if q.hooks.OnSend == nil {
+		return
+	}
+	if b, err := id.MarshalBinary(); err == nil {
+		q.hooks.OnSend(b)
+	}
+}
+
+func (q *Q) onAck(id []byte) {
+	if q.hooks.OnAck != nil {
+		q.hooks.OnAck(id)
+	}
+}
+
+func (q *Q) onNack(id []byte, retry bool) {
+	if q.hooks.OnNack != nil {
+		q.hooks.OnNack(id, retry)
+	}
+}
+
+func (q *Q) onDeadLetter(id []byte) {
+	if q.hooks.OnDeadLetter != nil {
+		q.hooks.OnDeadLetter(id)
+	}
+}
+
+func (q *Q) onExpire(id []byte) {
+	if q.hooks.OnExpire != nil {
+		q.hooks.OnExpire(id)
+	}
+}
+
+func (q *Q) onSweep(s Sweep) {
+	if q.hooks.OnSweep != nil {
+		q.hooks.OnSweep(s)
+	}
+}
@@ -0,0 +1,116 @@
+package lasr
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+type hookLog struct {
+	sync.Mutex
+	events []string
+}
+
+func (l *hookLog) add(event string, id []byte) {
+	l.Lock()
+	defer l.Unlock()
+	l.events = append(l.events, fmt.Sprintf("%s %x", event, id))
+}
+
+func (l *hookLog) hooks() Hooks {
+	return Hooks{
+		OnSend:       func(id []byte) { l.add("send", id) },
+		OnAck:        func(id []byte) { l.add("ack", id) },
+		OnNack:       func(id []byte, retry bool) { l.add(fmt.Sprint("nack ", retry), id) },
+		OnDeadLetter: func(id []byte) { l.add("deadletter", id) },
+		OnExpire:     func(id []byte) { l.add("expire", id) },
+	}
+}
+
+func (l *hookLog) get() []string {
+	l.Lock()
+	defer l.Unlock()
+	return append([]string(nil), l.events...)
+}
+
+func TestHooks(t *testing.T) {
+	var log hookLog
+	q, cleanup := newQ(t, WithHooks(log.hooks()), WithDeadLetters())
+	defer cleanup()
+
+	for i := 0; i < 3; i++ {
+		if _, err := q.Send([]byte("foo")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	msg, err := q.Receive(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := msg.Ack(); err != nil {
+		t.Fatal(err)
+	}
+	msg, err = q.Receive(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := msg.Nack(false); err != nil {
+		t.Fatal(err)
+	}
+	msg, err = q.Receive(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := msg.Nack(true); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		"send 0000000000000001",
+		"send 0000000000000002",
+		"send 0000000000000003",
+		"ack 0000000000000001",
+		"nack false 0000000000000002",
+		"deadletter 0000000000000002",
+		"nack true 0000000000000003",
+	}
+	if got := log.get(); !reflect.DeepEqual(got, want) {
+		t.Errorf("bad events: got %q, want %q", got, want)
+	}
+}
+
+func TestHooksExpire(t *testing.T) {
+	var log hookLog
+	q, cleanup := newQ(t, WithHooks(log.hooks()), WithAckTimeout(10*time.Millisecond))
+	defer cleanup()
+
+	if _, err := q.Send([]byte("foo")); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if _, err := q.Receive(ctx); err != nil {
+		t.Fatal(err)
+	}
+	msg, err := q.Receive(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := msg.Ack(); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"send 0000000000000001",
+		"expire 0000000000000001",
+		"nack true 0000000000000001",
+		"ack 0000000000000001",
+	}
+	if got := log.get(); !reflect.DeepEqual(got, want) {
+		t.Errorf("bad events: got %q, want %q", got, want)
+	}
+}
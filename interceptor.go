@@ -0,0 +1,126 @@
+package lasr
+
+import "context"
+
+// SendFunc sends a message with headers, like Q.SendWithHeaders.
+type SendFunc func(body []byte, headers map[string][]byte) (ID, error)
+
+// ReceiveFunc receives a message, like Q.Receive.
+type ReceiveFunc func(ctx context.Context) (*Message, error)
+
+// Interceptor wraps the sending, receiving, acking and nacking of messages,
+// like the interceptors of gRPC, so that metrics, tracing, validation or
+// transformations of messages can be added to a Q without changing it. Each
+// function is optional, and calls next to carry on, or returns without
+// calling it to stop.
+//
+// Send sees the messages sent with Send and SendWithHeaders, and with the
+// methods built on them, like SendJSON and TypedQ.Send. Receive sees
+// Receive, ReceiveTimeout and Consumer.Receive. Ack and Nack see Message.Ack,
+// Message.Nack and Message.NackDelay, whose retry is true, and each message of
+// AckBatch, NackBatch and AckUpTo. The messages of a batch are still acked or
+// nacked in one transaction, once every one of them has been through the
+// interceptors, so next returns the result of the whole batch. A message that
+// an interceptor stops is left out of the batch, and stays unacked.
+type Interceptor struct {
+	Send    func(body []byte, headers map[string][]byte, next SendFunc) (ID, error)
+	Receive func(ctx context.Context, next ReceiveFunc) (*Message, error)
+	Ack     func(msg *Message, next func() error) error
+	Nack    func(msg *Message, retry bool, next func() error) error
+}
+
+// WithInterceptor adds i to the interceptors of q. The interceptors are
+// called in the order they were added, so the first one added sees the calls
+// first, and their results last.
+func WithInterceptor(i Interceptor) Option {
+	return func(q *Q) error {
+		if q.optsApplied {
+			return ErrOptionsApplied
+		}
+		q.interceptors = append(q.interceptors, i)
+		return nil
+	}
+}
+
+func (q *Q) interceptSend(body []byte, headers map[string][]byte, send SendFunc) (ID, error) {
+	for i := len(q.interceptors) - 1; i >= 0; i-- {
+		if f := q.interceptors[i].Send; f != nil {
+			next := send
+			send = func(body []byte, headers map[string][]byte) (ID, error) {
+				return f(body, headers, next)
+			}
+		}
+	}
+	return send(body, headers)
+}
+
+func (q *Q) interceptReceive(ctx context.Context, receive ReceiveFunc) (*Message, error) {
+	for i := len(q.interceptors) - 1; i >= 0; i-- {
+		if f := q.interceptors[i].Receive; f != nil {
+			next := receive
+			receive = func(ctx context.Context) (*Message, error) {
+				return f(ctx, next)
+			}
+		}
+	}
+	return receive(ctx)
+}
+
+func (q *Q) interceptAck(msg *Message, ack func() error) error {
+	for i := len(q.interceptors) - 1; i >= 0; i-- {
+		if f := q.interceptors[i].Ack; f != nil {
+			next := ack
+			ack = func() error {
+				return f(msg, next)
+			}
+		}
+	}
+	return ack()
+}
+
+func (q *Q) interceptNack(msg *Message, retry bool, nack func() error) error {
+	for i := len(q.interceptors) - 1; i >= 0; i-- {
+		if f := q.interceptors[i].Nack; f != nil {
+			next := nack
+			nack = func() error {
+				return f(msg, retry, next)
+			}
+		}
+	}
+	return nack()
+}
+
+// interceptBatch runs each of msgs, which were finished by finishReceived,
+// through intercept, and then finishes the ones that the interceptors let
+// through with finish. The messages that the interceptors stop are reopened.
+// The error is that of the first interceptor to fail, or else of finish.
+func (q *Q) interceptBatch(msgs []*Message, intercept func(msg *Message, next func() error) error, finish func([]*Message) error) error {
+	if len(q.interceptors) == 0 {
+		return finish(msgs)
+	}
+	var chosen []*Message
+	var run func(i int) error
+	run = func(i int) error {
+		if i == len(msgs) {
+			return finish(chosen)
+		}
+		msg := msgs[i]
+		var called bool
+		err := intercept(msg, func() error {
+			if called {
+				return ErrAckNack
+			}
+			called = true
+			chosen = append(chosen, msg)
+			return run(i + 1)
+		})
+		if !called {
+			msg.reopen()
+			if rest := run(i + 1); err == nil {
+				err = rest
+			}
+		}
+		return err
+	}
+	return run(0)
+}
@@ -0,0 +1,158 @@
+package lasr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestInterceptors(t *testing.T) {
+	var calls []string
+	logged := func(name string) Interceptor {
+		return Interceptor{
+			Send: func(body []byte, headers map[string][]byte, next SendFunc) (ID, error) {
+				calls = append(calls, name+" send")
+				return next(body, headers)
+			},
+			Receive: func(ctx context.Context, next ReceiveFunc) (*Message, error) {
+				calls = append(calls, name+" receive")
+				return next(ctx)
+			},
+			Ack: func(msg *Message, next func() error) error {
+				calls = append(calls, name+" ack")
+				return next()
+			},
+			Nack: func(msg *Message, retry bool, next func() error) error {
+				calls = append(calls, name+" nack")
+				return next()
+			},
+		}
+	}
+	errEmpty := errors.New("empty message")
+	validate := Interceptor{
+		Send: func(body []byte, headers map[string][]byte, next SendFunc) (ID, error) {
+			if len(body) == 0 {
+				return nil, errEmpty
+			}
+			if headers == nil {
+				headers = make(map[string][]byte)
+			}
+			headers["validated"] = []byte("yes")
+			return next(body, headers)
+		},
+		Receive: func(ctx context.Context, next ReceiveFunc) (*Message, error) {
+			msg, err := next(ctx)
+			if err == nil {
+				msg.Body = append([]byte("seen: "), msg.Body...)
+			}
+			return msg, err
+		},
+	}
+	q, cleanup := newQ(t, WithInterceptor(logged("outer")), WithInterceptor(validate), WithInterceptor(logged("inner")))
+	defer cleanup()
+
+	if _, err := q.Send(nil); err != errEmpty {
+		t.Fatalf("got %v, want errEmpty", err)
+	}
+	sendBodies(t, q, "a", "b")
+	msgs := receiveN(t, q, 2)
+	if got, want := string(msgs[0].Body), "seen: a"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if got := string(msgs[0].Headers["validated"]); got != "yes" {
+		t.Errorf("got header %q, want %q", got, "yes")
+	}
+	if err := msgs[0].Ack(); err != nil {
+		t.Fatal(err)
+	}
+	if err := msgs[1].Nack(false); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		"outer send",
+		"outer send", "inner send",
+		"outer send", "inner send",
+		"outer receive", "inner receive",
+		"outer receive", "inner receive",
+		"outer ack", "inner ack",
+		"outer nack", "inner nack",
+	}
+	if len(calls) != len(want) {
+		t.Fatalf("got calls %q, want %q", calls, want)
+	}
+	for i := range want {
+		if calls[i] != want[i] {
+			t.Fatalf("got calls %q, want %q", calls, want)
+		}
+	}
+}
+
+func TestInterceptorStopsAck(t *testing.T) {
+	errRefused := errors.New("refused")
+	q, cleanup := newQ(t, WithInterceptor(Interceptor{
+		Ack: func(msg *Message, next func() error) error {
+			if string(msg.Body) == "keep" {
+				return errRefused
+			}
+			return next()
+		},
+	}))
+	defer cleanup()
+	sendBodies(t, q, "keep")
+	msg := receiveN(t, q, 1)[0]
+	if err := msg.Ack(); err != errRefused {
+		t.Fatalf("got %v, want errRefused", err)
+	}
+	// The message is still unacked.
+	if err := msg.Nack(false); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestInterceptorBatches(t *testing.T) {
+	errRefused := errors.New("refused")
+	var acks, nacks []string
+	q, cleanup := newQ(t, WithInterceptor(Interceptor{
+		Ack: func(msg *Message, next func() error) error {
+			acks = append(acks, string(msg.Body))
+			if string(msg.Body) == "keep" {
+				return errRefused
+			}
+			return next()
+		},
+		Nack: func(msg *Message, retry bool, next func() error) error {
+			nacks = append(nacks, string(msg.Body))
+			return next()
+		},
+	}))
+	defer cleanup()
+	sendBodies(t, q, "a", "keep", "b", "c", "d")
+	msgs := receiveN(t, q, 5)
+	if err := q.AckBatch(msgs[0].ID, msgs[1].ID); err != errRefused {
+		t.Fatalf("got %v, want errRefused", err)
+	}
+	if n, err := q.AckUpTo(msgs[2].ID); err != errRefused || n != 1 {
+		t.Fatalf("got %d, %v, want 1, errRefused", n, err)
+	}
+	if err := q.NackBatch([][]byte{msgs[1].ID, msgs[3].ID}, false); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := q.AckUpTo(msgs[4].ID); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := fmt.Sprint(acks), "[a keep keep b d]"; got != want {
+		t.Errorf("got acks %s, want %s", got, want)
+	}
+	if got, want := fmt.Sprint(nacks), "[keep c]"; got != want {
+		t.Errorf("got nacks %s, want %s", got, want)
+	}
+	stats, err := q.Stats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Unacked != 0 || stats.Ready != 0 {
+		t.Errorf("got %d unacked and %d ready, want none", stats.Unacked, stats.Ready)
+	}
+}
@@ -0,0 +1,90 @@
+package lasr
+
+import (
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// Lag tells how far behind the consumers of a Q are. Depth alone doesn't
+// tell whether consumers are keeping up: a deep queue can be drained in time,
+// and a shallow one can hold a message that has waited for hours.
+type Lag struct {
+	// OldestReady is how long ago the oldest Ready message was sent, or 0
+	// if there are no Ready messages. If it keeps growing, consumers are
+	// falling behind.
+	OldestReady time.Duration
+
+	// OldestUnacked is how long ago the Unacked message that was delivered
+	// first was delivered, or 0 if there are no Unacked messages. If it
+	// keeps growing, a consumer is stuck.
+	OldestUnacked time.Duration
+}
+
+// Lag returns the age of the oldest Ready message and of the oldest Unacked
+// message of q. Messages sent before lasr recorded when messages were sent
+// don't count.
+func (q *Q) Lag() (Lag, error) {
+	var lag Lag
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	err := q.db.View(func(tx *bolt.Tx) (err error) {
+		now := time.Now()
+		if lag.OldestReady, err = q.oldestReady(tx, now); err != nil {
+			return err
+		}
+		lag.OldestUnacked, err = q.oldestUnacked(tx, now)
+		return err
+	})
+	return lag, err
+}
+
+// oldestReady returns the age of the first Ready message. Messages are Ready
+// in the order of their IDs, so it is the one that has waited the longest.
+func (q *Q) oldestReady(tx *bolt.Tx, now time.Time) (time.Duration, error) {
+	bucket := q.readBucket(tx, q.keys.ready)
+	if bucket == nil {
+		return 0, nil
+	}
+	k, _ := bucket.Cursor().First()
+	if k == nil {
+		return 0, nil
+	}
+	md, err := q.getMeta(tx, k)
+	if err != nil || md == nil || md.Enqueued == 0 {
+		return 0, err
+	}
+	return age(now, md.Enqueued), nil
+}
+
+// oldestUnacked returns the time since the earliest delivery of the Unacked
+// messages.
+func (q *Q) oldestUnacked(tx *bolt.Tx, now time.Time) (time.Duration, error) {
+	bucket := q.readBucket(tx, q.keys.unacked)
+	if bucket == nil {
+		return 0, nil
+	}
+	var oldest int64
+	err := bucket.ForEach(func(k, _ []byte) error {
+		md, err := q.getMeta(tx, k)
+		if err != nil || md == nil || md.Delivered == 0 {
+			return err
+		}
+		if oldest == 0 || md.Delivered < oldest {
+			oldest = md.Delivered
+		}
+		return nil
+	})
+	if err != nil || oldest == 0 {
+		return 0, err
+	}
+	return age(now, oldest), nil
+}
+
+// age returns the time from t, in nanoseconds since the epoch, to now.
+func age(now time.Time, t int64) time.Duration {
+	if d := now.Sub(time.Unix(0, t)); d > 0 {
+		return d
+	}
+	return 0
+}
@@ -0,0 +1,46 @@
+package lasr
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLag(t *testing.T) {
+	q, cleanup := newQ(t)
+	defer cleanup()
+
+	lag, err := q.Lag()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lag != (Lag{}) {
+		t.Errorf("bad lag on empty queue: %+v", lag)
+	}
+
+	sendBodies(t, q, "a", "b")
+	time.Sleep(20 * time.Millisecond)
+	msg := receiveN(t, q, 1)[0]
+	time.Sleep(20 * time.Millisecond)
+
+	lag, err = q.Lag()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lag.OldestReady < 40*time.Millisecond {
+		t.Errorf("bad oldest ready: %s", lag.OldestReady)
+	}
+	if lag.OldestUnacked < 20*time.Millisecond || lag.OldestUnacked >= lag.OldestReady {
+		t.Errorf("bad oldest unacked: %s", lag.OldestUnacked)
+	}
+
+	if err := msg.Ack(); err != nil {
+		t.Fatal(err)
+	}
+	lag, err = q.Lag()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lag.OldestUnacked != 0 {
+		t.Errorf("bad oldest unacked after ack: %s", lag.OldestUnacked)
+	}
+}
@@ -1,11 +1,16 @@
 package lasr
 
 import (
+	"crypto/cipher"
+	"errors"
 	"fmt"
+	"log/slog"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	bolt "go.etcd.io/bbolt"
+	"golang.org/x/time/rate"
 )
 
 // Q is a persistent message queue. Its methods are goroutine-safe.
@@ -15,39 +20,231 @@ type Q struct {
 	db          *bolt.DB
 	name        []byte
 	seq         Sequencer
+	seqName     []byte
 	keys        bucketKeys
 	messages    *fifo
 	closed      chan struct{}
+	closeMu     sync.Mutex
 	inFlight    sync.WaitGroup
+	outstanding int32
+	abandoned   int32
 	waker       *waker
 	optsApplied bool
 	mu          sync.RWMutex
+	aead        cipher.AEAD
+
+	// keyring holds every encryption key of q by ID, and keyID is the ID
+	// of aead, the one that bodies are sealed with.
+	keyring map[uint32]cipher.AEAD
+	keyID   uint32
+
+	// limiter paces the messages q hands out, see WithDeliveryRate.
+	limiter *rate.Limiter
+
+	// writes coalesces concurrent sends into shared transactions.
+	writes committer
+
+	// shared tracks the other queues that use db.
+	shared *sharedDB
+
+	dedupWindow  time.Duration
+	retryPolicy  RetryPolicy
+	syncPolicy   SyncPolicy
+	adaptive     *AdaptiveBuffer
+	selectors    []string
+	codec        Codec
+	chunkSize    int
+	lifo         bool
+	groupHeader  string
+	prefetch     int
+	reconfigure  bool
+	storedConfig bool
+
+	// streamBodies leaves chunked bodies for Message.Open to read.
+	streamBodies bool
+
+	checksums          bool
+	corruptDeadLetters bool
+
+	// blobs, if set, holds the bodies that are larger than blobThreshold.
+	blobs         BlobStore
+	blobThreshold int
+
+	// leases holds the received messages that are not acked or nacked
+	// yet, with their deadlines when q has an ack timeout.
+	ackTimeout time.Duration
+	leases     map[*Message]time.Time
+	leaseMu    sync.Mutex
+
+	hooks        Hooks
+	interceptors []Interceptor
+
+	onHandlerError HandlerPolicy
+	onHandlerPanic HandlerPolicy
+
+	// atMostOnce is set by WithDeliveryMode.
+	atMostOnce bool
+
+	ledgerWindow time.Duration
+
+	// partitions is set by WithPartitions, and parts holds the partitions
+	// of q that were opened with Partition.
+	partitions int
+	parts      map[int]*Q
+	partsMu    sync.Mutex
+
+	// auditing is set by WithAuditLog, along with the actor it records.
+	auditing   bool
+	auditActor string
+	log        *slog.Logger
+
+	// boltOptions are the options that OpenQ opens the database with.
+	boltOptions *bolt.Options
+	readOnly    bool
+	watermarks  *watermarks
+
+	autoCompact     int64
+	autoCompactDone chan struct{}
+
+	deadLetterTTL time.Duration
+
+	// maintenanceInterval is set by WithMaintenanceInterval. The
+	// maintenance loop is stopped by closing maintenanceStop.
+	maintenanceInterval time.Duration
+	maintenanceStop     chan struct{}
+	maintenanceDone     chan struct{}
+	// dueCounted is the key of the last Delayed message that TaskDue
+	// counted as due. It is only used by the maintenance loop.
+	dueCounted []byte
+
+	// deadLetterQueue names the queue that dead letters are sent to, see
+	// WithDeadLetterQueue.
+	deadLetterQueue []byte
+
+	// catchUp is set by WithScheduleCatchUp. The schedule loop is started
+	// under closeMu, once q has schedules.
+	catchUp       CatchUpPolicy
+	schedulesWake chan struct{}
+	schedulesDone chan struct{}
+
+	archiveLimit uint64
+	archiveTTL   time.Duration
+
+	// repl, if set, streams the changes to q to its replicas.
+	repl *replication
+	// wal, if set, is the write-ahead log that sends are appended to.
+	wal *wal
+
+	recovery  Recovery
+	recovered int
+	// kept holds the IDs of the messages that RecoverNone left Unacked.
+	kept     map[string]bool
+	syncDone chan struct{}
+
+	maxDepth      uint64
+	blockWhenFull bool
+	space         chan struct{}
+	spaceMu       sync.Mutex
+
+	// subs are the open subscriptions of q.
+	subs   map[string]*openSubscription
+	subsMu sync.Mutex
+
+	// resumed is closed by Resume, and is nil unless q is paused.
+	resumed chan struct{}
+	pauseMu sync.Mutex
+
+	// release, if set, frees resources that q owns once it is closed.
+	release func() error
 }
 
 type bucketKeys struct {
-	ready     []byte
-	returned  []byte
-	unacked   []byte
-	delayed   []byte
-	waiting   []byte
-	blockedOn []byte
-	blocking  []byte
+	ready         []byte
+	returned      []byte
+	unacked       []byte
+	delayed       []byte
+	waiting       []byte
+	blockedOn     []byte
+	blocking      []byte
+	counters      []byte
+	meta          []byte
+	subscriptions []byte
+	dedup         []byte
+	retrying      []byte
+	selectors     []byte
+	groups        []byte
+	archive       []byte
+}
+
+func defaultKeys() bucketKeys {
+	return bucketKeys{
+		ready:         []byte("ready"),
+		unacked:       []byte("unacked"),
+		delayed:       []byte("delayed"),
+		waiting:       []byte("waiting"),
+		blockedOn:     []byte("blockedOn"),
+		blocking:      []byte("blocking"),
+		counters:      []byte("counters"),
+		meta:          []byte("meta"),
+		subscriptions: []byte("subscriptions"),
+		dedup:         []byte("dedup"),
+		retrying:      []byte("retrying"),
+		selectors:     []byte("selectors"),
+	}
 }
 
 // Close closes q. When q is closed, Send, Receive, and Close will return
 // ErrQClosed. Close blocks until all messages in the "unacked" state are Acked
 // or Nacked.
 func (q *Q) Close() error {
-	q.messages.Lock()
-	defer q.messages.Unlock()
+	return q.shutdown(func() error {
+		q.inFlight.Wait()
+		return nil
+	})
+}
+
+// shutdown closes q, after calling drain to wait for in-flight messages.
+func (q *Q) shutdown(drain func() error) error {
+	// Close q before taking the messages lock, which is held by consumers
+	// that are blocked in Receive until q is closed.
+	q.closeMu.Lock()
 	select {
 	case <-q.closed:
+		q.closeMu.Unlock()
 		return ErrQClosed
 	default:
 		close(q.closed)
 	}
-	q.inFlight.Wait()
-	return q.equilibrate()
+	q.closeMu.Unlock()
+	q.messages.Lock()
+	defer q.messages.Unlock()
+	err := drain()
+	if err != nil {
+		// Messages that are still in flight will be returned to Ready,
+		// so they can no longer be acked or nacked.
+		atomic.StoreInt32(&q.abandoned, 1)
+	}
+	if werr := q.stopWAL(); err == nil {
+		err = werr
+	}
+	q.stopMaintenance()
+	q.stopAutoCompact()
+	q.stopSchedules()
+	if !q.readOnly {
+		if eerr := q.equilibrate(); err == nil {
+			err = eerr
+		}
+	}
+	if serr := q.stopSync(); err == nil {
+		err = serr
+	}
+	q.shared.unregister(q)
+	if q.release != nil {
+		if rerr := q.release(); err == nil {
+			err = rerr
+		}
+	}
+	return err
 }
 
 func (q *Q) isClosed() bool {
@@ -59,46 +256,125 @@ func (q *Q) isClosed() bool {
 	}
 }
 
+// Name returns the name that q was created with.
+func (q *Q) Name() string {
+	return string(q.name)
+}
+
 func (q *Q) String() string {
 	return fmt.Sprintf("Q{Name: %q}", string(q.name))
 }
 
-// NewQ creates a new Q. Only one queue should be created in a given bolt db,
-// unless compaction is disabled.
+// NewQ creates a new Q.
+//
+// Several queues can share a bolt db, as long as their names are different.
+// Each queue keeps its messages, sequence and dead letters in its own bucket,
+// named after the queue, and is woken independently of the others. Creating
+// two queues with the same name in the same db at the same time is not
+// supported.
 func NewQ(db *bolt.DB, name string, options ...Option) (*Q, error) {
+	q, err := configure(name, options)
+	if err != nil {
+		return nil, err
+	}
+	if q.boltOptions != nil {
+		return nil, errors.New("lasr: couldn't create Q: WithBoltOptions requires OpenQ")
+	}
+	if err := q.open(db); err != nil {
+		return nil, err
+	}
+	return q, nil
+}
+
+// configure creates a Q with options applied, that is not backed by a database
+// yet.
+func configure(name string, options []Option) (*Q, error) {
 	bName := []byte(name)
 	closed := make(chan struct{})
 	q := &Q{
-		db:   db,
-		name: bName,
-		keys: bucketKeys{
-			ready:     []byte("ready"),
-			unacked:   []byte("unacked"),
-			delayed:   []byte("delayed"),
-			waiting:   []byte("waiting"),
-			blockedOn: []byte("blockedOn"),
-			blocking:  []byte("blocking"),
-		},
-		waker:  newWaker(closed),
-		closed: closed,
+		name:    bName,
+		seqName: bName,
+		keys:    defaultKeys(),
+		waker:   newWaker(closed),
+		closed:  closed,
+		leases:  make(map[*Message]time.Time),
 	}
 	for _, o := range options {
 		if err := o(q); err != nil {
 			return nil, fmt.Errorf("lasr: couldn't create Q: %s", err)
 		}
 	}
+	if string(q.deadLetterQueue) == name {
+		return nil, errors.New("lasr: couldn't create Q: WithDeadLetterQueue can't name the queue itself")
+	}
+	if q.deadLetterTTL > 0 && len(q.keys.returned) == 0 {
+		return nil, errors.New("lasr: couldn't create Q: WithDeadLetterTTL requires WithDeadLetters")
+	}
+	if (q.onHandlerError == HandlerDeadLetter || q.onHandlerPanic == HandlerDeadLetter) && len(q.keys.returned) == 0 {
+		return nil, errors.New("lasr: couldn't create Q: HandlerDeadLetter requires WithDeadLetters")
+	}
+	if err := q.checkDeliveryMode(); err != nil {
+		return nil, fmt.Errorf("lasr: couldn't create Q: %s", err)
+	}
+	if (q.archiveLimit > 0 || q.archiveTTL > 0) && len(q.keys.archive) == 0 {
+		return nil, errors.New("lasr: couldn't create Q: WithArchiveLimit and WithArchiveTTL require WithArchive")
+	}
 	q.optsApplied = true
+	return q, nil
+}
+
+// open starts q on db.
+func (q *Q) open(db *bolt.DB) error {
+	q.db = db
+	q.shared = register(q, db)
+	q.readOnly = db.IsReadOnly()
+	if err := q.loadSequencer(); err != nil {
+		q.shared.unregister(q)
+		return err
+	}
+	if err := q.reconcileConfig(); err != nil {
+		q.shared.unregister(q)
+		return err
+	}
 	if err := q.init(); err != nil {
-		return nil, err
+		q.shared.unregister(q)
+		return err
 	}
-	return q, nil
+	if err := q.openWAL(); err != nil {
+		q.shared.unregister(q)
+		return err
+	}
+	q.startSync()
+	q.startMaintenance()
+	q.startAutoCompact()
+	if err := q.startStoredSchedules(); err != nil {
+		q.logger().Warn("lasr: couldn't start schedules", "error", err)
+	}
+	return nil
 }
 
 func (q *Q) init() error {
 	if q.messages == nil {
 		q.messages = newFifo(1)
 	}
-	return q.equilibrate()
+	if err := q.migrate(); err != nil {
+		return err
+	}
+	if q.readOnly {
+		// q is only inspected, and is left exactly as it was found, unacked
+		// messages included.
+		return nil
+	}
+	if err := q.recoverUnacked(); err != nil {
+		return err
+	}
+	if err := q.equilibrate(); err != nil {
+		return err
+	}
+	if err := q.pruneArchive(); err != nil {
+		return err
+	}
+	return q.addSelectors()
 }
 
 func (q *Q) equilibrate() error {
@@ -115,11 +391,16 @@ func (q *Q) equilibrate() error {
 			return err
 		}
 		cursor := unacked.Cursor()
+		var requeued [][]byte
 		// put unacked messages from previous session back in the queue
 		for k, v := cursor.First(); k != nil; k, v = cursor.Next() {
+			if q.kept[string(k)] {
+				continue
+			}
 			if err := bucket.Put(k, v); err != nil {
 				return err
 			}
+			requeued = append(requeued, k)
 			readyKeys++
 		}
 		if readyKeys > 0 && !q.isClosed() {
@@ -145,9 +426,31 @@ func (q *Q) equilibrate() error {
 				q.waker.WakeAt(time.Unix(0, int64(id)))
 			}
 		}
+		if retrying := q.readBucket(tx, q.keys.retrying); retrying != nil && !q.isClosed() {
+			cur := retrying.Cursor()
+			for k, _ := cur.First(); k != nil; k, _ = cur.Next() {
+				due, err := retryDue(k)
+				if err != nil {
+					return fmt.Errorf("error reading retry key %v: %s", k, err)
+				}
+				q.waker.WakeAt(due)
+			}
+		}
 		// Delete the unacked bucket now that the unacked messages have been
 		// returned to the ready bucket.
-		return root.DeleteBucket(q.keys.unacked)
+		if len(q.kept) > 0 {
+			for _, k := range requeued {
+				if err := unacked.Delete(k); err != nil {
+					return err
+				}
+			}
+		} else if err := root.DeleteBucket(q.keys.unacked); err != nil {
+			return err
+		}
+		if err := q.pruneDedup(tx, time.Now()); err != nil {
+			return err
+		}
+		return q.resetDepth(tx)
 	})
 }
 
@@ -167,3 +470,16 @@ func (q *Q) bucket(tx *bolt.Tx, key []byte) (*bolt.Bucket, error) {
 	}
 	return bucket, nil
 }
+
+// readBucket is like bucket, but for use in read-only transactions. It
+// returns nil if the bucket does not exist.
+func (q *Q) readBucket(tx *bolt.Tx, key []byte) *bolt.Bucket {
+	if len(key) == 0 {
+		return nil
+	}
+	root := tx.Bucket(q.name)
+	if root == nil {
+		return nil
+	}
+	return root.Bucket(key)
+}
@@ -0,0 +1,99 @@
+// Package amqpbridge provides a lasrbridge.Publisher for AMQP 0.9.1 brokers
+// such as RabbitMQ.
+package amqpbridge
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"sync"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+	"github.com/sensu/lasr"
+)
+
+// Publisher publishes lasr messages to an AMQP exchange, and waits for a
+// publisher confirm from the broker for each of them.
+//
+// Messages are published as mandatory. A broker confirms messages that it
+// could not route to any queue, after returning them, so Publish treats a
+// returned message as a failed publish. Otherwise the message would be acked
+// in lasr and lost.
+type Publisher struct {
+	ch         *amqp.Channel
+	exchange   string
+	routingKey string
+	returns    chan amqp.Return
+	mu         sync.Mutex
+}
+
+// NewPublisher creates a Publisher that publishes to exchange with routingKey
+// on ch. It puts ch into confirm mode and listens for returned messages on
+// it, so ch should not be used for anything else.
+func NewPublisher(ch *amqp.Channel, exchange, routingKey string) (*Publisher, error) {
+	if err := ch.Confirm(false); err != nil {
+		return nil, fmt.Errorf("amqpbridge: couldn't enable confirms: %s", err)
+	}
+	return &Publisher{
+		ch:         ch,
+		exchange:   exchange,
+		routingKey: routingKey,
+		returns:    ch.NotifyReturn(make(chan amqp.Return, 1)),
+	}, nil
+}
+
+// Publish publishes msg as a persistent, mandatory message, and returns once
+// the broker has confirmed it. The message ID is set to the hex encoded lasr
+// ID, and the lasr headers are sent as AMQP headers.
+//
+// Publishes are serialized, so that a returned message can be matched with
+// the publish that it belongs to.
+func (p *Publisher) Publish(ctx context.Context, msg *lasr.Message) error {
+	pub := publishing(msg)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	// Discard returns left over from publishes that were abandoned before
+	// their confirm arrived.
+	for drained := false; !drained; {
+		select {
+		case <-p.returns:
+		default:
+			drained = true
+		}
+	}
+	dc, err := p.ch.PublishWithDeferredConfirmWithContext(ctx, p.exchange, p.routingKey, true, false, pub)
+	if err != nil {
+		return fmt.Errorf("amqpbridge: publish failed: %s", err)
+	}
+	ok, err := dc.WaitContext(ctx)
+	if err != nil {
+		return fmt.Errorf("amqpbridge: publish not confirmed: %s", err)
+	}
+	if !ok {
+		return fmt.Errorf("amqpbridge: message %s nacked by broker", pub.MessageId)
+	}
+	// The broker sends basic.return before the confirm, and the client
+	// delivers it to p.returns before it processes the confirm.
+	select {
+	case ret := <-p.returns:
+		return fmt.Errorf("amqpbridge: message %s returned by broker: %d %s", ret.MessageId, ret.ReplyCode, ret.ReplyText)
+	default:
+	}
+	return nil
+}
+
+// publishing converts msg to an AMQP message.
+func publishing(msg *lasr.Message) amqp.Publishing {
+	pub := amqp.Publishing{
+		DeliveryMode: amqp.Persistent,
+		MessageId:    hex.EncodeToString(msg.ID),
+		Body:         msg.Body,
+	}
+	if len(msg.Headers) > 0 {
+		pub.Headers = make(amqp.Table, len(msg.Headers))
+		for k, v := range msg.Headers {
+			pub.Headers[k] = v
+		}
+	}
+	return pub
+}
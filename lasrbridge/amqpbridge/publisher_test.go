@@ -0,0 +1,37 @@
+package amqpbridge
+
+import (
+	"bytes"
+	"testing"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+	"github.com/sensu/lasr"
+)
+
+func TestPublishing(t *testing.T) {
+	msg := &lasr.Message{
+		ID:      []byte{0, 0, 0, 0, 0, 0, 0, 42},
+		Body:    []byte("foo"),
+		Headers: map[string][]byte{"k": []byte("v")},
+	}
+	pub := publishing(msg)
+	if got, want := pub.MessageId, "000000000000002a"; got != want {
+		t.Errorf("bad message id: got %q, want %q", got, want)
+	}
+	if got, want := pub.DeliveryMode, uint8(amqp.Persistent); got != want {
+		t.Errorf("bad delivery mode: got %d, want %d", got, want)
+	}
+	if !bytes.Equal(pub.Body, msg.Body) {
+		t.Errorf("bad body: got %q, want %q", pub.Body, msg.Body)
+	}
+	if got, ok := pub.Headers["k"].([]byte); !ok || string(got) != "v" {
+		t.Errorf("bad header: got %#v", pub.Headers["k"])
+	}
+	if err := pub.Headers.Validate(); err != nil {
+		t.Errorf("invalid headers: %s", err)
+	}
+
+	if pub := publishing(&lasr.Message{ID: []byte{1}}); pub.Headers != nil {
+		t.Errorf("expected no headers, got %v", pub.Headers)
+	}
+}
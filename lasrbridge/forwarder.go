@@ -0,0 +1,78 @@
+// Package lasrbridge forwards messages from a lasr queue to another message
+// broker, using lasr as a durable local buffer in front of it.
+//
+// A message is only acked in lasr once the Publisher reports that the broker
+// has accepted it, so messages are never lost if the broker or the process
+// fails. They may be delivered to the broker more than once.
+//
+// Publishers for specific brokers live in subpackages, so that programs only
+// depend on the client libraries they use.
+package lasrbridge
+
+import (
+	"context"
+	"time"
+
+	"github.com/sensu/lasr"
+)
+
+// DefaultRetryDelay is how long a Forwarder waits after a failed publish, by
+// default.
+const DefaultRetryDelay = time.Second
+
+// Publisher publishes messages to a broker. Publish must not return nil until
+// the broker has durably accepted the message.
+type Publisher interface {
+	Publish(ctx context.Context, msg *lasr.Message) error
+}
+
+// Forwarder drains a Q into a Publisher.
+type Forwarder struct {
+	// RetryDelay is how long to wait after a failed publish before trying
+	// again. The failed message is nacked for retry, so it is published
+	// again in order.
+	RetryDelay time.Duration
+
+	// OnError, if set, is called with every error from the Publisher.
+	OnError func(error)
+
+	q   *lasr.Q
+	pub Publisher
+}
+
+// NewForwarder creates a Forwarder from q to pub.
+func NewForwarder(q *lasr.Q, pub Publisher) *Forwarder {
+	return &Forwarder{
+		RetryDelay: DefaultRetryDelay,
+		q:          q,
+		pub:        pub,
+	}
+}
+
+// Run forwards messages until ctx is done or the Q is closed. It returns the
+// error that stopped it.
+func (f *Forwarder) Run(ctx context.Context) error {
+	for {
+		msg, err := f.q.Receive(ctx)
+		if err != nil {
+			return err
+		}
+		if err := f.pub.Publish(ctx, msg); err != nil {
+			if f.OnError != nil {
+				f.OnError(err)
+			}
+			if nerr := msg.Nack(true); nerr != nil {
+				return nerr
+			}
+			select {
+			case <-time.After(f.RetryDelay):
+				continue
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		if err := msg.Ack(); err != nil {
+			return err
+		}
+	}
+}
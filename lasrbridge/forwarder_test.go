@@ -0,0 +1,84 @@
+package lasrbridge
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sensu/lasr"
+)
+
+type fakePublisher struct {
+	mu        sync.Mutex
+	failures  int
+	published []string
+	done      chan struct{}
+	want      int
+}
+
+func (p *fakePublisher) Publish(ctx context.Context, msg *lasr.Message) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.failures > 0 {
+		p.failures--
+		return errors.New("broker unavailable")
+	}
+	p.published = append(p.published, string(msg.Body))
+	if len(p.published) == p.want {
+		close(p.done)
+	}
+	return nil
+}
+
+func TestForwarder(t *testing.T) {
+	q, err := lasr.NewTempQ("testing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer q.Close()
+
+	for _, body := range []string{"a", "b", "c"} {
+		if _, err := q.Send([]byte(body)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	pub := &fakePublisher{failures: 2, done: make(chan struct{}), want: 3}
+	f := NewForwarder(q, pub)
+	f.RetryDelay = time.Millisecond
+	var errs int
+	f.OnError = func(error) { errs++ }
+
+	ctx, cancel := context.WithCancel(context.Background())
+	result := make(chan error, 1)
+	go func() {
+		result <- f.Run(ctx)
+	}()
+	select {
+	case <-pub.done:
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for messages to be forwarded")
+	}
+	cancel()
+	if err := <-result; err != context.Canceled {
+		t.Errorf("bad error: got %v, want %v", err, context.Canceled)
+	}
+
+	if got, want := errs, 2; got != want {
+		t.Errorf("bad error count: got %d, want %d", got, want)
+	}
+	for i, want := range []string{"a", "b", "c"} {
+		if got := pub.published[i]; got != want {
+			t.Errorf("bad message %d: got %q, want %q", i, got, want)
+		}
+	}
+	stats, err := q.Stats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := stats.Acked, uint64(3); got != want {
+		t.Errorf("bad acked count: got %d, want %d", got, want)
+	}
+}
@@ -0,0 +1,103 @@
+// Package natsbridge connects lasr queues to NATS JetStream. Publisher
+// forwards messages from a Q to a stream, and Persist stores the messages of
+// a JetStream consumer in a Q.
+package natsbridge
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+	"github.com/sensu/lasr"
+	"github.com/sensu/lasr/lasrbridge"
+)
+
+// Publisher is a lasrbridge.Publisher that publishes to a JetStream subject.
+type Publisher struct {
+	js      jetstream.JetStream
+	subject string
+}
+
+// NewPublisher creates a Publisher that publishes to subject with js.
+func NewPublisher(js jetstream.JetStream, subject string) *Publisher {
+	return &Publisher{js: js, subject: subject}
+}
+
+// Publish publishes msg and waits for the stream to acknowledge it. The
+// JetStream message ID is set to the hex encoded lasr ID, so that the stream
+// can discard messages that are forwarded twice within its duplicate window.
+// The lasr headers are sent as NATS headers.
+func (p *Publisher) Publish(ctx context.Context, msg *lasr.Message) error {
+	m := nats.NewMsg(p.subject)
+	m.Data = msg.Body
+	for k, v := range msg.Headers {
+		m.Header.Set(k, string(v))
+	}
+	id := hex.EncodeToString(msg.ID)
+	if _, err := p.js.PublishMsg(ctx, m, jetstream.WithMsgID(id)); err != nil {
+		return fmt.Errorf("natsbridge: publish of %s failed: %s", id, err)
+	}
+	return nil
+}
+
+// Persist sends every message from cons to q, until ctx is done or q is
+// closed. Each message is acked in JetStream only after it has been sent to
+// q. If the send fails, the message is nacked with a delay of
+// lasrbridge.DefaultRetryDelay so that JetStream redelivers it, and Persist
+// waits as long before it consumes again. NATS headers are stored as lasr
+// headers, using the first value of each.
+func Persist(ctx context.Context, cons jetstream.Consumer, q *lasr.Q) error {
+	return persist(ctx, cons, q, lasrbridge.DefaultRetryDelay)
+}
+
+func persist(ctx context.Context, cons jetstream.Consumer, q *lasr.Q, retryDelay time.Duration) error {
+	it, err := cons.Messages()
+	if err != nil {
+		return fmt.Errorf("natsbridge: couldn't consume: %s", err)
+	}
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		select {
+		case <-ctx.Done():
+			it.Stop()
+		case <-stop:
+			it.Stop()
+		}
+	}()
+	for {
+		m, err := it.Next()
+		if err == jetstream.ErrMsgIteratorClosed && ctx.Err() != nil {
+			return ctx.Err()
+		} else if err != nil {
+			return fmt.Errorf("natsbridge: couldn't consume: %s", err)
+		}
+		var headers map[string][]byte
+		for k := range m.Headers() {
+			if headers == nil {
+				headers = make(map[string][]byte)
+			}
+			headers[k] = []byte(m.Headers().Get(k))
+		}
+		if _, err := q.SendWithHeaders(m.Data(), headers); err != nil {
+			if nerr := m.NakWithDelay(retryDelay); nerr != nil {
+				return fmt.Errorf("natsbridge: couldn't nack: %s", nerr)
+			}
+			if err == lasr.ErrQClosed {
+				return err
+			}
+			select {
+			case <-time.After(retryDelay):
+				continue
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		if err := m.Ack(); err != nil {
+			return fmt.Errorf("natsbridge: couldn't ack: %s", err)
+		}
+	}
+}
@@ -0,0 +1,152 @@
+package natsbridge
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+	"github.com/sensu/lasr"
+)
+
+type fakeJetStream struct {
+	jetstream.JetStream
+	msgs []*nats.Msg
+}
+
+func (js *fakeJetStream) PublishMsg(ctx context.Context, m *nats.Msg, opts ...jetstream.PublishOpt) (*jetstream.PubAck, error) {
+	js.msgs = append(js.msgs, m)
+	return &jetstream.PubAck{}, nil
+}
+
+func TestPublish(t *testing.T) {
+	js := &fakeJetStream{}
+	pub := NewPublisher(js, "subject")
+	msg := &lasr.Message{
+		ID:      []byte{0, 0, 0, 0, 0, 0, 0, 42},
+		Body:    []byte("foo"),
+		Headers: map[string][]byte{"K": []byte("v")},
+	}
+	if err := pub.Publish(context.Background(), msg); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(js.msgs), 1; got != want {
+		t.Fatalf("bad publish count: got %d, want %d", got, want)
+	}
+	m := js.msgs[0]
+	if got, want := m.Subject, "subject"; got != want {
+		t.Errorf("bad subject: got %q, want %q", got, want)
+	}
+	if got, want := string(m.Data), "foo"; got != want {
+		t.Errorf("bad data: got %q, want %q", got, want)
+	}
+	if got, want := m.Header.Get("K"), "v"; got != want {
+		t.Errorf("bad header: got %q, want %q", got, want)
+	}
+}
+
+type fakeMsg struct {
+	jetstream.Msg
+	data    []byte
+	headers nats.Header
+	it      *fakeIterator
+	acked   chan string
+}
+
+func (m *fakeMsg) Data() []byte         { return m.data }
+func (m *fakeMsg) Headers() nats.Header { return m.headers }
+
+func (m *fakeMsg) Ack() error {
+	m.acked <- string(m.data)
+	return nil
+}
+
+func (m *fakeMsg) NakWithDelay(time.Duration) error {
+	m.it.msgs <- m
+	return nil
+}
+
+type fakeIterator struct {
+	jetstream.MessagesContext
+	msgs    chan *fakeMsg
+	stopped chan struct{}
+}
+
+func (it *fakeIterator) Next() (jetstream.Msg, error) {
+	select {
+	case m := <-it.msgs:
+		return m, nil
+	case <-it.stopped:
+		return nil, jetstream.ErrMsgIteratorClosed
+	}
+}
+
+func (it *fakeIterator) Stop() {
+	close(it.stopped)
+}
+
+type fakeConsumer struct {
+	jetstream.Consumer
+	it *fakeIterator
+}
+
+func (c *fakeConsumer) Messages(...jetstream.PullMessagesOpt) (jetstream.MessagesContext, error) {
+	return c.it, nil
+}
+
+func TestPersistRetries(t *testing.T) {
+	q, err := lasr.NewTempQ("testing", lasr.WithMaxDepth(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer q.Close()
+
+	it := &fakeIterator{msgs: make(chan *fakeMsg, 2), stopped: make(chan struct{})}
+	acked := make(chan string, 2)
+	for _, body := range []string{"a", "b"} {
+		it.msgs <- &fakeMsg{
+			data:    []byte(body),
+			headers: nats.Header{"K": []string{body}},
+			it:      it,
+			acked:   acked,
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	errs := make(chan error, 1)
+	go func() {
+		errs <- persist(ctx, &fakeConsumer{it: it}, q, time.Millisecond)
+	}()
+
+	// The Q only holds one message, so b is nacked until a is acked in lasr.
+	for _, want := range []string{"a", "b"} {
+		select {
+		case got := <-acked:
+			if got != want {
+				t.Fatalf("bad ack: got %q, want %q", got, want)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("%s was never acked", want)
+		}
+		msg, err := q.Receive(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := string(msg.Body); got != want {
+			t.Errorf("bad body: got %q, want %q", got, want)
+		}
+		if got := string(msg.Headers["K"]); got != want {
+			t.Errorf("bad header: got %q, want %q", got, want)
+		}
+		if err := msg.Ack(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cancel()
+	if err := <-errs; err != context.Canceled {
+		t.Errorf("bad error: got %v, want %v", err, context.Canceled)
+	}
+}
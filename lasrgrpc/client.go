@@ -0,0 +1,93 @@
+package lasrgrpc
+
+import (
+	"context"
+	"sync"
+
+	"google.golang.org/grpc"
+)
+
+// Client is a client for a Server.
+type Client struct {
+	c QueueClient
+
+	// cc is the connection that DialUnix made for the Client.
+	cc *grpc.ClientConn
+}
+
+// NewClient creates a Client that calls the Server on cc.
+func NewClient(cc grpc.ClientConnInterface) *Client {
+	return &Client{c: NewQueueClient(cc)}
+}
+
+// Close closes the connection of a Client created by DialUnix. Clients
+// created by NewClient use a connection that belongs to the caller, so Close
+// does nothing for them.
+func (c *Client) Close() error {
+	if c.cc == nil {
+		return nil
+	}
+	return c.cc.Close()
+}
+
+// Send sends a message with optional headers, and returns its ID.
+func (c *Client) Send(ctx context.Context, body []byte, headers map[string][]byte) ([]byte, error) {
+	resp, err := c.c.Send(ctx, &SendRequest{Body: body, Headers: headers})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Id, nil
+}
+
+// Receive starts receiving messages. Up to prefetch messages are delivered
+// before they are acked or nacked; a prefetch of 0 is the same as 1. The
+// stream ends when ctx is done or the Receiver is closed.
+func (c *Client) Receive(ctx context.Context, prefetch int) (*Receiver, error) {
+	stream, err := c.c.Receive(ctx)
+	if err != nil {
+		return nil, err
+	}
+	r := &Receiver{stream: stream}
+	start := &ReceiveRequest_Start{Start: &Start{Prefetch: uint32(prefetch)}}
+	if err := r.send(&ReceiveRequest{Request: start}); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Receiver receives messages from a Server. Next must not be called
+// concurrently, but Ack and Nack can be called from any goroutine.
+type Receiver struct {
+	stream Queue_ReceiveClient
+	mu     sync.Mutex
+}
+
+// Next returns the next message. It returns io.EOF once the Receiver is
+// closed and the Server has nacked the messages that weren't acked or nacked.
+func (r *Receiver) Next() (*Delivery, error) {
+	return r.stream.Recv()
+}
+
+// Ack acks the message with the given ID.
+func (r *Receiver) Ack(id []byte) error {
+	return r.send(&ReceiveRequest{Request: &ReceiveRequest_Ack{Ack: &Ack{Id: id}}})
+}
+
+// Nack nacks the message with the given ID. If retry is true, the message is
+// returned to the Ready state.
+func (r *Receiver) Nack(id []byte, retry bool) error {
+	return r.send(&ReceiveRequest{Request: &ReceiveRequest_Nack{Nack: &Nack{Id: id, Retry: retry}}})
+}
+
+// Close tells the Server that no more messages are wanted.
+func (r *Receiver) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.stream.CloseSend()
+}
+
+func (r *Receiver) send(req *ReceiveRequest) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.stream.Send(req)
+}
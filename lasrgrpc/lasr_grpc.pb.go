@@ -0,0 +1,193 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.4.0
+// - protoc             (unknown)
+// source: lasr.proto
+
+package lasrgrpc
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.62.0 or later.
+const _ = grpc.SupportPackageIsVersion8
+
+const (
+	Queue_Send_FullMethodName    = "/lasr.Queue/Send"
+	Queue_Receive_FullMethodName = "/lasr.Queue/Receive"
+)
+
+// QueueClient is the client API for Queue service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// Queue serves a lasr queue to remote producers and consumers.
+type QueueClient interface {
+	// Send sends a message to the queue.
+	Send(ctx context.Context, in *SendRequest, opts ...grpc.CallOption) (*SendResponse, error)
+	// Receive streams messages to a consumer. The first request must be a
+	// Start, and the rest acks and nacks of delivered messages. Messages that
+	// are neither acked nor nacked when the stream ends are nacked for retry.
+	Receive(ctx context.Context, opts ...grpc.CallOption) (Queue_ReceiveClient, error)
+}
+
+type queueClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewQueueClient(cc grpc.ClientConnInterface) QueueClient {
+	return &queueClient{cc}
+}
+
+func (c *queueClient) Send(ctx context.Context, in *SendRequest, opts ...grpc.CallOption) (*SendResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(SendResponse)
+	err := c.cc.Invoke(ctx, Queue_Send_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *queueClient) Receive(ctx context.Context, opts ...grpc.CallOption) (Queue_ReceiveClient, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Queue_ServiceDesc.Streams[0], Queue_Receive_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &queueReceiveClient{ClientStream: stream}
+	return x, nil
+}
+
+type Queue_ReceiveClient interface {
+	Send(*ReceiveRequest) error
+	Recv() (*Delivery, error)
+	grpc.ClientStream
+}
+
+type queueReceiveClient struct {
+	grpc.ClientStream
+}
+
+func (x *queueReceiveClient) Send(m *ReceiveRequest) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *queueReceiveClient) Recv() (*Delivery, error) {
+	m := new(Delivery)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// QueueServer is the server API for Queue service.
+// All implementations must embed UnimplementedQueueServer
+// for forward compatibility
+//
+// Queue serves a lasr queue to remote producers and consumers.
+type QueueServer interface {
+	// Send sends a message to the queue.
+	Send(context.Context, *SendRequest) (*SendResponse, error)
+	// Receive streams messages to a consumer. The first request must be a
+	// Start, and the rest acks and nacks of delivered messages. Messages that
+	// are neither acked nor nacked when the stream ends are nacked for retry.
+	Receive(Queue_ReceiveServer) error
+	mustEmbedUnimplementedQueueServer()
+}
+
+// UnimplementedQueueServer must be embedded to have forward compatible implementations.
+type UnimplementedQueueServer struct {
+}
+
+func (UnimplementedQueueServer) Send(context.Context, *SendRequest) (*SendResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Send not implemented")
+}
+func (UnimplementedQueueServer) Receive(Queue_ReceiveServer) error {
+	return status.Errorf(codes.Unimplemented, "method Receive not implemented")
+}
+func (UnimplementedQueueServer) mustEmbedUnimplementedQueueServer() {}
+
+// UnsafeQueueServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to QueueServer will
+// result in compilation errors.
+type UnsafeQueueServer interface {
+	mustEmbedUnimplementedQueueServer()
+}
+
+func RegisterQueueServer(s grpc.ServiceRegistrar, srv QueueServer) {
+	s.RegisterService(&Queue_ServiceDesc, srv)
+}
+
+func _Queue_Send_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SendRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(QueueServer).Send(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Queue_Send_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(QueueServer).Send(ctx, req.(*SendRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Queue_Receive_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(QueueServer).Receive(&queueReceiveServer{ServerStream: stream})
+}
+
+type Queue_ReceiveServer interface {
+	Send(*Delivery) error
+	Recv() (*ReceiveRequest, error)
+	grpc.ServerStream
+}
+
+type queueReceiveServer struct {
+	grpc.ServerStream
+}
+
+func (x *queueReceiveServer) Send(m *Delivery) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *queueReceiveServer) Recv() (*ReceiveRequest, error) {
+	m := new(ReceiveRequest)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Queue_ServiceDesc is the grpc.ServiceDesc for Queue service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Queue_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "lasr.Queue",
+	HandlerType: (*QueueServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Send",
+			Handler:    _Queue_Send_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Receive",
+			Handler:       _Queue_Receive_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+	},
+	Metadata: "lasr.proto",
+}
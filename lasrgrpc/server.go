@@ -0,0 +1,179 @@
+// Package lasrgrpc serves a lasr queue over gRPC, so that producers and
+// consumers in other processes, on the same host or on others, can use it.
+// The service is defined in lasr.proto. See ListenUnix for sharing a queue
+// between local processes.
+//
+// Consumers receive over a stream, and ack or nack on the same stream. A
+// received message is leased to its stream, and nacked for retry if the
+// stream ends before the consumer acks or nacks it.
+package lasrgrpc
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative lasr.proto
+
+import (
+	"context"
+	"encoding/hex"
+	"io"
+	"sync"
+
+	"github.com/sensu/lasr"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Server is a QueueServer for a lasr.Q.
+type Server struct {
+	UnimplementedQueueServer
+
+	q *lasr.Q
+}
+
+// NewServer creates a Server for q.
+func NewServer(q *lasr.Q) *Server {
+	return &Server{q: q}
+}
+
+// Send implements QueueServer.
+func (s *Server) Send(ctx context.Context, req *SendRequest) (*SendResponse, error) {
+	id, err := s.q.SendWithHeaders(req.Body, req.Headers)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	idb, err := id.MarshalBinary()
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &SendResponse{Id: idb}, nil
+}
+
+// Receive implements QueueServer.
+func (s *Server) Receive(stream Queue_ReceiveServer) error {
+	req, err := stream.Recv()
+	if err != nil {
+		return err
+	}
+	start := req.GetStart()
+	if start == nil {
+		return status.Error(codes.InvalidArgument, "lasrgrpc: the first request must be a start")
+	}
+	prefetch := int(start.Prefetch)
+	if prefetch == 0 {
+		prefetch = 1
+	}
+	r := &receiver{
+		leases: make(map[string]*lasr.Message),
+		credit: make(chan struct{}, prefetch),
+	}
+	for i := 0; i < prefetch; i++ {
+		r.credit <- struct{}{}
+	}
+	defer r.nackAll()
+
+	ctx, cancel := context.WithCancel(stream.Context())
+	defer cancel()
+	errs := make(chan error, 1)
+	go func() {
+		errs <- r.handle(stream)
+		cancel()
+	}()
+	for {
+		select {
+		case <-r.credit:
+		case <-ctx.Done():
+			return <-errs
+		}
+		msg, err := s.q.Receive(ctx)
+		if err == context.Canceled {
+			return <-errs
+		} else if err != nil {
+			return toStatus(err)
+		}
+		r.lease(msg)
+		err = stream.Send(&Delivery{Id: msg.ID, Body: msg.Body, Headers: msg.Headers})
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// receiver tracks the messages leased to one receive stream.
+type receiver struct {
+	mu     sync.Mutex
+	leases map[string]*lasr.Message
+	credit chan struct{}
+}
+
+func (r *receiver) lease(msg *lasr.Message) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.leases[string(msg.ID)] = msg
+}
+
+func (r *receiver) take(id []byte) *lasr.Message {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	msg, ok := r.leases[string(id)]
+	if !ok {
+		return nil
+	}
+	delete(r.leases, string(id))
+	return msg
+}
+
+// handle acks and nacks messages as the client requests, until the client
+// closes its side of the stream.
+func (r *receiver) handle(stream Queue_ReceiveServer) error {
+	for {
+		req, err := stream.Recv()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		var id []byte
+		switch x := req.Request.(type) {
+		case *ReceiveRequest_Ack:
+			id = x.Ack.Id
+		case *ReceiveRequest_Nack:
+			id = x.Nack.Id
+		default:
+			return status.Error(codes.InvalidArgument, "lasrgrpc: already started")
+		}
+		msg := r.take(id)
+		if msg == nil {
+			return status.Errorf(codes.NotFound, "lasrgrpc: no leased message with id %s", hex.EncodeToString(id))
+		}
+		if req.GetAck() != nil {
+			err = msg.Ack()
+		} else {
+			err = msg.Nack(req.GetNack().Retry)
+		}
+		if err != nil {
+			return toStatus(err)
+		}
+		r.credit <- struct{}{}
+	}
+}
+
+// nackAll nacks every message that is still leased, for retry.
+func (r *receiver) nackAll() {
+	r.mu.Lock()
+	leases := r.leases
+	r.leases = make(map[string]*lasr.Message)
+	r.mu.Unlock()
+	for _, msg := range leases {
+		msg.Nack(true)
+	}
+}
+
+func toStatus(err error) error {
+	switch err {
+	case lasr.ErrQClosed:
+		return status.Error(codes.Unavailable, err.Error())
+	case lasr.ErrQueueFull:
+		return status.Error(codes.ResourceExhausted, err.Error())
+	case context.Canceled, context.DeadlineExceeded:
+		return status.FromContextError(err).Err()
+	}
+	return status.Error(codes.Internal, err.Error())
+}
@@ -0,0 +1,133 @@
+package lasrgrpc
+
+import (
+	"context"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/sensu/lasr"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func newClient(t *testing.T, q *lasr.Q) (*Client, func()) {
+	lis := bufconn.Listen(1 << 20)
+	srv := grpc.NewServer()
+	RegisterQueueServer(srv, NewServer(q))
+	go srv.Serve(lis)
+	dial := func(ctx context.Context, _ string) (net.Conn, error) {
+		return lis.DialContext(ctx)
+	}
+	cc, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(dial),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewClient(cc), func() {
+		cc.Close()
+		srv.Stop()
+	}
+}
+
+func TestSendReceive(t *testing.T) {
+	q, err := lasr.NewTempQ("testing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer q.Close()
+	c, cleanup := newClient(t, q)
+	defer cleanup()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	for _, body := range []string{"a", "b", "c"} {
+		if _, err := c.Send(ctx, []byte(body), map[string][]byte{"k": []byte(body)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	r, err := c.Receive(ctx, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, err := r.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(a.Body), "a"; got != want {
+		t.Errorf("bad body: got %q, want %q", got, want)
+	}
+	if got, want := string(a.Headers["k"]), "a"; got != want {
+		t.Errorf("bad header: got %q, want %q", got, want)
+	}
+	if err := r.Ack(a.Id); err != nil {
+		t.Fatal(err)
+	}
+	b, err := r.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Nack(b.Id, false); err != nil {
+		t.Fatal(err)
+	}
+
+	// c is delivered but never acked, so it is nacked for retry when the
+	// stream ends.
+	if _, err := r.Next(); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+	for {
+		if _, err := r.Next(); err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	stats, err := q.Stats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := stats.Ready, 1; got != want {
+		t.Errorf("bad ready count: got %d, want %d", got, want)
+	}
+	if got, want := stats.Acked, uint64(1); got != want {
+		t.Errorf("bad acked count: got %d, want %d", got, want)
+	}
+	if got, want := stats.Nacked, uint64(2); got != want {
+		t.Errorf("bad nacked count: got %d, want %d", got, want)
+	}
+}
+
+func TestAckUnknown(t *testing.T) {
+	q, err := lasr.NewTempQ("testing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer q.Close()
+	c, cleanup := newClient(t, q)
+	defer cleanup()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	r, err := c.Receive(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Ack([]byte("nope")); err != nil {
+		t.Fatal(err)
+	}
+	_, err = r.Next()
+	if got, want := status.Code(err), codes.NotFound; got != want {
+		t.Errorf("bad code: got %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,70 @@
+package lasrgrpc
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// ListenUnix listens on the unix socket at path, so that other processes on
+// the same host can use a queue that is open in this one. bolt only lets one
+// process open a database file at a time, so a producer process and a
+// consumer process share a queue by having one of them, or a third process,
+// serve it:
+//
+//	lis, err := lasrgrpc.ListenUnix("/run/myapp/queue.sock")
+//	...
+//	srv := grpc.NewServer()
+//	lasrgrpc.RegisterQueueServer(srv, lasrgrpc.NewServer(q))
+//	go srv.Serve(lis)
+//
+// and having the others connect with DialUnix. The socket is only accessible
+// by the user that created it.
+//
+// A socket file left behind by a process that exited without closing its
+// listener is replaced. ListenUnix returns an error if another process is
+// still listening on path.
+func ListenUnix(path string) (net.Listener, error) {
+	if fi, err := os.Lstat(path); err == nil {
+		if fi.Mode()&os.ModeSocket == 0 {
+			return nil, fmt.Errorf("lasrgrpc: %s exists and is not a socket", path)
+		}
+		conn, err := net.DialTimeout("unix", path, time.Second)
+		if err == nil {
+			conn.Close()
+			return nil, fmt.Errorf("lasrgrpc: %s is in use", path)
+		}
+		if err := os.Remove(path); err != nil {
+			return nil, err
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	lis, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(path, 0600); err != nil {
+		lis.Close()
+		return nil, err
+	}
+	return lis, nil
+}
+
+// DialUnix creates a Client for the Server listening on the unix socket at
+// path, see ListenUnix. The connection is closed by Client.Close.
+func DialUnix(path string, opts ...grpc.DialOption) (*Client, error) {
+	opts = append([]grpc.DialOption{grpc.WithTransportCredentials(insecure.NewCredentials())}, opts...)
+	cc, err := grpc.NewClient("unix://"+path, opts...)
+	if err != nil {
+		return nil, err
+	}
+	c := NewClient(cc)
+	c.cc = cc
+	return c, nil
+}
@@ -0,0 +1,83 @@
+package lasrgrpc
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sensu/lasr"
+	"google.golang.org/grpc"
+)
+
+func TestUnix(t *testing.T) {
+	q, err := lasr.NewTempQ("testing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer q.Close()
+	td, err := os.MkdirTemp("", "lasrgrpc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(td)
+	path := filepath.Join(td, "queue.sock")
+
+	// A socket left behind by a process that crashed is replaced.
+	stale, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	stale.Close()
+
+	lis, err := ListenUnix(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := grpc.NewServer()
+	RegisterQueueServer(srv, NewServer(q))
+	go srv.Serve(lis)
+	defer srv.Stop()
+
+	if _, err := ListenUnix(path); err == nil {
+		t.Error("expected an error listening on a socket in use")
+	}
+	fi, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := fi.Mode().Perm(); got != 0600 {
+		t.Errorf("bad socket mode: %v", got)
+	}
+
+	c, err := DialUnix(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if _, err := c.Send(ctx, []byte("a"), nil); err != nil {
+		t.Fatal(err)
+	}
+	r, err := c.Receive(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err := r.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(d.Body); got != "a" {
+		t.Errorf("bad body: got %q, want %q", got, "a")
+	}
+	if err := r.Ack(d.Id); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,338 @@
+// Package lasrhttp serves a lasr queue over HTTP, so that programs written in
+// any language can use it as a small single-node queue server.
+//
+// The endpoints are, relative to where the Handler is mounted:
+//
+//	POST /messages                   send the request body as a message
+//	GET  /messages?wait=10s          receive a message, waiting up to wait
+//	POST /messages/<id>/ack          ack a received message
+//	POST /messages/<id>/nack?retry=1 nack a received message
+//	GET  /stats                      queue statistics as JSON
+//	GET  /deadletters?n=10           list up to n dead letters as JSON
+//	POST /deadletters/redrive?n=10   return up to n dead letters to Ready
+//
+// Message IDs are hex encoded. Request headers starting with Lasr-Header-
+// are stored as message headers, and returned the same way on receive. A
+// message body larger than the MaxBodySize of the Handler is refused with
+// 413 Request Entity Too Large.
+//
+// A received message is leased to the client until it is acked or nacked. If
+// neither happens within the lease timeout, the message is nacked for retry.
+package lasrhttp
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sensu/lasr"
+)
+
+const (
+	// DefaultLeaseTimeout is the default time a client has to ack or nack
+	// a message it received.
+	DefaultLeaseTimeout = 30 * time.Second
+
+	// DefaultMaxWait is the default limit on how long a receive waits for
+	// a message.
+	DefaultMaxWait = 30 * time.Second
+
+	// DefaultMaxBodySize is the default limit on the size of the body of
+	// a sent message, in bytes.
+	DefaultMaxBodySize = 1 << 20
+
+	// HeaderPrefix is the prefix of the HTTP headers that carry message
+	// headers.
+	HeaderPrefix = "Lasr-Header-"
+
+	// IDHeader is the HTTP header that carries a received message's ID.
+	IDHeader = "Lasr-Id"
+)
+
+// Handler is an http.Handler that serves a lasr.Q.
+type Handler struct {
+	// LeaseTimeout is how long a client has to ack or nack a message.
+	LeaseTimeout time.Duration
+
+	// MaxWait limits the wait parameter of receives.
+	MaxWait time.Duration
+
+	// MaxBodySize limits the size of the body of a sent message, in
+	// bytes. If it is zero, the size is not limited.
+	MaxBodySize int64
+
+	q      *lasr.Q
+	mu     sync.Mutex
+	leases map[string]*lease
+}
+
+type lease struct {
+	msg   *lasr.Message
+	timer *time.Timer
+}
+
+// NewHandler creates a Handler for q, with the default lease timeout, maximum
+// wait and maximum body size.
+func NewHandler(q *lasr.Q) *Handler {
+	return &Handler{
+		LeaseTimeout: DefaultLeaseTimeout,
+		MaxWait:      DefaultMaxWait,
+		MaxBodySize:  DefaultMaxBodySize,
+		q:            q,
+		leases:       make(map[string]*lease),
+	}
+}
+
+// Close nacks every message that is leased to a client, for retry. It should
+// be called before closing the Q.
+func (h *Handler) Close() error {
+	h.mu.Lock()
+	leases := h.leases
+	h.leases = make(map[string]*lease)
+	h.mu.Unlock()
+	var err error
+	for _, l := range leases {
+		l.timer.Stop()
+		if nerr := l.msg.Nack(true); err == nil {
+			err = nerr
+		}
+	}
+	return err
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	path := strings.Trim(req.URL.Path, "/")
+	parts := strings.Split(path, "/")
+	switch {
+	case path == "messages" && req.Method == http.MethodPost:
+		h.send(w, req)
+	case path == "messages" && req.Method == http.MethodGet:
+		h.receive(w, req)
+	case len(parts) == 3 && parts[0] == "messages" && req.Method == http.MethodPost:
+		h.ackNack(w, req, parts[1], parts[2])
+	case path == "stats" && req.Method == http.MethodGet:
+		h.stats(w)
+	case path == "deadletters" && req.Method == http.MethodGet:
+		h.deadLetters(w, req)
+	case path == "deadletters/redrive" && req.Method == http.MethodPost:
+		h.redrive(w, req)
+	default:
+		http.NotFound(w, req)
+	}
+}
+
+func (h *Handler) send(w http.ResponseWriter, req *http.Request) {
+	if h.MaxBodySize > 0 {
+		req.Body = http.MaxBytesReader(w, req.Body, h.MaxBodySize)
+	}
+	body, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var headers map[string][]byte
+	for k, v := range req.Header {
+		if strings.HasPrefix(k, HeaderPrefix) && len(v) > 0 {
+			if headers == nil {
+				headers = make(map[string][]byte)
+			}
+			headers[strings.TrimPrefix(k, HeaderPrefix)] = []byte(v[0])
+		}
+	}
+	id, err := h.q.SendWithHeaders(body, headers)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	idb, err := id.MarshalBinary()
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, map[string]string{"id": hex.EncodeToString(idb)})
+}
+
+func (h *Handler) receive(w http.ResponseWriter, req *http.Request) {
+	wait := h.MaxWait
+	if s := req.URL.Query().Get("wait"); s != "" {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			http.Error(w, "invalid wait: "+s, http.StatusBadRequest)
+			return
+		}
+		if d < wait {
+			wait = d
+		}
+	}
+	ctx, cancel := context.WithTimeout(req.Context(), wait)
+	defer cancel()
+	msg, err := h.q.Receive(ctx)
+	if err == context.DeadlineExceeded || err == context.Canceled {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	} else if err != nil {
+		writeError(w, err)
+		return
+	}
+	id := hex.EncodeToString(msg.ID)
+	h.mu.Lock()
+	h.leases[id] = &lease{
+		msg:   msg,
+		timer: time.AfterFunc(h.LeaseTimeout, func() { h.expire(id) }),
+	}
+	h.mu.Unlock()
+	w.Header().Set(IDHeader, id)
+	for k, v := range msg.Headers {
+		w.Header().Set(HeaderPrefix+textproto.CanonicalMIMEHeaderKey(k), string(v))
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Write(msg.Body)
+}
+
+// expire nacks the message with the given id, if it is still leased.
+func (h *Handler) expire(id string) {
+	if l := h.takeLease(id); l != nil {
+		l.msg.Nack(true)
+	}
+}
+
+func (h *Handler) takeLease(id string) *lease {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	l, ok := h.leases[id]
+	if !ok {
+		return nil
+	}
+	delete(h.leases, id)
+	l.timer.Stop()
+	return l
+}
+
+func (h *Handler) ackNack(w http.ResponseWriter, req *http.Request, id, action string) {
+	if action != "ack" && action != "nack" {
+		http.NotFound(w, req)
+		return
+	}
+	var retry bool
+	if s := req.URL.Query().Get("retry"); s != "" {
+		var err error
+		if retry, err = strconv.ParseBool(s); err != nil {
+			http.Error(w, "invalid retry: "+s, http.StatusBadRequest)
+			return
+		}
+	}
+	l := h.takeLease(strings.ToLower(id))
+	if l == nil {
+		http.Error(w, "no leased message with id "+id, http.StatusNotFound)
+		return
+	}
+	var err error
+	if action == "ack" {
+		err = l.msg.Ack()
+	} else {
+		err = l.msg.Nack(retry)
+	}
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) stats(w http.ResponseWriter) {
+	stats, err := h.q.Stats()
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, stats)
+}
+
+// errStopScan stops a Scan early without reporting an error.
+var errStopScan = errors.New("stop scan")
+
+// DeadLetter is a dead letter, as listed by GET /deadletters. Body is base64
+// encoded.
+type DeadLetter struct {
+	ID   string `json:"id"`
+	Body []byte `json:"body"`
+}
+
+func (h *Handler) deadLetters(w http.ResponseWriter, req *http.Request) {
+	n, ok := countParam(w, req, 100)
+	if !ok {
+		return
+	}
+	letters := []DeadLetter{}
+	err := h.q.Scan(lasr.Returned, func(id, body []byte) error {
+		if n > 0 && len(letters) >= n {
+			return errStopScan
+		}
+		letters = append(letters, DeadLetter{
+			ID:   hex.EncodeToString(id),
+			Body: append([]byte(nil), body...),
+		})
+		return nil
+	})
+	if err != nil && err != errStopScan {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, letters)
+}
+
+func (h *Handler) redrive(w http.ResponseWriter, req *http.Request) {
+	n, ok := countParam(w, req, 0)
+	if !ok {
+		return
+	}
+	moved, err := h.q.RedriveDeadLetters(n)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]int{"redriven": moved})
+}
+
+func countParam(w http.ResponseWriter, req *http.Request, def int) (int, bool) {
+	s := req.URL.Query().Get("n")
+	if s == "" {
+		return def, true
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		http.Error(w, "invalid n: "+s, http.StatusBadRequest)
+		return 0, false
+	}
+	return n, true
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	code := http.StatusInternalServerError
+	switch err {
+	case lasr.ErrQClosed:
+		code = http.StatusServiceUnavailable
+	case lasr.ErrQueueFull:
+		code = http.StatusTooManyRequests
+	}
+	http.Error(w, err.Error(), code)
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,172 @@
+package lasrhttp
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sensu/lasr"
+)
+
+func newServer(t *testing.T) (*lasr.Q, *Handler, *httptest.Server) {
+	q, err := lasr.NewTempQ("testing", lasr.WithDeadLetters())
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := NewHandler(q)
+	return q, h, httptest.NewServer(h)
+}
+
+func do(t *testing.T, method, url string, body string, header http.Header) *http.Response {
+	req, err := http.NewRequest(method, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp
+}
+
+func TestSendReceiveAck(t *testing.T) {
+	q, h, srv := newServer(t)
+	defer q.Close()
+	defer h.Close()
+	defer srv.Close()
+
+	resp := do(t, "POST", srv.URL+"/messages", "hello", http.Header{"Lasr-Header-Foo": {"bar"}})
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("bad status: %d", resp.StatusCode)
+	}
+
+	resp = do(t, "GET", srv.URL+"/messages?wait=1s", "", nil)
+	buf := make([]byte, 16)
+	n, _ := resp.Body.Read(buf)
+	resp.Body.Close()
+	if got, want := string(buf[:n]), "hello"; got != want {
+		t.Errorf("bad body: got %q, want %q", got, want)
+	}
+	if got, want := resp.Header.Get("Lasr-Header-Foo"), "bar"; got != want {
+		t.Errorf("bad header: got %q, want %q", got, want)
+	}
+	id := resp.Header.Get(IDHeader)
+
+	resp = do(t, "POST", srv.URL+"/messages/"+id+"/ack", "", nil)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("bad status: %d", resp.StatusCode)
+	}
+	resp = do(t, "POST", srv.URL+"/messages/"+id+"/ack", "", nil)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("bad status for second ack: %d", resp.StatusCode)
+	}
+
+	resp = do(t, "GET", srv.URL+"/messages?wait=10ms", "", nil)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		t.Errorf("bad status for empty receive: %d", resp.StatusCode)
+	}
+
+	resp = do(t, "GET", srv.URL+"/stats", "", nil)
+	var stats lasr.Stats
+	err := json.NewDecoder(resp.Body).Decode(&stats)
+	resp.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Acked != 1 {
+		t.Errorf("bad acked count: %d", stats.Acked)
+	}
+}
+
+func TestDeadLetters(t *testing.T) {
+	q, h, srv := newServer(t)
+	defer q.Close()
+	defer h.Close()
+	defer srv.Close()
+
+	do(t, "POST", srv.URL+"/messages", "hello", nil).Body.Close()
+	resp := do(t, "GET", srv.URL+"/messages", "", nil)
+	resp.Body.Close()
+	id := resp.Header.Get(IDHeader)
+	do(t, "POST", srv.URL+"/messages/"+id+"/nack?retry=false", "", nil).Body.Close()
+
+	resp = do(t, "GET", srv.URL+"/deadletters", "", nil)
+	var letters []DeadLetter
+	err := json.NewDecoder(resp.Body).Decode(&letters)
+	resp.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(letters) != 1 || letters[0].ID != id || string(letters[0].Body) != "hello" {
+		t.Fatalf("bad dead letters: %+v", letters)
+	}
+
+	resp = do(t, "POST", srv.URL+"/deadletters/redrive", "", nil)
+	var result map[string]int
+	err = json.NewDecoder(resp.Body).Decode(&result)
+	resp.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result["redriven"] != 1 {
+		t.Errorf("bad redrive result: %v", result)
+	}
+}
+
+func TestLeaseExpiry(t *testing.T) {
+	q, h, srv := newServer(t)
+	defer q.Close()
+	defer h.Close()
+	defer srv.Close()
+	h.LeaseTimeout = 10 * time.Millisecond
+
+	do(t, "POST", srv.URL+"/messages", "hello", nil).Body.Close()
+	resp := do(t, "GET", srv.URL+"/messages", "", nil)
+	resp.Body.Close()
+	first := resp.Header.Get(IDHeader)
+
+	resp = do(t, "GET", srv.URL+"/messages?wait=1s", "", nil)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("message was not redelivered after lease expired: %d", resp.StatusCode)
+	}
+	if got := resp.Header.Get(IDHeader); got != first {
+		t.Errorf("bad id: got %s, want %s", got, first)
+	}
+}
+
+func TestMaxBodySize(t *testing.T) {
+	q, h, srv := newServer(t)
+	defer q.Close()
+	defer h.Close()
+	defer srv.Close()
+	h.MaxBodySize = 5
+
+	resp := do(t, "POST", srv.URL+"/messages", "hello!", nil)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusRequestEntityTooLarge {
+		t.Errorf("bad status for a large body: %d", resp.StatusCode)
+	}
+	resp = do(t, "POST", srv.URL+"/messages", "hello", nil)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		t.Errorf("bad status: %d", resp.StatusCode)
+	}
+	stats, err := q.Stats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Ready != 1 {
+		t.Errorf("bad ready count: %d", stats.Ready)
+	}
+}
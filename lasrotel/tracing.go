@@ -0,0 +1,178 @@
+// Package lasrotel traces the messages of a lasr.Q with OpenTelemetry.
+//
+// Sending a message starts a producer span, whose context is stored in the
+// headers of the message. Receiving it starts a consumer span that links to
+// the producer span, and ends when the message is acked or nacked.
+package lasrotel
+
+import (
+	"context"
+	"encoding/hex"
+
+	"github.com/sensu/lasr"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentationName = "github.com/sensu/lasr/lasrotel"
+
+// Option configures a Tracer.
+type Option func(t *Tracer)
+
+// WithTracerProvider sets the provider of the tracer that spans are created
+// with. It defaults to the global provider.
+func WithTracerProvider(tp trace.TracerProvider) Option {
+	return func(t *Tracer) {
+		t.provider = tp
+	}
+}
+
+// WithPropagator sets how span contexts are stored in message headers. It
+// defaults to the global propagator.
+func WithPropagator(p propagation.TextMapPropagator) Option {
+	return func(t *Tracer) {
+		t.propagator = p
+	}
+}
+
+// Tracer sends and receives the messages of a Q, with spans.
+type Tracer struct {
+	q          *lasr.Q
+	provider   trace.TracerProvider
+	propagator propagation.TextMapPropagator
+	tracer     trace.Tracer
+}
+
+// New returns a Tracer for q.
+func New(q *lasr.Q, options ...Option) *Tracer {
+	t := &Tracer{
+		q:          q,
+		provider:   otel.GetTracerProvider(),
+		propagator: otel.GetTextMapPropagator(),
+	}
+	for _, o := range options {
+		o(t)
+	}
+	t.tracer = t.provider.Tracer(instrumentationName)
+	return t
+}
+
+// Send sends body with headers to the Q of t, like Q.SendWithHeaders, in a
+// producer span that is a child of the span in ctx. The span context is
+// added to a copy of headers.
+func (t *Tracer) Send(ctx context.Context, body []byte, headers map[string][]byte) (lasr.ID, error) {
+	ctx, span := t.tracer.Start(ctx, t.q.Name()+" publish",
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(t.attributes("publish")...))
+	defer span.End()
+	carrier := make(headerCarrier, len(headers)+2)
+	for k, v := range headers {
+		carrier[k] = v
+	}
+	t.propagator.Inject(ctx, carrier)
+	id, err := t.q.SendWithHeaders(body, carrier)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return id, err
+	}
+	if b, err := id.MarshalBinary(); err == nil {
+		span.SetAttributes(attribute.String("messaging.message.id", hex.EncodeToString(b)))
+	}
+	return id, nil
+}
+
+// Receive receives a message from the Q of t, like Q.Receive, and starts a
+// consumer span for it. The span is a child of the span in ctx, and links to
+// the span that the message was sent in, if any.
+func (t *Tracer) Receive(ctx context.Context) (*Message, error) {
+	msg, err := t.q.Receive(ctx)
+	if err != nil {
+		return nil, err
+	}
+	producer := trace.SpanContextFromContext(t.propagator.Extract(context.Background(), headerCarrier(msg.Headers)))
+	options := []trace.SpanStartOption{
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(t.attributes("receive")...),
+		trace.WithAttributes(
+			attribute.String("messaging.message.id", hex.EncodeToString(msg.ID)),
+			attribute.Int("messaging.lasr.attempts", msg.Attempts),
+		),
+	}
+	if producer.IsValid() {
+		options = append(options, trace.WithLinks(trace.Link{SpanContext: producer}))
+	}
+	ctx, span := t.tracer.Start(ctx, t.q.Name()+" receive", options...)
+	return &Message{Message: msg, ctx: ctx, span: span}, nil
+}
+
+func (t *Tracer) attributes(operation string) []attribute.KeyValue {
+	return []attribute.KeyValue{
+		attribute.String("messaging.system", "lasr"),
+		attribute.String("messaging.destination.name", t.q.Name()),
+		attribute.String("messaging.operation", operation),
+	}
+}
+
+// Message is a message received by a Tracer. Its span ends when it is acked
+// or nacked.
+type Message struct {
+	*lasr.Message
+	ctx  context.Context
+	span trace.Span
+}
+
+// Context returns a context that carries the consumer span of m, for the work
+// that is done on m.
+func (m *Message) Context() context.Context {
+	return m.ctx
+}
+
+// Ack acks m, and ends its span.
+func (m *Message) Ack() error {
+	err := m.Message.Ack()
+	m.end("ack", err)
+	return err
+}
+
+// Nack nacks m, and ends its span.
+func (m *Message) Nack(retry bool) error {
+	err := m.Message.Nack(retry)
+	m.end("nack", err, attribute.Bool("retry", retry))
+	return err
+}
+
+func (m *Message) end(event string, err error, attrs ...attribute.KeyValue) {
+	if err == lasr.ErrAckNack {
+		// The span already ended with the first Ack or Nack.
+		return
+	}
+	m.span.AddEvent(event, trace.WithAttributes(attrs...))
+	if err != nil {
+		m.span.RecordError(err)
+		m.span.SetStatus(codes.Error, err.Error())
+	}
+	m.span.End()
+}
+
+// headerCarrier stores span contexts in message headers.
+type headerCarrier map[string][]byte
+
+func (c headerCarrier) Get(key string) string {
+	return string(c[key])
+}
+
+func (c headerCarrier) Set(key, value string) {
+	c[key] = []byte(value)
+}
+
+func (c headerCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for k := range c {
+		keys = append(keys, k)
+	}
+	return keys
+}
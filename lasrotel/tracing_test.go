@@ -0,0 +1,68 @@
+package lasrotel
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/sensu/lasr"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestTracer(t *testing.T) {
+	q, err := lasr.NewTempQ("testing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer q.Close()
+	recorder := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	tracer := New(q, WithTracerProvider(tp), WithPropagator(propagation.TraceContext{}))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if _, err := tracer.Send(ctx, []byte("foo"), map[string][]byte{"k": []byte("v")}); err != nil {
+		t.Fatal(err)
+	}
+	msg, err := tracer.Receive(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(msg.Headers["k"]), "v"; got != want {
+		t.Errorf("bad header: got %q, want %q", got, want)
+	}
+	if _, ok := msg.Headers["traceparent"]; !ok {
+		t.Error("no trace context in headers")
+	}
+	if !trace.SpanContextFromContext(msg.Context()).IsValid() {
+		t.Error("no span in message context")
+	}
+	if err := msg.Ack(); err != nil {
+		t.Fatal(err)
+	}
+	if err := msg.Ack(); err != lasr.ErrAckNack {
+		t.Errorf("bad error: got %v, want %v", err, lasr.ErrAckNack)
+	}
+
+	spans := recorder.Ended()
+	if got, want := len(spans), 2; got != want {
+		t.Fatalf("bad span count: got %d, want %d", got, want)
+	}
+	producer, consumer := spans[0], spans[1]
+	if got, want := producer.SpanKind(), trace.SpanKindProducer; got != want {
+		t.Errorf("bad span kind: got %s, want %s", got, want)
+	}
+	if got, want := consumer.SpanKind(), trace.SpanKindConsumer; got != want {
+		t.Errorf("bad span kind: got %s, want %s", got, want)
+	}
+	links := consumer.Links()
+	if len(links) != 1 || links[0].SpanContext.SpanID() != producer.SpanContext().SpanID() {
+		t.Errorf("consumer span is not linked to the producer span: %v", links)
+	}
+	if events := consumer.Events(); len(events) != 1 || events[0].Name != "ack" {
+		t.Errorf("bad events: %v", events)
+	}
+}
@@ -0,0 +1,144 @@
+// Package lasrprom exports lasr queue metrics to Prometheus.
+//
+// Metrics are computed from Q.Stats and Q.Lag each time the collector is scraped, so
+// they reflect the persisted state of the queue, including messages sent and
+// acked by previous processes. Receive latency can't be computed from Stats,
+// so consumers report it with Collector.ObserveReceive.
+package lasrprom
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sensu/lasr"
+)
+
+// Collector is a prometheus.Collector for a lasr.Q.
+type Collector struct {
+	q            *lasr.Q
+	messages     *prometheus.Desc
+	sent         *prometheus.Desc
+	acked        *prometheus.Desc
+	nacked       *prometheus.Desc
+	deadLettered *prometheus.Desc
+	expired      *prometheus.Desc
+	oldest       *prometheus.Desc
+	scrapeErrors prometheus.Counter
+	latency      prometheus.Histogram
+}
+
+// NewCollector creates a Collector for q. Every metric carries a "queue"
+// label set to the name of q, so that collectors for several queues can be
+// registered with the same registry.
+func NewCollector(q *lasr.Q) *Collector {
+	labels := prometheus.Labels{"queue": q.Name()}
+	return &Collector{
+		q: q,
+		messages: prometheus.NewDesc(
+			"lasr_messages",
+			"Number of messages in the queue, by state.",
+			[]string{"state"}, labels,
+		),
+		sent: prometheus.NewDesc(
+			"lasr_sent_total",
+			"Total number of messages sent to the queue.",
+			nil, labels,
+		),
+		acked: prometheus.NewDesc(
+			"lasr_acked_total",
+			"Total number of messages acked.",
+			nil, labels,
+		),
+		nacked: prometheus.NewDesc(
+			"lasr_nacked_total",
+			"Total number of messages nacked, with or without retry.",
+			nil, labels,
+		),
+		deadLettered: prometheus.NewDesc(
+			"lasr_dead_lettered_total",
+			"Total number of messages moved to the dead-letter queue.",
+			nil, labels,
+		),
+		expired: prometheus.NewDesc(
+			"lasr_expired_total",
+			"Total number of messages dead-lettered or deleted because their deadline passed.",
+			nil, labels,
+		),
+		oldest: prometheus.NewDesc(
+			"lasr_oldest_message_age_seconds",
+			"Age of the oldest ready message since it was sent, and of the oldest unacked message since it was delivered.",
+			[]string{"state"}, labels,
+		),
+		scrapeErrors: prometheus.NewCounter(prometheus.CounterOpts{
+			Name:        "lasr_scrape_errors_total",
+			Help:        "Total number of errors reading queue statistics.",
+			ConstLabels: labels,
+		}),
+		latency: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Name:        "lasr_receive_latency_seconds",
+			Help:        "Time from sending a message to receiving it.",
+			ConstLabels: labels,
+			Buckets:     prometheus.ExponentialBuckets(0.001, 4, 10),
+		}),
+	}
+}
+
+// ObserveReceive records the receive latency of msg, which must have been
+// received from the Q of c. Messages that were sent before lasr recorded send
+// times are ignored. Delayed messages are observed from when Delay was called.
+func (c *Collector) ObserveReceive(msg *lasr.Message) {
+	if msg.EnqueuedAt.IsZero() {
+		return
+	}
+	c.latency.Observe(time.Since(msg.EnqueuedAt).Seconds())
+}
+
+// Describe implements prometheus.Collector.
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.messages
+	ch <- c.sent
+	ch <- c.acked
+	ch <- c.nacked
+	ch <- c.deadLettered
+	ch <- c.expired
+	ch <- c.oldest
+	c.scrapeErrors.Describe(ch)
+	c.latency.Describe(ch)
+}
+
+// Collect implements prometheus.Collector.
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	defer c.scrapeErrors.Collect(ch)
+	defer c.latency.Collect(ch)
+	stats, err := c.q.Stats()
+	if err != nil {
+		c.scrapeErrors.Inc()
+		return
+	}
+	states := []struct {
+		name  string
+		count int
+	}{
+		{"ready", stats.Ready},
+		{"unacked", stats.Unacked},
+		{"delayed", stats.Delayed},
+		{"waiting", stats.Waiting},
+		{"retrying", stats.Retrying},
+		{"returned", stats.Returned},
+	}
+	for _, s := range states {
+		ch <- prometheus.MustNewConstMetric(c.messages, prometheus.GaugeValue, float64(s.count), s.name)
+	}
+	ch <- prometheus.MustNewConstMetric(c.sent, prometheus.CounterValue, float64(stats.Sent))
+	ch <- prometheus.MustNewConstMetric(c.acked, prometheus.CounterValue, float64(stats.Acked))
+	ch <- prometheus.MustNewConstMetric(c.nacked, prometheus.CounterValue, float64(stats.Nacked))
+	ch <- prometheus.MustNewConstMetric(c.deadLettered, prometheus.CounterValue, float64(stats.DeadLettered))
+	ch <- prometheus.MustNewConstMetric(c.expired, prometheus.CounterValue, float64(stats.Expired))
+	lag, err := c.q.Lag()
+	if err != nil {
+		c.scrapeErrors.Inc()
+		return
+	}
+	ch <- prometheus.MustNewConstMetric(c.oldest, prometheus.GaugeValue, lag.OldestReady.Seconds(), "ready")
+	ch <- prometheus.MustNewConstMetric(c.oldest, prometheus.GaugeValue, lag.OldestUnacked.Seconds(), "unacked")
+}
@@ -0,0 +1,155 @@
+package lasrprom
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/sensu/lasr"
+)
+
+func TestCollector(t *testing.T) {
+	q, err := lasr.NewTempQ("testing", lasr.WithDeadLetters())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer q.Close()
+	for i := 0; i < 3; i++ {
+		if _, err := q.Send([]byte("foo")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	m, err := q.Receive(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Ack(); err != nil {
+		t.Fatal(err)
+	}
+	m, err = q.Receive(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Nack(false); err != nil {
+		t.Fatal(err)
+	}
+
+	reg := prometheus.NewPedanticRegistry()
+	if err := reg.Register(NewCollector(q)); err != nil {
+		t.Fatal(err)
+	}
+	want := `
+# HELP lasr_acked_total Total number of messages acked.
+# TYPE lasr_acked_total counter
+lasr_acked_total{queue="testing"} 1
+# HELP lasr_dead_lettered_total Total number of messages moved to the dead-letter queue.
+# TYPE lasr_dead_lettered_total counter
+lasr_dead_lettered_total{queue="testing"} 1
+# HELP lasr_messages Number of messages in the queue, by state.
+# TYPE lasr_messages gauge
+lasr_messages{queue="testing",state="delayed"} 0
+lasr_messages{queue="testing",state="ready"} 1
+lasr_messages{queue="testing",state="retrying"} 0
+lasr_messages{queue="testing",state="returned"} 1
+lasr_messages{queue="testing",state="unacked"} 0
+lasr_messages{queue="testing",state="waiting"} 0
+# HELP lasr_nacked_total Total number of messages nacked, with or without retry.
+# TYPE lasr_nacked_total counter
+lasr_nacked_total{queue="testing"} 1
+# HELP lasr_sent_total Total number of messages sent to the queue.
+# TYPE lasr_sent_total counter
+lasr_sent_total{queue="testing"} 3
+`
+	names := []string{
+		"lasr_acked_total",
+		"lasr_dead_lettered_total",
+		"lasr_messages",
+		"lasr_nacked_total",
+		"lasr_sent_total",
+	}
+	if err := testutil.GatherAndCompare(reg, strings.NewReader(want), names...); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestObserveReceive(t *testing.T) {
+	q, err := lasr.NewTempQ("testing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer q.Close()
+	if _, err := q.Send([]byte("foo")); err != nil {
+		t.Fatal(err)
+	}
+	m, err := q.Receive(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Ack()
+
+	c := NewCollector(q)
+	c.ObserveReceive(m)
+	c.ObserveReceive(&lasr.Message{})
+
+	reg := prometheus.NewPedanticRegistry()
+	if err := reg.Register(c); err != nil {
+		t.Fatal(err)
+	}
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range families {
+		if f.GetName() != "lasr_receive_latency_seconds" {
+			continue
+		}
+		if got, want := f.GetMetric()[0].GetHistogram().GetSampleCount(), uint64(1); got != want {
+			t.Errorf("bad sample count: got %d, want %d", got, want)
+		}
+		return
+	}
+	t.Error("no receive latency metric")
+}
+
+func TestCollectorLag(t *testing.T) {
+	q, err := lasr.NewTempQ("testing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer q.Close()
+	if _, err := q.Send([]byte("foo")); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(10 * time.Millisecond)
+
+	reg := prometheus.NewPedanticRegistry()
+	if err := reg.Register(NewCollector(q)); err != nil {
+		t.Fatal(err)
+	}
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ages := make(map[string]float64)
+	for _, f := range families {
+		if f.GetName() != "lasr_oldest_message_age_seconds" {
+			continue
+		}
+		for _, m := range f.GetMetric() {
+			for _, l := range m.GetLabel() {
+				if l.GetName() == "state" {
+					ages[l.GetValue()] = m.GetGauge().GetValue()
+				}
+			}
+		}
+	}
+	if ages["ready"] < 0.01 {
+		t.Errorf("bad ready age: %v", ages["ready"])
+	}
+	if age, ok := ages["unacked"]; !ok || age != 0 {
+		t.Errorf("bad unacked age: %v", age)
+	}
+}
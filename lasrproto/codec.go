@@ -0,0 +1,56 @@
+// Package lasrproto provides a lasr.Codec for protocol buffer messages.
+package lasrproto
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/sensu/lasr"
+	"google.golang.org/protobuf/proto"
+)
+
+// Codec encodes values that implement proto.Message in the protobuf wire
+// format.
+var Codec lasr.Codec = codec{}
+
+type codec struct{}
+
+func (codec) ContentType() string {
+	return "application/x-protobuf"
+}
+
+func (codec) Marshal(v interface{}) ([]byte, error) {
+	m, ok := v.(proto.Message)
+	if !ok {
+		return nil, fmt.Errorf("lasrproto: %T is not a proto.Message", v)
+	}
+	return proto.Marshal(m)
+}
+
+// Unmarshal decodes data into v, which is either a proto.Message or a pointer
+// to one. A nil proto.Message that v points to is allocated first.
+func (codec) Unmarshal(data []byte, v interface{}) error {
+	m, ok := v.(proto.Message)
+	if !ok {
+		m, ok = indirect(v)
+	}
+	if !ok {
+		return fmt.Errorf("lasrproto: %T is not a proto.Message", v)
+	}
+	return proto.Unmarshal(data, m)
+}
+
+// indirect returns the proto.Message that v points to, allocating it if it is
+// nil.
+func indirect(v interface{}) (proto.Message, bool) {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Ptr {
+		return nil, false
+	}
+	elem := rv.Elem()
+	if elem.IsNil() {
+		elem.Set(reflect.New(elem.Type().Elem()))
+	}
+	m, ok := elem.Interface().(proto.Message)
+	return m, ok
+}
@@ -0,0 +1,35 @@
+package lasrproto
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/sensu/lasr"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func TestCodec(t *testing.T) {
+	q, err := lasr.NewTempQ("testing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer q.Close()
+	tq := lasr.NewTypedQ[*wrapperspb.StringValue](q, Codec)
+
+	if _, err := tq.Send(wrapperspb.String("hello")); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	msg, err := tq.Receive(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := msg.Value.GetValue(), "hello"; got != want {
+		t.Errorf("bad value: got %q, want %q", got, want)
+	}
+	if err := msg.Ack(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,230 @@
+// Package lasrrpc makes calls over lasr queues: a Client sends a request to
+// one Q and waits for the matching reply on another, and Serve answers the
+// requests of a Q.
+//
+// Requests carry a correlation ID and the name of the Q to reply to in their
+// headers, and replies carry the correlation ID of their request. Replies
+// that arrive after their caller gave up are acked and dropped.
+package lasrrpc
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/sensu/lasr"
+)
+
+const (
+	// CorrelationHeader holds the correlation ID of requests and replies.
+	CorrelationHeader = "lasr-correlation-id"
+
+	// ReplyToHeader holds the name of the Q that a request is replied to.
+	ReplyToHeader = "lasr-reply-to"
+
+	// ErrorHeader holds the error that a request failed with, in replies.
+	ErrorHeader = "lasr-rpc-error"
+)
+
+// ErrClosed is returned by Call once the Client is closed.
+var ErrClosed = errors.New("lasrrpc: client is closed")
+
+// RemoteError is the error that a Handler returned for a request.
+type RemoteError string
+
+func (e RemoteError) Error() string {
+	return string(e)
+}
+
+// Client calls a server through a request Q, and receives the replies on a Q
+// of its own.
+type Client struct {
+	requests *lasr.Q
+	replies  *lasr.Q
+
+	mu      sync.Mutex
+	pending map[string]chan *lasr.Message
+	cancel  context.CancelFunc
+	done    chan struct{}
+	err     error
+}
+
+// NewClient creates a Client that sends requests to requests, and receives
+// their replies from replies, which it must be the only consumer of. Close
+// the Client to stop receiving replies.
+func NewClient(requests, replies *lasr.Q) *Client {
+	ctx, cancel := context.WithCancel(context.Background())
+	c := &Client{
+		requests: requests,
+		replies:  replies,
+		pending:  make(map[string]chan *lasr.Message),
+		cancel:   cancel,
+		done:     make(chan struct{}),
+	}
+	go c.receive(ctx)
+	return c
+}
+
+// Call sends a request with body and headers, and waits until its reply
+// arrives or ctx is done. The reply has already been acked. If the Handler
+// failed, Call returns a RemoteError.
+func (c *Client) Call(ctx context.Context, body []byte, headers map[string][]byte) (*lasr.Message, error) {
+	id, err := newCorrelationID()
+	if err != nil {
+		return nil, err
+	}
+	reply := make(chan *lasr.Message, 1)
+	c.mu.Lock()
+	if c.err != nil {
+		c.mu.Unlock()
+		return nil, c.err
+	}
+	c.pending[id] = reply
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		delete(c.pending, id)
+		c.mu.Unlock()
+	}()
+
+	request := make(map[string][]byte, len(headers)+2)
+	for k, v := range headers {
+		request[k] = v
+	}
+	request[CorrelationHeader] = []byte(id)
+	request[ReplyToHeader] = []byte(c.replies.Name())
+	if _, err := c.requests.SendWithHeaders(body, request); err != nil {
+		return nil, fmt.Errorf("lasrrpc: couldn't send request: %s", err)
+	}
+	select {
+	case msg := <-reply:
+		if msg == nil {
+			return nil, c.closedErr()
+		}
+		if remote, ok := msg.Headers[ErrorHeader]; ok {
+			return msg, RemoteError(remote)
+		}
+		return msg, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (c *Client) closedErr() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.err
+}
+
+// receive hands the replies out to their callers, until ctx is done or the
+// reply Q is closed.
+func (c *Client) receive(ctx context.Context) {
+	defer close(c.done)
+	for {
+		msg, err := c.replies.Receive(ctx)
+		if err != nil {
+			if err == context.Canceled {
+				err = ErrClosed
+			}
+			c.stop(err)
+			return
+		}
+		// A reply that can't be acked may be delivered again, so only
+		// hand it out once it is acked.
+		if err := msg.Ack(); err != nil {
+			continue
+		}
+		c.mu.Lock()
+		reply, ok := c.pending[string(msg.Headers[CorrelationHeader])]
+		c.mu.Unlock()
+		if ok {
+			select {
+			case reply <- msg:
+			default:
+				// The caller already has a reply.
+			}
+		}
+	}
+}
+
+// stop fails the pending calls, and the calls that follow, with err.
+func (c *Client) stop(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.err = err
+	for id, reply := range c.pending {
+		close(reply)
+		delete(c.pending, id)
+	}
+}
+
+// Close stops c from receiving replies. Pending calls return ErrClosed. It
+// does not close either Q.
+func (c *Client) Close() error {
+	c.cancel()
+	<-c.done
+	return nil
+}
+
+// Handler answers a request with the body and headers of its reply.
+type Handler func(ctx context.Context, req *lasr.Message) ([]byte, map[string][]byte, error)
+
+// Resolver returns the Q named name, which requests are replied to.
+type Resolver func(name string) (*lasr.Q, error)
+
+// Serve answers the requests in requests with handle, one at a time, until ctx
+// is done or requests is closed, and returns the error that stopped it. The
+// replies are sent to the Q that resolve returns for the ReplyToHeader of the
+// request. Errors that handle returns are sent back in the ErrorHeader of the
+// reply. Requests are acked once they are replied to, and requests without a
+// reply Q are acked without one.
+func Serve(ctx context.Context, requests *lasr.Q, resolve Resolver, handle Handler) error {
+	for {
+		req, err := requests.Receive(ctx)
+		if err != nil {
+			return err
+		}
+		if err := serve(ctx, req, resolve, handle); err != nil {
+			req.Nack(true)
+			return err
+		}
+		if err := req.Ack(); err != nil {
+			return err
+		}
+	}
+}
+
+func serve(ctx context.Context, req *lasr.Message, resolve Resolver, handle Handler) error {
+	to, ok := req.Headers[ReplyToHeader]
+	if !ok {
+		return nil
+	}
+	replies, err := resolve(string(to))
+	if err != nil {
+		return fmt.Errorf("lasrrpc: couldn't find reply queue %q: %s", to, err)
+	}
+	body, headers, err := handle(ctx, req)
+	reply := make(map[string][]byte, len(headers)+2)
+	for k, v := range headers {
+		reply[k] = v
+	}
+	reply[CorrelationHeader] = req.Headers[CorrelationHeader]
+	if err != nil {
+		reply[ErrorHeader] = []byte(err.Error())
+	}
+	if _, err := replies.SendWithHeaders(body, reply); err != nil {
+		return fmt.Errorf("lasrrpc: couldn't send reply: %s", err)
+	}
+	return nil
+}
+
+func newCorrelationID() (string, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", fmt.Errorf("lasrrpc: couldn't create correlation ID: %s", err)
+	}
+	return hex.EncodeToString(b[:]), nil
+}
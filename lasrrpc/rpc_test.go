@@ -0,0 +1,116 @@
+package lasrrpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sensu/lasr"
+)
+
+func newQ(t *testing.T, name string) *lasr.Q {
+	t.Helper()
+	q, err := lasr.NewTempQ(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { q.Close() })
+	return q
+}
+
+func TestCall(t *testing.T) {
+	requests, replies := newQ(t, "requests"), newQ(t, "replies")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	served := make(chan error, 1)
+	go func() {
+		served <- Serve(ctx, requests, func(name string) (*lasr.Q, error) {
+			if name != replies.Name() {
+				return nil, fmt.Errorf("no queue %q", name)
+			}
+			return replies, nil
+		}, func(ctx context.Context, req *lasr.Message) ([]byte, map[string][]byte, error) {
+			if string(req.Body) == "fail" {
+				return nil, nil, errors.New("failed")
+			}
+			return append([]byte("re: "), req.Body...), map[string][]byte{"k": []byte("v")}, nil
+		})
+	}()
+
+	c := NewClient(requests, replies)
+	defer c.Close()
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			body := fmt.Sprintf("%d", i)
+			reply, err := c.Call(ctx, []byte(body), nil)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if got, want := string(reply.Body), "re: "+body; got != want {
+				t.Errorf("got %q, want %q", got, want)
+			}
+			if got := string(reply.Headers["k"]); got != "v" {
+				t.Errorf("got header %q, want %q", got, "v")
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if _, err := c.Call(ctx, []byte("fail"), nil); err != RemoteError("failed") {
+		t.Errorf("got %v, want RemoteError", err)
+	}
+
+	cancel()
+	if err := <-served; err != context.Canceled {
+		t.Errorf("got %v, want context.Canceled", err)
+	}
+}
+
+func TestCallTimeout(t *testing.T) {
+	requests, replies := newQ(t, "requests"), newQ(t, "replies")
+	c := NewClient(requests, replies)
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := c.Call(ctx, []byte("nobody listens"), nil); err != context.DeadlineExceeded {
+		t.Errorf("got %v, want context.DeadlineExceeded", err)
+	}
+
+	// A late reply is dropped.
+	req, err := requests.Receive(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := serve(context.Background(), req, func(string) (*lasr.Q, error) { return replies, nil },
+		func(context.Context, *lasr.Message) ([]byte, map[string][]byte, error) { return nil, nil, nil }); err != nil {
+		t.Fatal(err)
+	}
+	if err := req.Ack(); err != nil {
+		t.Fatal(err)
+	}
+	for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(5 * time.Millisecond) {
+		stats, err := replies.Stats()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stats.Acked == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("late reply wasn't dropped")
+		}
+	}
+
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Call(context.Background(), nil, nil); err != ErrClosed {
+		t.Errorf("got %v, want ErrClosed", err)
+	}
+}
@@ -0,0 +1,182 @@
+package lasrseq
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sensu/lasr"
+	bolt "go.etcd.io/bbolt"
+)
+
+// clock returns a clock that starts at t, and moves by the given steps on
+// each call, repeating the last one.
+func clock(t time.Time, steps ...time.Duration) func() time.Time {
+	return func() time.Time {
+		now := t
+		if len(steps) > 1 {
+			t = t.Add(steps[0])
+			steps = steps[1:]
+		} else if len(steps) == 1 {
+			t = t.Add(steps[0])
+		}
+		return now
+	}
+}
+
+func checkIncreasing(t *testing.T, seq lasr.Sequencer, n int) {
+	t.Helper()
+	var last []byte
+	for i := 0; i < n; i++ {
+		id, err := seq.NextSequence()
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := id.MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if bytes.Compare(b, last) <= 0 {
+			t.Fatalf("id %d is not increasing: %x after %x", i, b, last)
+		}
+		last = b
+	}
+}
+
+func TestULIDSequencer(t *testing.T) {
+	seq := NewULIDSequencer(nil)
+	// The clock stands still, then goes backwards.
+	seq.now = clock(time.Now(), 0, 0, 0, -time.Second, time.Millisecond)
+	checkIncreasing(t, seq, 100)
+}
+
+func TestULIDString(t *testing.T) {
+	var u ULID
+	if got, want := u.String(), "00000000000000000000000000"; got != want {
+		t.Errorf("bad string: got %q, want %q", got, want)
+	}
+	for i := range u {
+		u[i] = 0xff
+	}
+	if got, want := u.String(), "7ZZZZZZZZZZZZZZZZZZZZZZZZZ"; got != want {
+		t.Errorf("bad string: got %q, want %q", got, want)
+	}
+}
+
+func TestULIDTime(t *testing.T) {
+	now := time.UnixMilli(time.Now().UnixMilli())
+	seq := NewULIDSequencer(nil)
+	seq.now = clock(now)
+	id, err := seq.NextSequence()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := id.(ULID).Time(); !got.Equal(now) {
+		t.Errorf("bad time: got %s, want %s", got, now)
+	}
+}
+
+func TestSnowflake(t *testing.T) {
+	seq, err := NewSnowflake(7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Enough IDs in one millisecond to run out of counter, then the clock
+	// goes backwards.
+	steps := make([]time.Duration, 5000)
+	steps = append(steps, -time.Second, time.Millisecond)
+	seq.now = clock(time.Now(), steps...)
+	checkIncreasing(t, seq, 6000)
+
+	id, err := seq.NextSequence()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := uint64(id.(lasr.Uint64ID))>>counterBits&MaxNode, uint64(7); got != want {
+		t.Errorf("bad node: got %d, want %d", got, want)
+	}
+}
+
+func TestSnowflakeNode(t *testing.T) {
+	if _, err := NewSnowflake(MaxNode + 1); err == nil {
+		t.Error("expected an error")
+	}
+}
+
+func TestWithSequencer(t *testing.T) {
+	q, err := lasr.NewTempQ("testing", lasr.WithSequencer(NewULIDSequencer(nil)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer q.Close()
+	id, err := q.Send([]byte("foo"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	msg, err := q.Receive(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer msg.Ack()
+	want, _ := id.MarshalBinary()
+	if !bytes.Equal(msg.ID, want) {
+		t.Errorf("bad id: got %x, want %x", msg.ID, want)
+	}
+}
+
+func TestPersistence(t *testing.T) {
+	dir := t.TempDir()
+	db, err := bolt.Open(filepath.Join(dir, "lasr.db"), 0600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	now := time.Now()
+	ulid := NewULIDSequencer(nil)
+	ulid.now = clock(now)
+	flake, _ := NewSnowflake(1)
+	flake.now = clock(now)
+	seqs := []lasr.Sequencer{ulid, flake}
+
+	last := make([]lasr.ID, len(seqs))
+	for i, seq := range seqs {
+		q, err := lasr.NewQ(db, fmt.Sprint(i), lasr.WithSequencer(seq))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if last[i], err = q.Send([]byte("foo")); err != nil {
+			t.Fatal(err)
+		}
+		q.Close()
+	}
+
+	// After a restart, the clock is an hour behind.
+	ulid = NewULIDSequencer(nil)
+	ulid.now = clock(now.Add(-time.Hour))
+	flake, _ = NewSnowflake(1)
+	flake.now = clock(now.Add(-time.Hour))
+	seqs = []lasr.Sequencer{ulid, flake}
+
+	for i, seq := range seqs {
+		q, err := lasr.NewQ(db, fmt.Sprint(i), lasr.WithSequencer(seq))
+		if err != nil {
+			t.Fatal(err)
+		}
+		id, err := q.Send([]byte("foo"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		q.Close()
+		a, _ := last[i].MarshalBinary()
+		b, _ := id.MarshalBinary()
+		if bytes.Compare(b, a) <= 0 {
+			t.Errorf("%T went backwards: %x after %x", seq, b, a)
+		}
+	}
+}
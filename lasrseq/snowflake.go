@@ -0,0 +1,99 @@
+package lasrseq
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/sensu/lasr"
+)
+
+const (
+	nodeBits    = 10
+	counterBits = 12
+
+	// MaxNode is the largest node number of a Snowflake.
+	MaxNode = 1<<nodeBits - 1
+)
+
+// Epoch is the time that Snowflake timestamps count from.
+var Epoch = time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// Snowflake generates 64-bit IDs made of a 41-bit timestamp in milliseconds
+// since Epoch, a 10-bit node number and a 12-bit counter. Each producer that
+// sends messages that may end up in the same queue must use its own node
+// number.
+//
+// The IDs of a Snowflake are strictly increasing. When the counter runs out
+// within a millisecond, or the clock goes backwards, a Snowflake carries on
+// from its last timestamp instead of waiting for the clock.
+type Snowflake struct {
+	mu      sync.Mutex
+	node    uint64
+	last    int64
+	counter uint64
+	now     func() time.Time
+}
+
+var _ lasr.PersistentSequencer = (*Snowflake)(nil)
+
+// NewSnowflake returns a Snowflake for node, which must be between 0 and
+// MaxNode.
+func NewSnowflake(node int) (*Snowflake, error) {
+	if node < 0 || node > MaxNode {
+		return nil, fmt.Errorf("lasrseq: invalid node %d", node)
+	}
+	return &Snowflake{node: uint64(node), last: -1, now: time.Now}, nil
+}
+
+// NextSequence returns the next ID as a lasr.Uint64ID.
+func (s *Snowflake) NextSequence() (lasr.ID, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ms := s.now().Sub(Epoch).Milliseconds()
+	if ms <= s.last {
+		s.counter++
+		ms = s.last
+		if s.counter == 1<<counterBits {
+			s.counter = 0
+			ms++
+		}
+	} else {
+		s.counter = 0
+	}
+	if ms < 0 || ms >= 1<<(63-nodeBits-counterBits) {
+		return nil, errors.New("lasrseq: time can't be encoded in a Snowflake")
+	}
+	s.last = ms
+	return lasr.Uint64ID(uint64(ms)<<(nodeBits+counterBits) | s.node<<counterBits | s.counter), nil
+}
+
+// Load restores the timestamp and counter of the last ID that s generated,
+// so that s never goes back to an earlier ID, even if the clock does.
+func (s *Snowflake) Load(state []byte) error {
+	if state == nil {
+		return nil
+	}
+	if len(state) != 8 {
+		return errors.New("lasrseq: bad snowflake state")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	last := binary.BigEndian.Uint64(state)
+	s.last = int64(last >> (nodeBits + counterBits))
+	s.counter = last & (1<<counterBits - 1)
+	return nil
+}
+
+// Save returns the timestamp and counter of the last ID that s generated.
+func (s *Snowflake) Save() ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	state := make([]byte, 8)
+	if s.last >= 0 {
+		binary.BigEndian.PutUint64(state, uint64(s.last)<<(nodeBits+counterBits)|s.counter)
+	}
+	return state, nil
+}
@@ -0,0 +1,124 @@
+// Package lasrseq provides lasr.Sequencer implementations that generate IDs
+// that are unique across processes and machines, and ordered by time.
+package lasrseq
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/sensu/lasr"
+)
+
+// ULID is a Universally Unique Lexicographically Sortable Identifier: a 48-bit
+// timestamp in milliseconds, followed by 80 random bits.
+type ULID [16]byte
+
+func (u ULID) MarshalBinary() ([]byte, error) {
+	return u[:], nil
+}
+
+func (u *ULID) UnmarshalBinary(b []byte) error {
+	if len(b) != len(u) {
+		return errors.New("lasrseq: ULIDs are 16 bytes long")
+	}
+	copy(u[:], b)
+	return nil
+}
+
+// Time returns the time that is encoded in u.
+func (u ULID) Time() time.Time {
+	var ms [8]byte
+	copy(ms[2:], u[:6])
+	return time.UnixMilli(int64(binary.BigEndian.Uint64(ms[:])))
+}
+
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// String returns the canonical 26 character encoding of u.
+func (u ULID) String() string {
+	// 128 bits are encoded 5 bits at a time, from the most significant
+	// bit, with 2 bits of padding in front.
+	var s [26]byte
+	hi := binary.BigEndian.Uint64(u[:8])
+	lo := binary.BigEndian.Uint64(u[8:])
+	for i := 25; i >= 0; i-- {
+		s[i] = crockford[lo&31]
+		lo = lo>>5 | hi<<59
+		hi >>= 5
+	}
+	return string(s[:])
+}
+
+// ULIDSequencer generates ULIDs that are strictly increasing. IDs generated
+// in the same millisecond, or after the clock went backwards, increment the
+// random bits of the previous ID instead of drawing new ones.
+type ULIDSequencer struct {
+	mu      sync.Mutex
+	entropy io.Reader
+	last    ULID
+	now     func() time.Time
+}
+
+var _ lasr.PersistentSequencer = (*ULIDSequencer)(nil)
+
+// NewULIDSequencer returns a ULIDSequencer that reads random bits from
+// entropy, or from crypto/rand if entropy is nil.
+func NewULIDSequencer(entropy io.Reader) *ULIDSequencer {
+	if entropy == nil {
+		entropy = rand.Reader
+	}
+	return &ULIDSequencer{entropy: entropy, now: time.Now}
+}
+
+func (s *ULIDSequencer) NextSequence() (lasr.ID, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ms := s.now().UnixMilli()
+	if ms < 0 || ms >= 1<<48 {
+		return nil, errors.New("lasrseq: time can't be encoded in a ULID")
+	}
+	var id ULID
+	var t [8]byte
+	binary.BigEndian.PutUint64(t[:], uint64(ms))
+	copy(id[:6], t[2:])
+	if string(id[:6]) <= string(s.last[:6]) {
+		// Increment the previous ID, as an 80-bit number.
+		id = s.last
+		i := len(id) - 1
+		for ; i >= 6; i-- {
+			id[i]++
+			if id[i] != 0 {
+				break
+			}
+		}
+		if i < 6 {
+			return nil, errors.New("lasrseq: too many ULIDs in one millisecond")
+		}
+	} else if _, err := io.ReadFull(s.entropy, id[6:]); err != nil {
+		return nil, err
+	}
+	s.last = id
+	return id, nil
+}
+
+// Load restores the last ID that s generated, so that s never goes back to
+// an earlier ID, even if the clock does.
+func (s *ULIDSequencer) Load(state []byte) error {
+	if state == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.last.UnmarshalBinary(state)
+}
+
+// Save returns the last ID that s generated.
+func (s *ULIDSequencer) Save() ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.last.MarshalBinary()
+}
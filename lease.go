@@ -0,0 +1,139 @@
+package lasr
+
+import (
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// The states of Message.once.
+const (
+	messageOpen int32 = iota
+	messageDone
+	messageExpired
+)
+
+// WithAckTimeout limits how long a received message can stay unacked. A
+// message that is neither acked nor nacked within timeout of being returned
+// by Receive is nacked for retry, and can then be received again. Acking or
+// nacking it afterwards returns ErrLeaseExpired. Consumers that need more
+// time can call Message.Touch.
+//
+// Timeouts are checked every tenth of timeout, or every second if that is
+// sooner, so messages can be kept a little longer than timeout, see
+// WithMaintenanceInterval.
+func WithAckTimeout(timeout time.Duration) Option {
+	return func(q *Q) error {
+		if q.optsApplied {
+			return ErrOptionsApplied
+		}
+		if timeout <= 0 {
+			return fmt.Errorf("lasr: invalid ack timeout: %s", timeout)
+		}
+		q.ackTimeout = timeout
+		return nil
+	}
+}
+
+// Touch extends the lease on m, so that it is not nacked by the ack timeout
+// of its Q until extend has passed, see WithAckTimeout. It returns
+// ErrLeaseExpired if m was already nacked for having timed out, and
+// ErrAckNack if m was acked or nacked.
+func (m *Message) Touch(extend time.Duration) error {
+	if m.q == nil {
+		return nil
+	}
+	if m.q.ackTimeout == 0 {
+		return errors.New("lasr: Q has no ack timeout")
+	}
+	q := m.q
+	q.leaseMu.Lock()
+	defer q.leaseMu.Unlock()
+	if state := atomic.LoadInt32(&m.once); state != messageOpen {
+		return doneErr(state)
+	}
+	q.leases[m] = time.Now().Add(extend)
+	return nil
+}
+
+// doneErr returns the error for acking or nacking a message in state.
+func doneErr(state int32) error {
+	if state == messageExpired {
+		return ErrLeaseExpired
+	}
+	return ErrAckNack
+}
+
+// finish marks m as acked or nacked. If m was already, it returns the error to
+// report.
+func (m *Message) finish() error {
+	if !atomic.CompareAndSwapInt32(&m.once, messageOpen, messageDone) {
+		return doneErr(atomic.LoadInt32(&m.once))
+	}
+	m.released()
+	if m.q != nil {
+		m.q.leaseMu.Lock()
+		delete(m.q.leases, m)
+		m.q.leaseMu.Unlock()
+	}
+	return nil
+}
+
+// reopen undoes finish, when acking or nacking m failed without any effect.
+// The ack timeout of m starts over.
+func (m *Message) reopen() {
+	if m.consumer != nil {
+		m.consumer.mu.Lock()
+		m.consumer.held++
+		m.consumer.mu.Unlock()
+	}
+	atomic.StoreInt32(&m.once, messageOpen)
+	m.q.lease(m)
+}
+
+// lease records that msg was just received, and starts its ack timeout.
+func (q *Q) lease(msg *Message) {
+	var deadline time.Time
+	if q.ackTimeout > 0 {
+		deadline = time.Now().Add(q.ackTimeout)
+	}
+	q.leaseMu.Lock()
+	q.leases[msg] = deadline
+	q.leaseMu.Unlock()
+}
+
+// expireLeases nacks the messages whose lease ended by now, and forgets the
+// messages that were acked or nacked. It returns how many it nacked.
+func (q *Q) expireLeases(now time.Time) (int, error) {
+	var expired []*Message
+	q.leaseMu.Lock()
+	for msg, deadline := range q.leases {
+		if atomic.LoadInt32(&msg.once) != messageOpen {
+			delete(q.leases, msg)
+			continue
+		}
+		if now.Before(deadline) {
+			continue
+		}
+		if atomic.CompareAndSwapInt32(&msg.once, messageOpen, messageExpired) {
+			delete(q.leases, msg)
+			msg.released()
+			expired = append(expired, msg)
+		}
+	}
+	q.leaseMu.Unlock()
+	var n int
+	for _, msg := range expired {
+		q.logger().Warn("lasr: ack timeout expired", "id", hexID(msg.ID), "attempts", msg.Attempts)
+		q.onExpire(msg.ID)
+		if err := q.nack(msg.ID, true, -1, ""); err != nil && err != ErrQClosed {
+			q.logger().Warn("lasr: couldn't nack expired message, retrying", "id", hexID(msg.ID), "error", err)
+			// Try again on the next tick.
+			msg.reopen()
+			continue
+		}
+		n++
+	}
+	return n, nil
+}
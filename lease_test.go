@@ -0,0 +1,107 @@
+package lasr
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestAckTimeout(t *testing.T) {
+	q, cleanup := newQ(t, WithAckTimeout(20*time.Millisecond))
+	defer cleanup()
+
+	if _, err := q.Send([]byte("foo")); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	first, err := q.Receive(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The message is delivered again once its lease expires.
+	second, err := q.Receive(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(second.ID), string(first.ID); got != want {
+		t.Errorf("bad id: got %x, want %x", got, want)
+	}
+	if got, want := second.Attempts, 2; got != want {
+		t.Errorf("bad attempts: got %d, want %d", got, want)
+	}
+	if err := first.Ack(); err != ErrLeaseExpired {
+		t.Errorf("bad error: got %v, want %v", err, ErrLeaseExpired)
+	}
+	if err := first.Touch(time.Second); err != ErrLeaseExpired {
+		t.Errorf("bad error: got %v, want %v", err, ErrLeaseExpired)
+	}
+	if err := second.Ack(); err != nil {
+		t.Fatal(err)
+	}
+	if err := second.Touch(time.Second); err != ErrAckNack {
+		t.Errorf("bad error: got %v, want %v", err, ErrAckNack)
+	}
+}
+
+func TestTouch(t *testing.T) {
+	q, cleanup := newQ(t, WithAckTimeout(20*time.Millisecond))
+	defer cleanup()
+
+	if _, err := q.Send([]byte("foo")); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	msg, err := q.Receive(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 5; i++ {
+		if err := msg.Touch(50 * time.Millisecond); err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err := msg.Ack(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTouchWithoutTimeout(t *testing.T) {
+	q, cleanup := newQ(t)
+	defer cleanup()
+
+	if _, err := q.Send([]byte("foo")); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	msg, err := q.Receive(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer msg.Ack()
+	if err := msg.Touch(time.Second); err == nil {
+		t.Error("expected an error")
+	}
+}
+
+func TestAckTimeoutClose(t *testing.T) {
+	q, cleanup := newQ(t, WithAckTimeout(20*time.Millisecond))
+	defer cleanup()
+
+	if _, err := q.Send([]byte("foo")); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if _, err := q.Receive(ctx); err != nil {
+		t.Fatal(err)
+	}
+	// Close doesn't wait for the abandoned message past its timeout.
+	if err := q.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,105 @@
+package lasr
+
+import (
+	"encoding/binary"
+	"fmt"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// processedBucket holds the ledger of the messages that may have been
+// processed already, keyed by message ID, with when they were recorded, in
+// nanoseconds since the epoch, see WithProcessedLedger.
+var processedBucket = []byte("processed")
+
+// WithProcessedLedger makes q remember, for window, the IDs of the messages
+// that consumers may have processed: the messages that were acked, the ones
+// whose ack came after their ack timeout, see WithAckTimeout, and the ones
+// that were Unacked when q was last closed abruptly, and are recovered in
+// place, see WithRecovery. When one of them is delivered again within window,
+// for example after a failover to a replica that missed the ack, its
+// PossiblyDuplicate method reports it, so that consumers can check whether
+// its side effects already happened.
+func WithProcessedLedger(window time.Duration) Option {
+	return func(q *Q) error {
+		if q.optsApplied {
+			return ErrOptionsApplied
+		}
+		if window <= 0 {
+			return fmt.Errorf("lasr: invalid processed ledger window: %s", window)
+		}
+		q.ledgerWindow = window
+		return nil
+	}
+}
+
+// PossiblyDuplicate reports whether m may have been processed before, see
+// WithProcessedLedger.
+func (m *Message) PossiblyDuplicate() bool {
+	return m.possiblyDuplicate
+}
+
+// recordProcessed records the message id in the ledger of q, if q has one,
+// and deletes the entries that are older than its window.
+func (q *Q) recordProcessed(tx *bolt.Tx, id []byte, now time.Time) error {
+	if q.ledgerWindow == 0 {
+		return nil
+	}
+	bucket, err := q.bucket(tx, processedBucket)
+	if err != nil {
+		return err
+	}
+	var v [8]byte
+	binary.BigEndian.PutUint64(v[:], uint64(now.UnixNano()))
+	if err := bucket.Put(id, v[:]); err != nil {
+		return err
+	}
+	// IDs mostly grow with time, so the oldest entries come first. The
+	// first entry that hasn't expired stops the pruning until it does.
+	cutoff := now.Add(-q.ledgerWindow).UnixNano()
+	cur := bucket.Cursor()
+	for k, v := cur.First(); k != nil && processedAt(v) <= cutoff; k, v = cur.First() {
+		if err := bucket.Delete(k); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// recordLateAck records the message id, whose ack came too late, in the
+// ledger of q.
+func (q *Q) recordLateAck(id []byte) {
+	if q.ledgerWindow == 0 || q.isClosed() {
+		return
+	}
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	err := q.db.Update(func(tx *bolt.Tx) error {
+		return q.recordProcessed(tx, id, time.Now())
+	})
+	if err != nil {
+		q.logger().Warn("lasr: couldn't record late ack", "id", hexID(id), "error", err)
+	}
+}
+
+// wasProcessed reports whether the message id is in the ledger of q, and was
+// recorded within its window of now.
+func (q *Q) wasProcessed(tx *bolt.Tx, id []byte, now time.Time) bool {
+	if q.ledgerWindow == 0 {
+		return false
+	}
+	bucket := q.readBucket(tx, processedBucket)
+	if bucket == nil {
+		return false
+	}
+	v := bucket.Get(id)
+	return v != nil && processedAt(v) > now.Add(-q.ledgerWindow).UnixNano()
+}
+
+func processedAt(v []byte) int64 {
+	if len(v) != 8 {
+		return 0
+	}
+	return int64(binary.BigEndian.Uint64(v))
+}
@@ -0,0 +1,78 @@
+package lasr
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestProcessedLedgerLateAck(t *testing.T) {
+	q, cleanup := newQ(t, WithProcessedLedger(time.Hour), WithAckTimeout(20*time.Millisecond))
+	defer cleanup()
+	sendBodies(t, q, "a")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	first, err := q.Receive(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.PossiblyDuplicate() {
+		t.Error("first delivery is a possible duplicate")
+	}
+	second, err := q.Receive(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second.PossiblyDuplicate() {
+		t.Error("redelivery before the late ack is a possible duplicate")
+	}
+	if err := first.Ack(); err != ErrLeaseExpired {
+		t.Fatalf("got %v, want ErrLeaseExpired", err)
+	}
+	if err := second.Nack(true); err != nil {
+		t.Fatal(err)
+	}
+	third, err := q.Receive(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !third.PossiblyDuplicate() {
+		t.Error("redelivery after the late ack isn't a possible duplicate")
+	}
+	if err := third.Ack(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestProcessedLedgerRecovery(t *testing.T) {
+	q, cleanup := newQ(t)
+	defer cleanup()
+	sendBodies(t, q, "a", "b")
+	crash(t, q, 1)
+
+	q, err := NewQ(q.db, "testing", WithProcessedLedger(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer q.Close()
+	for _, want := range []bool{true, false} {
+		msg, err := q.Receive(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := msg.PossiblyDuplicate(); got != want {
+			t.Errorf("%q: got PossiblyDuplicate %v, want %v", msg.Body, got, want)
+		}
+		if err := msg.Ack(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	stats, err := q.Stats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Acked != 2 {
+		t.Errorf("got %d acks, want 2", stats.Acked)
+	}
+}
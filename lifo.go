@@ -0,0 +1,52 @@
+package lasr
+
+import (
+	"bytes"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// WithLIFO makes q deliver the newest Ready message first, instead of the
+// oldest. Delayed messages that are due are still delivered before the Ready
+// messages, oldest first.
+//
+// Messages that are buffered for Receive are delivered before any message
+// that is sent after they were buffered, see WithMessageBufferSize.
+func WithLIFO() Option {
+	return func(q *Q) error {
+		if q.optsApplied {
+			return ErrOptionsApplied
+		}
+		q.lifo = true
+		return nil
+	}
+}
+
+// orderedCursor walks a bucket forwards or backwards.
+type orderedCursor struct {
+	*bolt.Cursor
+	reverse bool
+}
+
+func (c orderedCursor) First() ([]byte, []byte) {
+	if c.reverse {
+		return c.Cursor.Last()
+	}
+	return c.Cursor.First()
+}
+
+func (c orderedCursor) Next() ([]byte, []byte) {
+	if c.reverse {
+		return c.Cursor.Prev()
+	}
+	return c.Cursor.Next()
+}
+
+// cursor returns a cursor that walks the bucket at key in the order that its
+// messages are delivered in.
+func (q *Q) cursor(bucket *bolt.Bucket, key []byte) orderedCursor {
+	return orderedCursor{
+		Cursor:  bucket.Cursor(),
+		reverse: q.lifo && bytes.Equal(key, q.keys.ready),
+	}
+}
@@ -0,0 +1,62 @@
+package lasr
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestLIFO(t *testing.T) {
+	for _, size := range []int{0, 3} {
+		t.Run(fmt.Sprintf("buffer %d", size), func(t *testing.T) {
+			q, cleanup := newQ(t, WithLIFO(), WithMessageBufferSize(size))
+			defer cleanup()
+
+			for i := 0; i < 5; i++ {
+				if _, err := q.Send([]byte(fmt.Sprint(i))); err != nil {
+					t.Fatal(err)
+				}
+			}
+			peeked, err := q.Peek(1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got, want := string(peeked[0].Body), "4"; got != want {
+				t.Errorf("bad peek: got %q, want %q", got, want)
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			for _, want := range []string{"4", "3", "2", "1", "0"} {
+				msg, err := q.Receive(ctx)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got := string(msg.Body); got != want {
+					t.Errorf("bad body: got %q, want %q", got, want)
+				}
+				if err := msg.Ack(); err != nil {
+					t.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestLIFOReceiveWhere(t *testing.T) {
+	q, cleanup := newQ(t, WithLIFO())
+	defer cleanup()
+
+	sendKinds(t, q, "a", "b", "a")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	msg, err := q.ReceiveWhere(ctx, kindIs("a"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer msg.Ack()
+	if got, want := string(msg.ID), string([]byte{0, 0, 0, 0, 0, 0, 0, 3}); got != want {
+		t.Errorf("bad id: got %x, want %x", got, want)
+	}
+}
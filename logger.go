@@ -0,0 +1,35 @@
+package lasr
+
+import (
+	"encoding/hex"
+	"log/slog"
+)
+
+// WithLogger makes q log to l: recoveries of unacked messages, expired ack
+// timeouts and failed writes that are retried are logged as warnings, and
+// dead-lettering and compaction are logged for debugging. Every record has a
+// "queue" attribute with the name of q. By default, q does not log.
+func WithLogger(l *slog.Logger) Option {
+	return func(q *Q) error {
+		if q.optsApplied {
+			return ErrOptionsApplied
+		}
+		q.log = l.With("queue", string(q.name))
+		return nil
+	}
+}
+
+var discardLogger = slog.New(slog.DiscardHandler)
+
+// logger returns the logger of q.
+func (q *Q) logger() *slog.Logger {
+	if q.log == nil {
+		return discardLogger
+	}
+	return q.log
+}
+
+// hexID formats a message ID for logs.
+func hexID(id []byte) string {
+	return hex.EncodeToString(id)
+}
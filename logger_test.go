@@ -0,0 +1,51 @@
+package lasr
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWithLogger(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	q, cleanup := newQ(t, WithDeadLetters())
+	defer cleanup()
+	sendBodies(t, q, "a", "b")
+	crash(t, q, 1)
+
+	q, err := NewQ(q.db, "testing", WithDeadLetters(), WithLogger(logger))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer q.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	for i := 0; i < 2; i++ {
+		msg, err := q.Receive(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := msg.Nack(false); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := q.Compact(); err != nil {
+		t.Fatal(err)
+	}
+
+	out := buf.String()
+	for _, want := range []string{
+		`level=WARN msg="lasr: recovered unacked messages" queue=testing count=1 recovery=head`,
+		`level=DEBUG msg="lasr: dead-lettered message" queue=testing id=`,
+		`level=DEBUG msg="lasr: compacted database" queue=testing`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("missing %q in log:\n%s", want, out)
+		}
+	}
+}
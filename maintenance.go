@@ -0,0 +1,326 @@
+package lasr
+
+import (
+	"bytes"
+	"fmt"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// maintenanceBatch is how many messages a sweep looks at per transaction.
+const maintenanceBatch = 1000
+
+// minMaintenanceInterval is the shortest interval a background task runs at,
+// however short the ack timeout or TTL it is derived from.
+const minMaintenanceInterval = time.Millisecond
+
+// A Task is one of the sweeps that a Q runs in the background, see
+// WithMaintenanceInterval.
+type Task string
+
+// The sweeps that a Q can run.
+const (
+	// TaskDeadlines dead-letters or deletes the Ready and Delayed messages
+	// whose deadline passed, see SendWithDeadline. Without it, they are
+	// only expired when they are about to be delivered.
+	TaskDeadlines Task = "deadlines"
+
+	// TaskDue makes the messages that are Retrying and due Ready, and
+	// wakes the receivers of q if any Delayed messages are due.
+	TaskDue Task = "due"
+
+	// TaskLeases nacks the received messages whose ack timeout passed, see
+	// WithAckTimeout.
+	TaskLeases Task = "leases"
+
+	// TaskDeadLetters deletes the dead letters that outlived their TTL, see
+	// WithDeadLetterTTL.
+	TaskDeadLetters Task = "dead letters"
+)
+
+// Sweep describes a run of one of the background tasks of a Q, for the
+// OnSweep hook. Count is the number of messages it acted on, and Err the
+// error it failed with, if any.
+type Sweep struct {
+	Task     Task
+	Started  time.Time
+	Duration time.Duration
+	Count    int
+	Err      error
+}
+
+// WithMaintenanceInterval makes q run its background tasks every d, and
+// enables the ones that are off by default, TaskDeadlines and TaskDue. The
+// other tasks run when the options they serve are set, every tenth of the ack
+// timeout or every second for TaskLeases, and every tenth of the TTL or every
+// minute for TaskDeadLetters, whichever is sooner, unless d is set.
+//
+// The tasks of q run one at a time, in a single goroutine, and each run is
+// reported to the OnSweep hook, see WithHooks.
+func WithMaintenanceInterval(d time.Duration) Option {
+	return func(q *Q) error {
+		if q.optsApplied {
+			return ErrOptionsApplied
+		}
+		if d <= 0 {
+			return fmt.Errorf("lasr: invalid maintenance interval: %s", d)
+		}
+		q.maintenanceInterval = d
+		return nil
+	}
+}
+
+// maintenanceTask is a background task of q, that runs sweep every interval.
+type maintenanceTask struct {
+	task     Task
+	interval time.Duration
+	next     time.Time
+	// draining tasks keep running once q is closed, until the messages in
+	// flight are drained.
+	draining bool
+	sweep    func(now time.Time) (int, error)
+}
+
+// maintenanceTasks returns the background tasks that the options of q call
+// for.
+func (q *Q) maintenanceTasks() []*maintenanceTask {
+	var tasks []*maintenanceTask
+	add := func(task Task, interval time.Duration, draining bool, sweep func(time.Time) (int, error)) {
+		if q.maintenanceInterval > 0 {
+			interval = q.maintenanceInterval
+		}
+		if interval < minMaintenanceInterval {
+			interval = minMaintenanceInterval
+		}
+		tasks = append(tasks, &maintenanceTask{task: task, interval: interval, draining: draining, sweep: sweep})
+	}
+	if q.ackTimeout > 0 {
+		add(TaskLeases, minDuration(q.ackTimeout/10, time.Second), true, q.expireLeases)
+	}
+	if q.readOnly {
+		return tasks
+	}
+	if q.maintenanceInterval > 0 {
+		add(TaskDeadlines, 0, false, q.expireDeadlines)
+		add(TaskDue, 0, false, q.releaseDue)
+	}
+	if q.deadLetterTTL > 0 {
+		add(TaskDeadLetters, minDuration(q.deadLetterTTL/10, time.Minute), false, q.sweepDeadLetters)
+	}
+	return tasks
+}
+
+func minDuration(a, b time.Duration) time.Duration {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// startMaintenance starts running the background tasks of q.
+func (q *Q) startMaintenance() {
+	tasks := q.maintenanceTasks()
+	if len(tasks) == 0 {
+		return
+	}
+	tick := tasks[0].interval
+	now := time.Now()
+	for _, t := range tasks {
+		tick = minDuration(tick, t.interval)
+		t.next = now.Add(t.interval)
+	}
+	q.maintenanceStop = make(chan struct{})
+	q.maintenanceDone = make(chan struct{})
+	go q.maintenanceLoop(tasks, tick)
+}
+
+func (q *Q) maintenanceLoop(tasks []*maintenanceTask, tick time.Duration) {
+	defer close(q.maintenanceDone)
+	ticker := time.NewTicker(tick)
+	defer ticker.Stop()
+	for {
+		select {
+		case now := <-ticker.C:
+			for _, t := range tasks {
+				if now.Before(t.next) || (q.isClosed() && !t.draining) {
+					continue
+				}
+				t.next = now.Add(t.interval)
+				q.runSweep(t, now)
+			}
+		case <-q.maintenanceStop:
+			return
+		}
+	}
+}
+
+// runSweep runs t, and reports it.
+func (q *Q) runSweep(t *maintenanceTask, now time.Time) {
+	n, err := t.sweep(now)
+	if err == ErrQClosed {
+		return
+	}
+	if err != nil {
+		q.logger().Warn("lasr: background task failed", "task", t.task, "error", err)
+	}
+	q.onSweep(Sweep{
+		Task:     t.task,
+		Started:  now,
+		Duration: time.Since(now),
+		Count:    n,
+		Err:      err,
+	})
+}
+
+// stopMaintenance stops the background tasks of q, once the messages in
+// flight have been drained.
+func (q *Q) stopMaintenance() {
+	if q.maintenanceStop == nil {
+		return
+	}
+	close(q.maintenanceStop)
+	<-q.maintenanceDone
+}
+
+// expireDeadlines expires the Ready and Delayed messages whose deadline passed
+// by now, and returns how many it expired.
+func (q *Q) expireDeadlines(now time.Time) (int, error) {
+	var expired int
+	var after []byte
+	for {
+		n, next, err := q.expireDeadlineBatch(now, after)
+		expired += n
+		if err != nil || next == nil {
+			return expired, err
+		}
+		after = next
+	}
+}
+
+// expireDeadlineBatch looks at the metadata of up to maintenanceBatch
+// messages, after the key after, and expires the Ready and Delayed ones whose
+// deadline passed. It returns the last key it looked at, if there may be more.
+func (q *Q) expireDeadlineBatch(now time.Time, after []byte) (int, []byte, error) {
+	if q.isClosed() {
+		return 0, nil, ErrQClosed
+	}
+	var expired [][]byte
+	var next []byte
+	var wake bool
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	err := q.db.Update(func(tx *bolt.Tx) error {
+		expired, next, wake = nil, nil, false
+		meta := q.readBucket(tx, q.keys.meta)
+		if meta == nil {
+			return nil
+		}
+		var due [][]byte
+		var seen int
+		cur := meta.Cursor()
+		k, _ := cur.First()
+		if after != nil {
+			k, _ = cur.Seek(after)
+			if k != nil && string(k) == string(after) {
+				k, _ = cur.Next()
+			}
+		}
+		for ; k != nil; k, _ = cur.Next() {
+			if seen == maintenanceBatch {
+				next = cloneBytes(k)
+				break
+			}
+			seen++
+			md, err := q.getMeta(tx, k)
+			if err != nil {
+				return err
+			}
+			if q.expired(md, now) {
+				due = append(due, cloneBytes(k))
+			}
+		}
+		for _, id := range due {
+			for _, key := range [][]byte{q.keys.ready, q.keys.delayed} {
+				bucket := q.readBucket(tx, key)
+				if bucket == nil || bucket.Get(id) == nil {
+					continue
+				}
+				released, err := q.expire(tx, bucket, id)
+				if err != nil {
+					return err
+				}
+				wake = wake || released
+				expired = append(expired, id)
+				break
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, nil, err
+	}
+	if len(expired) > 0 {
+		q.signalSpace()
+	}
+	if len(q.keys.returned) > 0 {
+		for _, id := range expired {
+			q.onDeadLetter(id)
+		}
+	}
+	if wake && !q.isClosed() {
+		q.waker.Wake()
+	}
+	return len(expired), next, nil
+}
+
+// releaseDue makes the Retrying messages that are due by now Ready, and wakes
+// the receivers of q if there are any, or any Delayed messages that became
+// due since the last sweep. It returns how many messages became Ready or due.
+func (q *Q) releaseDue(now time.Time) (int, error) {
+	if q.isClosed() {
+		return 0, ErrQClosed
+	}
+	var n int
+	var counted []byte
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	err := q.db.Update(func(tx *bolt.Tx) error {
+		counted = q.dueCounted
+		released, err := q.releaseRetries(tx, now)
+		if err != nil {
+			return err
+		}
+		n = released
+		delayed := q.readBucket(tx, q.keys.delayed)
+		if delayed == nil {
+			return nil
+		}
+		until, err := Uint64ID(now.UnixNano()).MarshalBinary()
+		if err != nil {
+			return err
+		}
+		// Delayed messages are keyed by when they are due, so the ones up
+		// to the last one that was counted were already due before.
+		cur := delayed.Cursor()
+		k, _ := cur.First()
+		if counted != nil {
+			if k, _ = cur.Seek(counted); bytes.Equal(k, counted) {
+				k, _ = cur.Next()
+			}
+		}
+		for ; k != nil && string(k) <= string(until); k, _ = cur.Next() {
+			n++
+			counted = cloneBytes(k)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	q.dueCounted = counted
+	if n > 0 && !q.isClosed() {
+		q.waker.Wake()
+	}
+	return n, nil
+}
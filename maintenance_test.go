@@ -0,0 +1,168 @@
+package lasr
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+// sweepRecorder collects the sweeps reported to OnSweep.
+type sweepRecorder struct {
+	sync.Mutex
+	counts map[Task]int
+}
+
+func (r *sweepRecorder) hooks() Hooks {
+	r.counts = make(map[Task]int)
+	return Hooks{OnSweep: func(s Sweep) {
+		r.Lock()
+		defer r.Unlock()
+		if s.Err == nil {
+			r.counts[s.Task] += s.Count
+		}
+	}}
+}
+
+// waitCount waits for the sweeps of task to have acted on n messages.
+func (r *sweepRecorder) waitCount(t *testing.T, task Task, n int) {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for time.Now().Before(deadline) {
+		r.Lock()
+		got := r.counts[task]
+		r.Unlock()
+		if got >= n {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("%s: sweeps didn't act on %d messages", task, n)
+}
+
+func TestMaintenanceDeadlines(t *testing.T) {
+	var sweeps sweepRecorder
+	q, cleanup := newQ(t, WithDeadLetters(), WithMaintenanceInterval(5*time.Millisecond), WithHooks(sweeps.hooks()))
+	defer cleanup()
+	if _, err := q.SendWithDeadline([]byte("late"), time.Now().Add(10*time.Millisecond)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := q.Delay([]byte("later"), time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	sendBodies(t, q, "kept")
+	sweeps.waitCount(t, TaskDeadlines, 1)
+	stats, err := q.Stats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Ready != 1 || stats.Delayed != 1 || stats.Returned != 1 {
+		t.Errorf("got %d ready, %d delayed and %d returned, want 1 of each", stats.Ready, stats.Delayed, stats.Returned)
+	}
+	if got := receiveBodies(t, q, 1); got[0] != "kept" {
+		t.Errorf("got %q, want kept", got[0])
+	}
+}
+
+func TestMaintenanceDue(t *testing.T) {
+	var sweeps sweepRecorder
+	q, cleanup := newQ(t, WithMaintenanceInterval(5*time.Millisecond), WithHooks(sweeps.hooks()))
+	defer cleanup()
+	sendBodies(t, q, "foo")
+	if err := receiveN(t, q, 1)[0].NackDelay(10 * time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	sweeps.waitCount(t, TaskDue, 1)
+	stats, err := q.Stats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Ready != 1 || stats.Retrying != 0 {
+		t.Errorf("got %d ready and %d retrying, want 1 and 0", stats.Ready, stats.Retrying)
+	}
+	if got := receiveBodies(t, q, 1); got[0] != "foo" {
+		t.Errorf("got %q, want foo", got[0])
+	}
+}
+
+func TestMaintenanceDueDelayed(t *testing.T) {
+	q, cleanup := newQ(t)
+	defer cleanup()
+	now := time.Now()
+	for _, d := range []time.Duration{time.Hour, 2 * time.Hour} {
+		if _, err := q.Delay([]byte("foo"), now.Add(d)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Each Delayed message is counted once, when it becomes due.
+	for i, want := range []struct {
+		now time.Time
+		n   int
+	}{
+		{now.Add(90 * time.Minute), 1},
+		{now.Add(90 * time.Minute), 0},
+		{now.Add(3 * time.Hour), 1},
+		{now.Add(3 * time.Hour), 0},
+	} {
+		n, err := q.releaseDue(want.now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n != want.n {
+			t.Errorf("%d: got %d due messages, want %d", i, n, want.n)
+		}
+	}
+}
+
+func TestMaintenanceLeases(t *testing.T) {
+	var sweeps sweepRecorder
+	q, cleanup := newQ(t, WithAckTimeout(time.Hour), WithMaintenanceInterval(5*time.Millisecond), WithHooks(sweeps.hooks()))
+	defer cleanup()
+	sendBodies(t, q, "foo")
+	msg := receiveN(t, q, 1)[0]
+	q.leaseMu.Lock()
+	q.leases[msg] = time.Now()
+	q.leaseMu.Unlock()
+	sweeps.waitCount(t, TaskLeases, 1)
+	if err := msg.Ack(); err != ErrLeaseExpired {
+		t.Errorf("got %v, want ErrLeaseExpired", err)
+	}
+	if got := receiveBodies(t, q, 1); got[0] != "foo" {
+		t.Errorf("got %q, want foo", got[0])
+	}
+}
+
+func TestMaintenanceTasks(t *testing.T) {
+	q, cleanup := newQ(t)
+	defer cleanup()
+	if tasks := q.maintenanceTasks(); len(tasks) != 0 {
+		t.Errorf("got %d tasks by default, want none", len(tasks))
+	}
+	if _, err := NewQ(q.db, "other", WithMaintenanceInterval(0)); err == nil {
+		t.Error("expected an error for a zero interval")
+	}
+	other, err := NewQ(q.db, "other", WithAckTimeout(time.Minute), WithDeadLetters(), WithDeadLetterTTL(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer other.Close()
+	want := map[Task]time.Duration{TaskLeases: time.Second, TaskDeadLetters: time.Minute}
+	tasks := other.maintenanceTasks()
+	if len(tasks) != len(want) {
+		t.Fatalf("got %d tasks, want %d", len(tasks), len(want))
+	}
+	for _, task := range tasks {
+		if task.interval != want[task.task] {
+			t.Errorf("%s: got interval %s, want %s", task.task, task.interval, want[task.task])
+		}
+	}
+
+	// A tenth of the ack timeout rounds down to zero.
+	short, err := NewQ(q.db, "short", WithAckTimeout(5))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer short.Close()
+	if tasks := short.maintenanceTasks(); len(tasks) != 1 || tasks[0].interval != minMaintenanceInterval {
+		t.Errorf("got %d tasks, want one every %s", len(tasks), minMaintenanceInterval)
+	}
+}
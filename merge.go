@@ -0,0 +1,161 @@
+package lasr
+
+import (
+	"errors"
+	"fmt"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// imported is a message read from another Q by ImportFrom.
+type imported struct {
+	body []byte
+	md   *metadata
+}
+
+// ImportFrom copies the Ready and dead-lettered messages of other into q, and
+// returns how many it copied. The messages are given new IDs by q, and keep
+// their headers, when they were sent, and their deadlines. Dead letters also
+// keep why and when they were dead-lettered, and require q to use
+// WithDeadLetters.
+//
+// other may be in another database, and is left as it is, so it can be opened
+// with OpenReadOnly. Messages are copied a batch at a time, so if ImportFrom
+// fails, the messages it returns the count of have been copied already.
+// Copied Ready messages count towards the Sent counter, but are not subject
+// to WithMaxDepth.
+func (q *Q) ImportFrom(other *Q) (int, error) {
+	if q.isClosed() || other.isClosed() {
+		return 0, ErrQClosed
+	}
+	if q.readOnly {
+		return 0, ErrReadOnly
+	}
+	if q == other || (q.shared == other.shared && string(q.name) == string(other.name)) {
+		return 0, errors.New("lasr: can't import a queue into itself")
+	}
+	var n int
+	for _, state := range []Status{Ready, Returned} {
+		key := other.stateBucketKey(state)
+		if key == nil {
+			continue
+		}
+		next := []byte{}
+		for next != nil {
+			var batch []imported
+			var err error
+			batch, next, err = other.readImport(key, next)
+			if err != nil {
+				return n, fmt.Errorf("lasr: couldn't import queue: %s", err)
+			}
+			if len(batch) == 0 {
+				break
+			}
+			if err := q.commit(func(tx *bolt.Tx) error {
+				return q.importBatch(tx, state, batch)
+			}); err != nil {
+				return n, fmt.Errorf("lasr: couldn't import queue: %s", err)
+			}
+			n += len(batch)
+			if state == Ready && !q.isClosed() {
+				q.waker.Wake()
+				q.wakeSubscriptions()
+			}
+		}
+	}
+	return n, nil
+}
+
+// readImport returns up to replayBatch messages from the state bucket key of
+// q, from the ID from on, and the ID to read the next ones from, if there are
+// any.
+func (q *Q) readImport(key, from []byte) ([]imported, []byte, error) {
+	var batch []imported
+	var next []byte
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	err := q.db.View(func(tx *bolt.Tx) error {
+		bucket := q.readBucket(tx, key)
+		if bucket == nil {
+			return nil
+		}
+		cur := bucket.Cursor()
+		for k, v := cur.Seek(from); k != nil; k, v = cur.Next() {
+			if len(batch) == replayBatch {
+				next = cloneBytes(k)
+				return nil
+			}
+			body, err := q.openBody(tx, k, v)
+			if err != nil {
+				return fmt.Errorf("message %x: %s", k, err)
+			}
+			md, err := q.getMeta(tx, k)
+			if err != nil {
+				return err
+			}
+			if md == nil {
+				md = &metadata{}
+			}
+			batch = append(batch, imported{
+				body: cloneBytes(body),
+				md: &metadata{
+					Headers:      md.Headers,
+					Enqueued:     md.Enqueued,
+					Deadline:     md.Deadline,
+					Reason:       md.Reason,
+					DeadLettered: md.DeadLettered,
+					Error:        md.Error,
+				},
+			})
+		}
+		return nil
+	})
+	return batch, next, err
+}
+
+// importBatch stores batch in q, in the given state, under new IDs.
+func (q *Q) importBatch(tx *bolt.Tx, state Status, batch []imported) error {
+	for _, msg := range batch {
+		id, err := q.nextSequence(tx)
+		if err != nil {
+			return err
+		}
+		if state == Ready {
+			md := *msg.md
+			md.Reason, md.DeadLettered, md.Error = "", 0, ""
+			if err := q.send(id, msg.body, &md, tx); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := q.importDeadLetter(tx, id, msg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// importDeadLetter stores msg in the dead letters of q, as id.
+func (q *Q) importDeadLetter(tx *bolt.Tx, id ID, msg imported) error {
+	key, err := q.stateKey(Returned)
+	if err != nil {
+		return err
+	}
+	returned, err := q.bucket(tx, key)
+	if err != nil {
+		return err
+	}
+	k, err := id.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	value, err := q.sealBody(tx, k, msg.body)
+	if err != nil {
+		return err
+	}
+	if err := returned.Put(k, value); err != nil {
+		return err
+	}
+	q.touch(tx, k)
+	return q.putMeta(tx, k, msg.md)
+}
@@ -0,0 +1,85 @@
+package lasr
+
+import (
+	"testing"
+)
+
+func TestImportFrom(t *testing.T) {
+	src, cleanup := newQ(t, WithEncryption(testKey), WithDeadLetters())
+	defer cleanup()
+	dst, cleanup2 := newQ(t, WithDeadLetters())
+	defer cleanup2()
+
+	sendBodies(t, src, "dead")
+	msg := receiveN(t, src, 1)[0]
+	if err := msg.Nack(false); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := src.SendWithHeaders([]byte("a"), map[string][]byte{"body": []byte("a")}); err != nil {
+		t.Fatal(err)
+	}
+	sendBodies(t, src, "b")
+	sendBodies(t, dst, "first")
+
+	n, err := dst.ImportFrom(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 3 {
+		t.Errorf("imported %d messages, want 3", n)
+	}
+
+	msgs := receiveN(t, dst, 3)
+	for i, want := range []string{"first", "a", "b"} {
+		if got := string(msgs[i].Body); got != want {
+			t.Errorf("message %d: got %q, want %q", i, got, want)
+		}
+		if err := msgs[i].Ack(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := string(msgs[1].Headers["body"]); got != "a" {
+		t.Errorf("bad header: got %q, want %q", got, "a")
+	}
+	key, err := Uint64ID(4).MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dead, err := dst.Get(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dead.State != Returned || string(dead.Body) != "dead" {
+		t.Errorf("got %s message %q, want dead-lettered %q", dead.State, dead.Body, "dead")
+	}
+	if dead.DeadLetterReason != DeadLetterNacked || dead.DeadLetteredAt.IsZero() {
+		t.Errorf("bad dead letter: reason %q at %s", dead.DeadLetterReason, dead.DeadLetteredAt)
+	}
+
+	stats, err := src.Stats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Ready != 2 || stats.Returned != 1 {
+		t.Errorf("source changed: %d ready, %d returned", stats.Ready, stats.Returned)
+	}
+}
+
+func TestImportFromErrors(t *testing.T) {
+	src, cleanup := newQ(t, WithDeadLetters())
+	defer cleanup()
+	if _, err := src.ImportFrom(src); err == nil {
+		t.Error("expected an error importing a queue into itself")
+	}
+
+	sendBodies(t, src, "dead")
+	msg := receiveN(t, src, 1)[0]
+	if err := msg.Nack(false); err != nil {
+		t.Fatal(err)
+	}
+	dst, cleanup2 := newQ(t)
+	defer cleanup2()
+	if _, err := dst.ImportFrom(src); err == nil {
+		t.Error("expected an error importing dead letters without WithDeadLetters")
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"encoding"
 	"encoding/binary"
+	"time"
 )
 
 // ID is used for uniquely identifying messages in a Q.
@@ -27,11 +28,47 @@ func (id *Uint64ID) UnmarshalBinary(b []byte) error {
 // Message is a messaged returned from Q on Receive.
 //
 // Message contains a Body and an ID. The ID will be equal to the ID that was
-// returned on Send, Delay or Wait for this message.
+// returned on Send, Delay or Wait for this message. Headers holds the headers
+// the message was sent with, if any. EnqueuedAt is when the message was sent,
+// or the zero time if it was sent before lasr recorded it.
+//
+// Attempts is the number of times the message has been delivered, including
+// this delivery, and LastDeliveredAt is when it was most recently taken from
+// the Ready state for delivery. Deliveries are counted when a message is
+// buffered for Receive, so a message that was buffered when its Q was closed
+// counts as an attempt.
+//
+// Deadline is when the message expires, or the zero time if it doesn't, see
+// SendWithDeadline.
+//
+// DeadLetterReason is why the message was dead-lettered, like
+// DeadLetterNacked, and DeadLetteredAt is when. DeadLetterError is the error
+// it was nacked with, if it was nacked with NackError. They are only set for
+// the messages of a dead-letter queue, see DeadLetters and
+// WithDeadLetterQueue.
 type Message struct {
-	Body []byte
-	ID   []byte
-	q    *Q
-	once int32
-	err  error
+	Body             []byte
+	ID               []byte
+	Headers          map[string][]byte
+	EnqueuedAt       time.Time
+	Attempts         int
+	LastDeliveredAt  time.Time
+	Deadline         time.Time
+	DeadLetterReason string
+	DeadLetteredAt   time.Time
+	DeadLetterError  string
+	q                *Q
+	once             int32
+	err              error
+
+	// chunksOf is the Q that holds the body of the message, if it was left
+	// in chunks for Open to read.
+	chunksOf *Q
+
+	// consumer is the Consumer that received the message, if any.
+	consumer *Consumer
+
+	// possiblyDuplicate is set if the message was in the processed ledger
+	// of its Q when it was delivered.
+	possiblyDuplicate bool
 }
@@ -0,0 +1,51 @@
+package lasr
+
+import (
+	"context"
+	"time"
+)
+
+// retryReceiveAfter is how long Messages waits before receiving again after
+// Receive returns an error.
+const retryReceiveAfter = 100 * time.Millisecond
+
+// Messages delivers messages from q to the returned channel until ctx is done
+// or q is closed, at which point the channel is closed. Messages must still
+// be acked or nacked by the caller.
+//
+// The channel is unbuffered, so messages are only received from q when the
+// caller is ready for them. If ctx is done after a message has been received
+// but before it could be delivered, the message is nacked for retry.
+//
+// Errors from Receive, other than the Q being closed or read-only, are
+// retried.
+func (q *Q) Messages(ctx context.Context) <-chan *Message {
+	c := make(chan *Message)
+	go func() {
+		defer close(c)
+		for {
+			msg, err := q.Receive(ctx)
+			if err != nil {
+				if err == ErrQClosed || err == ErrReadOnly || ctx.Err() != nil {
+					return
+				}
+				q.logger().Warn("lasr: couldn't receive message, retrying", "error", err)
+				select {
+				case <-time.After(retryReceiveAfter):
+					continue
+				case <-ctx.Done():
+					return
+				case <-q.closed:
+					return
+				}
+			}
+			select {
+			case c <- msg:
+			case <-ctx.Done():
+				_ = msg.Nack(true)
+				return
+			}
+		}
+	}()
+	return c
+}
@@ -0,0 +1,87 @@
+package lasr
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestMessages(t *testing.T) {
+	q, cleanup := newQ(t)
+	defer cleanup()
+
+	for _, body := range []string{"a", "b", "c"} {
+		if _, err := q.Send([]byte(body)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	messages := q.Messages(ctx)
+	for _, want := range []string{"a", "b", "c"} {
+		select {
+		case msg := <-messages:
+			if got := string(msg.Body); got != want {
+				t.Errorf("bad body: got %q, want %q", got, want)
+			}
+			if err := msg.Ack(); err != nil {
+				t.Fatal(err)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("timed out waiting for message")
+		}
+	}
+
+	if _, err := q.Send([]byte("d")); err != nil {
+		t.Fatal(err)
+	}
+	// Give the consumer a chance to receive "d" before it is cancelled, so
+	// that it has to be nacked.
+	time.Sleep(10 * time.Millisecond)
+	cancel()
+	select {
+	case msg, ok := <-messages:
+		if ok {
+			// The message won the race against cancellation.
+			if err := msg.Ack(); err != nil {
+				t.Fatal(err)
+			}
+		}
+	case <-time.After(time.Second):
+		t.Fatal("channel not closed after cancel")
+	}
+	select {
+	case _, ok := <-messages:
+		if ok {
+			t.Fatal("unexpected message")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("channel not closed after cancel")
+	}
+
+	stats, err := q.Stats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Unacked != 0 {
+		t.Errorf("bad unacked count: got %d, want 0", stats.Unacked)
+	}
+}
+
+func TestMessagesClose(t *testing.T) {
+	q, cleanup := newQ(t)
+	defer cleanup()
+
+	messages := q.Messages(context.Background())
+	if err := q.Close(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case _, ok := <-messages:
+		if ok {
+			t.Fatal("unexpected message")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("channel not closed after Close")
+	}
+}
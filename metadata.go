@@ -0,0 +1,106 @@
+package lasr
+
+import (
+	"encoding/json"
+	"fmt"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// metadata is persisted alongside a message, keyed by the message's ID, in
+// the queue's meta bucket. Message bodies are stored as-is in the state
+// buckets, so metadata stays in place while a message moves between states.
+//
+// Messages that have no metadata have no entry in the meta bucket.
+type metadata struct {
+	Headers map[string][]byte `json:"headers,omitempty"`
+	Retries int               `json:"retries,omitempty"`
+	// Enqueued is when the message was sent, in nanoseconds since the
+	// epoch. It is zero for messages sent before it was recorded.
+	Enqueued int64 `json:"enqueued,omitempty"`
+	// Attempts is the number of times the message has been delivered, and
+	// Delivered is when it was last delivered, in nanoseconds since the
+	// epoch.
+	Attempts  int   `json:"attempts,omitempty"`
+	Delivered int64 `json:"delivered,omitempty"`
+	// Deadline is when the message expires, in nanoseconds since the
+	// epoch, or zero if it doesn't, see SendWithDeadline.
+	Deadline int64 `json:"deadline,omitempty"`
+	// Reason is why the message was dead-lettered, if it was, and
+	// DeadLettered is when, in nanoseconds since the epoch.
+	Reason       string `json:"reason,omitempty"`
+	DeadLettered int64  `json:"deadLettered,omitempty"`
+	// Error is the error that the message was nacked with, see NackError.
+	Error string `json:"error,omitempty"`
+}
+
+func (m *metadata) empty() bool {
+	return m == nil || (len(m.Headers) == 0 && m.Retries == 0 && m.Enqueued == 0 &&
+		m.Attempts == 0 && m.Delivered == 0 && m.Deadline == 0 &&
+		m.Reason == "" && m.DeadLettered == 0 && m.Error == "")
+}
+
+// getMeta returns the metadata for key, or nil if it has none.
+func (q *Q) getMeta(tx *bolt.Tx, key []byte) (*metadata, error) {
+	var bucket *bolt.Bucket
+	if tx.Writable() {
+		var err error
+		bucket, err = q.bucket(tx, q.keys.meta)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		bucket = q.readBucket(tx, q.keys.meta)
+	}
+	if bucket == nil {
+		return nil, nil
+	}
+	v := bucket.Get(key)
+	if v == nil {
+		return nil, nil
+	}
+	var md metadata
+	if err := json.Unmarshal(v, &md); err != nil {
+		return nil, fmt.Errorf("lasr: error reading metadata for %x: %s", key, err)
+	}
+	return &md, nil
+}
+
+func (q *Q) putMeta(tx *bolt.Tx, key []byte, md *metadata) error {
+	bucket, err := q.bucket(tx, q.keys.meta)
+	if err != nil {
+		return err
+	}
+	if md.empty() {
+		return bucket.Delete(key)
+	}
+	v, err := json.Marshal(md)
+	if err != nil {
+		return err
+	}
+	return bucket.Put(key, v)
+}
+
+// retire removes what refers to the message id outside of its state buckets,
+// once the message leaves q: its selector indexes and its group. It must be
+// called before the metadata of id is deleted.
+func (q *Q) retire(tx *bolt.Tx, id []byte) error {
+	if err := q.unindex(tx, id); err != nil {
+		return err
+	}
+	return q.freeGroup(tx, id)
+}
+
+func (q *Q) deleteMeta(tx *bolt.Tx, key []byte) error {
+	if err := q.retire(tx, key); err != nil {
+		return err
+	}
+	if err := q.deleteBody(tx, key); err != nil {
+		return err
+	}
+	bucket, err := q.bucket(tx, q.keys.meta)
+	if err != nil {
+		return err
+	}
+	return bucket.Delete(key)
+}
@@ -0,0 +1,171 @@
+package lasr
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+func TestSendWithHeaders(t *testing.T) {
+	q, cleanup := newQ(t, WithDeadLetters())
+	defer cleanup()
+
+	headers := map[string][]byte{
+		"content-type":   []byte("text/plain"),
+		"correlation-id": []byte("1234"),
+	}
+	id, err := q.SendWithHeaders([]byte("foo"), headers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := id.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	// headers survive a retry
+	m, err := q.Receive(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Nack(true); err != nil {
+		t.Fatal(err)
+	}
+	m, err = q.Receive(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(m.Headers), len(headers); got != want {
+		t.Fatalf("bad headers: got %d, want %d", got, want)
+	}
+	for k, v := range headers {
+		if got := m.Headers[k]; !bytes.Equal(got, v) {
+			t.Errorf("bad header %q: got %q, want %q", k, got, v)
+		}
+	}
+
+	// and dead-lettering
+	if err := m.Nack(false); err != nil {
+		t.Fatal(err)
+	}
+	d, err := DeadLetters(q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err = d.Receive(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(m.Headers["correlation-id"]), "1234"; got != want {
+		t.Errorf("bad dead-letter header: got %q, want %q", got, want)
+	}
+	if err := m.Ack(); err != nil {
+		t.Fatal(err)
+	}
+
+	// metadata is removed with the message
+	err = q.db.View(func(tx *bolt.Tx) error {
+		if md, err := q.getMeta(tx, key); err != nil {
+			return err
+		} else if md != nil {
+			t.Errorf("metadata not deleted: %+v", md)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSendWithoutHeaders(t *testing.T) {
+	q, cleanup := newQ(t)
+	defer cleanup()
+
+	if _, err := q.Send([]byte("foo")); err != nil {
+		t.Fatal(err)
+	}
+	m, err := q.Receive(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Ack()
+	if m.Headers != nil {
+		t.Errorf("expected nil headers, got %v", m.Headers)
+	}
+	err = q.db.View(func(tx *bolt.Tx) error {
+		md, err := q.getMeta(tx, m.ID)
+		if err != nil {
+			return err
+		}
+		if md == nil || md.Headers != nil {
+			t.Errorf("headers stored for message without headers: %v", md)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestEnqueuedAt(t *testing.T) {
+	q, cleanup := newQ(t)
+	defer cleanup()
+
+	before := time.Now()
+	if _, err := q.Send([]byte("foo")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := q.Delay([]byte("bar"), time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	after := time.Now()
+	for i := 0; i < 2; i++ {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		m, err := q.Receive(ctx)
+		cancel()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if m.EnqueuedAt.Before(before) || m.EnqueuedAt.After(after) {
+			t.Errorf("bad enqueued time for %q: %s not in [%s, %s]", m.Body, m.EnqueuedAt, before, after)
+		}
+		if err := m.Ack(); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestAttempts(t *testing.T) {
+	q, cleanup := newQ(t)
+	defer cleanup()
+
+	if _, err := q.Send([]byte("foo")); err != nil {
+		t.Fatal(err)
+	}
+	var last time.Time
+	for attempt := 1; attempt <= 3; attempt++ {
+		m, err := q.Receive(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := m.Attempts, attempt; got != want {
+			t.Errorf("bad attempts: got %d, want %d", got, want)
+		}
+		if !m.LastDeliveredAt.After(last) {
+			t.Errorf("bad last delivery time: %s", m.LastDeliveredAt)
+		}
+		last = m.LastDeliveredAt
+		if attempt < 3 {
+			if err := m.Nack(true); err != nil {
+				t.Fatal(err)
+			}
+		} else if err := m.Ack(); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package lasr
+
+import (
+	"errors"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// MoveTo moves the Ready message with the given ID from q to dst, in a single
+// transaction, so that the message can neither be lost nor duplicated. The
+// message is given a new ID by dst, which is returned. Its headers are moved
+// along with it.
+//
+// q and dst must share the same bolt database. If the message is not in the
+// Ready state, ErrNotFound is returned. Messages waiting on the moved message
+// enter the Ready state, as if it had been nacked.
+func (q *Q) MoveTo(dst *Q, id []byte) (ID, error) {
+	ids, err := q.MoveBatchTo(dst, [][]byte{id})
+	if err != nil {
+		return nil, err
+	}
+	return ids[0], nil
+}
+
+// MoveBatchTo is like MoveTo, but moves several messages in one transaction.
+// If any of the messages can't be moved, none of them are. The new IDs are
+// returned in the same order as ids.
+func (q *Q) MoveBatchTo(dst *Q, ids [][]byte) ([]ID, error) {
+	if q.isClosed() || dst.isClosed() {
+		return nil, ErrQClosed
+	}
+	if q.readOnly || dst.readOnly {
+		return nil, ErrReadOnly
+	}
+	if q.shared != dst.shared {
+		return nil, errors.New("lasr: can't move messages between databases")
+	}
+	if q == dst || string(q.name) == string(dst.name) {
+		return nil, errors.New("lasr: can't move messages to the same queue")
+	}
+	newIDs := make([]ID, 0, len(ids))
+	var wake bool
+	// dst shares q's database, so holding q's lock is enough to keep the
+	// database from being replaced.
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	err := q.db.Update(func(tx *bolt.Tx) error {
+		ready, err := q.bucket(tx, q.keys.ready)
+		if err != nil {
+			return err
+		}
+		for _, id := range ids {
+			v := ready.Get(id)
+			if v == nil {
+				return ErrNotFound
+			}
+			body, err := q.openBody(tx, id, v)
+			if err != nil {
+				return err
+			}
+			md, err := q.getMeta(tx, id)
+			if err != nil {
+				return err
+			}
+			newID, err := dst.nextSequence(tx)
+			if err != nil {
+				return err
+			}
+			if err := dst.send(newID, body, md, tx); err != nil {
+				return err
+			}
+			if err := ready.Delete(id); err != nil {
+				return err
+			}
+			q.touch(tx, id)
+			if err := q.deleteMeta(tx, id); err != nil {
+				return err
+			}
+			released, err := q.stopWaitingOn(tx, id)
+			if err != nil {
+				return err
+			}
+			wake = wake || released
+			newIDs = append(newIDs, newID)
+		}
+		return q.adjustDepth(tx, -len(ids))
+	})
+	if err != nil {
+		return nil, err
+	}
+	q.signalSpace()
+	if !dst.isClosed() {
+		dst.waker.Wake()
+		dst.wakeSubscriptions()
+	}
+	if wake && !q.isClosed() {
+		q.waker.Wake()
+	}
+	return newIDs, nil
+}
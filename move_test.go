@@ -0,0 +1,101 @@
+package lasr
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestMoveTo(t *testing.T) {
+	retry, cleanup := newQ(t, WithEncryption(testKey))
+	defer cleanup()
+	main, err := NewQ(retry.db, "main")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer main.Close()
+
+	if _, err := main.Send([]byte("first")); err != nil {
+		t.Fatal(err)
+	}
+	var keys [][]byte
+	for _, body := range []string{"a", "b", "c"} {
+		id, err := retry.SendWithHeaders([]byte(body), map[string][]byte{"body": []byte(body)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		key, err := id.MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+		keys = append(keys, key)
+	}
+
+	id, err := retry.MoveTo(main, keys[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := id, Uint64ID(2); got != want {
+		t.Errorf("bad id: got %v, want %v", got, want)
+	}
+	if _, err := retry.MoveTo(main, keys[0]); err != ErrNotFound {
+		t.Errorf("bad error moving a message twice: got %v, want %v", err, ErrNotFound)
+	}
+	// a failed batch moves nothing
+	if _, err := retry.MoveBatchTo(main, [][]byte{keys[1], keys[0]}); err != ErrNotFound {
+		t.Errorf("bad error: got %v, want %v", err, ErrNotFound)
+	}
+	ids, err := retry.MoveBatchTo(main, keys[1:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(ids), 2; got != want {
+		t.Fatalf("bad id count: got %d, want %d", got, want)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	for _, want := range []string{"first", "a", "b", "c"} {
+		m, err := main.Receive(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := string(m.Body); got != want {
+			t.Errorf("bad body: got %q, want %q", got, want)
+		}
+		if want != "first" {
+			if got := string(m.Headers["body"]); got != want {
+				t.Errorf("bad header: got %q, want %q", got, want)
+			}
+		}
+		if err := m.Ack(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	stats, err := retry.Stats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Ready != 0 {
+		t.Errorf("messages left in source queue: %d", stats.Ready)
+	}
+}
+
+func TestMoveToOtherDB(t *testing.T) {
+	a, cleanupA := newQ(t)
+	defer cleanupA()
+	b, cleanupB := newQ(t)
+	defer cleanupB()
+
+	id, err := a.Send([]byte("foo"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := id.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := a.MoveTo(b, key); err == nil {
+		t.Error("expected error moving between databases")
+	}
+}
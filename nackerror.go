@@ -0,0 +1,39 @@
+package lasr
+
+import (
+	"fmt"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// NackError negatively acknowledges the Message without retry, like
+// Nack(false), and records err with it, so that its dead letter says what
+// failed, in DeadLetterError. err is formatted with %+v, so errors that print
+// a stack trace that way keep it. If q doesn't dead-letter messages, the error
+// is dropped along with the message.
+func (m *Message) NackError(err error) error {
+	var cause string
+	if err != nil {
+		cause = fmt.Sprintf("%+v", err)
+	}
+	nack := func() error {
+		return m.nack(false, -1, cause)
+	}
+	if m.q == nil || len(m.q.interceptors) == 0 {
+		return nack()
+	}
+	return m.q.interceptNack(m, false, nack)
+}
+
+// recordError records cause as the error that the message id was nacked with.
+func (q *Q) recordError(tx *bolt.Tx, id []byte, cause string) error {
+	md, err := q.getMeta(tx, id)
+	if err != nil {
+		return err
+	}
+	if md == nil {
+		md = &metadata{}
+	}
+	md.Error = cause
+	return q.putMeta(tx, id, md)
+}
@@ -0,0 +1,119 @@
+package lasr
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestNackError(t *testing.T) {
+	q, cleanup := newQ(t, WithDeadLetters())
+	defer cleanup()
+	sendBodies(t, q, "foo")
+	msg := receiveN(t, q, 1)[0]
+	if err := msg.NackError(errors.New("boom")); err != nil {
+		t.Fatal(err)
+	}
+	if err := msg.NackError(errors.New("again")); err != ErrAckNack {
+		t.Errorf("got %v, want ErrAckNack", err)
+	}
+
+	stored, err := q.Get(msg.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.State != Returned || stored.DeadLetterError != "boom" || stored.DeadLetterReason != DeadLetterNacked {
+		t.Errorf("got %s message with error %q for %q", stored.State, stored.DeadLetterError, stored.DeadLetterReason)
+	}
+
+	var buf bytes.Buffer
+	if err := q.DumpDeadLetters(&buf); err != nil {
+		t.Fatal(err)
+	}
+	var rec ExportRecord
+	if err := json.Unmarshal(buf.Bytes(), &rec); err != nil {
+		t.Fatal(err)
+	}
+	if rec.DeadLetterError != "boom" {
+		t.Errorf("got dumped error %q, want boom", rec.DeadLetterError)
+	}
+
+	dlq, err := DeadLetters(q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dlq.Close()
+	dead := receiveN(t, dlq, 1)[0]
+	if dead.DeadLetterError != "boom" {
+		t.Errorf("got error %q, want boom", dead.DeadLetterError)
+	}
+	if err := dead.Nack(true); err != nil {
+		t.Fatal(err)
+	}
+
+	if n, err := q.RedriveDeadLetters(0); err != nil || n != 1 {
+		t.Fatalf("redrove %d messages: %v", n, err)
+	}
+	redriven := receiveN(t, q, 1)[0]
+	if redriven.DeadLetterError != "" {
+		t.Errorf("redriven message kept its error %q", redriven.DeadLetterError)
+	}
+	if err := redriven.Ack(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestNackErrorDeadLetterQueue(t *testing.T) {
+	q, cleanup := newQ(t, WithDeadLetterQueue("dlq"))
+	defer cleanup()
+	sendBodies(t, q, "foo")
+	if err := receiveN(t, q, 1)[0].NackError(errors.New("boom")); err != nil {
+		t.Fatal(err)
+	}
+	dlq, err := NewQ(q.db, "dlq")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dlq.Close()
+	msg := receiveN(t, dlq, 1)[0]
+	if msg.DeadLetterError != "boom" {
+		t.Errorf("got error %q, want boom", msg.DeadLetterError)
+	}
+	if err := msg.Ack(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestConsumeRecordsError(t *testing.T) {
+	q, cleanup := newQ(t, WithDeadLetters(), WithHandlerPolicy(HandlerDeadLetter, HandlerStop))
+	defer cleanup()
+	sendBodies(t, q, "error", "panic")
+	err := q.Consume(context.Background(), 1, func(msg *Message) error {
+		if string(msg.Body) == "panic" {
+			panic("oops")
+		}
+		return errors.New("failed")
+	})
+	if _, ok := err.(*HandlerError); !ok {
+		t.Fatalf("got %v, want a *HandlerError", err)
+	}
+	dlq, err := DeadLetters(q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dlq.Close()
+	msg := receiveN(t, dlq, 1)[0]
+	if string(msg.Body) != "error" || msg.DeadLetterError != "failed" {
+		t.Errorf("got %q with error %q", msg.Body, msg.DeadLetterError)
+	}
+	if err := msg.Ack(); err != nil {
+		t.Fatal(err)
+	}
+	if msg, err := q.TryReceive(); err != nil {
+		t.Fatal(err)
+	} else if err := msg.Ack(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,58 @@
+package lasr
+
+import (
+	"fmt"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// WithBoltOptions sets the options that OpenQ opens the bolt database with,
+// such as the open timeout, the initial mmap size and the freelist type. It
+// can only be used with OpenQ: queues created with NewQ use a database that
+// is already open.
+//
+// Compact reopens the database with the same options.
+func WithBoltOptions(opts bolt.Options) Option {
+	return func(q *Q) error {
+		if q.optsApplied {
+			return ErrOptionsApplied
+		}
+		q.boltOptions = &opts
+		return nil
+	}
+}
+
+// OpenQ opens the bolt database at path, creating it if it doesn't exist,
+// and creates a Q named name in it. The database is opened with the options
+// given by WithBoltOptions, if any, and is closed when the Q is closed.
+//
+// To use several queues in one database, open it with bolt.Open and create
+// them with NewQ instead.
+func OpenQ(path, name string, options ...Option) (*Q, error) {
+	q, err := configure(name, options)
+	if err != nil {
+		return nil, err
+	}
+	db, err := bolt.Open(path, 0600, q.boltOptions)
+	if err != nil {
+		return nil, fmt.Errorf("lasr: couldn't open %s: %s", path, err)
+	}
+	if err := q.open(db); err != nil {
+		db.Close()
+		return nil, err
+	}
+	q.release = func() error {
+		return q.db.Close()
+	}
+	return q, nil
+}
+
+// reopenOptions returns the options to reopen the database of q with after
+// compaction.
+func (q *Q) reopenOptions() *bolt.Options {
+	if q.boltOptions == nil {
+		return dbOptions(q.db)
+	}
+	opts := *q.boltOptions
+	return &opts
+}
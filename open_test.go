@@ -0,0 +1,70 @@
+package lasr
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+func TestOpenQ(t *testing.T) {
+	td, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(td)
+	path := filepath.Join(td, "lasr.db")
+
+	q, err := OpenQ(path, "testing", WithBoltOptions(bolt.Options{
+		Timeout:         time.Second,
+		NoFreelistSync:  true,
+		FreelistType:    bolt.FreelistMapType,
+		InitialMmapSize: 1 << 20,
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !q.db.NoFreelistSync || q.db.FreelistType != bolt.FreelistMapType {
+		t.Error("bolt options not applied")
+	}
+	sendBodies(t, q, "a")
+	if err := q.Compact(); err != nil {
+		t.Fatal(err)
+	}
+	if !q.db.NoFreelistSync || q.db.FreelistType != bolt.FreelistMapType {
+		t.Error("bolt options not kept by Compact")
+	}
+	if err := q.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The database is closed along with q, so it can be opened again
+	// without waiting for the lock.
+	q, err = OpenQ(path, "testing", WithBoltOptions(bolt.Options{Timeout: time.Second}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer q.Close()
+	if got := receiveBodies(t, q, 1); got[0] != "a" {
+		t.Errorf("bad body: got %q, want %q", got[0], "a")
+	}
+}
+
+func TestWithBoltOptionsRequiresOpenQ(t *testing.T) {
+	td, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(td)
+	db, err := bolt.Open(filepath.Join(td, "lasr.db"), 0600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if _, err := NewQ(db, "testing", WithBoltOptions(bolt.Options{})); err == nil {
+		t.Error("expected an error")
+	}
+}
@@ -51,6 +51,7 @@ func WithMessageBufferSize(size int) Option {
 			return fmt.Errorf("lasr: invalid message buffer size: %d", size)
 		}
 		q.messages = newFifo(size + 1)
+		q.adaptive = nil
 		return nil
 	}
 }
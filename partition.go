@@ -0,0 +1,153 @@
+package lasr
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// PartitionKeyHeader is the header that holds the key of a message, which
+// picks its partition, see WithPartitions.
+const PartitionKeyHeader = "lasr-partition-key"
+
+// WithPartitions splits q into n partitions. Each message that is sent to q
+// is stored in one of them, chosen by the hash of its PartitionKeyHeader, so
+// that the messages with the same key keep their order. Messages without a
+// key are spread across the partitions by ID, and delayed messages by the
+// time they are due.
+//
+// The partitions are queues in their own right, opened with Partition, each
+// with its own Ready, Unacked and dead-letter states, so consumers drain
+// disjoint partitions without contending for the same buckets. q itself holds
+// no messages then, and Wait is not supported. Messages sent to the
+// subscriptions of q are not partitioned.
+//
+// The number of partitions is recorded when the queue is created, since
+// changing it would reorder the messages of a key, see ErrConfigMismatch.
+func WithPartitions(n int) Option {
+	return func(q *Q) error {
+		if q.optsApplied {
+			return ErrOptionsApplied
+		}
+		if n < 1 {
+			return fmt.Errorf("lasr: invalid partition count: %d", n)
+		}
+		q.partitions = n
+		return nil
+	}
+}
+
+// Partitions returns the number of partitions of q, or 0 if it isn't
+// partitioned.
+func (q *Q) Partitions() int {
+	return q.partitions
+}
+
+// Partition opens the partition i of q, which must be less than the number
+// of partitions of q, see WithPartitions. The options are applied to the
+// partition, except that it always uses the Sequencer, encryption and storage
+// settings of q, as for Subscribe. A partition can only be open once at a
+// time, and must be closed by the caller.
+//
+// The partition's bucket is named after q and the partition, separated by a
+// hash sign, so no other queue in the same db should use that name.
+func (q *Q) Partition(i int, options ...Option) (*Q, error) {
+	if q.isClosed() {
+		return nil, ErrQClosed
+	}
+	if q.partitions == 0 {
+		return nil, errors.New("lasr: Q is not partitioned")
+	}
+	if i < 0 || i >= q.partitions {
+		return nil, fmt.Errorf("lasr: invalid partition: %d", i)
+	}
+	q.partsMu.Lock()
+	defer q.partsMu.Unlock()
+	if p, ok := q.parts[i]; ok && !p.isClosed() {
+		return nil, fmt.Errorf("lasr: partition %d is already open", i)
+	}
+	q.mu.RLock()
+	db := q.db
+	q.mu.RUnlock()
+	options = append(options[:len(options):len(options)], subscriptionOf(q))
+	p, err := NewQ(db, string(partitionName(q.name, i)), options...)
+	if err != nil {
+		return nil, err
+	}
+	if q.parts == nil {
+		q.parts = make(map[int]*Q)
+	}
+	q.parts[i] = p
+	return p, nil
+}
+
+func partitionName(name []byte, i int) []byte {
+	return []byte(fmt.Sprintf("%s#%d", name, i))
+}
+
+// partitionOf returns the partition of the message key with headers.
+func (q *Q) partitionOf(key []byte, headers map[string][]byte) int {
+	if k, ok := headers[PartitionKeyHeader]; ok {
+		h := fnv.New32a()
+		h.Write(k)
+		return int(h.Sum32() % uint32(q.partitions))
+	}
+	if len(key) == 8 {
+		return int(binary.BigEndian.Uint64(key) % uint64(q.partitions))
+	}
+	// IDs of other sizes, from SendWithID or a Sequencer, are hashed.
+	h := fnv.New32a()
+	h.Write(key)
+	return int(h.Sum32() % uint32(q.partitions))
+}
+
+// partitionTarget returns a Q that can be used to store messages in the
+// partition i of q within tx.
+func (q *Q) partitionTarget(i int) *Q {
+	return q.storageFor(partitionName(q.name, i))
+}
+
+// wakePartition wakes the partition i of q once tx is committed, or at t if
+// it isn't zero, if the partition is open.
+func (q *Q) wakePartition(tx *bolt.Tx, i int, t time.Time) {
+	tx.OnCommit(func() {
+		q.partsMu.Lock()
+		defer q.partsMu.Unlock()
+		p, ok := q.parts[i]
+		if !ok || p.isClosed() {
+			return
+		}
+		if t.IsZero() {
+			p.waker.Wake()
+		} else {
+			p.waker.WakeAt(t)
+		}
+	})
+}
+
+// storedPartitions returns the number of partitions that is recorded in the
+// configuration of the queue whose bucket is root.
+func storedPartitions(root *bolt.Bucket) int {
+	var c config
+	if v := root.Get(configKey); v == nil || json.Unmarshal(v, &c) != nil {
+		return 0
+	}
+	return c.Partitions
+}
+
+// partitionQueues returns the names of the buckets of the partitions in tx.
+func partitionQueues(tx *bolt.Tx) map[string]bool {
+	names := make(map[string]bool)
+	_ = tx.ForEach(func(name []byte, root *bolt.Bucket) error {
+		for i := 0; i < storedPartitions(root); i++ {
+			names[string(partitionName(name, i))] = true
+		}
+		return nil
+	})
+	return names
+}
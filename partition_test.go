@@ -0,0 +1,156 @@
+package lasr
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestPartitions(t *testing.T) {
+	q, cleanup := newQ(t, WithPartitions(3))
+	defer cleanup()
+
+	for i := 0; i < 4; i++ {
+		for _, key := range []string{"a", "b", "c", "d", "e"} {
+			headers := map[string][]byte{PartitionKeyHeader: []byte(key)}
+			if _, err := q.SendWithHeaders([]byte(fmt.Sprintf("%s%d", key, i)), headers); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if _, err := q.Delay([]byte("delayed"), time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := q.Wait([]byte("waiting"), Uint64ID(1)); err == nil {
+		t.Error("expected an error for Wait")
+	}
+
+	keys := make(map[string]int)
+	got := make(map[string][]string)
+	var total int
+	for i := 0; i < q.Partitions(); i++ {
+		p, err := q.Partition(i)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := q.Partition(i); err == nil {
+			t.Error("expected an error for a partition that is open")
+		}
+		for {
+			msg, err := p.TryReceive()
+			if err == ErrEmpty {
+				break
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			total++
+			if key := string(msg.Headers[PartitionKeyHeader]); key != "" {
+				if other, ok := keys[key]; ok && other != i {
+					t.Errorf("key %q is in partitions %d and %d", key, other, i)
+				}
+				keys[key] = i
+				got[key] = append(got[key], string(msg.Body))
+			}
+			if err := msg.Ack(); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := p.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if total != 21 {
+		t.Errorf("got %d messages from the partitions, want 21", total)
+	}
+	for key, bodies := range got {
+		want := []string{key + "0", key + "1", key + "2", key + "3"}
+		if !reflect.DeepEqual(bodies, want) {
+			t.Errorf("got %q for key %q, want %q", bodies, key, want)
+		}
+	}
+	if stats, err := q.Stats(); err != nil {
+		t.Fatal(err)
+	} else if stats.Sent != 21 || stats.Ready != 0 {
+		t.Errorf("bad stats: %+v", stats)
+	}
+
+	names, err := ListQueues(q.db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(names, []string{"testing"}) {
+		t.Errorf("got queues %q, want only the partitioned one", names)
+	}
+}
+
+func TestPartitionWake(t *testing.T) {
+	q, cleanup := newQ(t, WithPartitions(1))
+	defer cleanup()
+	p, err := q.Partition(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	received := make(chan *Message)
+	go func() {
+		msg, err := p.Receive(context.Background())
+		if err != nil {
+			t.Error(err)
+		}
+		received <- msg
+	}()
+	time.Sleep(10 * time.Millisecond)
+	sendBodies(t, q, "a")
+	select {
+	case msg := <-received:
+		if err := msg.Ack(); err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("partition wasn't woken")
+	}
+
+	if _, err := NewQ(q.db, "testing", WithPartitions(2)); err != ErrConfigMismatch {
+		t.Errorf("got %v for another partition count, want ErrConfigMismatch", err)
+	}
+}
+
+func TestPartitionShortIDs(t *testing.T) {
+	q, cleanup := newQ(t, WithPartitions(3))
+	defer cleanup()
+	for _, id := range []string{"a", "abc", "0123456789"} {
+		if err := q.SendWithID(rawID(id), []byte(id)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var total int
+	for i := 0; i < q.Partitions(); i++ {
+		p, err := q.Partition(i)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for {
+			msg, err := p.TryReceive()
+			if err == ErrEmpty {
+				break
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			total++
+			if err := msg.Ack(); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := p.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if total != 3 {
+		t.Errorf("got %d messages, want 3", total)
+	}
+}
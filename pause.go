@@ -0,0 +1,54 @@
+package lasr
+
+import "context"
+
+// Pause stops q from handing out messages until Resume is called. Receive,
+// ReceiveWhere and ReceiveSelect block while q is paused, and TryReceive
+// returns ErrPaused. Sends are still accepted, and messages that are already
+// unacked can still be acked and nacked.
+//
+// A receive that is already taking a message when Pause is called may still
+// return it. The pause is not persisted, so q is resumed if it is reopened.
+func (q *Q) Pause() {
+	q.pauseMu.Lock()
+	defer q.pauseMu.Unlock()
+	if q.resumed == nil {
+		q.resumed = make(chan struct{})
+	}
+}
+
+// Resume lets q hand out messages again, after Pause.
+func (q *Q) Resume() {
+	q.pauseMu.Lock()
+	defer q.pauseMu.Unlock()
+	if q.resumed != nil {
+		close(q.resumed)
+		q.resumed = nil
+	}
+}
+
+// Paused reports whether q is paused.
+func (q *Q) Paused() bool {
+	q.pauseMu.Lock()
+	defer q.pauseMu.Unlock()
+	return q.resumed != nil
+}
+
+// waitResumed blocks while q is paused.
+func (q *Q) waitResumed(ctx context.Context) error {
+	for {
+		q.pauseMu.Lock()
+		resumed := q.resumed
+		q.pauseMu.Unlock()
+		if resumed == nil {
+			return nil
+		}
+		select {
+		case <-resumed:
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-q.closed:
+			return ErrQClosed
+		}
+	}
+}
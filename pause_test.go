@@ -0,0 +1,85 @@
+package lasr
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestPause(t *testing.T) {
+	q, cleanup := newQ(t, WithMessageBufferSize(4))
+	defer cleanup()
+
+	sendBodies(t, q, "a")
+	got := receiveN(t, q, 1)[0]
+	q.Pause()
+	if !q.Paused() {
+		t.Fatal("not paused")
+	}
+	// Sends are accepted, and unacked messages can be acked.
+	sendBodies(t, q, "b", "c")
+	if err := got.Ack(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := q.TryReceive(); err != ErrPaused {
+		t.Errorf("got %v, want ErrPaused", err)
+	}
+	if _, err := q.ReceiveTimeout(20 * time.Millisecond); err != ErrNoMessages {
+		t.Errorf("got %v, want ErrNoMessages", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := q.ReceiveWhere(ctx, matchAll); err != context.DeadlineExceeded {
+		t.Errorf("got %v, want context.DeadlineExceeded", err)
+	}
+
+	received := make(chan *Message)
+	go func() {
+		msg, err := q.Receive(context.Background())
+		if err != nil {
+			t.Error(err)
+		}
+		received <- msg
+	}()
+	select {
+	case msg := <-received:
+		t.Fatalf("received %q while paused", msg.Body)
+	case <-time.After(20 * time.Millisecond):
+	}
+	q.Resume()
+	if q.Paused() {
+		t.Fatal("still paused")
+	}
+	msg := <-received
+	if msg == nil {
+		t.FailNow()
+	}
+	if got, want := string(msg.Body), "b"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if err := msg.Ack(); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := receiveBodies(t, q, 1)[0], "c"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestPauseClose(t *testing.T) {
+	q, cleanup := newQ(t)
+	defer cleanup()
+	q.Pause()
+	done := make(chan error)
+	go func() {
+		_, err := q.Receive(context.Background())
+		done <- err
+	}()
+	time.Sleep(10 * time.Millisecond)
+	if err := q.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-done; err != ErrQClosed {
+		t.Errorf("got %v, want ErrQClosed", err)
+	}
+}
@@ -0,0 +1,73 @@
+package lasr
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// Peek returns copies of up to n of the messages that are next in line to be
+// received, in the order they would be received, without changing their
+// state. Peek only considers messages in the Ready state, and retries that
+// are due, so messages that have already been buffered for receipt are not
+// included.
+//
+// The returned messages are for inspection only. Calling Ack or Nack on them
+// returns ErrAckNack.
+func (q *Q) Peek(n int) ([]*Message, error) {
+	if n < 0 {
+		return nil, fmt.Errorf("lasr: invalid peek count: %d", n)
+	}
+	if q.isClosed() {
+		return nil, ErrQClosed
+	}
+	var messages []*Message
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	err := q.db.View(func(tx *bolt.Tx) error {
+		if bucket := q.readBucket(tx, q.keys.ready); bucket != nil {
+			cur := q.cursor(bucket, q.keys.ready)
+			for k, v := cur.First(); k != nil && len(messages) < n; k, v = cur.Next() {
+				msg, _, err := q.readMessage(tx, k, v)
+				if err != nil {
+					return err
+				}
+				messages = append(messages, msg)
+			}
+		}
+		// Due retries are returned to Ready under their original IDs on
+		// the next Receive, so they rank among the Ready messages.
+		if bucket := q.readBucket(tx, q.keys.retrying); bucket != nil {
+			until, err := Uint64ID(time.Now().UnixNano()).MarshalBinary()
+			if err != nil {
+				return err
+			}
+			cur := bucket.Cursor()
+			for k, v := cur.First(); k != nil && bytes.Compare(k[:8], until) <= 0; k, v = cur.Next() {
+				msg, _, err := q.readMessage(tx, k[8:], v)
+				if err != nil {
+					return err
+				}
+				messages = append(messages, msg)
+			}
+		}
+		sort.Slice(messages, func(i, j int) bool {
+			if q.lifo {
+				return bytes.Compare(messages[i].ID, messages[j].ID) > 0
+			}
+			return bytes.Compare(messages[i].ID, messages[j].ID) < 0
+		})
+		if len(messages) > n {
+			messages = messages[:n]
+		}
+		for _, msg := range messages {
+			// peeked messages can't be acked or nacked
+			msg.once = messageDone
+		}
+		return nil
+	})
+	return messages, err
+}
@@ -0,0 +1,68 @@
+package lasr
+
+import (
+	"context"
+	"testing"
+)
+
+func TestPeek(t *testing.T) {
+	q, cleanup := newQ(t, WithEncryption(testKey))
+	defer cleanup()
+
+	for _, body := range []string{"a", "b", "c"} {
+		headers := map[string][]byte{"body": []byte(body)}
+		if _, err := q.SendWithHeaders([]byte(body), headers); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for i := 0; i < 2; i++ {
+		peeked, err := q.Peek(2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := len(peeked), 2; got != want {
+			t.Fatalf("bad peek count: got %d, want %d", got, want)
+		}
+		for i, want := range []string{"a", "b"} {
+			if got := string(peeked[i].Body); got != want {
+				t.Errorf("bad body: got %q, want %q", got, want)
+			}
+			if got := string(peeked[i].Headers["body"]); got != want {
+				t.Errorf("bad header: got %q, want %q", got, want)
+			}
+		}
+		if err := peeked[0].Ack(); err != ErrAckNack {
+			t.Errorf("expected ErrAckNack acking a peeked message, got %v", err)
+		}
+	}
+
+	stats, err := q.Stats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := stats.Ready, 3; got != want {
+		t.Errorf("peek changed state: got %d ready, want %d", got, want)
+	}
+
+	m, err := q.Receive(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(m.Body), "a"; got != want {
+		t.Errorf("bad body: got %q, want %q", got, want)
+	}
+	if err := m.Ack(); err != nil {
+		t.Fatal(err)
+	}
+	peeked, err := q.Peek(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(peeked), 2; got != want {
+		t.Fatalf("bad peek count: got %d, want %d", got, want)
+	}
+	if got, want := string(peeked[0].Body), "b"; got != want {
+		t.Errorf("bad body: got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,61 @@
+package lasr
+
+import (
+	"context"
+	"time"
+)
+
+// ReceiveTimeout is like Receive, but waits for at most d for a message to
+// arrive. If none does, it returns a nil Message and ErrNoMessages.
+func (q *Q) ReceiveTimeout(d time.Duration) (*Message, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), d)
+	defer cancel()
+	msg, err := q.Receive(ctx)
+	if err == context.DeadlineExceeded {
+		return nil, ErrNoMessages
+	}
+	return msg, err
+}
+
+// TryReceive receives a message from the queue without blocking. If no
+// message is available, it returns a nil Message and ErrEmpty, while q is
+// paused, ErrPaused, and while its delivery rate doesn't allow another
+// message, ErrRateLimited.
+//
+// Messages that are buffered for Receive are handed out first. While another
+// goroutine is blocked in Receive, TryReceive takes the next message from the
+// Ready state instead.
+func (q *Q) TryReceive() (*Message, error) {
+	if q.isClosed() {
+		return nil, ErrQClosed
+	}
+	if q.readOnly {
+		return nil, ErrReadOnly
+	}
+	if q.Paused() {
+		return nil, ErrPaused
+	}
+	if !q.mayDeliver() {
+		return nil, ErrRateLimited
+	}
+	if q.messages.TryLock() {
+		if q.messages.Len() > 0 {
+			defer q.messages.Unlock()
+			q.delivered()
+			return q.popMessage(nil)
+		}
+		q.messages.Unlock()
+	}
+	msg, err := q.take(q.firstMatch(matchAll))
+	if msg != nil {
+		q.delivered()
+	}
+	if err == nil && msg == nil {
+		return nil, ErrEmpty
+	}
+	return msg, err
+}
+
+func matchAll(map[string][]byte) bool {
+	return true
+}
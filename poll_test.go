@@ -0,0 +1,102 @@
+package lasr
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReceiveTimeout(t *testing.T) {
+	q, cleanup := newQ(t)
+	defer cleanup()
+
+	start := time.Now()
+	msg, err := q.ReceiveTimeout(50 * time.Millisecond)
+	if err != ErrNoMessages {
+		t.Fatalf("got %v, want ErrNoMessages", err)
+	}
+	if msg != nil {
+		t.Error("expected no message")
+	}
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Errorf("returned too early: %s", elapsed)
+	}
+
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		if _, err := q.Send([]byte("a")); err != nil {
+			t.Error(err)
+		}
+	}()
+	msg, err = q.ReceiveTimeout(10 * time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(msg.Body), "a"; got != want {
+		t.Errorf("bad body: got %q, want %q", got, want)
+	}
+	if err := msg.Ack(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := q.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := q.ReceiveTimeout(time.Second); err != ErrQClosed {
+		t.Errorf("got %v, want ErrQClosed", err)
+	}
+}
+
+func TestTryReceive(t *testing.T) {
+	q, cleanup := newQ(t)
+	defer cleanup()
+
+	if _, err := q.TryReceive(); err != ErrEmpty {
+		t.Fatalf("got %v, want ErrEmpty", err)
+	}
+	sendBodies(t, q, "a", "b")
+	if _, err := q.Delay([]byte("later"), time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	var bodies []string
+	for {
+		msg, err := q.TryReceive()
+		if err == ErrEmpty {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		bodies = append(bodies, string(msg.Body))
+		if err := msg.Ack(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got, want := strings.Join(bodies, ","), "a,b"; got != want {
+		t.Errorf("bad bodies: got %q, want %q", got, want)
+	}
+}
+
+func TestTryReceiveBuffered(t *testing.T) {
+	q, cleanup := newQ(t, WithMessageBufferSize(4))
+	defer cleanup()
+
+	sendBodies(t, q, "a", "b", "c")
+	// Receiving "a" buffers the others.
+	receiveBodies(t, q, 1)
+	for _, want := range []string{"b", "c"} {
+		msg, err := q.TryReceive()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := string(msg.Body); got != want {
+			t.Errorf("bad body: got %q, want %q", got, want)
+		}
+		if err := msg.Ack(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := q.TryReceive(); err != ErrEmpty {
+		t.Errorf("got %v, want ErrEmpty", err)
+	}
+}
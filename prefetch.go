@@ -0,0 +1,115 @@
+package lasr
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// WithPrefetch limits how many unacked messages each Consumer of q can hold
+// at once to n, so that a slow consumer can't take the messages that are
+// buffered for Receive away from the others. It has no effect on messages
+// that are received from q directly.
+func WithPrefetch(n int) Option {
+	return func(q *Q) error {
+		if q.optsApplied {
+			return ErrOptionsApplied
+		}
+		if n < 1 {
+			return fmt.Errorf("lasr: invalid prefetch: %d", n)
+		}
+		q.prefetch = n
+		return nil
+	}
+}
+
+// Consumer receives messages from a Q on behalf of one worker, which can hold
+// a limited number of them unacked, see WithPrefetch. It is safe for
+// concurrent use, but the limit applies to all of its callers together.
+type Consumer struct {
+	q     *Q
+	limit int
+
+	mu   sync.Mutex
+	held int
+	// freed, if not nil, is closed the next time a message is released.
+	freed chan struct{}
+}
+
+// Consumer returns a new Consumer of q. Without WithPrefetch, it can hold any
+// number of messages.
+func (q *Q) Consumer() *Consumer {
+	return &Consumer{q: q, limit: q.prefetch}
+}
+
+// Receive receives a message like Q.Receive, once c holds fewer unacked
+// messages than its limit. Messages count towards the limit until they are
+// acked or nacked, or their ack timeout expires.
+func (c *Consumer) Receive(ctx context.Context) (*Message, error) {
+	if err := c.acquire(ctx); err != nil {
+		return nil, err
+	}
+	receive := func(ctx context.Context) (*Message, error) {
+		return c.q.receive(ctx, c)
+	}
+	var msg *Message
+	var err error
+	if len(c.q.interceptors) > 0 {
+		msg, err = c.q.interceptReceive(ctx, receive)
+	} else {
+		msg, err = receive(ctx)
+	}
+	if err != nil {
+		c.release()
+	}
+	return msg, err
+}
+
+// Held returns the number of unacked messages that c holds.
+func (c *Consumer) Held() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.held
+}
+
+// acquire waits until c can hold another message, and counts it.
+func (c *Consumer) acquire(ctx context.Context) error {
+	for {
+		c.mu.Lock()
+		if c.limit == 0 || c.held < c.limit {
+			c.held++
+			c.mu.Unlock()
+			return nil
+		}
+		if c.freed == nil {
+			c.freed = make(chan struct{})
+		}
+		freed := c.freed
+		c.mu.Unlock()
+		select {
+		case <-freed:
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-c.q.closed:
+			return ErrQClosed
+		}
+	}
+}
+
+func (c *Consumer) release() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.held--
+	if c.freed != nil {
+		close(c.freed)
+		c.freed = nil
+	}
+}
+
+// released is called once m stops being open, and frees its place with its
+// Consumer, if any.
+func (m *Message) released() {
+	if m.consumer != nil {
+		m.consumer.release()
+	}
+}
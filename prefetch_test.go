@@ -0,0 +1,95 @@
+package lasr
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestPrefetch(t *testing.T) {
+	q, cleanup := newQ(t, WithPrefetch(2), WithMessageBufferSize(10))
+	defer cleanup()
+	sendBodies(t, q, "a", "b", "c", "d")
+
+	slow, fast := q.Consumer(), q.Consumer()
+	ctx := context.Background()
+	var held []*Message
+	for i := 0; i < 2; i++ {
+		msg, err := slow.Receive(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		held = append(held, msg)
+	}
+	if got := slow.Held(); got != 2 {
+		t.Errorf("got %d held messages, want 2", got)
+	}
+	short, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	if _, err := slow.Receive(short); err != context.DeadlineExceeded {
+		t.Fatalf("got %v, want context.DeadlineExceeded", err)
+	}
+
+	// The other consumer still gets the buffered messages.
+	msg, err := fast.Receive(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(msg.Body), "c"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if err := msg.Ack(); err != nil {
+		t.Fatal(err)
+	}
+
+	received := make(chan *Message)
+	go func() {
+		msg, err := slow.Receive(ctx)
+		if err != nil {
+			t.Error(err)
+		}
+		received <- msg
+	}()
+	if err := held[0].Nack(false); err != nil {
+		t.Fatal(err)
+	}
+	msg = <-received
+	if msg == nil {
+		t.FailNow()
+	}
+	if got, want := string(msg.Body), "d"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	for _, msg := range []*Message{held[1], msg} {
+		if err := msg.Ack(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := slow.Held(); got != 0 {
+		t.Errorf("got %d held messages, want 0", got)
+	}
+}
+
+func TestPrefetchAckTimeout(t *testing.T) {
+	q, cleanup := newQ(t, WithPrefetch(1), WithAckTimeout(20*time.Millisecond))
+	defer cleanup()
+	sendBodies(t, q, "a")
+	c := q.Consumer()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	first, err := c.Receive(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The expired message is released, and received again.
+	msg, err := c.Receive(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := first.Ack(); err != ErrLeaseExpired {
+		t.Errorf("got %v, want ErrLeaseExpired", err)
+	}
+	if err := msg.Ack(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,104 @@
+package lasr
+
+import (
+	"errors"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// Purge deletes every message in the given state in a single transaction,
+// and returns the number of messages deleted.
+//
+// Unacked messages can't be purged, since they belong to consumers that may
+// still Ack or Nack them. Messages that are waiting on purged messages enter
+// the Ready state, as if the purged messages had been nacked. Purging Waiting
+// messages also discards the record of what they were waiting on.
+func (q *Q) Purge(state Status) (int, error) {
+	if q.isClosed() {
+		return 0, ErrQClosed
+	}
+	if q.readOnly {
+		return 0, ErrReadOnly
+	}
+	if state == Unacked {
+		return 0, errors.New("lasr: can't purge unacked messages")
+	}
+	key, err := q.stateKey(state)
+	if err != nil {
+		return 0, err
+	}
+	var n int
+	var wake bool
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	err = q.db.Update(func(tx *bolt.Tx) error {
+		bucket, err := q.bucket(tx, key)
+		if err != nil {
+			return err
+		}
+		meta, err := q.bucket(tx, q.keys.meta)
+		if err != nil {
+			return err
+		}
+		var purged [][]byte
+		if err := bucket.ForEach(func(k, _ []byte) error {
+			purged = append(purged, k)
+			return nil
+		}); err != nil {
+			return err
+		}
+		for _, k := range purged {
+			id := messageID(state, k)
+			if err := q.retire(tx, id); err != nil {
+				return err
+			}
+			if err := meta.Delete(id); err != nil {
+				return err
+			}
+			if err := q.deleteBody(tx, id); err != nil {
+				return err
+			}
+			if err := bucket.Delete(k); err != nil {
+				return err
+			}
+			q.touch(tx, id)
+			if state == Ready || state == Delayed || state == Retrying {
+				// purged messages will never be acked, so release the
+				// messages waiting on them.
+				released, err := q.stopWaitingOn(tx, id)
+				if err != nil {
+					return err
+				}
+				wake = wake || released
+			}
+		}
+		n = len(purged)
+		if err := q.audit(tx, AuditEntry{Op: AuditPurge, State: state.String(), Count: n}); err != nil {
+			return err
+		}
+		if state != Returned {
+			if err := q.adjustDepth(tx, -n); err != nil {
+				return err
+			}
+		}
+		if state == Waiting {
+			// only waiting messages are blocked, so nothing is blocking
+			// anything else anymore.
+			root := tx.Bucket(q.name)
+			for _, k := range [][]byte{q.keys.blockedOn, q.keys.blocking} {
+				if err := root.DeleteBucket(k); err != nil && err != bolt.ErrBucketNotFound {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	if wake && !q.isClosed() {
+		q.waker.Wake()
+	}
+	q.signalSpace()
+	return n, nil
+}
@@ -0,0 +1,114 @@
+package lasr
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+func TestPurge(t *testing.T) {
+	q, cleanup := newQ(t, WithDeadLetters())
+	defer cleanup()
+
+	var ids []ID
+	for i := 0; i < 5; i++ {
+		id, err := q.SendWithHeaders([]byte("foo"), map[string][]byte{"n": {byte(i)}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, id)
+	}
+	if _, err := q.Wait([]byte("blocked"), ids[4]); err != nil {
+		t.Fatal(err)
+	}
+	m, err := q.Receive(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Nack(false); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := q.Purge(Unacked); err == nil {
+		t.Error("expected error purging unacked messages")
+	}
+
+	tests := []struct {
+		state Status
+		want  int
+	}{
+		{Ready, 4},
+		{Returned, 1},
+		// purging ids[4] released the waiting message
+		{Waiting, 0},
+		{Delayed, 0},
+		{Ready, 1},
+		{Ready, 0},
+	}
+	for _, test := range tests {
+		n, err := q.Purge(test.state)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n != test.want {
+			t.Errorf("bad purge count for %s: got %d, want %d", test.state, n, test.want)
+		}
+	}
+
+	// purging waiting messages
+	id, err := q.Send([]byte("blocker"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := q.Wait([]byte("waiter"), id); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := q.Purge(Waiting); err != nil {
+		t.Fatal(err)
+	} else if n != 1 {
+		t.Errorf("bad purge count: got %d, want 1", n)
+	}
+	m, err = q.Receive(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Ack(); err != nil {
+		t.Fatal(err)
+	}
+
+	stats, err := q.Stats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Ready+stats.Returned+stats.Waiting+stats.Unacked != 0 {
+		t.Errorf("messages left after purge: %+v", stats)
+	}
+	err = q.db.View(func(tx *bolt.Tx) error {
+		if got := q.keyCount(tx, q.keys.meta); got != 0 {
+			t.Errorf("%d metadata entries left after purge", got)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the queue still works after a purge
+	if _, err := q.Send([]byte("bar")); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	m, err = q.Receive(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(m.Body), "bar"; got != want {
+		t.Errorf("bad body: got %q, want %q", got, want)
+	}
+	if err := m.Ack(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,110 @@
+package lasr
+
+import (
+	"fmt"
+	"sort"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// ListQueues returns the names of the queues in db, in lexical order.
+// Subscriptions are not included; they are listed by Q.Subscriptions. Neither
+// are partitions, see WithPartitions.
+func ListQueues(db *bolt.DB) ([]string, error) {
+	var names []string
+	err := db.View(func(tx *bolt.Tx) error {
+		subs := subscriptionQueues(tx)
+		parts := partitionQueues(tx)
+		return tx.ForEach(func(name []byte, root *bolt.Bucket) error {
+			if isQueue(root) && !subs[string(name)] && !parts[string(name)] {
+				names = append(names, string(name))
+			}
+			return nil
+		})
+	})
+	sort.Strings(names)
+	return names, err
+}
+
+// DeleteQueue deletes the queue named name from db, in a single transaction:
+// its messages, dead letters, sequence, subscriptions and partitions are all
+// deleted. The queue, its subscriptions and its partitions must not be open.
+// Subscriptions and partitions can't be deleted with DeleteQueue, see
+// Q.Unsubscribe.
+func DeleteQueue(db *bolt.DB, name string) error {
+	if open := openQueue(db, name); open != "" {
+		return fmt.Errorf("lasr: queue %q is open", open)
+	}
+	return db.Update(func(tx *bolt.Tx) error {
+		root := tx.Bucket([]byte(name))
+		if root == nil || !isQueue(root) {
+			return fmt.Errorf("lasr: no such queue: %q", name)
+		}
+		if subscriptionQueues(tx)[name] {
+			return fmt.Errorf("lasr: %q is a subscription", name)
+		}
+		if partitionQueues(tx)[name] {
+			return fmt.Errorf("lasr: %q is a partition", name)
+		}
+		for i := 0; i < storedPartitions(root); i++ {
+			err := tx.DeleteBucket(partitionName([]byte(name), i))
+			if err != nil && err != bolt.ErrBucketNotFound {
+				return err
+			}
+		}
+		if subs := root.Bucket(defaultKeys().subscriptions); subs != nil {
+			if err := subs.ForEach(func(sub, _ []byte) error {
+				err := tx.DeleteBucket(subscriptionName([]byte(name), string(sub)))
+				if err == bolt.ErrBucketNotFound {
+					err = nil
+				}
+				return err
+			}); err != nil {
+				return err
+			}
+		}
+		return tx.DeleteBucket([]byte(name))
+	})
+}
+
+// isQueue reports whether root is the bucket of a queue, which always has a
+// Ready bucket once it has been created.
+func isQueue(root *bolt.Bucket) bool {
+	return root.Bucket(defaultKeys().ready) != nil
+}
+
+// subscriptionQueues returns the names of the buckets of the subscriptions in
+// tx.
+func subscriptionQueues(tx *bolt.Tx) map[string]bool {
+	names := make(map[string]bool)
+	_ = tx.ForEach(func(name []byte, root *bolt.Bucket) error {
+		subs := root.Bucket(defaultKeys().subscriptions)
+		if subs == nil {
+			return nil
+		}
+		return subs.ForEach(func(sub, _ []byte) error {
+			names[string(subscriptionName(name, string(sub)))] = true
+			return nil
+		})
+	})
+	return names
+}
+
+// openQueue returns the name of a queue in db that is open in this process,
+// and is either named name or a subscription to it.
+func openQueue(db *bolt.DB, name string) string {
+	registry.Lock()
+	shared := registry.dbs[db]
+	registry.Unlock()
+	if shared == nil {
+		return ""
+	}
+	shared.mu.Lock()
+	defer shared.mu.Unlock()
+	for q := range shared.queues {
+		if string(q.name) == name || string(q.seqName) == name {
+			return string(q.name)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,74 @@
+package lasr
+
+import (
+	"reflect"
+	"testing"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+func TestListAndDeleteQueues(t *testing.T) {
+	q, cleanup := newQ(t, WithDeadLetters())
+	defer cleanup()
+	other, err := NewQ(q.db, "other")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sub, err := q.Subscribe("audit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sendBodies(t, q, "a")
+	sendBodies(t, other, "b")
+
+	names, err := ListQueues(q.db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"other", "testing"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("bad queues: got %v, want %v", names, want)
+	}
+
+	if err := DeleteQueue(q.db, "testing"); err == nil {
+		t.Error("expected an error deleting an open queue")
+	}
+	if err := DeleteQueue(q.db, "missing"); err == nil {
+		t.Error("expected an error deleting a missing queue")
+	}
+	if err := sub.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := DeleteQueue(q.db, "testing/audit"); err == nil {
+		t.Error("expected an error deleting a subscription")
+	}
+	if err := q.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := DeleteQueue(q.db, "testing"); err != nil {
+		t.Fatal(err)
+	}
+	err = q.db.View(func(tx *bolt.Tx) error {
+		for _, name := range []string{"testing", "testing/audit"} {
+			if tx.Bucket([]byte(name)) != nil {
+				t.Errorf("bucket %q not deleted", name)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	names, err = ListQueues(q.db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"other"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("bad queues: got %v, want %v", names, want)
+	}
+	if got := receiveBodies(t, other, 1); got[0] != "b" {
+		t.Errorf("bad body: got %q, want %q", got[0], "b")
+	}
+	if err := other.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
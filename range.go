@@ -0,0 +1,116 @@
+package lasr
+
+import (
+	"bytes"
+	"context"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// ReceiveRange is like Receive, but only receives a Ready message whose ID is
+// at least from and less than to. A nil from or to leaves that end of the
+// range open. Messages outside of the range are left in place for other
+// consumers.
+//
+// Like ReceiveWhere, ReceiveRange does not see messages that have already
+// been buffered for Receive, or messages that are not Ready yet.
+func (q *Q) ReceiveRange(ctx context.Context, from, to []byte) (*Message, error) {
+	return q.receiveBy(ctx, func(tx *bolt.Tx, now time.Time) (*Message, error) {
+		bucket := q.readBucket(tx, q.keys.ready)
+		if bucket == nil {
+			return nil, nil
+		}
+		cur := bucket.Cursor()
+		k, v := seekRange(cur, from, to, q.lifo)
+		next := cur.Next
+		if q.lifo {
+			next = cur.Prev
+		}
+		for ; k != nil && inRange(k, from, to); k, v = next() {
+			if busy, err := q.groupBusy(tx, k); err != nil {
+				return nil, err
+			} else if busy {
+				continue
+			}
+			msg, err := q.deliver(tx, bucket, k, v, now)
+			if err == errExpired {
+				continue
+			}
+			return msg, err
+		}
+		return nil, nil
+	})
+}
+
+// ScanRange is like Scan, but only calls fn for the messages whose ID is at
+// least from and less than to. A nil from or to leaves that end of the range
+// open.
+func (q *Q) ScanRange(state Status, from, to []byte, fn func(id, body []byte) error) error {
+	if q.isClosed() {
+		return ErrQClosed
+	}
+	key, err := q.stateKey(state)
+	if err != nil {
+		return err
+	}
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	return q.db.View(func(tx *bolt.Tx) error {
+		bucket := q.readBucket(tx, key)
+		if bucket == nil {
+			return nil
+		}
+		if state == Retrying {
+			// Retries are keyed by their due time, so the whole bucket
+			// is looked at.
+			return bucket.ForEach(func(k, v []byte) error {
+				id := messageID(state, k)
+				if !inRange(id, from, to) {
+					return nil
+				}
+				body, err := q.openBody(tx, id, v)
+				if err != nil {
+					return err
+				}
+				return fn(id, body)
+			})
+		}
+		cur := bucket.Cursor()
+		for k, v := seekRange(cur, from, to, false); k != nil && inRange(k, from, to); k, v = cur.Next() {
+			body, err := q.openBody(tx, k, v)
+			if err != nil {
+				return err
+			}
+			if err := fn(k, body); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// seekRange moves cur to the first key of the range from, to, or to the last
+// one if reverse is set. The key it returns is out of the range if the range
+// is empty.
+func seekRange(cur *bolt.Cursor, from, to []byte, reverse bool) ([]byte, []byte) {
+	if !reverse {
+		if from == nil {
+			return cur.First()
+		}
+		return cur.Seek(from)
+	}
+	if to == nil {
+		return cur.Last()
+	}
+	if k, _ := cur.Seek(to); k == nil {
+		return cur.Last()
+	}
+	return cur.Prev()
+}
+
+// inRange reports whether from <= k < to, where a nil from or to is
+// unbounded.
+func inRange(k, from, to []byte) bool {
+	return (from == nil || bytes.Compare(k, from) >= 0) && (to == nil || bytes.Compare(k, to) < 0)
+}
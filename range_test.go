@@ -0,0 +1,98 @@
+package lasr
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// sendRange sends the bodies a to e, and returns the keys of b and d.
+func sendRange(t *testing.T, q *Q) (from, to []byte) {
+	t.Helper()
+	var keys [][]byte
+	for _, body := range []string{"a", "b", "c", "d", "e"} {
+		id, err := q.Send([]byte(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		key, err := id.MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+		keys = append(keys, key)
+	}
+	return keys[1], keys[3]
+}
+
+func TestScanRange(t *testing.T) {
+	q, cleanup := newQ(t)
+	defer cleanup()
+	from, to := sendRange(t, q)
+
+	for _, test := range []struct {
+		from, to []byte
+		want     []string
+	}{
+		{from, to, []string{"b", "c"}},
+		{nil, to, []string{"a", "b", "c"}},
+		{from, nil, []string{"b", "c", "d", "e"}},
+		{to, from, nil},
+	} {
+		var got []string
+		err := q.ScanRange(Ready, test.from, test.to, func(id, body []byte) error {
+			got = append(got, string(body))
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("ScanRange(%x, %x): got %q, want %q", test.from, test.to, got, test.want)
+		}
+	}
+}
+
+func TestReceiveRange(t *testing.T) {
+	for _, test := range []struct {
+		name string
+		opts []Option
+		want []string
+	}{
+		{"fifo", nil, []string{"b", "c"}},
+		{"lifo", []Option{WithLIFO()}, []string{"c", "b"}},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			q, cleanup := newQ(t, test.opts...)
+			defer cleanup()
+			from, to := sendRange(t, q)
+
+			var got []string
+			for range test.want {
+				msg, err := q.ReceiveRange(context.Background(), from, to)
+				if err != nil {
+					t.Fatal(err)
+				}
+				got = append(got, string(msg.Body))
+				if err := msg.Ack(); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got %q, want %q", got, test.want)
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+			defer cancel()
+			if _, err := q.ReceiveRange(ctx, from, to); err != context.DeadlineExceeded {
+				t.Errorf("got %v for an empty range, want context.DeadlineExceeded", err)
+			}
+			stats, err := q.Stats()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if stats.Ready != 3 {
+				t.Errorf("got %d ready messages, want 3", stats.Ready)
+			}
+		})
+	}
+}
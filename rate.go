@@ -0,0 +1,72 @@
+package lasr
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// WithDeliveryRate limits how fast q hands out messages, to limit messages
+// per second on average, with bursts of up to burst messages, so that a large
+// backlog doesn't flood the services downstream when it starts to drain.
+// Receive and the other receiving methods wait their turn, and TryReceive
+// returns ErrRateLimited instead.
+//
+// The limit applies to every receiver of q in the process, and not to the
+// other processes that open the database.
+func WithDeliveryRate(limit rate.Limit, burst int) Option {
+	return func(q *Q) error {
+		if q.optsApplied {
+			return ErrOptionsApplied
+		}
+		if limit <= 0 {
+			return fmt.Errorf("lasr: invalid delivery rate: %v", limit)
+		}
+		if burst < 1 && limit != rate.Inf {
+			return fmt.Errorf("lasr: invalid delivery burst: %d", burst)
+		}
+		q.limiter = rate.NewLimiter(limit, burst)
+		return nil
+	}
+}
+
+// pace waits until the delivery rate of q lets it hand out another message,
+// and uses up the turn.
+func (q *Q) pace(ctx context.Context) error {
+	if q.limiter == nil {
+		return nil
+	}
+	r := q.limiter.Reserve()
+	d := r.Delay()
+	if d == 0 {
+		return nil
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		r.Cancel()
+		return ctx.Err()
+	case <-q.closed:
+		r.Cancel()
+		return ErrQClosed
+	}
+}
+
+// mayDeliver reports whether the delivery rate of q lets it hand out a message
+// now, without using up the turn, see delivered.
+func (q *Q) mayDeliver() bool {
+	return q.limiter == nil || q.limiter.Tokens() >= 1
+}
+
+// delivered uses up the turn of a message that was handed out once
+// mayDeliver allowed it.
+func (q *Q) delivered() {
+	if q.limiter != nil {
+		q.limiter.Reserve()
+	}
+}
@@ -0,0 +1,59 @@
+package lasr
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestDeliveryRate(t *testing.T) {
+	q, cleanup := newQ(t, WithDeliveryRate(20, 1))
+	defer cleanup()
+	sendBodies(t, q, "a", "b", "c", "d")
+	start := time.Now()
+	if got := receiveBodies(t, q, 4); len(got) != 4 {
+		t.Fatalf("got %d messages, want 4", len(got))
+	}
+	// The first message is handed out at once, and each of the others 50ms
+	// after the one before it.
+	if elapsed := time.Since(start); elapsed < 120*time.Millisecond {
+		t.Errorf("received 4 messages in %s, want at least 150ms", elapsed)
+	}
+}
+
+func TestDeliveryRateTryReceive(t *testing.T) {
+	q, cleanup := newQ(t, WithDeliveryRate(1, 1))
+	defer cleanup()
+
+	// Finding no message doesn't use up the turn.
+	if _, err := q.TryReceive(); err != ErrEmpty {
+		t.Fatalf("got %v, want ErrEmpty", err)
+	}
+	sendBodies(t, q, "a", "b")
+	msg, err := q.TryReceive()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := msg.Ack(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := q.TryReceive(); err != ErrRateLimited {
+		t.Errorf("got %v, want ErrRateLimited", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := q.Receive(ctx); err != context.DeadlineExceeded {
+		t.Errorf("got %v, want context.DeadlineExceeded", err)
+	}
+}
+
+func TestDeliveryRateInvalid(t *testing.T) {
+	q, cleanup := newQ(t)
+	defer cleanup()
+	if _, err := NewQ(q.db, "other", WithDeliveryRate(0, 1)); err == nil {
+		t.Error("expected an error for a zero rate")
+	}
+	if _, err := NewQ(q.db, "other", WithDeliveryRate(10, 0)); err == nil {
+		t.Error("expected an error for a zero burst")
+	}
+}
@@ -0,0 +1,36 @@
+package lasr
+
+import bolt "go.etcd.io/bbolt"
+
+// OpenReadOnly opens the queue named name in the bolt database at path for
+// inspection, with OpenQ and the ReadOnly bolt option. Peek, Scan, Stats,
+// Export, Backup and CompactTo work as usual; Send, Receive, Purge, Compact and
+// the other methods that would modify the queue return ErrReadOnly. Unacked
+// messages are left as they are, and are not recovered.
+//
+// bolt takes a shared lock on read-only databases, so any number of processes
+// can inspect the file at once. A process that has it open for writing still
+// excludes them: OpenReadOnly waits for it to close the file, for as long
+// as the Timeout given by WithBoltOptions allows.
+//
+// NewQ also creates a read-only Q when the database it is given was opened
+// read-only.
+func OpenReadOnly(path, name string, options ...Option) (*Q, error) {
+	return OpenQ(path, name, append(options, readOnly)...)
+}
+
+// readOnly makes OpenQ open the database read-only.
+func readOnly(q *Q) error {
+	var opts bolt.Options
+	if q.boltOptions != nil {
+		opts = *q.boltOptions
+	}
+	opts.ReadOnly = true
+	q.boltOptions = &opts
+	return nil
+}
+
+// ReadOnly reports whether q was opened read-only.
+func (q *Q) ReadOnly() bool {
+	return q.readOnly
+}
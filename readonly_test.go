@@ -0,0 +1,109 @@
+package lasr
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+func TestOpenReadOnly(t *testing.T) {
+	td, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(td)
+	path := filepath.Join(td, "lasr.db")
+
+	db, err := bolt.Open(path, 0600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q, err := NewQ(db, "testing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sendBodies(t, q, "a", "b")
+	// Leave "a" unacked, as if the writer had crashed.
+	crash(t, q, 1)
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	ro, err := OpenReadOnly(path, "testing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ro.ReadOnly() {
+		t.Error("expected a read-only Q")
+	}
+	// A second reader can open the file at the same time.
+	ro2, err := OpenReadOnly(path, "testing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ro2.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	stats, err := ro.Stats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Ready != 1 || stats.Unacked != 1 {
+		t.Errorf("bad stats: %+v", stats)
+	}
+	peeked, err := ro.Peek(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(peeked) != 1 || string(peeked[0].Body) != "b" {
+		t.Errorf("bad peek: %v", peeked)
+	}
+	var scanned int
+	if err := ro.Scan(Unacked, func(id, body []byte) error {
+		scanned++
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if scanned != 1 {
+		t.Errorf("bad scan count: got %d, want 1", scanned)
+	}
+
+	if _, err := ro.Send([]byte("c")); err != ErrReadOnly {
+		t.Errorf("Send: got %v, want ErrReadOnly", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if _, err := ro.Receive(ctx); err != ErrReadOnly {
+		t.Errorf("Receive: got %v, want ErrReadOnly", err)
+	}
+	if _, err := ro.Purge(Ready); err != ErrReadOnly {
+		t.Errorf("Purge: got %v, want ErrReadOnly", err)
+	}
+	if err := ro.Compact(); err != ErrReadOnly {
+		t.Errorf("Compact: got %v, want ErrReadOnly", err)
+	}
+	if err := peeked[0].Ack(); err != ErrAckNack {
+		t.Errorf("Ack: got %v, want ErrAckNack", err)
+	}
+	if err := ro.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The unacked message was not touched, and is recovered once the queue
+	// is opened for writing.
+	q, err = OpenQ(path, "testing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer q.Close()
+	if got, want := q.Recovered(), 1; got != want {
+		t.Errorf("bad recovered count: got %d, want %d", got, want)
+	}
+}
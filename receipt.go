@@ -0,0 +1,62 @@
+package lasr
+
+import (
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// Receipt describes a message sent with SendReceipt.
+type Receipt struct {
+	// ID is the ID of the message.
+	ID ID
+
+	// EnqueuedAt is when the message was sent, as recorded with it.
+	EnqueuedAt time.Time
+
+	// Depth is the number of Ready messages in q once the message was
+	// sent, which may include messages that were sent or received
+	// concurrently. Messages sent to a Q with subscriptions or partitions
+	// are held by those, and don't count towards it. With WithWAL, it
+	// leaves out the messages that are only in the log.
+	Depth int
+}
+
+// SendReceipt is like SendWithHeaders, but returns a Receipt for the message,
+// which producers can use for backpressure, or to track how long messages
+// take, without calling Stats.
+func (q *Q) SendReceipt(message []byte, headers map[string][]byte) (*Receipt, error) {
+	var r *Receipt
+	send := func(message []byte, headers map[string][]byte) (ID, error) {
+		var err error
+		if r, err = q.sendWithHeaders(message, headers); err != nil {
+			return nil, err
+		}
+		return r.ID, nil
+	}
+	var err error
+	if len(q.interceptors) > 0 {
+		_, err = q.interceptSend(message, headers, send)
+	} else {
+		_, err = send(message, headers)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if r.Depth, err = q.readyDepth(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// readyDepth returns the number of Ready messages in q.
+func (q *Q) readyDepth() (int, error) {
+	var n int
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	err := q.db.View(func(tx *bolt.Tx) error {
+		n = q.keyCount(tx, q.keys.ready)
+		return nil
+	})
+	return n, err
+}
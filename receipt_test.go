@@ -0,0 +1,65 @@
+package lasr
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSendReceipt(t *testing.T) {
+	q, cleanup := newQ(t)
+	defer cleanup()
+	sendBodies(t, q, "a")
+
+	start := time.Now()
+	r, err := q.SendReceipt([]byte("b"), map[string][]byte{"k": []byte("v")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := r.ID, Uint64ID(2); got != want {
+		t.Errorf("got id %v, want %v", got, want)
+	}
+	if r.EnqueuedAt.Before(start) || r.EnqueuedAt.After(time.Now()) {
+		t.Errorf("bad enqueued time: %s", r.EnqueuedAt)
+	}
+	if r.Depth != 2 {
+		t.Errorf("got depth %d, want 2", r.Depth)
+	}
+
+	msgs := receiveN(t, q, 2)
+	if got := string(msgs[1].Headers["k"]); got != "v" {
+		t.Errorf("got header %q, want %q", got, "v")
+	}
+	if !msgs[1].EnqueuedAt.Equal(r.EnqueuedAt) {
+		t.Errorf("message was enqueued at %s, receipt says %s", msgs[1].EnqueuedAt, r.EnqueuedAt)
+	}
+	for _, msg := range msgs {
+		if err := msg.Ack(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	r, err = q.SendReceipt([]byte("c"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Depth != 1 {
+		t.Errorf("got depth %d after acks, want 1", r.Depth)
+	}
+}
+
+func TestSendReceiptReadyDepth(t *testing.T) {
+	q, cleanup := newQ(t)
+	defer cleanup()
+	sendBodies(t, q, "unacked")
+	msg := receiveN(t, q, 1)[0]
+	defer msg.Ack()
+	if _, err := q.Delay([]byte("delayed"), time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	r, err := q.SendReceipt([]byte("ready"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Depth != 1 {
+		t.Errorf("got depth %d, want only the Ready message", r.Depth)
+	}
+}
@@ -0,0 +1,247 @@
+package lasr
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// Recovery decides what happens to the messages that are found in the
+// Unacked state when a Q is created. They were received before the process
+// that received them exited without acking or nacking them.
+type Recovery int
+
+const (
+	// RecoverHead returns the messages to the Ready state in their
+	// original position, in front of the messages sent after them. It is
+	// the default.
+	RecoverHead Recovery = iota
+
+	// RecoverTail returns the messages to the Ready state behind every
+	// message that is already in the queue. The messages get new IDs, and
+	// the messages that wait on them wait on the new IDs instead.
+	RecoverTail
+
+	// RecoverDeadLetter moves the messages to the dead letters. It
+	// requires WithDeadLetters.
+	RecoverDeadLetter
+
+	// RecoverNone leaves the messages Unacked, for inspection. They are
+	// not delivered until the Q is created again with another Recovery.
+	RecoverNone
+)
+
+// WithRecovery sets what happens to the messages that are found in the
+// Unacked state when q is created. Messages that are still unacked when q
+// is closed are always returned to the Ready state, in their original
+// position. Recovered returns the number of messages that were found.
+func WithRecovery(r Recovery) Option {
+	return func(q *Q) error {
+		if q.optsApplied {
+			return ErrOptionsApplied
+		}
+		if r < RecoverHead || r > RecoverNone {
+			return fmt.Errorf("lasr: invalid recovery: %d", r)
+		}
+		q.recovery = r
+		return nil
+	}
+}
+
+// Recovered returns the number of messages that were found in the Unacked
+// state when q was created, see WithRecovery.
+func (q *Q) Recovered() int {
+	return q.recovered
+}
+
+// recoverUnacked applies the recovery of q to the messages that are Unacked
+// when q is created. Messages that are returned to the Ready state in their
+// original position are left to equilibrate.
+func (q *Q) recoverUnacked() error {
+	if q.recovery == RecoverDeadLetter && len(q.keys.returned) == 0 {
+		return errors.New("lasr: recovering to dead letters requires WithDeadLetters")
+	}
+	var deadLettered [][]byte
+	q.mu.RLock()
+	err := q.db.Update(func(tx *bolt.Tx) error {
+		deadLettered = nil
+		unacked := q.readBucket(tx, q.keys.unacked)
+		if unacked == nil {
+			return nil
+		}
+		var ids [][]byte
+		if err := unacked.ForEach(func(k, _ []byte) error {
+			ids = append(ids, k)
+			return nil
+		}); err != nil {
+			return err
+		}
+		q.recovered = len(ids)
+		for _, id := range ids {
+			var err error
+			switch q.recovery {
+			case RecoverHead:
+				err = q.recordProcessed(tx, id, time.Now())
+			case RecoverTail:
+				err = q.requeue(tx, unacked, id)
+			case RecoverDeadLetter:
+				err = q.deadLetter(tx, unacked, id, DeadLetterRecovered)
+				deadLettered = append(deadLettered, cloneBytes(id))
+			case RecoverNone:
+				if q.kept == nil {
+					q.kept = make(map[string]bool)
+				}
+				q.kept[string(id)] = true
+			}
+			if err != nil {
+				return fmt.Errorf("lasr: couldn't recover message %x: %s", id, err)
+			}
+		}
+		return nil
+	})
+	q.mu.RUnlock()
+	if err != nil {
+		return err
+	}
+	if q.recovered > 0 {
+		q.logger().Warn("lasr: recovered unacked messages",
+			"count", q.recovered, "recovery", q.recovery.String())
+	}
+	for _, id := range deadLettered {
+		q.logger().Debug("lasr: dead-lettered message", "id", hexID(id))
+		q.onDeadLetter(id)
+	}
+	return nil
+}
+
+func (r Recovery) String() string {
+	switch r {
+	case RecoverHead:
+		return "head"
+	case RecoverTail:
+		return "tail"
+	case RecoverDeadLetter:
+		return "deadletter"
+	case RecoverNone:
+		return "none"
+	}
+	return fmt.Sprintf("Recovery(%d)", int(r))
+}
+
+// requeue moves the unacked message id to the back of the Ready state, under
+// a new ID.
+func (q *Q) requeue(tx *bolt.Tx, unacked *bolt.Bucket, id []byte) error {
+	body, err := q.openBody(tx, id, unacked.Get(id))
+	if err != nil {
+		return err
+	}
+	md, err := q.getMeta(tx, id)
+	if err != nil {
+		return err
+	}
+	newID, err := q.nextSequence(tx)
+	if err != nil {
+		return err
+	}
+	key, err := newID.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	sealed, err := q.sealBody(tx, key, body)
+	if err != nil {
+		return err
+	}
+	ready, err := q.bucket(tx, q.keys.ready)
+	if err != nil {
+		return err
+	}
+	if err := ready.Put(key, sealed); err != nil {
+		return err
+	}
+	q.touch(tx, id)
+	q.touch(tx, key)
+	if err := q.renameBlocker(tx, id, key); err != nil {
+		return err
+	}
+	if err := q.deleteMeta(tx, id); err != nil {
+		return err
+	}
+	if md != nil {
+		if err := q.putMeta(tx, key, md); err != nil {
+			return err
+		}
+		if err := q.index(tx, key, md.Headers); err != nil {
+			return err
+		}
+	}
+	return unacked.Delete(id)
+}
+
+// deadLetter moves the message id, stored in bucket, to the dead letters, as
+// if it had been nacked without retry, for reason.
+func (q *Q) deadLetter(tx *bolt.Tx, bucket *bolt.Bucket, id []byte, reason string) error {
+	if len(q.deadLetterQueue) > 0 {
+		return q.forwardDeadLetter(tx, bucket, id, reason)
+	}
+	if _, err := q.stopWaitingOn(tx, id); err != nil {
+		return err
+	}
+	returned, err := q.bucket(tx, q.keys.returned)
+	if err != nil {
+		return err
+	}
+	if err := returned.Put(id, bucket.Get(id)); err != nil {
+		return err
+	}
+	if err := q.stampDeadLetter(tx, id, time.Now(), reason); err != nil {
+		return err
+	}
+	q.touch(tx, id)
+	if err := q.retire(tx, id); err != nil {
+		return err
+	}
+	if err := q.incrCounter(tx, deadLetteredCounter); err != nil {
+		return err
+	}
+	return bucket.Delete(id)
+}
+
+// renameBlocker makes the messages that wait on from wait on to instead.
+func (q *Q) renameBlocker(tx *bolt.Tx, from, to []byte) error {
+	if len(q.keys.blocking) == 0 {
+		return nil
+	}
+	blocking, err := q.bucket(tx, q.keys.blocking)
+	if err != nil {
+		return err
+	}
+	blocker := blocking.Bucket(from)
+	if blocker == nil {
+		return nil
+	}
+	blockedOn, err := q.bucket(tx, q.keys.blockedOn)
+	if err != nil {
+		return err
+	}
+	renamed, err := blocking.CreateBucket(to)
+	if err != nil {
+		return err
+	}
+	if err := blocker.ForEach(func(k, _ []byte) error {
+		if blockedMsg := blockedOn.Bucket(k); blockedMsg != nil {
+			if err := blockedMsg.Delete(from); err != nil {
+				return err
+			}
+			if err := blockedMsg.Put(to, nil); err != nil {
+				return err
+			}
+			q.touch(tx, k)
+		}
+		return renamed.Put(k, nil)
+	}); err != nil {
+		return err
+	}
+	return blocking.DeleteBucket(from)
+}
@@ -0,0 +1,164 @@
+package lasr
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// crash closes q and then moves its first n Ready messages to the Unacked
+// state, as if they had been received by a process that exited before it
+// could ack them.
+func crash(t *testing.T, q *Q, n int) {
+	t.Helper()
+	if err := q.Close(); err != nil {
+		t.Fatal(err)
+	}
+	err := q.db.Update(func(tx *bolt.Tx) error {
+		ready, err := q.bucket(tx, q.keys.ready)
+		if err != nil {
+			return err
+		}
+		unacked, err := q.bucket(tx, q.keys.unacked)
+		if err != nil {
+			return err
+		}
+		var keys [][]byte
+		cur := ready.Cursor()
+		for k, _ := cur.First(); k != nil && len(keys) < n; k, _ = cur.Next() {
+			keys = append(keys, k)
+		}
+		for _, k := range keys {
+			if err := unacked.Put(k, ready.Get(k)); err != nil {
+				return err
+			}
+			if err := ready.Delete(k); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func receiveBodies(t *testing.T, q *Q, n int) []string {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	var bodies []string
+	for i := 0; i < n; i++ {
+		msg, err := q.Receive(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		bodies = append(bodies, string(msg.Body))
+		if err := msg.Ack(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return bodies
+}
+
+func sendBodies(t *testing.T, q *Q, bodies ...string) {
+	t.Helper()
+	for _, body := range bodies {
+		if _, err := q.Send([]byte(body)); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestRecovery(t *testing.T) {
+	tests := []struct {
+		recovery Recovery
+		want     []string
+	}{
+		{RecoverHead, []string{"a", "b", "c"}},
+		{RecoverTail, []string{"b", "c", "a"}},
+		{RecoverDeadLetter, []string{"b", "c"}},
+		{RecoverNone, []string{"b", "c"}},
+	}
+	for _, test := range tests {
+		q, cleanup := newQ(t, WithDeadLetters())
+		sendBodies(t, q, "a", "b", "c")
+		crash(t, q, 1)
+
+		q, err := NewQ(q.db, "testing", WithDeadLetters(), WithRecovery(test.recovery))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := q.Recovered(), 1; got != want {
+			t.Errorf("%d: bad recovered count: got %d, want %d", test.recovery, got, want)
+		}
+		got := receiveBodies(t, q, len(test.want))
+		for i := range test.want {
+			if got[i] != test.want[i] {
+				t.Errorf("%d: bad bodies: got %q, want %q", test.recovery, got, test.want)
+				break
+			}
+		}
+		stats, err := q.Stats()
+		if err != nil {
+			t.Fatal(err)
+		}
+		switch test.recovery {
+		case RecoverDeadLetter:
+			if got, want := stats.Returned, 1; got != want {
+				t.Errorf("bad returned count: got %d, want %d", got, want)
+			}
+		case RecoverNone:
+			if got, want := stats.Unacked, 1; got != want {
+				t.Errorf("bad unacked count: got %d, want %d", got, want)
+			}
+			// The message is still kept after Close.
+			if err := q.Close(); err != nil {
+				t.Fatal(err)
+			}
+			q, err = NewQ(q.db, "testing", WithDeadLetters(), WithRecovery(RecoverHead))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got, want := receiveBodies(t, q, 1)[0], "a"; got != want {
+				t.Errorf("bad body: got %q, want %q", got, want)
+			}
+		}
+		q.Close()
+		cleanup()
+	}
+}
+
+func TestRecoverTailWaiting(t *testing.T) {
+	q, cleanup := newQ(t)
+	defer cleanup()
+
+	id, err := q.Send([]byte("a"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := q.Wait([]byte("b"), id); err != nil {
+		t.Fatal(err)
+	}
+	crash(t, q, 1)
+
+	q, err = NewQ(q.db, "testing", WithRecovery(RecoverTail))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer q.Close()
+	if got, want := receiveBodies(t, q, 2), []string{"a", "b"}; got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("bad bodies: got %q, want %q", got, want)
+	}
+}
+
+func TestRecoverDeadLetterRequiresDeadLetters(t *testing.T) {
+	q, cleanup := newQ(t)
+	defer cleanup()
+	q.Close()
+	if _, err := NewQ(q.db, "testing", WithRecovery(RecoverDeadLetter)); err == nil {
+		t.Error("expected an error")
+	}
+}
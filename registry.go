@@ -0,0 +1,80 @@
+package lasr
+
+import (
+	"sync"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// sharedDB tracks the open queues that share a bolt database, so that
+// operations that replace the database, like Compact, can update all of them.
+type sharedDB struct {
+	// compacting is held for the duration of a compaction.
+	compacting sync.Mutex
+
+	mu     sync.Mutex
+	db     *bolt.DB
+	queues map[*Q]struct{}
+}
+
+var registry = struct {
+	sync.Mutex
+	dbs map[*bolt.DB]*sharedDB
+}{dbs: make(map[*bolt.DB]*sharedDB)}
+
+// register records that q uses db, and returns the shared state for db.
+func register(q *Q, db *bolt.DB) *sharedDB {
+	registry.Lock()
+	defer registry.Unlock()
+	shared, ok := registry.dbs[db]
+	if !ok {
+		shared = &sharedDB{
+			db:     db,
+			queues: make(map[*Q]struct{}),
+		}
+		registry.dbs[db] = shared
+	}
+	shared.mu.Lock()
+	shared.queues[q] = struct{}{}
+	shared.mu.Unlock()
+	return shared
+}
+
+// unregister removes q from its shared database. When the last queue using a
+// database is unregistered, the database is forgotten.
+func (s *sharedDB) unregister(q *Q) {
+	registry.Lock()
+	defer registry.Unlock()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.queues, q)
+	if len(s.queues) == 0 && registry.dbs[s.db] == s {
+		delete(registry.dbs, s.db)
+	}
+}
+
+// others returns the queues sharing the database, other than q.
+func (s *sharedDB) others(q *Q) []*Q {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	queues := make([]*Q, 0, len(s.queues))
+	for other := range s.queues {
+		if other != q {
+			queues = append(queues, other)
+		}
+	}
+	return queues
+}
+
+// replace records that the database has been replaced with db.
+func (s *sharedDB) replace(db *bolt.DB) {
+	registry.Lock()
+	defer registry.Unlock()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if registry.dbs[s.db] == s {
+		delete(registry.dbs, s.db)
+	}
+	s.db = db
+	registry.dbs[db] = s
+}
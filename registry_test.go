@@ -0,0 +1,94 @@
+package lasr
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+)
+
+func TestMultipleQueues(t *testing.T) {
+	a, cleanup := newQ(t)
+	defer cleanup()
+	b, err := NewQ(a.db, "other", WithDeadLetters())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+
+	idA, err := a.Send([]byte("a"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	idB, err := b.Send([]byte("b"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// each queue has its own sequence
+	if idA != idB {
+		t.Errorf("expected independent sequences: got %v and %v", idA, idB)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	m, err := b.Receive(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := m.Body, []byte("b"); !bytes.Equal(got, want) {
+		t.Errorf("bad body: got %q, want %q", got, want)
+	}
+	if err := m.Nack(false); err != nil {
+		t.Fatal(err)
+	}
+
+	stats, err := a.Stats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := stats, (Stats{Ready: 1, Sent: 1}); got != want {
+		t.Errorf("bad stats: got %+v, want %+v", got, want)
+	}
+
+	// compacting one queue moves every queue in the db to the new file
+	if err := a.Compact(); err != nil {
+		t.Fatal(err)
+	}
+	if a.db != b.db {
+		t.Fatal("queues not moved to the same compacted db")
+	}
+	if _, err := b.Send([]byte("b2")); err != nil {
+		t.Fatal(err)
+	}
+	m, err = b.Receive(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := m.Body, []byte("b2"); !bytes.Equal(got, want) {
+		t.Errorf("bad body after compaction: got %q, want %q", got, want)
+	}
+	if err := m.Ack(); err != nil {
+		t.Fatal(err)
+	}
+	m, err = a.Receive(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := m.Body, []byte("a"); !bytes.Equal(got, want) {
+		t.Errorf("bad body after compaction: got %q, want %q", got, want)
+	}
+	if err := m.Ack(); err != nil {
+		t.Fatal(err)
+	}
+	d, err := DeadLetters(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err = d.Receive(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := m.Body, []byte("b"); !bytes.Equal(got, want) {
+		t.Errorf("bad dead letter: got %q, want %q", got, want)
+	}
+}
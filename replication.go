@@ -0,0 +1,457 @@
+package lasr
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// ReplicationRecord is a change to a message, as written by Replicate and
+// read by ApplyReplication. Each record is encoded as a single line of JSON.
+//
+// A record holds the state of the message after the change, in the same form
+// as ExportRecord, or has Deleted set if the message was acked, or otherwise
+// removed from the queue. The first record of a stream has Reset set, and no
+// message: it tells the standby to discard the messages it holds before the
+// snapshot that follows.
+type ReplicationRecord struct {
+	ExportRecord
+	Deleted bool `json:"deleted,omitempty"`
+	Reset   bool `json:"reset,omitempty"`
+}
+
+// maxApplyBatch is the largest number of records that ApplyReplication
+// applies in one transaction.
+const maxApplyBatch = 1000
+
+// WithReplication makes q keep track of the messages that change, so that
+// they can be streamed to a standby with Replicate.
+func WithReplication() Option {
+	return func(q *Q) error {
+		if q.optsApplied {
+			return ErrOptionsApplied
+		}
+		q.repl = &replication{streams: make(map[*replicaStream]struct{})}
+		return nil
+	}
+}
+
+// replication tracks the replication streams of a Q. It is shared with the
+// dead-letter queue of the Q, whose receives remove dead letters.
+type replication struct {
+	mu      sync.Mutex
+	streams map[*replicaStream]struct{}
+}
+
+// replicaStream holds the IDs of the messages that changed since they were
+// last written to a stream.
+type replicaStream struct {
+	mu      sync.Mutex
+	pending map[string]struct{}
+	signal  chan struct{}
+}
+
+// touch records that the message id changes in tx, for the replication
+// streams of q.
+func (q *Q) touch(tx *bolt.Tx, id []byte) {
+	q.watchReady(tx)
+	r := q.repl
+	if r == nil {
+		return
+	}
+	id = cloneBytes(id)
+	tx.OnCommit(func() {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		for s := range r.streams {
+			s.mu.Lock()
+			s.pending[string(id)] = struct{}{}
+			s.mu.Unlock()
+			select {
+			case s.signal <- struct{}{}:
+			default:
+			}
+		}
+	})
+}
+
+// Replicate streams the messages of q to w, for a standby to apply with
+// ApplyReplication: first a snapshot of every message, and then each message
+// that changes, as the change is committed. Replicate returns when ctx is
+// done, when q is closed, or when writing to w fails. A standby that falls
+// behind or disconnects catches up by replicating again from the start.
+//
+// Replication is asynchronous: Send and the other methods of q don't wait
+// for the change to reach w. Changes to the same message that happen in
+// quick succession may be written as a single record. Subscriptions, the
+// counters of Stats, and the keys of SendDedup are not replicated. q must have
+// been created with WithReplication.
+func (q *Q) Replicate(ctx context.Context, w io.Writer) error {
+	if q.repl == nil {
+		return errors.New("lasr: replication not enabled")
+	}
+	if q.isClosed() {
+		return ErrQClosed
+	}
+	s := &replicaStream{
+		pending: make(map[string]struct{}),
+		signal:  make(chan struct{}, 1),
+	}
+	q.repl.mu.Lock()
+	q.repl.streams[s] = struct{}{}
+	q.repl.mu.Unlock()
+	defer func() {
+		q.repl.mu.Lock()
+		delete(q.repl.streams, s)
+		q.repl.mu.Unlock()
+	}()
+
+	bw := bufio.NewWriter(w)
+	enc := json.NewEncoder(bw)
+	if err := enc.Encode(&ReplicationRecord{Reset: true}); err != nil {
+		return err
+	}
+	if err := q.snapshot(enc); err != nil {
+		return err
+	}
+	for {
+		if err := bw.Flush(); err != nil {
+			return err
+		}
+		select {
+		case <-s.signal:
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-q.closed:
+			return ErrQClosed
+		}
+		s.mu.Lock()
+		ids := s.pending
+		s.pending = make(map[string]struct{})
+		s.mu.Unlock()
+		if err := q.writeChanges(enc, ids); err != nil {
+			return err
+		}
+	}
+}
+
+// snapshot writes every message in q to enc.
+func (q *Q) snapshot(enc *json.Encoder) error {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	err := q.db.View(func(tx *bolt.Tx) error {
+		for _, state := range append(exportStates, Retrying) {
+			bucket := q.readBucket(tx, q.stateBucketKey(state))
+			if bucket == nil {
+				continue
+			}
+			err := bucket.ForEach(func(k, v []byte) error {
+				rec, err := q.replicationRecord(tx, state, messageID(state, k), v)
+				if err != nil {
+					return err
+				}
+				return enc.Encode(rec)
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("lasr: error replicating queue: %s", err)
+	}
+	return nil
+}
+
+// writeChanges writes the current state of the messages with the given IDs
+// to enc.
+func (q *Q) writeChanges(enc *json.Encoder, ids map[string]struct{}) error {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	err := q.db.View(func(tx *bolt.Tx) error {
+		for id := range ids {
+			state, v := q.find(tx, []byte(id))
+			if v == nil {
+				rec := &ReplicationRecord{Deleted: true}
+				rec.ID = []byte(id)
+				if err := enc.Encode(rec); err != nil {
+					return err
+				}
+				continue
+			}
+			rec, err := q.replicationRecord(tx, state, []byte(id), v)
+			if err != nil {
+				return err
+			}
+			if err := enc.Encode(rec); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("lasr: error replicating queue: %s", err)
+	}
+	return nil
+}
+
+// replicationRecord returns the record for the message id, stored as v in
+// the bucket for state. Messages waiting to be retried are replicated as
+// Ready, which is the state they will return to.
+func (q *Q) replicationRecord(tx *bolt.Tx, state Status, id, v []byte) (*ReplicationRecord, error) {
+	if state == Retrying {
+		state = Ready
+	}
+	rec, err := q.exportRecord(tx, state, id, v)
+	if err != nil {
+		return nil, err
+	}
+	return &ReplicationRecord{ExportRecord: *rec}, nil
+}
+
+// find returns the state of the message id, and its stored value, or a nil
+// value if it is not in q.
+func (q *Q) find(tx *bolt.Tx, id []byte) (Status, []byte) {
+	for _, state := range exportStates {
+		if bucket := q.readBucket(tx, q.stateBucketKey(state)); bucket != nil {
+			if v := bucket.Get(id); v != nil {
+				return state, v
+			}
+		}
+	}
+	if bucket := q.readBucket(tx, q.keys.retrying); bucket != nil {
+		cur := bucket.Cursor()
+		for k, v := cur.First(); k != nil; k, v = cur.Next() {
+			if bytes.Equal(k[8:], id) {
+				return Retrying, v
+			}
+		}
+	}
+	return Ready, nil
+}
+
+// ApplyReplication reads the stream written by Replicate from r, and applies
+// it to q, until r returns io.EOF or an error. Unacked messages are applied
+// as Ready, so that they are received again if the standby takes over.
+//
+// q is the standby: it must not be used to send or receive messages while
+// the stream is applied, but it can be inspected with Stats, Peek and Scan.
+// Records are applied in transactions of their own, so if the stream breaks
+// off, q holds the messages as of the last record that was applied.
+func (q *Q) ApplyReplication(r io.Reader) error {
+	if q.isClosed() {
+		return ErrQClosed
+	}
+	if q.readOnly {
+		return ErrReadOnly
+	}
+	recs := make(chan *ReplicationRecord, maxApplyBatch)
+	errc := make(chan error, 1)
+	go func() {
+		defer close(recs)
+		dec := json.NewDecoder(r)
+		for {
+			rec := new(ReplicationRecord)
+			if err := dec.Decode(rec); err != nil {
+				if err != io.EOF {
+					errc <- fmt.Errorf("lasr: error reading replication stream: %s", err)
+				}
+				return
+			}
+			recs <- rec
+		}
+	}()
+	for rec := range recs {
+		batch := []*ReplicationRecord{rec}
+	FILL:
+		for len(batch) < maxApplyBatch {
+			select {
+			case rec, ok := <-recs:
+				if !ok {
+					break FILL
+				}
+				batch = append(batch, rec)
+			default:
+				break FILL
+			}
+		}
+		if err := q.applyBatch(batch); err != nil {
+			// Let the reader finish, so that it doesn't leak.
+			for range recs {
+			}
+			return err
+		}
+	}
+	select {
+	case err := <-errc:
+		return err
+	default:
+		return nil
+	}
+}
+
+func (q *Q) applyBatch(batch []*ReplicationRecord) error {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	err := q.db.Update(func(tx *bolt.Tx) error {
+		for _, rec := range batch {
+			if err := q.apply(tx, rec); err != nil {
+				return fmt.Errorf("lasr: error applying replication record %x: %s", rec.ID, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if !q.isClosed() {
+		q.waker.Wake()
+	}
+	return nil
+}
+
+func (q *Q) apply(tx *bolt.Tx, rec *ReplicationRecord) error {
+	if rec.Reset {
+		return q.resetMessages(tx)
+	}
+	if err := q.forget(tx, rec.ID, rec.Deleted); err != nil {
+		return err
+	}
+	if rec.Deleted {
+		return nil
+	}
+	if _, err := q.importRecord(tx, &rec.ExportRecord); err != nil {
+		return err
+	}
+	return q.index(tx, rec.ID, rec.Headers)
+}
+
+// forget removes the message id from q, wherever it is. If the message is
+// deleted, rather than about to be replaced, the messages that wait on it
+// stop doing so.
+func (q *Q) forget(tx *bolt.Tx, id []byte, deleted bool) error {
+	state, v := q.find(tx, id)
+	if v == nil {
+		return nil
+	}
+	if state == Waiting {
+		if err := q.unblock(tx, id); err != nil {
+			return err
+		}
+	}
+	key := id
+	if state == Retrying {
+		cur := q.readBucket(tx, q.keys.retrying).Cursor()
+		for k, _ := cur.First(); k != nil; k, _ = cur.Next() {
+			if bytes.Equal(k[8:], id) {
+				key = cloneBytes(k)
+				break
+			}
+		}
+	}
+	bucket, err := q.bucket(tx, q.stateBucketKey(state))
+	if err != nil {
+		return err
+	}
+	if err := bucket.Delete(key); err != nil {
+		return err
+	}
+	if deleted {
+		if blocking := q.readBucket(tx, q.keys.blocking); blocking != nil {
+			if err := blocking.DeleteBucket(id); err != nil && err != bolt.ErrBucketNotFound {
+				return err
+			}
+		}
+	}
+	if err := q.deleteMeta(tx, id); err != nil {
+		return err
+	}
+	if state != Returned {
+		return q.adjustDepth(tx, -1)
+	}
+	return nil
+}
+
+// unblock removes the records of what the waiting message id waits on.
+func (q *Q) unblock(tx *bolt.Tx, id []byte) error {
+	blockedOn := q.readBucket(tx, q.keys.blockedOn)
+	if blockedOn == nil {
+		return nil
+	}
+	on := blockedOn.Bucket(id)
+	if on == nil {
+		return nil
+	}
+	if blocking := q.readBucket(tx, q.keys.blocking); blocking != nil {
+		if err := on.ForEach(func(blocker, _ []byte) error {
+			if b := blocking.Bucket(blocker); b != nil {
+				return b.Delete(id)
+			}
+			return nil
+		}); err != nil {
+			return err
+		}
+	}
+	return blockedOn.DeleteBucket(id)
+}
+
+// resetMessages deletes every message in q, before a snapshot is applied.
+func (q *Q) resetMessages(tx *bolt.Tx) error {
+	root := tx.Bucket(q.name)
+	if root == nil {
+		return nil
+	}
+	if blobs := root.Bucket(blobsBucket); blobs != nil {
+		var ids [][]byte
+		if err := blobs.ForEach(func(k, _ []byte) error {
+			ids = append(ids, cloneBytes(k))
+			return nil
+		}); err != nil {
+			return err
+		}
+		for _, id := range ids {
+			if err := q.deleteBlob(tx, id); err != nil {
+				return err
+			}
+		}
+	}
+	for _, key := range [][]byte{
+		q.keys.ready, q.keys.unacked, q.keys.delayed, q.keys.waiting,
+		q.keys.retrying, q.keys.returned, q.keys.meta, q.keys.blockedOn,
+		q.keys.blocking, q.keys.groups, chunksBucket, blobsBucket,
+		checksumsBucket,
+	} {
+		if len(key) == 0 {
+			continue
+		}
+		if err := root.DeleteBucket(key); err != nil && err != bolt.ErrBucketNotFound {
+			return err
+		}
+	}
+	if index := root.Bucket(q.keys.selectors); index != nil {
+		var headers [][]byte
+		if err := index.ForEach(func(k, _ []byte) error {
+			headers = append(headers, cloneBytes(k))
+			return nil
+		}); err != nil {
+			return err
+		}
+		for _, header := range headers {
+			if err := index.DeleteBucket(header); err != nil {
+				return err
+			}
+			if _, err := index.CreateBucket(header); err != nil {
+				return err
+			}
+		}
+	}
+	return q.resetDepth(tx)
+}
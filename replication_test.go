@@ -0,0 +1,124 @@
+package lasr
+
+import (
+	"context"
+	"io"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+// bodiesIn returns the sorted bodies of the messages in q in state.
+func bodiesIn(t *testing.T, q *Q, state Status) []string {
+	t.Helper()
+	var bodies []string
+	err := q.Scan(state, func(id, body []byte) error {
+		bodies = append(bodies, string(body))
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(bodies)
+	return bodies
+}
+
+func TestReplication(t *testing.T) {
+	primary, cleanup := newQ(t, WithReplication(), WithDeadLetters())
+	defer cleanup()
+	standby, cleanup2 := newQ(t, WithDeadLetters())
+	defer cleanup2()
+
+	if _, err := standby.Send([]byte("stale")); err != nil {
+		t.Fatal(err)
+	}
+	a, err := primary.Send([]byte("a"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	pr, pw := io.Pipe()
+	replicated := make(chan error, 1)
+	go func() {
+		err := primary.Replicate(ctx, pw)
+		pw.Close()
+		replicated <- err
+	}()
+	applied := make(chan error, 1)
+	go func() {
+		applied <- standby.ApplyReplication(pr)
+	}()
+
+	sendBodies(t, primary, "b", "c")
+	if _, err := primary.Wait([]byte("w"), a); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := primary.Delay([]byte("d"), time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	msgs := receiveN(t, primary, 2)
+	if err := msgs[0].Ack(); err != nil {
+		t.Fatal(err)
+	}
+	if err := msgs[1].Nack(false); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[Status][]string{
+		Ready:    {"c", "w"},
+		Unacked:  nil,
+		Delayed:  {"d"},
+		Waiting:  nil,
+		Returned: {"b"},
+	}
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		got := map[Status][]string{}
+		for state := range want {
+			got[state] = bodiesIn(t, standby, state)
+		}
+		if reflect.DeepEqual(got, want) {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("standby has %v, want %v", got, want)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	cancel()
+	if err := <-replicated; err != context.Canceled {
+		t.Errorf("Replicate: got %v, want context.Canceled", err)
+	}
+	if err := <-applied; err != nil {
+		t.Errorf("ApplyReplication: %s", err)
+	}
+
+	stats, err := standby.Stats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Ready != 2 || stats.Returned != 1 || stats.Delayed != 1 {
+		t.Errorf("bad standby stats: %+v", stats)
+	}
+	msg, err := standby.Receive(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(msg.Body); got != "c" {
+		t.Errorf("got %q, want %q", got, "c")
+	}
+	if err := msg.Ack(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReplicateNotEnabled(t *testing.T) {
+	q, cleanup := newQ(t)
+	defer cleanup()
+	if err := q.Replicate(context.Background(), io.Discard); err == nil {
+		t.Fatal("expected an error")
+	}
+}
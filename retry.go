@@ -0,0 +1,153 @@
+package lasr
+
+import (
+	"bytes"
+	"fmt"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// RetryPolicy decides how long a message that is nacked for retry waits
+// before it is Ready again. Backoff is called with the number of times the
+// message has been retried so far, starting at 1.
+type RetryPolicy interface {
+	Backoff(retries int) time.Duration
+}
+
+// FixedBackoff is a RetryPolicy that always waits the same amount of time.
+type FixedBackoff time.Duration
+
+// Backoff returns b.
+func (b FixedBackoff) Backoff(retries int) time.Duration {
+	return time.Duration(b)
+}
+
+// ExponentialBackoff is a RetryPolicy that doubles the wait with each retry,
+// starting at Initial, up to Max. If Max is zero, the wait is not limited.
+type ExponentialBackoff struct {
+	Initial time.Duration
+	Max     time.Duration
+}
+
+// Backoff returns Initial * 2^(retries-1), capped at Max.
+func (b ExponentialBackoff) Backoff(retries int) time.Duration {
+	d := b.Initial
+	for i := 1; i < retries; i++ {
+		d *= 2
+		if (b.Max > 0 && d >= b.Max) || d <= 0 {
+			// d <= 0 means that d overflowed.
+			return b.Max
+		}
+	}
+	if b.Max > 0 && d > b.Max {
+		return b.Max
+	}
+	return d
+}
+
+// WithRetryPolicy causes Nack(true) to make messages Ready again only after
+// the delay chosen by policy, instead of immediately. While they wait, the
+// messages are counted as Retrying in Stats.
+//
+// The number of times each message has been retried is persisted with the
+// message.
+func WithRetryPolicy(policy RetryPolicy) Option {
+	return func(q *Q) error {
+		if q.optsApplied {
+			return ErrOptionsApplied
+		}
+		if policy == nil {
+			return fmt.Errorf("lasr: nil retry policy")
+		}
+		q.retryPolicy = policy
+		return nil
+	}
+}
+
+// retryDelay records another retry of id in tx, and returns how long the
+// message should wait before it is Ready again. A delay that is not negative
+// is used instead of the retry policy.
+func (q *Q) retryDelay(tx *bolt.Tx, id []byte, delay time.Duration) (time.Duration, error) {
+	if q.retryPolicy == nil {
+		if delay < 0 {
+			return 0, nil
+		}
+		return delay, nil
+	}
+	md, err := q.getMeta(tx, id)
+	if err != nil {
+		return 0, err
+	}
+	if md == nil {
+		md = &metadata{}
+	}
+	md.Retries++
+	if err := q.putMeta(tx, id, md); err != nil {
+		return 0, err
+	}
+	if delay >= 0 {
+		return delay, nil
+	}
+	return q.retryPolicy.Backoff(md.Retries), nil
+}
+
+// scheduleRetry stores the message id with body in the retrying bucket, to
+// be made Ready again at due.
+func (q *Q) scheduleRetry(tx *bolt.Tx, id, body []byte, due time.Time) error {
+	bucket, err := q.bucket(tx, q.keys.retrying)
+	if err != nil {
+		return err
+	}
+	return bucket.Put(retryKey(due, id), body)
+}
+
+// releaseRetries moves the messages whose retry is due by now back to the
+// Ready state, under their original IDs.
+func (q *Q) releaseRetries(tx *bolt.Tx, now time.Time) (int, error) {
+	bucket := q.readBucket(tx, q.keys.retrying)
+	if bucket == nil {
+		return 0, nil
+	}
+	until, err := Uint64ID(now.UnixNano()).MarshalBinary()
+	if err != nil {
+		return 0, err
+	}
+	var due [][]byte
+	cur := bucket.Cursor()
+	for k, _ := cur.First(); k != nil && bytes.Compare(k[:8], until) <= 0; k, _ = cur.Next() {
+		due = append(due, k)
+	}
+	if len(due) == 0 {
+		return 0, nil
+	}
+	ready, err := q.bucket(tx, q.keys.ready)
+	if err != nil {
+		return 0, err
+	}
+	q.watchReady(tx)
+	for _, k := range due {
+		if err := ready.Put(k[8:], bucket.Get(k)); err != nil {
+			return 0, err
+		}
+		if err := bucket.Delete(k); err != nil {
+			return 0, err
+		}
+	}
+	return len(due), nil
+}
+
+// retryKey orders retries by their due time, then by message ID.
+func retryKey(due time.Time, id []byte) []byte {
+	t, _ := Uint64ID(due.UnixNano()).MarshalBinary()
+	return append(t, id...)
+}
+
+// retryDue returns the due time of the retry stored at k.
+func retryDue(k []byte) (time.Time, error) {
+	var t Uint64ID
+	if err := t.UnmarshalBinary(k[:8]); err != nil {
+		return time.Time{}, err
+	}
+	return time.Unix(0, int64(t)), nil
+}
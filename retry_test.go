@@ -0,0 +1,205 @@
+package lasr
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+func TestExponentialBackoff(t *testing.T) {
+	b := ExponentialBackoff{Initial: time.Second, Max: 10 * time.Second}
+	for retries, want := range map[int]time.Duration{
+		1:   time.Second,
+		2:   2 * time.Second,
+		4:   8 * time.Second,
+		5:   10 * time.Second,
+		100: 10 * time.Second,
+	} {
+		if got := b.Backoff(retries); got != want {
+			t.Errorf("bad backoff for %d retries: got %s, want %s", retries, got, want)
+		}
+	}
+}
+
+func TestRetryPolicy(t *testing.T) {
+	q, cleanup := newQ(t, WithRetryPolicy(FixedBackoff(50*time.Millisecond)))
+	defer cleanup()
+
+	if _, err := q.Send([]byte("foo")); err != nil {
+		t.Fatal(err)
+	}
+	m, err := q.Receive(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Retry twice, to check that the second retry is scheduled even though
+	// its due time is after the first one.
+	for i := 1; i <= 2; i++ {
+		if err := m.Nack(true); err != nil {
+			t.Fatal(err)
+		}
+		stats, err := q.Stats()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stats.Retrying != 1 || stats.Ready != 0 {
+			t.Fatalf("message not scheduled for retry: %+v", stats)
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		_, err = q.Receive(ctx)
+		cancel()
+		if err != context.DeadlineExceeded {
+			t.Fatalf("message retried too early: %v", err)
+		}
+
+		ctx, cancel = context.WithTimeout(context.Background(), time.Second)
+		m, err = q.Receive(ctx)
+		cancel()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := string(m.Body), "foo"; got != want {
+			t.Errorf("bad body: got %q, want %q", got, want)
+		}
+		err = q.db.View(func(tx *bolt.Tx) error {
+			md, err := q.getMeta(tx, m.ID)
+			if err != nil {
+				return err
+			}
+			if md == nil || md.Retries != i {
+				t.Errorf("bad retry count: got %+v, want %d", md, i)
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := m.Ack(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRetryingVisible(t *testing.T) {
+	q, cleanup := newQ(t, WithRetryPolicy(FixedBackoff(time.Hour)))
+	defer cleanup()
+
+	id, err := q.Send([]byte("foo"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := q.Receive(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Nack(true); err != nil {
+		t.Fatal(err)
+	}
+
+	want, _ := id.MarshalBinary()
+	var scanned int
+	err = q.Scan(Retrying, func(id, body []byte) error {
+		scanned++
+		if string(id) != string(want) || string(body) != "foo" {
+			t.Errorf("bad retrying message: %x %q", id, body)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if scanned != 1 {
+		t.Fatalf("bad scan count: got %d, want 1", scanned)
+	}
+
+	n, err := q.Purge(Retrying)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Errorf("bad purge count: got %d, want 1", n)
+	}
+	stats, err := q.Stats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Retrying != 0 {
+		t.Errorf("retrying message not purged: %+v", stats)
+	}
+}
+
+func TestPeekDueRetries(t *testing.T) {
+	q, cleanup := newQ(t, WithRetryPolicy(FixedBackoff(time.Millisecond)))
+	defer cleanup()
+
+	for _, body := range []string{"a", "b"} {
+		if _, err := q.Send([]byte(body)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	m, err := q.Receive(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Nack(true); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(5 * time.Millisecond)
+	peeked, err := q.Peek(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(peeked) != 2 || string(peeked[0].Body) != "a" || string(peeked[1].Body) != "b" {
+		t.Fatalf("bad peek: %v", peeked)
+	}
+}
+
+func TestNackDelay(t *testing.T) {
+	// The retry policy is overridden by NackDelay.
+	q, cleanup := newQ(t, WithRetryPolicy(FixedBackoff(time.Hour)))
+	defer cleanup()
+
+	if _, err := q.Send([]byte("foo")); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	m, err := q.Receive(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sent := time.Now()
+	if err := m.NackDelay(50 * time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	stats, err := q.Stats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := stats.Retrying, 1; got != want {
+		t.Errorf("bad retrying count: got %d, want %d", got, want)
+	}
+	m, err = q.Receive(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(sent); elapsed < 50*time.Millisecond {
+		t.Errorf("message was Ready after %s", elapsed)
+	}
+	if err := m.NackDelay(0); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.NackDelay(0); err != ErrAckNack {
+		t.Errorf("bad error on second nack: got %v, want %v", err, ErrAckNack)
+	}
+	stats, err = q.Stats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := stats.Ready, 1; got != want {
+		t.Errorf("bad ready count: got %d, want %d", got, want)
+	}
+}
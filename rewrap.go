@@ -0,0 +1,186 @@
+package lasr
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// rewrapBatch is how many stored messages Rewrap reads per transaction.
+const rewrapBatch = 1000
+
+// Rewrap re-encrypts the bodies of the messages in q that were encrypted with
+// another key than the current one, see WithEncryptionKeys, and returns how
+// many it re-encrypted. Messages in every state are rewrapped, and so are the
+// archive and the bodies of schedules. Subscriptions and partitions are queues
+// of their own, and are rewrapped with their own Rewrap.
+//
+// Rewrap works a batch of messages at a time, alongside sends and receives, so
+// it can run in the background on a live queue. It stops with the error of ctx
+// once ctx is done, and can be run again to carry on.
+func (q *Q) Rewrap(ctx context.Context) (int, error) {
+	if q.isClosed() {
+		return 0, ErrQClosed
+	}
+	if q.readOnly {
+		return 0, ErrReadOnly
+	}
+	if q.aead == nil {
+		return 0, errors.New("lasr: queue is not encrypted")
+	}
+	var n int
+	rewrap := func(key []byte, fn func(tx *bolt.Tx, k, v []byte) ([]byte, error)) error {
+		from := []byte{}
+		for from != nil {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			start := from
+			var done int
+			err := q.commit(func(tx *bolt.Tx) (err error) {
+				done, from, err = q.rewrapBatch(tx, key, start, fn)
+				return err
+			})
+			if err != nil {
+				return err
+			}
+			n += done
+		}
+		return nil
+	}
+	for _, state := range []Status{Ready, Unacked, Delayed, Waiting, Retrying, Returned} {
+		key := q.stateBucketKey(state)
+		if key == nil {
+			continue
+		}
+		state := state
+		err := rewrap(key, func(tx *bolt.Tx, k, v []byte) ([]byte, error) {
+			return q.rewrapBody(tx, messageID(state, k), v)
+		})
+		if err != nil {
+			return n, err
+		}
+	}
+	if len(q.keys.archive) > 0 {
+		if err := rewrap(q.keys.archive, q.rewrapArchived); err != nil {
+			return n, err
+		}
+	}
+	if err := rewrap(schedulesBucket, q.rewrapSchedule); err != nil {
+		return n, err
+	}
+	return n, nil
+}
+
+// rewrapBatch rewraps up to rewrapBatch values in the bucket key of q, from
+// the key from on, with fn, which returns nil for values that don't need it.
+// It returns how many values were rewrapped, and the key to carry on from, if
+// there are values left.
+func (q *Q) rewrapBatch(tx *bolt.Tx, key, from []byte, fn func(tx *bolt.Tx, k, v []byte) ([]byte, error)) (int, []byte, error) {
+	bucket := q.readBucket(tx, key)
+	if bucket == nil {
+		return 0, nil, nil
+	}
+	var keys, values [][]byte
+	var next []byte
+	var read int
+	cur := bucket.Cursor()
+	for k, v := cur.Seek(from); k != nil; k, v = cur.Next() {
+		if read == rewrapBatch {
+			next = cloneBytes(k)
+			break
+		}
+		read++
+		value, err := fn(tx, k, v)
+		if err != nil {
+			return 0, nil, err
+		}
+		if value != nil {
+			keys = append(keys, cloneBytes(k))
+			values = append(values, value)
+		}
+	}
+	// The bucket is only written to once the cursor is done with it.
+	for i, k := range keys {
+		if err := bucket.Put(k, values[i]); err != nil {
+			return 0, nil, err
+		}
+	}
+	return len(keys), next, nil
+}
+
+// rewrapBody seals the body of message id, stored as v, with the current key,
+// and stores it again. It returns the new value of the message, or nil if its
+// body is sealed with the current key already.
+func (q *Q) rewrapBody(tx *bolt.Tx, id, v []byte) ([]byte, error) {
+	stored, err := q.loadBody(tx, id, v)
+	if err != nil {
+		return nil, err
+	}
+	body, keyID, err := q.unsealKey(id, stored)
+	if err != nil {
+		return nil, err
+	}
+	if keyID == q.keyID {
+		return nil, nil
+	}
+	sealed, err := q.seal(id, body)
+	if err != nil {
+		return nil, err
+	}
+	// The sealed body can change size, and so where it is stored. A blob is
+	// stored under the same key again, so it is only deleted if the body
+	// moves out of the blob store.
+	if chunks := q.readBucket(tx, chunksBucket); chunks != nil && chunks.Bucket(id) != nil {
+		if err := chunks.DeleteBucket(id); err != nil {
+			return nil, err
+		}
+	}
+	if q.blobs == nil || len(sealed) <= q.blobThreshold {
+		if err := q.deleteBlob(tx, id); err != nil {
+			return nil, err
+		}
+	}
+	return q.storeBody(tx, id, sealed)
+}
+
+// rewrapArchived is rewrapBody for the archived message stored as v under the
+// archive key k.
+func (q *Q) rewrapArchived(tx *bolt.Tx, k, v []byte) ([]byte, error) {
+	var rec archived
+	if err := json.Unmarshal(v, &rec); err != nil {
+		return nil, err
+	}
+	body, keyID, err := q.unsealKey(k[8:], rec.Body)
+	if err != nil {
+		return nil, err
+	}
+	if keyID == q.keyID {
+		return nil, nil
+	}
+	if rec.Body, err = q.seal(k[8:], body); err != nil {
+		return nil, err
+	}
+	return json.Marshal(rec)
+}
+
+// rewrapSchedule is rewrapBody for the schedule stored as v under k.
+func (q *Q) rewrapSchedule(tx *bolt.Tx, k, v []byte) ([]byte, error) {
+	var rec recurring
+	if err := json.Unmarshal(v, &rec); err != nil {
+		return nil, err
+	}
+	body, keyID, err := q.unsealKey(scheduleAD(k), rec.Body)
+	if err != nil {
+		return nil, err
+	}
+	if keyID == q.keyID {
+		return nil, nil
+	}
+	if rec.Body, err = q.seal(scheduleAD(k), body); err != nil {
+		return nil, err
+	}
+	return json.Marshal(rec)
+}
@@ -0,0 +1,95 @@
+package lasr
+
+import (
+	"bytes"
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestRewrap(t *testing.T) {
+	q, cleanup := newQ(t, WithEncryption(testKey), WithDeadLetters(), WithChunking(32), WithArchive())
+	defer cleanup()
+	db := q.db
+
+	large := string(bytes.Repeat([]byte("x"), 100))
+	sendBodies(t, q, "dead", "acked", "ready", large)
+	msgs := receiveN(t, q, 2)
+	if err := msgs[0].Nack(false); err != nil {
+		t.Fatal(err)
+	}
+	if err := msgs[1].Ack(); err != nil {
+		t.Fatal(err)
+	}
+	if err := q.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	newKey := bytes.Repeat([]byte{'k'}, 32)
+	both := map[uint32][]byte{0: testKey, 1: newKey}
+	q, err := NewQ(db, "testing", WithEncryptionKeys(1, both), WithDeadLetters(), WithChunking(32), WithArchive())
+	if err != nil {
+		t.Fatal(err)
+	}
+	sendBodies(t, q, "new")
+	n, err := q.Rewrap(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 4 {
+		t.Errorf("rewrapped %d messages, want 4", n)
+	}
+	if n, err := q.Rewrap(context.Background()); err != nil || n != 0 {
+		t.Errorf("rewrapping again: got %d, %v, want 0, nil", n, err)
+	}
+	if err := q.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The old key is no longer needed.
+	q, err = NewQ(db, "testing", WithEncryptionKeys(1, map[uint32][]byte{1: newKey}),
+		WithDeadLetters(), WithChunking(32), WithArchive())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer q.Close()
+	if n, err := q.Replay(time.Time{}); err != nil || n != 1 {
+		t.Errorf("replaying the archive: got %d, %v, want 1, nil", n, err)
+	}
+	got := receiveBodies(t, q, 4)
+	if want := []string{"ready", large, "new", "acked"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	d, err := DeadLetters(q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := receiveBodies(t, d, 1); got[0] != "dead" {
+		t.Errorf("got dead letter %q, want %q", got[0], "dead")
+	}
+}
+
+func TestRewrapCanceled(t *testing.T) {
+	q, cleanup := newQ(t, WithEncryption(testKey))
+	defer cleanup()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := q.Rewrap(ctx); err != context.Canceled {
+		t.Errorf("got %v, want context.Canceled", err)
+	}
+}
+
+func TestEncryptionKeysErrors(t *testing.T) {
+	q, cleanup := newQ(t)
+	defer cleanup()
+	if _, err := q.Rewrap(context.Background()); err == nil {
+		t.Error("expected an error rewrapping an unencrypted queue")
+	}
+	if _, err := NewQ(q.db, "other", WithEncryptionKeys(1, map[uint32][]byte{0: testKey})); err == nil {
+		t.Error("expected an error for a missing current key")
+	}
+	if _, err := NewQ(q.db, "other", WithEncryptionKeys(0, map[uint32][]byte{0: []byte("short")})); err == nil {
+		t.Error("expected an error for a bad key")
+	}
+}
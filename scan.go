@@ -0,0 +1,35 @@
+package lasr
+
+import bolt "go.etcd.io/bbolt"
+
+// Scan calls fn for each message in the given state, in key order, with the
+// message's ID and body. If fn returns an error, Scan stops and returns it.
+//
+// Scan runs in a single read-only transaction, so fn sees a consistent
+// snapshot of q. The slices passed to fn are only valid until fn returns, and
+// fn must not call methods of q that modify it.
+func (q *Q) Scan(state Status, fn func(id, body []byte) error) error {
+	if q.isClosed() {
+		return ErrQClosed
+	}
+	key, err := q.stateKey(state)
+	if err != nil {
+		return err
+	}
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	return q.db.View(func(tx *bolt.Tx) error {
+		bucket := q.readBucket(tx, key)
+		if bucket == nil {
+			return nil
+		}
+		return bucket.ForEach(func(k, v []byte) error {
+			id := messageID(state, k)
+			body, err := q.openBody(tx, id, v)
+			if err != nil {
+				return err
+			}
+			return fn(id, body)
+		})
+	})
+}
@@ -0,0 +1,85 @@
+package lasr
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestScan(t *testing.T) {
+	q, cleanup := newQ(t, WithDeadLetters())
+	defer cleanup()
+
+	for _, body := range []string{"a", "b", "c", "d"} {
+		if _, err := q.Send([]byte(body)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := q.Delay([]byte("later"), time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	m, err := q.Receive(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Nack(false); err != nil {
+		t.Fatal(err)
+	}
+	m, err = q.Receive(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Ack()
+
+	scan := func(state Status) []string {
+		var bodies []string
+		err := q.Scan(state, func(id, body []byte) error {
+			bodies = append(bodies, string(body))
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return bodies
+	}
+	tests := []struct {
+		state Status
+		want  []string
+	}{
+		{Ready, []string{"c", "d"}},
+		{Unacked, []string{"b"}},
+		{Returned, []string{"a"}},
+		{Delayed, []string{"later"}},
+		{Waiting, nil},
+	}
+	for _, test := range tests {
+		if got := scan(test.state); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("bad %s messages: got %v, want %v", test.state, got, test.want)
+		}
+	}
+
+	stop := errors.New("stop")
+	var n int
+	err = q.Scan(Ready, func(id, body []byte) error {
+		n++
+		return stop
+	})
+	if err != stop {
+		t.Errorf("bad error: got %v, want %v", err, stop)
+	}
+	if n != 1 {
+		t.Errorf("scan didn't stop: %d calls", n)
+	}
+}
+
+func TestScanDeadLettersDisabled(t *testing.T) {
+	q, cleanup := newQ(t)
+	defer cleanup()
+
+	if err := q.Scan(Returned, func(id, body []byte) error { return nil }); err == nil {
+		t.Error("expected error")
+	}
+}
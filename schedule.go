@@ -0,0 +1,391 @@
+package lasr
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/robfig/cron/v3"
+	bolt "go.etcd.io/bbolt"
+)
+
+// schedulesBucket holds the recurring schedules of a queue, keyed by
+// schedule ID.
+var schedulesBucket = []byte("schedules")
+
+// scheduleGrace is how late a schedule can fire before CatchUpSkip counts the
+// time as missed.
+const scheduleGrace = time.Minute
+
+// maxCatchUp is the most messages CatchUpAll sends for one schedule at once.
+const maxCatchUp = 1000
+
+// CatchUpPolicy decides what a schedule does about the times it was due to
+// fire while no Q had its database open, see WithScheduleCatchUp.
+type CatchUpPolicy int
+
+const (
+	// CatchUpOnce sends a single message for all the missed times. It is
+	// the default.
+	CatchUpOnce CatchUpPolicy = iota
+
+	// CatchUpAll sends a message for each of the missed times, up to 1000
+	// of them.
+	CatchUpAll
+
+	// CatchUpSkip sends no message for the missed times. A time counts as
+	// missed once it is more than a minute past.
+	CatchUpSkip
+)
+
+// WithScheduleCatchUp sets what the schedules of q do about the times they
+// missed, see Schedule.
+func WithScheduleCatchUp(policy CatchUpPolicy) Option {
+	return func(q *Q) error {
+		if q.optsApplied {
+			return ErrOptionsApplied
+		}
+		if policy < CatchUpOnce || policy > CatchUpSkip {
+			return fmt.Errorf("lasr: invalid catch-up policy: %d", policy)
+		}
+		q.catchUp = policy
+		return nil
+	}
+}
+
+// Recurring is a schedule of q, see Schedule.
+type Recurring struct {
+	// ID identifies the schedule, for Unschedule.
+	ID uint64
+
+	// Spec is the cron spec of the schedule.
+	Spec string
+
+	// Body is the body of the messages that the schedule sends.
+	Body []byte
+
+	// Next is when the schedule fires next.
+	Next time.Time
+}
+
+// recurring is a schedule, as stored in the schedules bucket.
+type recurring struct {
+	Spec string `json:"spec"`
+	Body []byte `json:"body"`
+	// Next is when the schedule fires next, in nanoseconds since the
+	// epoch.
+	Next int64 `json:"next"`
+}
+
+// Schedule stores a schedule in q that sends a message with the given body
+// each time the cron spec fires, and returns its ID. The spec has five
+// fields, for the minute, hour, day of the month, month and day of the week,
+// or is one of the descriptors like @hourly and @every 1h30m. Times are in
+// the local time zone, unless the spec starts with CRON_TZ=, followed by the
+// name of a time zone.
+//
+// Schedules are kept in the database, and fire as long as a Q has it open.
+// The times a schedule missed while none did are dealt with as set by
+// WithScheduleCatchUp. The messages are sent like any other, but are not
+// subject to WithMaxDepth.
+func (q *Q) Schedule(spec string, body []byte) (uint64, error) {
+	if q.isClosed() {
+		return 0, ErrQClosed
+	}
+	if q.readOnly {
+		return 0, ErrReadOnly
+	}
+	sched, err := cron.ParseStandard(spec)
+	if err != nil {
+		return 0, fmt.Errorf("lasr: invalid schedule: %s", err)
+	}
+	var id uint64
+	err = q.commit(func(tx *bolt.Tx) error {
+		bucket, err := q.bucket(tx, schedulesBucket)
+		if err != nil {
+			return err
+		}
+		if id, err = bucket.NextSequence(); err != nil {
+			return err
+		}
+		key := scheduleKey(id)
+		sealed, err := q.seal(scheduleAD(key), body)
+		if err != nil {
+			return err
+		}
+		v, err := json.Marshal(recurring{
+			Spec: spec,
+			Body: sealed,
+			Next: sched.Next(time.Now()).UnixNano(),
+		})
+		if err != nil {
+			return err
+		}
+		return bucket.Put(key, v)
+	})
+	if err != nil {
+		return 0, fmt.Errorf("lasr: couldn't store schedule: %s", err)
+	}
+	q.startSchedules()
+	return id, nil
+}
+
+// Unschedule deletes the schedule id from q, or returns ErrNotFound if there
+// is no such schedule.
+func (q *Q) Unschedule(id uint64) error {
+	if q.isClosed() {
+		return ErrQClosed
+	}
+	if q.readOnly {
+		return ErrReadOnly
+	}
+	err := q.commit(func(tx *bolt.Tx) error {
+		bucket := q.readBucket(tx, schedulesBucket)
+		key := scheduleKey(id)
+		if bucket == nil || bucket.Get(key) == nil {
+			return ErrNotFound
+		}
+		return bucket.Delete(key)
+	})
+	if err == nil {
+		q.wakeSchedules()
+	}
+	return err
+}
+
+// Schedules returns the schedules of q, in the order they were stored.
+func (q *Q) Schedules() ([]Recurring, error) {
+	if q.isClosed() {
+		return nil, ErrQClosed
+	}
+	var schedules []Recurring
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	err := q.db.View(func(tx *bolt.Tx) error {
+		bucket := q.readBucket(tx, schedulesBucket)
+		if bucket == nil {
+			return nil
+		}
+		return bucket.ForEach(func(k, v []byte) error {
+			rec, body, err := q.readSchedule(k, v)
+			if err != nil {
+				return err
+			}
+			schedules = append(schedules, Recurring{
+				ID:   binary.BigEndian.Uint64(k),
+				Spec: rec.Spec,
+				Body: body,
+				Next: time.Unix(0, rec.Next),
+			})
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("lasr: couldn't read schedules: %s", err)
+	}
+	return schedules, nil
+}
+
+func scheduleKey(id uint64) []byte {
+	var key [8]byte
+	binary.BigEndian.PutUint64(key[:], id)
+	return key[:]
+}
+
+// scheduleAD is what the body of the schedule key is authenticated with,
+// which differs from the IDs of messages, so that it can't be swapped for the
+// body of one.
+func scheduleAD(key []byte) []byte {
+	return append([]byte("schedule/"), key...)
+}
+
+// readSchedule decodes the schedule stored as v under key, and opens its body.
+func (q *Q) readSchedule(key, v []byte) (*recurring, []byte, error) {
+	var rec recurring
+	if err := json.Unmarshal(v, &rec); err != nil {
+		return nil, nil, fmt.Errorf("error reading schedule %x: %s", key, err)
+	}
+	body, err := q.unseal(scheduleAD(key), rec.Body)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &rec, body, nil
+}
+
+// startSchedules starts firing the schedules of q, if it isn't already, or
+// makes it look at them again.
+func (q *Q) startSchedules() {
+	q.closeMu.Lock()
+	defer q.closeMu.Unlock()
+	if q.isClosed() || q.readOnly {
+		return
+	}
+	if q.schedulesDone != nil {
+		q.wakeSchedules()
+		return
+	}
+	q.schedulesWake = make(chan struct{}, 1)
+	q.schedulesDone = make(chan struct{})
+	go q.scheduleLoop()
+}
+
+// startStoredSchedules starts firing the schedules of q, if it has any.
+func (q *Q) startStoredSchedules() error {
+	if q.readOnly {
+		return nil
+	}
+	var stored bool
+	q.mu.RLock()
+	err := q.db.View(func(tx *bolt.Tx) error {
+		bucket := q.readBucket(tx, schedulesBucket)
+		stored = bucket != nil && bucket.Stats().KeyN > 0
+		return nil
+	})
+	q.mu.RUnlock()
+	if err != nil || !stored {
+		return err
+	}
+	q.startSchedules()
+	return nil
+}
+
+// wakeSchedules makes the schedule loop of q, if it runs, look at the
+// schedules again.
+func (q *Q) wakeSchedules() {
+	select {
+	case q.schedulesWake <- struct{}{}:
+	default:
+	}
+}
+
+func (q *Q) scheduleLoop() {
+	defer close(q.schedulesDone)
+	for {
+		next, err := q.fireSchedules(time.Now())
+		if err != nil {
+			q.logger().Warn("lasr: couldn't fire schedules", "error", err)
+			next = time.Now().Add(scheduleGrace)
+		}
+		var timer *time.Timer
+		var fire <-chan time.Time
+		if !next.IsZero() {
+			timer = time.NewTimer(time.Until(next))
+			fire = timer.C
+		}
+		select {
+		case <-fire:
+		case <-q.schedulesWake:
+		case <-q.closed:
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+		if q.isClosed() {
+			return
+		}
+	}
+}
+
+// stopSchedules waits for the schedule loop of q to stop, once q is closed.
+func (q *Q) stopSchedules() {
+	q.closeMu.Lock()
+	done := q.schedulesDone
+	q.closeMu.Unlock()
+	if done != nil {
+		<-done
+	}
+}
+
+// fireSchedules sends the messages of the schedules that are due by now, and
+// returns when the next schedule is due, or the zero time if q has none.
+func (q *Q) fireSchedules(now time.Time) (time.Time, error) {
+	var next int64
+	var sent []ID
+	err := q.commit(func(tx *bolt.Tx) error {
+		next, sent = 0, nil
+		bucket := q.readBucket(tx, schedulesBucket)
+		if bucket == nil {
+			return nil
+		}
+		var keys, values [][]byte
+		err := bucket.ForEach(func(k, v []byte) error {
+			rec, body, err := q.readSchedule(k, v)
+			if err != nil {
+				return err
+			}
+			if rec.Next <= now.UnixNano() {
+				sched, err := cron.ParseStandard(rec.Spec)
+				if err != nil {
+					return fmt.Errorf("schedule %x: %s", k, err)
+				}
+				var fires int
+				fires, rec.Next = q.catchUpFires(sched, time.Unix(0, rec.Next), now)
+				for i := 0; i < fires; i++ {
+					id, err := q.nextSequence(tx)
+					if err != nil {
+						return err
+					}
+					if err := q.send(id, body, &metadata{}, tx); err != nil {
+						return err
+					}
+					sent = append(sent, id)
+				}
+				v, err := json.Marshal(rec)
+				if err != nil {
+					return err
+				}
+				keys = append(keys, cloneBytes(k))
+				values = append(values, v)
+			}
+			if next == 0 || rec.Next < next {
+				next = rec.Next
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for i, k := range keys {
+			if err := bucket.Put(k, values[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return time.Time{}, err
+	}
+	if len(sent) > 0 && !q.isClosed() {
+		q.waker.Wake()
+		q.wakeSubscriptions()
+	}
+	for _, id := range sent {
+		q.onSend(id)
+	}
+	if next == 0 {
+		return time.Time{}, nil
+	}
+	return time.Unix(0, next), nil
+}
+
+// catchUpFires returns how many messages a schedule that was due at due sends
+// by now, and when it is due next.
+func (q *Q) catchUpFires(sched cron.Schedule, due, now time.Time) (int, int64) {
+	switch q.catchUp {
+	case CatchUpAll:
+		var fires int
+		for ; !due.After(now) && fires < maxCatchUp; due = sched.Next(due) {
+			fires++
+		}
+		if !due.After(now) {
+			due = sched.Next(now)
+		}
+		return fires, due.UnixNano()
+	case CatchUpSkip:
+		if now.Sub(due) > scheduleGrace {
+			return 0, sched.Next(now).UnixNano()
+		}
+	}
+	return 1, sched.Next(now).UnixNano()
+}
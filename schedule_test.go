@@ -0,0 +1,102 @@
+package lasr
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSchedule(t *testing.T) {
+	q, cleanup := newQ(t, WithEncryption(testKey))
+	defer cleanup()
+	db := q.db
+
+	id, err := q.Schedule("@every 1s", []byte("tick"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := receiveBodies(t, q, 1); got[0] != "tick" {
+		t.Errorf("got %q, want %q", got[0], "tick")
+	}
+	if err := q.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	q, err = NewQ(db, "testing", WithEncryption(testKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer q.Close()
+	schedules, err := q.Schedules()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(schedules) != 1 {
+		t.Fatalf("got %d schedules, want 1", len(schedules))
+	}
+	if s := schedules[0]; s.ID != id || s.Spec != "@every 1s" || string(s.Body) != "tick" {
+		t.Errorf("bad schedule: %+v", s)
+	}
+	// The stored schedule fires without being scheduled again.
+	if got := receiveBodies(t, q, 1); got[0] != "tick" {
+		t.Errorf("got %q, want %q", got[0], "tick")
+	}
+
+	if err := q.Unschedule(id); err != nil {
+		t.Fatal(err)
+	}
+	if err := q.Unschedule(id); err != ErrNotFound {
+		t.Errorf("got %v, want ErrNotFound", err)
+	}
+	if schedules, err := q.Schedules(); err != nil || len(schedules) != 0 {
+		t.Errorf("got %d schedules, %v, want none", len(schedules), err)
+	}
+}
+
+func TestScheduleCatchUp(t *testing.T) {
+	tests := []struct {
+		policy CatchUpPolicy
+		want   int
+	}{
+		{CatchUpOnce, 1},
+		{CatchUpAll, 10},
+		{CatchUpSkip, 0},
+	}
+	for _, test := range tests {
+		q, cleanup := newQ(t, WithScheduleCatchUp(test.policy))
+		if _, err := q.Schedule("* * * * *", []byte("tick")); err != nil {
+			t.Fatal(err)
+		}
+		schedules, err := q.Schedules()
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Ten times were missed, the last one thirty seconds ago.
+		now := schedules[0].Next.Add(9*time.Minute + 30*time.Second)
+		next, err := q.fireSchedules(now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := schedules[0].Next.Add(10 * time.Minute); !next.Equal(want) {
+			t.Errorf("policy %d: next fire at %s, want %s", test.policy, next, want)
+		}
+		stats, err := q.Stats()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stats.Ready != test.want {
+			t.Errorf("policy %d: got %d messages, want %d", test.policy, stats.Ready, test.want)
+		}
+		cleanup()
+	}
+}
+
+func TestScheduleInvalid(t *testing.T) {
+	q, cleanup := newQ(t)
+	defer cleanup()
+	if _, err := q.Schedule("not a spec", []byte("tick")); err == nil {
+		t.Error("expected an error for an invalid spec")
+	}
+	if _, err := NewQ(q.db, "other", WithScheduleCatchUp(CatchUpPolicy(-1))); err == nil {
+		t.Error("expected an error for an invalid catch-up policy")
+	}
+}
@@ -0,0 +1,92 @@
+package lasr
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// schemaKey holds the version of the layout of a queue, in its root bucket.
+var schemaKey = []byte("schema")
+
+// A migration upgrades the layout of q within tx, from the version it is
+// indexed by in migrations to the next one.
+type migration func(q *Q, tx *bolt.Tx) error
+
+// migrations upgrade queues from each version of the layout to the next. The
+// current version is the number of migrations, so a change to the layout
+// comes with a migration appended here, which must be safe for queues of
+// every configuration, and never with a change to an existing one.
+var migrations = []migration{
+	// Queues from before the version was recorded already have the layout
+	// of version 1.
+	func(q *Q, tx *bolt.Tx) error { return nil },
+}
+
+func schemaVersion() uint64 {
+	return uint64(len(migrations))
+}
+
+// migrate upgrades the layout of q to the current version, and records it.
+// New queues start at the current version. A queue with a newer layout than
+// this version of lasr knows is refused, since it can't be read safely.
+//
+// A read-only Q can't be upgraded, so it is read as is, provided its layout
+// isn't newer.
+func (q *Q) migrate() error {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	if q.readOnly {
+		return q.db.View(func(tx *bolt.Tx) error {
+			_, err := q.storedSchema(tx)
+			return err
+		})
+	}
+	return q.db.Update(func(tx *bolt.Tx) error {
+		version, err := q.storedSchema(tx)
+		if err != nil {
+			return err
+		}
+		if version == schemaVersion() {
+			return nil
+		}
+		root, err := tx.CreateBucketIfNotExists(q.name)
+		if err != nil {
+			return err
+		}
+		if root.Bucket(q.keys.ready) != nil && version < schemaVersion() {
+			q.logger().Info("lasr: upgrading queue", "from", version, "to", schemaVersion())
+			for ; version < schemaVersion(); version++ {
+				if err := migrations[version](q, tx); err != nil {
+					return fmt.Errorf("lasr: couldn't upgrade queue to version %d: %s", version+1, err)
+				}
+			}
+		}
+		var v [8]byte
+		binary.BigEndian.PutUint64(v[:], schemaVersion())
+		return root.Put(schemaKey, v[:])
+	})
+}
+
+// storedSchema returns the layout version that is recorded for q, which is 0
+// for queues that were created before versions were recorded, and for new
+// ones.
+func (q *Q) storedSchema(tx *bolt.Tx) (uint64, error) {
+	root := tx.Bucket(q.name)
+	if root == nil {
+		return 0, nil
+	}
+	v := root.Get(schemaKey)
+	if v == nil {
+		return 0, nil
+	}
+	if len(v) != 8 {
+		return 0, fmt.Errorf("lasr: bad schema version: %x", v)
+	}
+	version := binary.BigEndian.Uint64(v)
+	if version > schemaVersion() {
+		return 0, fmt.Errorf("lasr: queue has schema version %d, but this version of lasr only knows up to %d", version, schemaVersion())
+	}
+	return version, nil
+}
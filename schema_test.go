@@ -0,0 +1,90 @@
+package lasr
+
+import (
+	"encoding/binary"
+	"path/filepath"
+	"testing"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+func setSchema(t *testing.T, db *bolt.DB, name string, version uint64) {
+	t.Helper()
+	err := db.Update(func(tx *bolt.Tx) error {
+		root := tx.Bucket([]byte(name))
+		if version == 0 {
+			return root.Delete(schemaKey)
+		}
+		var v [8]byte
+		binary.BigEndian.PutUint64(v[:], version)
+		return root.Put(schemaKey, v[:])
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSchemaMigration(t *testing.T) {
+	db, err := bolt.Open(filepath.Join(t.TempDir(), "lasr.db"), 0600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	q, err := NewQ(db, "testing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sendBodies(t, q, "a")
+	err = db.View(func(tx *bolt.Tx) error {
+		version, err := q.storedSchema(tx)
+		if version != schemaVersion() {
+			t.Errorf("new queue has version %d, want %d", version, schemaVersion())
+		}
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := q.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// A queue from before versions were recorded goes through every
+	// migration.
+	var ran []string
+	defer func(m []migration) { migrations = m }(migrations)
+	migrations = append(migrations[:len(migrations):len(migrations)], func(q *Q, tx *bolt.Tx) error {
+		ran = append(ran, q.Name())
+		return nil
+	})
+	setSchema(t, db, "testing", 0)
+	q, err = NewQ(db, "testing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := q.Close(); err != nil {
+		t.Fatal(err)
+	}
+	q, err = NewQ(db, "testing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ran) != 1 {
+		t.Errorf("migration ran %d times, want 1", len(ran))
+	}
+	if got := receiveN(t, q, 1)[0]; string(got.Body) != "a" {
+		t.Errorf("got %q, want %q", got.Body, "a")
+	} else if err := got.Ack(); err != nil {
+		t.Fatal(err)
+	}
+	if err := q.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// A queue that is newer than this version of lasr is refused.
+	setSchema(t, db, "testing", schemaVersion()+1)
+	if _, err := NewQ(db, "testing"); err == nil {
+		t.Fatal("expected an error")
+	}
+}
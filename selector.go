@@ -0,0 +1,252 @@
+package lasr
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// WithSelector indexes the messages of q by the value of their header, so
+// that ReceiveSelect can find the messages with a given value without looking
+// at any others. Messages that were sent before the selector was added are
+// indexed when the Q is created.
+//
+// Selectors are stored in the database, so that messages fanned out to a
+// subscription with selectors are indexed as well, and stay registered until
+// DropSelector is called.
+func WithSelector(header string) Option {
+	return func(q *Q) error {
+		if q.optsApplied {
+			return ErrOptionsApplied
+		}
+		if header == "" {
+			return errors.New("lasr: selector header must not be empty")
+		}
+		q.selectors = append(q.selectors, header)
+		return nil
+	}
+}
+
+// ReceiveSelect is like Receive, but only receives a message whose header
+// has the given value. header must be a selector of q, see WithSelector.
+// Messages that don't match are left in place for other consumers.
+//
+// Unlike ReceiveWhere, ReceiveSelect does not look at the messages that
+// don't match. It does look at the matching messages that are still
+// unacked or waiting to be retried. Like ReceiveWhere, it does not see
+// messages that have already been buffered for Receive.
+func (q *Q) ReceiveSelect(ctx context.Context, header string, value []byte) (*Message, error) {
+	var take func(tx *bolt.Tx, now time.Time) (*Message, error)
+	take = func(tx *bolt.Tx, now time.Time) (*Message, error) {
+		index := q.readBucket(tx, q.keys.selectors)
+		if index != nil {
+			index = index.Bucket([]byte(header))
+		}
+		if index == nil {
+			return nil, ErrNoSelector
+		}
+		index = index.Bucket(selectorValue(value))
+		if index == nil {
+			return nil, nil
+		}
+		ready, err := q.bucket(tx, q.keys.ready)
+		if err != nil {
+			return nil, err
+		}
+		var found, v []byte
+		var stale [][]byte
+		cur := orderedCursor{Cursor: index.Cursor(), reverse: q.lifo}
+		for k, _ := cur.First(); k != nil; k, _ = cur.Next() {
+			if v = ready.Get(k); v != nil {
+				busy, err := q.groupBusy(tx, k)
+				if err != nil {
+					return nil, err
+				}
+				if !busy {
+					found = cloneBytes(k)
+					break
+				}
+				continue
+			}
+			md, err := q.getMeta(tx, k)
+			if err != nil {
+				return nil, err
+			}
+			if md == nil {
+				// The message is gone, without having been unindexed.
+				stale = append(stale, cloneBytes(k))
+			}
+		}
+		for _, k := range stale {
+			if err := index.Delete(k); err != nil {
+				return nil, err
+			}
+		}
+		if found == nil {
+			return nil, nil
+		}
+		msg, err := q.deliver(tx, ready, found, v, now)
+		if err == errExpired {
+			// The message is no longer indexed, so look for the next.
+			return take(tx, now)
+		}
+		return msg, err
+	}
+	return q.receiveBy(ctx, take)
+}
+
+// DropSelector removes the selector for header from q, and its index.
+func (q *Q) DropSelector(header string) error {
+	if q.isClosed() {
+		return ErrQClosed
+	}
+	if q.readOnly {
+		return ErrReadOnly
+	}
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	return q.db.Update(func(tx *bolt.Tx) error {
+		index := q.readBucket(tx, q.keys.selectors)
+		if index == nil {
+			return ErrNoSelector
+		}
+		if err := index.DeleteBucket([]byte(header)); err != nil {
+			if err == bolt.ErrBucketNotFound {
+				return ErrNoSelector
+			}
+			return err
+		}
+		return nil
+	})
+}
+
+// selectorValue returns the index key for a header value. Bucket names can't
+// be empty, so values are prefixed.
+func selectorValue(value []byte) []byte {
+	return append([]byte{0}, value...)
+}
+
+// addSelectors adds the selectors of q that are not in the database yet, and
+// indexes the messages that are already in q.
+func (q *Q) addSelectors() error {
+	if len(q.selectors) == 0 {
+		return nil
+	}
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	return q.db.Update(func(tx *bolt.Tx) error {
+		index, err := q.bucket(tx, q.keys.selectors)
+		if err != nil {
+			return err
+		}
+		var added []string
+		for _, header := range q.selectors {
+			if index.Bucket([]byte(header)) != nil {
+				continue
+			}
+			if _, err := index.CreateBucket([]byte(header)); err != nil {
+				return err
+			}
+			added = append(added, header)
+		}
+		if len(added) == 0 {
+			return nil
+		}
+		var ids [][]byte
+		for _, key := range [][]byte{q.keys.ready, q.keys.unacked, q.keys.retrying} {
+			bucket := q.readBucket(tx, key)
+			if bucket == nil {
+				continue
+			}
+			if err := bucket.ForEach(func(k, _ []byte) error {
+				if bytes.Equal(key, q.keys.retrying) {
+					k = k[8:]
+				}
+				ids = append(ids, k)
+				return nil
+			}); err != nil {
+				return err
+			}
+		}
+		for _, id := range ids {
+			md, err := q.getMeta(tx, id)
+			if err != nil {
+				return err
+			}
+			if md == nil {
+				continue
+			}
+			for _, header := range added {
+				if err := indexHeader(index, header, id, md.Headers); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// index adds id to the indexes of the selectors of q that match headers.
+func (q *Q) index(tx *bolt.Tx, id []byte, headers map[string][]byte) error {
+	if len(headers) == 0 {
+		return nil
+	}
+	index := q.readBucket(tx, q.keys.selectors)
+	if index == nil {
+		return nil
+	}
+	for header := range headers {
+		if err := indexHeader(index, header, id, headers); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// indexHeader adds id to the index for header, if header is a selector and
+// is one of headers.
+func indexHeader(index *bolt.Bucket, header string, id []byte, headers map[string][]byte) error {
+	value, ok := headers[header]
+	if !ok {
+		return nil
+	}
+	selector := index.Bucket([]byte(header))
+	if selector == nil {
+		return nil
+	}
+	values, err := selector.CreateBucketIfNotExists(selectorValue(value))
+	if err != nil {
+		return err
+	}
+	return values.Put(id, nil)
+}
+
+// unindex removes id from the indexes of the selectors of q. It must be
+// called before the metadata of id is deleted.
+func (q *Q) unindex(tx *bolt.Tx, id []byte) error {
+	index := q.readBucket(tx, q.keys.selectors)
+	if index == nil {
+		return nil
+	}
+	md, err := q.getMeta(tx, id)
+	if err != nil || md == nil {
+		return err
+	}
+	for header, value := range md.Headers {
+		selector := index.Bucket([]byte(header))
+		if selector == nil {
+			continue
+		}
+		values := selector.Bucket(selectorValue(value))
+		if values == nil {
+			continue
+		}
+		if err := values.Delete(id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,171 @@
+package lasr
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+func sendKinds(t *testing.T, q *Q, kinds ...string) {
+	t.Helper()
+	for _, kind := range kinds {
+		if _, err := q.SendWithHeaders([]byte(kind), map[string][]byte{"kind": []byte(kind)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestReceiveSelect(t *testing.T) {
+	q, cleanup := newQ(t, WithSelector("kind"))
+	defer cleanup()
+
+	sendKinds(t, q, "a", "b", "a", "b")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	first, err := q.ReceiveSelect(ctx, "kind", []byte("b"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(first.Body), "b"; got != want {
+		t.Errorf("bad body: got %q, want %q", got, want)
+	}
+
+	// The first b is unacked, so the second one is next.
+	second, err := q.ReceiveSelect(ctx, "kind", []byte("b"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(second.ID), string(first.ID); got == want {
+		t.Errorf("got the same message twice: %x", got)
+	}
+	if err := first.Ack(); err != nil {
+		t.Fatal(err)
+	}
+	if err := second.Nack(true); err != nil {
+		t.Fatal(err)
+	}
+
+	// The nacked message can be selected again.
+	msg, err := q.ReceiveSelect(ctx, "kind", []byte("b"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(msg.ID), string(second.ID); got != want {
+		t.Errorf("bad id: got %x, want %x", got, want)
+	}
+	if err := msg.Ack(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Only the acked messages are unindexed.
+	if got, want := indexed(t, q, "kind", "b"), 0; got != want {
+		t.Errorf("bad index size: got %d, want %d", got, want)
+	}
+	if got, want := indexed(t, q, "kind", "a"), 2; got != want {
+		t.Errorf("bad index size: got %d, want %d", got, want)
+	}
+
+	short, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := q.ReceiveSelect(short, "kind", []byte("b")); err != context.DeadlineExceeded {
+		t.Errorf("bad error: got %v, want %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestReceiveSelectWaits(t *testing.T) {
+	q, cleanup := newQ(t, WithSelector("kind"))
+	defer cleanup()
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		if _, err := q.SendWithHeaders([]byte("b"), map[string][]byte{"kind": []byte("b")}); err != nil {
+			t.Error(err)
+		}
+	}()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	msg, err := q.ReceiveSelect(ctx, "kind", []byte("b"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(msg.Body), "b"; got != want {
+		t.Errorf("bad body: got %q, want %q", got, want)
+	}
+	if err := msg.Ack(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReceiveSelectUnknown(t *testing.T) {
+	q, cleanup := newQ(t, WithSelector("kind"))
+	defer cleanup()
+
+	if _, err := q.ReceiveSelect(context.Background(), "other", []byte("b")); err != ErrNoSelector {
+		t.Errorf("bad error: got %v, want %v", err, ErrNoSelector)
+	}
+	if err := q.DropSelector("kind"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := q.ReceiveSelect(context.Background(), "kind", []byte("b")); err != ErrNoSelector {
+		t.Errorf("bad error: got %v, want %v", err, ErrNoSelector)
+	}
+}
+
+func TestSelectorIndexesExisting(t *testing.T) {
+	q, cleanup := newQ(t)
+	defer cleanup()
+
+	sendKinds(t, q, "a", "b")
+	q.Close()
+
+	q, err := NewQ(q.db, "testing", WithSelector("kind"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer q.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	msg, err := q.ReceiveSelect(ctx, "kind", []byte("b"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(msg.Body), "b"; got != want {
+		t.Errorf("bad body: got %q, want %q", got, want)
+	}
+	if err := msg.Ack(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSelectorPurge(t *testing.T) {
+	q, cleanup := newQ(t, WithSelector("kind"))
+	defer cleanup()
+
+	sendKinds(t, q, "a", "a")
+	if _, err := q.Purge(Ready); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := indexed(t, q, "kind", "a"), 0; got != want {
+		t.Errorf("bad index size: got %d, want %d", got, want)
+	}
+}
+
+// indexed returns the number of messages in the index for header and value.
+func indexed(t *testing.T, q *Q, header, value string) int {
+	t.Helper()
+	var n int
+	err := q.db.View(func(tx *bolt.Tx) error {
+		index := q.readBucket(tx, q.keys.selectors).Bucket([]byte(header))
+		if values := index.Bucket(selectorValue([]byte(value))); values != nil {
+			n = values.Stats().KeyN
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
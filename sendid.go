@@ -0,0 +1,70 @@
+package lasr
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// SendWithID is like Send, but the message is given id instead of an ID from
+// the Sequencer of q. If a message with the same ID is in q already, in any
+// state, nothing is sent and ErrDuplicateID is returned, so producers that
+// keep their own sequence can retry sends after a crash without creating
+// duplicates. Once the message is acked, or otherwise leaves q, its ID can be
+// used again.
+//
+// With the default sequencer, a Uint64ID that is ahead of the sequence moves
+// the sequence along, so that Send doesn't hand it out again.
+//
+// SendWithID is not supported with WithWAL, whose records aren't checked for
+// duplicates until they are folded into q.
+func (q *Q) SendWithID(id ID, body []byte) error {
+	if q.isClosed() {
+		return ErrQClosed
+	}
+	if q.wal != nil {
+		return errors.New("lasr: SendWithID is not supported with WithWAL")
+	}
+	key, err := id.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	if len(key) == 0 {
+		return fmt.Errorf("lasr: id required")
+	}
+	err = q.admit(func(tx *bolt.Tx) error {
+		targets, err := q.subscribers(tx)
+		if err != nil {
+			return err
+		}
+		if len(targets) == 0 {
+			targets = []*Q{q}
+			if q.partitions > 0 {
+				targets = []*Q{q.partitionTarget(q.partitionOf(key, nil))}
+			}
+		}
+		for _, t := range targets {
+			if _, v := t.find(tx, key); v != nil {
+				return ErrDuplicateID
+			}
+		}
+		if q.seq == nil && len(key) == 8 {
+			seq := tx.Bucket(q.seqName)
+			if n := binary.BigEndian.Uint64(key); n > seq.Sequence() {
+				if err := seq.SetSequence(n); err != nil {
+					return err
+				}
+			}
+		}
+		return q.send(id, body, &metadata{}, tx)
+	})
+	if err != nil {
+		return err
+	}
+	q.waker.Wake()
+	q.wakeSubscriptions()
+	q.onSend(id)
+	return nil
+}
@@ -0,0 +1,86 @@
+package lasr
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestSendWithID(t *testing.T) {
+	q, cleanup := newQ(t, WithDeadLetters())
+	defer cleanup()
+
+	if err := q.SendWithID(Uint64ID(10), []byte("a")); err != nil {
+		t.Fatal(err)
+	}
+	if err := q.SendWithID(Uint64ID(10), []byte("a")); err != ErrDuplicateID {
+		t.Errorf("ready: got %v, want ErrDuplicateID", err)
+	}
+	msg := receiveN(t, q, 1)[0]
+	if got, want := msg.ID, []byte{0, 0, 0, 0, 0, 0, 0, 10}; !bytes.Equal(got, want) {
+		t.Errorf("got id %x, want %x", got, want)
+	}
+	if err := q.SendWithID(Uint64ID(10), []byte("a")); err != ErrDuplicateID {
+		t.Errorf("unacked: got %v, want ErrDuplicateID", err)
+	}
+	if err := msg.Nack(false); err != nil {
+		t.Fatal(err)
+	}
+	if err := q.SendWithID(Uint64ID(10), []byte("a")); err != ErrDuplicateID {
+		t.Errorf("dead-lettered: got %v, want ErrDuplicateID", err)
+	}
+
+	// The sequence moves past the ID.
+	id, err := q.Send([]byte("b"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := id, Uint64ID(11); got != want {
+		t.Errorf("got id %v, want %v", got, want)
+	}
+	if got := receiveBodies(t, q, 1); got[0] != "b" {
+		t.Errorf("got %q, want %q", got[0], "b")
+	}
+
+	// Acked IDs can be used again.
+	if err := q.SendWithID(Uint64ID(11), []byte("c")); err != nil {
+		t.Fatal(err)
+	}
+	if got := receiveBodies(t, q, 1); got[0] != "c" {
+		t.Errorf("got %q, want %q", got[0], "c")
+	}
+}
+
+func TestSendWithIDSubscribed(t *testing.T) {
+	q, cleanup := newQ(t)
+	defer cleanup()
+	sub, err := q.Subscribe("sub")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sub.Close()
+	if err := q.SendWithID(Uint64ID(5), []byte("a")); err != nil {
+		t.Fatal(err)
+	}
+	if err := q.SendWithID(Uint64ID(5), []byte("a")); err != ErrDuplicateID {
+		t.Errorf("got %v, want ErrDuplicateID", err)
+	}
+	if got := receiveBodies(t, sub.Q, 1); got[0] != "a" {
+		t.Errorf("got %q, want %q", got[0], "a")
+	}
+}
+
+func TestSendWithIDWAL(t *testing.T) {
+	q, cleanup := newQ(t, WithWAL(t.TempDir()))
+	defer cleanup()
+	if err := q.SendWithID(Uint64ID(3), []byte("mine")); err == nil {
+		t.Fatal("expected an error with WithWAL")
+	}
+	sendBodies(t, q, "a", "b", "c", "d", "e")
+	got := receiveBodies(t, q, 5)
+	for i, want := range []string{"a", "b", "c", "d", "e"} {
+		if got[i] != want {
+			t.Errorf("got %q, want %q", got, want)
+			break
+		}
+	}
+}
@@ -3,6 +3,7 @@ package lasr
 import (
 	"bytes"
 	"context"
+	"sync/atomic"
 	"time"
 
 	bolt "go.etcd.io/bbolt"
@@ -11,62 +12,166 @@ import (
 // Send sends a message to Q. When send completes with nil error, the message
 // sent to Q will be in the Ready state.
 func (q *Q) Send(message []byte) (ID, error) {
+	return q.SendWithHeaders(message, nil)
+}
+
+// SendWithHeaders is like Send, but also stores headers alongside the message.
+// The headers are available as Message.Headers when the message is received.
+func (q *Q) SendWithHeaders(message []byte, headers map[string][]byte) (ID, error) {
+	send := func(message []byte, headers map[string][]byte) (ID, error) {
+		r, err := q.sendWithHeaders(message, headers)
+		if err != nil {
+			return nil, err
+		}
+		return r.ID, nil
+	}
+	if len(q.interceptors) > 0 {
+		return q.interceptSend(message, headers, send)
+	}
+	return send(message, headers)
+}
+
+// sendWithHeaders sends a message, and returns its receipt, without its
+// Depth, see SendReceipt.
+func (q *Q) sendWithHeaders(message []byte, headers map[string][]byte) (*Receipt, error) {
 	if q.isClosed() {
 		return nil, ErrQClosed
 	}
-	var id ID
-	q.mu.RLock()
-	err := q.db.Update(func(tx *bolt.Tx) (err error) {
-		id, err = q.nextSequence(tx)
+	r := &Receipt{}
+	if q.wal != nil {
+		id, err := q.sendWAL(message, headers)
 		if err != nil {
+			return nil, err
+		}
+		r.ID, r.EnqueuedAt = id, time.Now()
+		return r, nil
+	}
+	err := q.admit(func(tx *bolt.Tx) (err error) {
+		if r.ID, err = q.nextSequence(tx); err != nil {
 			return err
 		}
-		return q.send(id, message, tx)
+		r.EnqueuedAt = time.Now()
+		md := &metadata{Headers: headers, Enqueued: r.EnqueuedAt.UnixNano()}
+		return q.send(r.ID, message, md, tx)
 	})
-	q.mu.RUnlock()
-	if err == nil {
-		q.waker.Wake()
+	if err != nil {
+		return nil, err
 	}
-	return id, err
+	q.waker.Wake()
+	q.wakeSubscriptions()
+	q.onSend(r.ID)
+	return r, nil
 }
 
-func (q *Q) send(id ID, body []byte, tx *bolt.Tx) error {
+func (q *Q) send(id ID, body []byte, md *metadata, tx *bolt.Tx) error {
+	if md.Enqueued == 0 {
+		md.Enqueued = time.Now().UnixNano()
+	}
 	key, err := id.MarshalBinary()
 	if err != nil {
 		return err
 	}
+	if subscribed, err := q.fanOut(id, body, md, tx); err != nil {
+		return err
+	} else if subscribed {
+		return q.countSent(tx, key)
+	}
+	if q.partitions > 0 {
+		i := q.partitionOf(key, md.Headers)
+		if err := q.partitionTarget(i).send(id, body, md, tx); err != nil {
+			return err
+		}
+		q.wakePartition(tx, i, time.Time{})
+		return q.countSent(tx, key)
+	}
+
+	value, err := q.sealBody(tx, key, body)
+	if err != nil {
+		return err
+	}
+	return q.putReady(tx, key, value, md)
+}
+
+// putReady stores the message key in the Ready state, as value, once its body
+// has been stored.
+func (q *Q) putReady(tx *bolt.Tx, key, value []byte, md *metadata) error {
+	if !md.empty() {
+		if err := q.putMeta(tx, key, md); err != nil {
+			return err
+		}
+		if err := q.index(tx, key, md.Headers); err != nil {
+			return err
+		}
+	}
 
 	bucket, err := q.bucket(tx, q.keys.ready)
 	if err != nil {
 		return err
 	}
 
-	return bucket.Put(key, body)
+	if err := bucket.Put(key, value); err != nil {
+		return err
+	}
+	q.touch(tx, key)
+
+	if err := q.adjustDepth(tx, 1); err != nil {
+		return err
+	}
+
+	return q.countSent(tx, key)
 }
 
 // Receive receives a message from the queue. If no messages are available by
 // the time the context is done, then the function will return a nil Message
 // and the result of ctx.Err().
+//
+// Goroutines that block in Receive, and in Consumer.Receive, are handed
+// messages one at a time, in the order they started waiting. ReceiveWhere,
+// ReceiveSelect, ReceiveRange and TryReceive don't wait their turn, and take
+// the messages they find as soon as they find them.
 func (q *Q) Receive(ctx context.Context) (*Message, error) {
+	if len(q.interceptors) > 0 {
+		return q.interceptReceive(ctx, func(ctx context.Context) (*Message, error) {
+			return q.receive(ctx, nil)
+		})
+	}
+	return q.receive(ctx, nil)
+}
+
+// receive receives a message on behalf of c, if it isn't nil.
+func (q *Q) receive(ctx context.Context, c *Consumer) (*Message, error) {
 	if q.isClosed() {
 		return nil, ErrQClosed
 	}
-	q.messages.Lock()
+	if q.readOnly {
+		return nil, ErrReadOnly
+	}
+	if err := q.messages.LockWait(ctx, q.closed); err != nil {
+		return nil, err
+	}
 	defer q.messages.Unlock()
 START:
+	if err := q.waitResumed(ctx); err != nil {
+		return nil, err
+	}
 	if q.messages.Len() > 0 {
-		msg := q.messages.Pop()
-		err := msg.err
-		if err != nil {
-			msg = nil
-		} else {
-			q.inFlight.Add(1)
+		if err := q.pace(ctx); err != nil {
+			return nil, err
 		}
-		return msg, err
+		return q.popMessage(c)
 	}
 	select {
 	case <-q.waker.C:
-		q.processReceives()
+		if err := q.processReceives(); err != nil {
+			// The messages are still in the Ready state, so make sure
+			// the next Receive tries again.
+			if !q.isClosed() {
+				q.waker.Wake()
+			}
+			if q.messages.Len() == 0 {
+				return nil, err
+			}
+		}
 		goto START
 	case <-ctx.Done():
 		return nil, ctx.Err()
@@ -75,20 +180,50 @@ START:
 	}
 }
 
-func (q *Q) processReceives() {
-	q.messages.SetError(q.db.Update(func(tx *bolt.Tx) error {
+// popMessage hands out the next buffered message, to c if it isn't nil. The
+// caller must hold the messages lock, and the buffer must not be empty.
+func (q *Q) popMessage(c *Consumer) (*Message, error) {
+	msg := q.messages.Pop()
+	if msg.err != nil {
+		return nil, msg.err
+	}
+	msg.consumer = c
+	if q.atMostOnce {
+		if err := q.consumeBuffered(msg); err != nil {
+			return nil, err
+		}
+		return msg, nil
+	}
+	q.inFlight.Add(1)
+	atomic.AddInt32(&q.outstanding, 1)
+	q.lease(msg)
+	return msg, nil
+}
+
+func (q *Q) processReceives() error {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	err := q.db.Update(func(tx *bolt.Tx) error {
+		if _, err := q.releaseRetries(tx, time.Now()); err != nil {
+			return err
+		}
 		// Prioritize delayed messages first. Not all instances of Q will
 		// have delayed messages.
 		if len(q.keys.delayed) > 0 {
 			if err := q.getMessages(tx, q.keys.delayed); err != nil {
 				return err
 			}
-			if q.messages.Len() == q.messages.Cap() {
+			if q.messages.Len() >= q.messages.Limit() {
 				return nil
 			}
 		}
 		return q.getMessages(tx, q.keys.ready)
-	}))
+	})
+	q.messages.SetError(err)
+	if err == nil {
+		q.adaptBuffer()
+	}
+	return err
 }
 
 func (q *Q) getMessages(tx *bolt.Tx, key []byte) error {
@@ -96,7 +231,7 @@ func (q *Q) getMessages(tx *bolt.Tx, key []byte) error {
 	if err != nil {
 		return err
 	}
-	cur := bucket.Cursor()
+	cur := q.cursor(bucket, key)
 	i := q.messages.Len()
 	var currentTime []byte
 	if bytes.Equal(key, q.keys.delayed) {
@@ -107,36 +242,94 @@ func (q *Q) getMessages(tx *bolt.Tx, key []byte) error {
 			return err
 		}
 	}
-	for k, v := cur.First(); k != nil && i < q.messages.Cap(); k, v = cur.Next() {
+	for k, v := cur.First(); k != nil && i < q.messages.Limit(); k, v = cur.Next() {
 		if currentTime != nil && bytes.Compare(k, currentTime) > 0 {
 			return nil
 		}
-		id := cloneBytes(k)
-		body := cloneBytes(v)
-		unacked, err := q.bucket(tx, q.keys.unacked)
-		if err != nil {
+		if busy, err := q.groupBusy(tx, k); err != nil {
 			return err
+		} else if busy {
+			continue
 		}
-		if err := unacked.Put(k, v); err != nil {
-			return err
+		msg, err := q.deliver(tx, bucket, k, v, time.Now())
+		if err == errExpired {
+			continue
+		}
+		if err == ErrCorruptMessage && q.corruptDeadLetters && len(q.keys.returned) > 0 {
+			if err := q.deadLetterCorrupt(tx, bucket, k); err != nil {
+				return err
+			}
+			continue
 		}
-		if err := bucket.Delete(k); err != nil {
+		if err != nil {
 			return err
 		}
-		q.messages.Push(&Message{
-			Body: body,
-			ID:   id,
-			q:    q,
-		})
+		q.messages.Push(msg)
 		i++
 	}
-	if i >= q.messages.Cap() {
+	if i >= q.messages.Limit() {
 		// More work could be available
 		q.waker.Wake()
 	}
 	return nil
 }
 
+// readMessage creates a Message from the key and value of a stored message,
+// and returns it with its metadata, if any. The Message does not belong to q
+// until its q field is set.
+func (q *Q) readMessage(tx *bolt.Tx, k, v []byte) (*Message, *metadata, error) {
+	id := cloneBytes(k)
+	msg := &Message{ID: id}
+	if q.streamBodies && q.aead == nil && !q.atMostOnce && len(v) == 0 && q.isChunked(tx, id) {
+		msg.chunksOf = q
+	} else {
+		body, err := q.openBody(tx, k, v)
+		if err != nil {
+			return nil, nil, err
+		}
+		msg.Body = body
+	}
+	md, err := q.getMeta(tx, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	if md != nil {
+		msg.Headers = md.Headers
+		msg.Attempts = md.Attempts
+		if md.Deadline != 0 {
+			msg.Deadline = time.Unix(0, md.Deadline)
+		}
+		msg.DeadLetterReason = md.Reason
+		msg.DeadLetterError = md.Error
+		if md.DeadLettered != 0 {
+			msg.DeadLetteredAt = time.Unix(0, md.DeadLettered)
+		}
+		if md.Enqueued != 0 {
+			msg.EnqueuedAt = time.Unix(0, md.Enqueued)
+		}
+		if md.Delivered != 0 {
+			msg.LastDeliveredAt = time.Unix(0, md.Delivered)
+		}
+	}
+	return msg, md, nil
+}
+
+// recordDelivery counts another delivery of msg at now, in its metadata and
+// in msg itself.
+func (q *Q) recordDelivery(tx *bolt.Tx, msg *Message, md *metadata, now time.Time) error {
+	if md == nil {
+		md = &metadata{}
+	}
+	md.Attempts++
+	md.Delivered = now.UnixNano()
+	if err := q.putMeta(tx, msg.ID, md); err != nil {
+		return err
+	}
+	msg.Attempts = md.Attempts
+	msg.LastDeliveredAt = now
+	return nil
+}
+
 func cloneBytes(b []byte) []byte {
 	r := make([]byte, len(b))
 	copy(r, b)
@@ -5,6 +5,7 @@ import (
 	"context"
 	"sync"
 	"testing"
+	"time"
 
 	bolt "go.etcd.io/bbolt"
 )
@@ -255,3 +256,98 @@ func BenchmarkRoundTrip_1M(b *testing.B) {
 func BenchmarkRoundTrip_16M(b *testing.B) {
 	benchRoundtrip(b, 1<<24)
 }
+
+func TestReceiveError(t *testing.T) {
+	q, cleanup := newQ(t)
+	defer cleanup()
+
+	id, err := q.SendWithHeaders([]byte("foo"), map[string][]byte{"k": []byte("v")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := id.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Corrupt the metadata, so that the message can't be read.
+	err = q.db.Update(func(tx *bolt.Tx) error {
+		bucket, err := q.bucket(tx, q.keys.meta)
+		if err != nil {
+			return err
+		}
+		return bucket.Put(key, []byte("{"))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		_, err = q.Receive(ctx)
+		cancel()
+		if err == nil || err == context.DeadlineExceeded {
+			t.Fatalf("expected read error, got %v", err)
+		}
+	}
+}
+
+func TestReceiveFairness(t *testing.T) {
+	q, cleanup := newQ(t)
+	defer cleanup()
+
+	// The first receiver holds the lock while it waits for a message, and
+	// the others queue up behind it.
+	const n = 5
+	got := make([]chan string, n)
+	for i := range got {
+		got[i] = make(chan string, 1)
+		go func(i int) {
+			msg, err := q.Receive(context.Background())
+			if err != nil {
+				t.Error(err)
+				got[i] <- ""
+				return
+			}
+			got[i] <- string(msg.Body)
+			if err := msg.Ack(); err != nil {
+				t.Error(err)
+			}
+		}(i)
+		waitFor(t, q.messages, i)
+	}
+	sendBodies(t, q, "0", "1", "2", "3", "4")
+	for i := range got {
+		if body := <-got[i]; body != string(rune('0'+i)) {
+			t.Errorf("receiver %d got %q", i, body)
+		}
+	}
+}
+
+func TestReceiveTimeoutWhileWaiting(t *testing.T) {
+	q, cleanup := newQ(t)
+	defer cleanup()
+
+	done := make(chan error, 1)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		msg, err := q.Receive(ctx)
+		if err == nil {
+			err = msg.Ack()
+		}
+		done <- err
+	}()
+	time.Sleep(10 * time.Millisecond)
+	// A receiver that queues up behind a blocked one still gives up in time.
+	start := time.Now()
+	if _, err := q.ReceiveTimeout(20 * time.Millisecond); err != ErrNoMessages {
+		t.Errorf("got %v, want ErrNoMessages", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("gave up after %s", elapsed)
+	}
+	sendBodies(t, q, "message")
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+}
@@ -1,6 +1,10 @@
 package lasr
 
-import bolt "go.etcd.io/bbolt"
+import (
+	"fmt"
+
+	bolt "go.etcd.io/bbolt"
+)
 
 // Sequencer returns an ID with each call to NextSequence and any error
 // that occurred.
@@ -13,20 +17,74 @@ import bolt "go.etcd.io/bbolt"
 //
 // * NextSequence will return IDs whose big-endian binary representation is incrementing.
 //
-// Q is not guaranteed to use all of the IDs generated by its Sequencer.
+// Q is not guaranteed to use all of the IDs generated by its Sequencer, unless
+// it is a TxSequencer.
+//
+// A Sequencer that keeps its position in memory can break these invariants
+// when the process restarts. It can implement PersistentSequencer to have
+// the Q store its position instead.
 type Sequencer interface {
 	NextSequence() (ID, error)
 }
 
+// PersistentSequencer is a Sequencer whose state is stored by the Q that uses
+// it, in the same transaction as the messages it generated IDs for.
+type PersistentSequencer interface {
+	Sequencer
+
+	// Load restores the state that was last saved, when the Q is created.
+	// state is nil if nothing was saved yet.
+	Load(state []byte) error
+
+	// Save returns the state of the sequencer, after NextSequence returned
+	// an ID that is about to be stored.
+	Save() ([]byte, error)
+}
+
+// TxSequencer is a Sequencer that generates IDs within the transaction of
+// the send they are for, so that they are only used if the send commits. A
+// TxSequencer that keeps its state in tx, and nowhere else, never skips an ID:
+// when a send fails, or a transaction it shares with other sends is retried,
+// whatever NextSequenceTx wrote is rolled back along with it. The exception is
+// SendFrom with WithChunking, which takes its ID before reading the body.
+//
+// Q only calls NextSequenceTx, and a TxSequencer can't be used with WithWAL.
+type TxSequencer interface {
+	Sequencer
+
+	// NextSequenceTx returns the next ID, within tx, which is writable.
+	NextSequenceTx(tx *bolt.Tx) (ID, error)
+}
+
+var (
+	seqStateBucket = []byte("sequencer")
+	seqStateKey    = []byte("state")
+)
+
 func (q *Q) nextSequence(tx *bolt.Tx) (ID, error) {
+	if ts, ok := q.seq.(TxSequencer); ok {
+		return ts.NextSequenceTx(tx)
+	}
 	if q.seq != nil {
-		return q.seq.NextSequence()
+		id, err := q.seq.NextSequence()
+		if err != nil {
+			return nil, err
+		}
+		if ps, ok := q.seq.(PersistentSequencer); ok {
+			if err := q.saveSequencer(tx, ps); err != nil {
+				return nil, err
+			}
+		}
+		return id, nil
 	}
 	return q.nextUint64ID(tx)
 }
 
 func (q *Q) nextUint64ID(tx *bolt.Tx) (Uint64ID, error) {
-	bucket := tx.Bucket(q.name)
+	if q.wal != nil && q.wal.done != nil {
+		return q.wal.nextID(tx, q.seqName)
+	}
+	bucket := tx.Bucket(q.seqName)
 	seq, err := bucket.NextSequence()
 
 	if err != nil {
@@ -35,3 +93,43 @@ func (q *Q) nextUint64ID(tx *bolt.Tx) (Uint64ID, error) {
 
 	return Uint64ID(seq), nil
 }
+
+func (q *Q) saveSequencer(tx *bolt.Tx, ps PersistentSequencer) error {
+	state, err := ps.Save()
+	if err != nil {
+		return fmt.Errorf("lasr: couldn't save sequencer: %s", err)
+	}
+	bucket, err := tx.Bucket(q.seqName).CreateBucketIfNotExists(seqStateBucket)
+	if err != nil {
+		return err
+	}
+	return bucket.Put(seqStateKey, state)
+}
+
+// loadSequencer restores the state of the sequencer of q, if it is a
+// PersistentSequencer. Queues that share the sequencer of another queue,
+// like subscriptions, leave it to that queue.
+func (q *Q) loadSequencer() error {
+	ps, ok := q.seq.(PersistentSequencer)
+	if !ok || q.readOnly || string(q.seqName) != string(q.name) {
+		return nil
+	}
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	return q.db.Update(func(tx *bolt.Tx) error {
+		root, err := tx.CreateBucketIfNotExists(q.seqName)
+		if err != nil {
+			return err
+		}
+		var state []byte
+		if bucket := root.Bucket(seqStateBucket); bucket != nil {
+			if v := bucket.Get(seqStateKey); v != nil {
+				state = cloneBytes(v)
+			}
+		}
+		if err := ps.Load(state); err != nil {
+			return fmt.Errorf("lasr: couldn't load sequencer: %s", err)
+		}
+		return nil
+	})
+}
@@ -3,7 +3,11 @@ package lasr
 import (
 	"bytes"
 	"context"
+	"errors"
+	"sync"
 	"testing"
+
+	bolt "go.etcd.io/bbolt"
 )
 
 type mockSeq struct {
@@ -50,3 +54,120 @@ func TestSequencer(t *testing.T) {
 		t.Errorf("bad ID: got %v, want %v", got, want)
 	}
 }
+
+type persistentSeq struct {
+	mockSeq
+	loaded []byte
+}
+
+func (p *persistentSeq) Load(state []byte) error {
+	p.loaded = state
+	if state != nil {
+		var id Uint64ID
+		if err := id.UnmarshalBinary(state); err != nil {
+			return err
+		}
+		p.id = uint64(id)
+	}
+	return nil
+}
+
+func (p *persistentSeq) Save() ([]byte, error) {
+	return Uint64ID(p.id).MarshalBinary()
+}
+
+func TestPersistentSequencer(t *testing.T) {
+	q, cleanup := newQ(t)
+	defer cleanup()
+
+	seq := &persistentSeq{mockSeq: mockSeq{id: 10}}
+	q, err := NewQ(q.db, "testing", WithSequencer(seq))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if seq.loaded != nil {
+		t.Errorf("expected no state, got %x", seq.loaded)
+	}
+	for i := 0; i < 3; i++ {
+		if _, err := q.Send([]byte("foo")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	q.Close()
+
+	// A new sequencer carries on where the last one stopped.
+	seq = &persistentSeq{}
+	q, err = NewQ(q.db, "testing", WithSequencer(seq))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer q.Close()
+	id, err := q.Send([]byte("foo"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := id, Uint64ID(13); got != want {
+		t.Errorf("bad ID: got %v, want %v", got, want)
+	}
+}
+
+// txSeq counts IDs in a bucket of their own, within the transaction of the
+// send.
+type txSeq struct{}
+
+func (txSeq) NextSequence() (ID, error) {
+	return nil, errors.New("not in a transaction")
+}
+
+func (txSeq) NextSequenceTx(tx *bolt.Tx) (ID, error) {
+	bucket, err := tx.CreateBucketIfNotExists([]byte("txseq"))
+	if err != nil {
+		return nil, err
+	}
+	n, err := bucket.NextSequence()
+	return Uint64ID(n), err
+}
+
+func TestTxSequencer(t *testing.T) {
+	q, cleanup := newQ(t, WithSequencer(txSeq{}))
+	defer cleanup()
+
+	// A send that fails after taking its ID gives it back.
+	err := q.admit(func(tx *bolt.Tx) error {
+		if _, err := q.nextSequence(tx); err != nil {
+			return err
+		}
+		return errors.New("failed")
+	})
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+
+	const n = 50
+	var wg sync.WaitGroup
+	ids := make(chan ID, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			id, err := q.Send([]byte("foo"))
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			ids <- id
+		}()
+	}
+	wg.Wait()
+	close(ids)
+	seen := make(map[ID]bool)
+	for id := range ids {
+		seen[id] = true
+	}
+	for i := 1; i <= n; i++ {
+		if !seen[Uint64ID(i)] {
+			t.Errorf("ID %d was skipped", i)
+		}
+	}
+	receiveBodies(t, q, n)
+}
@@ -0,0 +1,34 @@
+package lasr
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+// Shutdown closes q gracefully. It stops handing out new messages right
+// away, like Close, and then waits for the messages that have already been
+// received to be acked or nacked before closing q.
+//
+// If ctx is done before all in-flight messages have been acked or nacked,
+// Shutdown stops waiting, returns the remaining messages to the Ready state,
+// closes q and returns ctx.Err(). Acking or nacking those messages afterwards
+// returns ErrQClosed.
+func (q *Q) Shutdown(ctx context.Context) error {
+	return q.shutdown(func() error {
+		drained := make(chan struct{})
+		go func() {
+			q.inFlight.Wait()
+			close(drained)
+		}()
+		select {
+		case <-drained:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	})
+}
+
+func (q *Q) isAbandoned() bool {
+	return atomic.LoadInt32(&q.abandoned) == 1
+}
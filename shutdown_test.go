@@ -0,0 +1,113 @@
+package lasr
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestShutdown(t *testing.T) {
+	q, cleanup := newQ(t)
+	defer cleanup()
+
+	if _, err := q.Send([]byte("foo")); err != nil {
+		t.Fatal(err)
+	}
+	m, err := q.Receive(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	errs := make(chan error, 1)
+	go func() {
+		errs <- q.Shutdown(context.Background())
+	}()
+
+	time.Sleep(10 * time.Millisecond)
+	if _, err := q.Receive(context.Background()); err != ErrQClosed {
+		t.Errorf("bad error: got %v, want %v", err, ErrQClosed)
+	}
+	select {
+	case err := <-errs:
+		t.Fatalf("shutdown did not wait for in-flight message: %v", err)
+	default:
+	}
+
+	if err := m.Ack(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err := <-errs:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("shutdown did not return after ack")
+	}
+}
+
+func TestShutdownTimeout(t *testing.T) {
+	q, cleanup := newQ(t)
+	defer cleanup()
+
+	if _, err := q.Send([]byte("foo")); err != nil {
+		t.Fatal(err)
+	}
+	m, err := q.Receive(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := q.Shutdown(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("bad error: got %v, want %v", err, context.DeadlineExceeded)
+	}
+	if err := m.Ack(); err != ErrQClosed {
+		t.Fatalf("bad error: got %v, want %v", err, ErrQClosed)
+	}
+
+	q, err = NewQ(q.db, "testing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer q.Close()
+	stats, err := q.Stats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := stats.Ready, 1; got != want {
+		t.Errorf("bad ready count: got %d, want %d", got, want)
+	}
+}
+
+func TestShutdownBlockedReceive(t *testing.T) {
+	q, cleanup := newQ(t)
+	defer cleanup()
+
+	received := make(chan error, 1)
+	go func() {
+		_, err := q.Receive(context.Background())
+		received <- err
+	}()
+	// Let the consumer block on the empty queue.
+	time.Sleep(10 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	errs := make(chan error, 1)
+	go func() {
+		errs <- q.Shutdown(ctx)
+	}()
+	select {
+	case err := <-errs:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("shutdown blocked by a consumer waiting in Receive")
+	}
+	if err := <-received; err != ErrQClosed {
+		t.Errorf("bad error: got %v, want %v", err, ErrQClosed)
+	}
+}
@@ -0,0 +1,120 @@
+package lasr
+
+import bolt "go.etcd.io/bbolt"
+
+var (
+	sentCounter         = []byte("sent")
+	ackedCounter        = []byte("acked")
+	nackedCounter       = []byte("nacked")
+	deadLetteredCounter = []byte("deadlettered")
+)
+
+// Stats is a snapshot of the state of a Q.
+type Stats struct {
+	// Ready is the number of messages that are ready to be received.
+	Ready int
+
+	// Unacked is the number of messages that have been received, or
+	// buffered for receipt, and not yet acked or nacked.
+	Unacked int
+
+	// Delayed is the number of messages sent with Delay that have not yet
+	// entered the Ready state.
+	Delayed int
+
+	// Waiting is the number of messages sent with Wait that are still
+	// waiting on other messages.
+	Waiting int
+
+	// Retrying is the number of nacked messages that are waiting out the
+	// delay set by WithRetryPolicy before they are Ready again.
+	Retrying int
+
+	// Returned is the number of dead-lettered messages. It is always 0 if
+	// dead-lettering is not enabled.
+	Returned int
+
+	// Archived is the number of acked messages in the archive, see
+	// WithArchive.
+	Archived int
+
+	// Sent is the total number of messages ever sent to the Q.
+	Sent uint64
+
+	// Acked is the total number of messages ever acked.
+	Acked uint64
+
+	// Nacked is the total number of messages ever nacked, with or without
+	// retry.
+	Nacked uint64
+
+	// DeadLettered is the total number of messages ever moved to the
+	// dead-letter queue.
+	DeadLettered uint64
+
+	// Expired is the total number of messages ever dead-lettered or
+	// deleted because their deadline passed, see SendWithDeadline.
+	Expired uint64
+}
+
+// Stats returns the number of messages in each state of q, and the number of
+// messages that have been sent, acked and nacked over the lifetime of q.
+func (q *Q) Stats() (Stats, error) {
+	var stats Stats
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	err := q.db.View(func(tx *bolt.Tx) error {
+		stats.Ready = q.keyCount(tx, q.keys.ready)
+		stats.Unacked = q.keyCount(tx, q.keys.unacked)
+		stats.Delayed = q.keyCount(tx, q.keys.delayed)
+		stats.Waiting = q.keyCount(tx, q.keys.waiting)
+		stats.Retrying = q.keyCount(tx, q.keys.retrying)
+		stats.Returned = q.keyCount(tx, q.keys.returned)
+		stats.Archived = q.keyCount(tx, q.keys.archive)
+		stats.Sent = q.counter(tx, sentCounter)
+		stats.Acked = q.counter(tx, ackedCounter)
+		stats.Nacked = q.counter(tx, nackedCounter)
+		stats.DeadLettered = q.counter(tx, deadLetteredCounter)
+		stats.Expired = q.counter(tx, expiredCounter)
+		return nil
+	})
+	return stats, err
+}
+
+func (q *Q) keyCount(tx *bolt.Tx, key []byte) int {
+	bucket := q.readBucket(tx, key)
+	if bucket == nil {
+		return 0
+	}
+	return bucket.Stats().KeyN
+}
+
+func (q *Q) counter(tx *bolt.Tx, name []byte) uint64 {
+	bucket := q.readBucket(tx, q.keys.counters)
+	if bucket == nil {
+		return 0
+	}
+	var count Uint64ID
+	if v := bucket.Get(name); v != nil {
+		if err := count.UnmarshalBinary(v); err != nil {
+			return 0
+		}
+	}
+	return uint64(count)
+}
+
+func (q *Q) incrCounter(tx *bolt.Tx, name []byte) error {
+	return q.setCounter(tx, name, q.counter(tx, name)+1)
+}
+
+func (q *Q) setCounter(tx *bolt.Tx, name []byte, count uint64) error {
+	bucket, err := q.bucket(tx, q.keys.counters)
+	if err != nil {
+		return err
+	}
+	v, err := Uint64ID(count).MarshalBinary()
+	if err != nil {
+		return err
+	}
+	return bucket.Put(name, v)
+}
@@ -0,0 +1,87 @@
+package lasr
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestStats(t *testing.T) {
+	q, cleanup := newQ(t, WithDeadLetters())
+	defer cleanup()
+
+	stats, err := q.Stats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := stats, (Stats{}); got != want {
+		t.Errorf("bad stats on empty queue: got %+v, want %+v", got, want)
+	}
+
+	var ids []ID
+	for i := 0; i < 4; i++ {
+		id, err := q.Send([]byte("foo"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, id)
+	}
+	if _, err := q.Delay([]byte("later"), time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := q.Wait([]byte("blocked"), ids[3]); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	for _, ack := range []bool{true, false, true} {
+		m, err := q.Receive(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !ack {
+			if err := m.Nack(false); err != nil {
+				t.Fatal(err)
+			}
+			continue
+		}
+		if err := m.Ack(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	m, err := q.Receive(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Ack()
+
+	stats, err = q.Stats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Stats{
+		Unacked:      1,
+		Delayed:      1,
+		Waiting:      1,
+		Returned:     1,
+		Sent:         6,
+		Acked:        2,
+		Nacked:       1,
+		DeadLettered: 1,
+	}
+	if stats != want {
+		t.Errorf("bad stats: got %+v, want %+v", stats, want)
+	}
+
+	d, err := DeadLetters(q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stats, err = d.Stats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := stats.Ready, 1; got != want {
+		t.Errorf("bad dead-letter stats: got %d ready, want %d", got, want)
+	}
+}
@@ -0,0 +1,96 @@
+package lasr
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Status is the state of a message in a Q.
+type Status int
+
+const (
+	// Ready messages are waiting to be received.
+	Ready Status = iota
+
+	// Unacked messages have been received, or buffered for receipt, and
+	// not yet acked or nacked.
+	Unacked
+
+	// Returned messages have been dead-lettered.
+	Returned
+
+	// Delayed messages were sent with Delay, and are not yet Ready.
+	Delayed
+
+	// Waiting messages were sent with Wait, and are waiting for other
+	// messages to be acked.
+	Waiting
+
+	// Retrying messages were nacked for retry, and are waiting out the
+	// delay chosen by the Q's RetryPolicy before they are Ready again.
+	Retrying
+)
+
+func (s Status) String() string {
+	switch s {
+	case Ready:
+		return "ready"
+	case Unacked:
+		return "unacked"
+	case Returned:
+		return "returned"
+	case Delayed:
+		return "delayed"
+	case Waiting:
+		return "waiting"
+	case Retrying:
+		return "retrying"
+	default:
+		return fmt.Sprintf("Status(%d)", int(s))
+	}
+}
+
+// parseStatus returns the Status whose String form is s.
+func parseStatus(s string) (Status, error) {
+	for _, status := range []Status{Ready, Unacked, Returned, Delayed, Waiting, Retrying} {
+		if status.String() == s {
+			return status, nil
+		}
+	}
+	return 0, fmt.Errorf("lasr: invalid state: %q", s)
+}
+
+// messageID returns the ID of the message stored at k in the bucket for
+// state s. Retries are keyed by their due time, followed by the message ID.
+func messageID(s Status, k []byte) []byte {
+	if s == Retrying {
+		return k[8:]
+	}
+	return k
+}
+
+// stateKey returns the key of the bucket that holds messages in state s.
+func (q *Q) stateKey(s Status) ([]byte, error) {
+	var key []byte
+	switch s {
+	case Ready:
+		key = q.keys.ready
+	case Unacked:
+		key = q.keys.unacked
+	case Returned:
+		if len(q.keys.returned) == 0 {
+			return nil, errors.New("lasr: dead-letters not available")
+		}
+		key = q.keys.returned
+	case Delayed:
+		key = q.keys.delayed
+	case Waiting:
+		key = q.keys.waiting
+	case Retrying:
+		key = q.keys.retrying
+	}
+	if len(key) == 0 {
+		return nil, fmt.Errorf("lasr: invalid state for %s: %s", q, s)
+	}
+	return key, nil
+}
@@ -0,0 +1,276 @@
+package lasr
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// streamBatch is how many bytes of a streamed body SendFrom writes per
+// transaction.
+const streamBatch = 4 << 20
+
+// WithStreamedBodies makes Receive leave the Body of messages that are stored
+// in chunks nil, so that they can be read a chunk at a time with
+// Message.Open instead of being held in memory. It has no effect without
+// WithChunking, and on encrypted messages, which are sealed as a whole.
+func WithStreamedBodies() Option {
+	return func(q *Q) error {
+		if q.optsApplied {
+			return ErrOptionsApplied
+		}
+		q.streamBodies = true
+		return nil
+	}
+}
+
+// SendFrom sends a message whose body is read from r until io.EOF.
+//
+// If q uses WithChunking, the body is written to the database a few chunks at
+// a time as it is read, and only becomes Ready once all of it is written, so
+// it is never held in memory as a whole, unless it fans out to subscriptions.
+// Otherwise, and for encrypted messages, messages sent to a blob store or a
+// write-ahead log, and partitioned queues, SendFrom reads the whole body
+// before sending it.
+func (q *Q) SendFrom(r io.Reader) (ID, error) {
+	if q.isClosed() {
+		return nil, ErrQClosed
+	}
+	if q.chunkSize == 0 || q.aead != nil || q.blobs != nil || q.wal != nil || q.partitions > 0 {
+		body, err := io.ReadAll(r)
+		if err != nil {
+			return nil, fmt.Errorf("lasr: couldn't read message: %s", err)
+		}
+		return q.Send(body)
+	}
+	head := make([]byte, q.chunkSize+1)
+	n, err := io.ReadFull(r, head)
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return q.Send(head[:n])
+	}
+	if err != nil {
+		return nil, fmt.Errorf("lasr: couldn't read message: %s", err)
+	}
+
+	var id ID
+	var key []byte
+	q.mu.RLock()
+	err = q.db.Update(func(tx *bolt.Tx) (err error) {
+		if id, err = q.nextSequence(tx); err != nil {
+			return err
+		}
+		if key, err = id.MarshalBinary(); err != nil {
+			return err
+		}
+		chunks, err := q.bucket(tx, chunksBucket)
+		if err != nil {
+			return err
+		}
+		_, err = chunks.CreateBucket(key)
+		return err
+	})
+	q.mu.RUnlock()
+	if err != nil {
+		return nil, err
+	}
+	sum, err := q.streamChunks(key, head, r)
+	if err != nil {
+		q.dropChunks(key)
+		return nil, err
+	}
+	err = q.admit(func(tx *bolt.Tx) error {
+		md := &metadata{}
+		if subs, err := q.subscribers(tx); err != nil {
+			return err
+		} else if len(subs) > 0 {
+			// Subscriptions get their own copy of the body.
+			body, err := q.loadBody(tx, key, []byte{})
+			if err != nil {
+				return err
+			}
+			if err := q.deleteBody(tx, key); err != nil {
+				return err
+			}
+			return q.send(id, body, md, tx)
+		}
+		if err := q.putChecksum(tx, key, sum); err != nil {
+			return err
+		}
+		return q.putReady(tx, key, []byte{}, md)
+	})
+	if err != nil {
+		q.dropChunks(key)
+		return nil, err
+	}
+	q.waker.Wake()
+	q.wakeSubscriptions()
+	q.onSend(id)
+	return id, nil
+}
+
+// streamChunks stores the body that starts with head and continues in r, in
+// the chunks bucket of the message key, and returns its checksum.
+func (q *Q) streamChunks(key, head []byte, r io.Reader) (uint32, error) {
+	size := q.chunkSize
+	perTx := streamBatch / size
+	if perTx < 1 {
+		perTx = 1
+	}
+	var i, sum uint32
+	pending := head
+	var eof bool
+	for !eof {
+		// Read the chunks of the next transaction, keeping the ones bolt
+		// holds on to until it commits.
+		var batch [][]byte
+		for len(batch) < perTx && !eof {
+			if len(pending) >= size {
+				batch = append(batch, pending[:size:size])
+				pending = pending[size:]
+				continue
+			}
+			chunk := make([]byte, size)
+			copy(chunk, pending)
+			n, err := io.ReadFull(r, chunk[len(pending):])
+			n += len(pending)
+			pending = nil
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				eof = true
+			} else if err != nil {
+				return 0, fmt.Errorf("lasr: couldn't read message: %s", err)
+			}
+			if n > 0 {
+				batch = append(batch, chunk[:n])
+			}
+		}
+		q.mu.RLock()
+		err := q.db.Update(func(tx *bolt.Tx) error {
+			chunks := q.readBucket(tx, chunksBucket)
+			if chunks == nil || chunks.Bucket(key) == nil {
+				return ErrNotFound
+			}
+			bucket := chunks.Bucket(key)
+			bucket.FillPercent = 1
+			var k [4]byte
+			for j, chunk := range batch {
+				binary.BigEndian.PutUint32(k[:], i+uint32(j))
+				if err := bucket.Put(k[:], chunk); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		q.mu.RUnlock()
+		if err != nil {
+			return 0, err
+		}
+		for _, chunk := range batch {
+			sum = crc32.Update(sum, checksumTable, chunk)
+		}
+		i += uint32(len(batch))
+	}
+	return sum, nil
+}
+
+// dropChunks deletes the chunks of a message whose SendFrom failed.
+func (q *Q) dropChunks(key []byte) {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	err := q.db.Update(func(tx *bolt.Tx) error {
+		return q.deleteBody(tx, key)
+	})
+	if err != nil {
+		q.logger().Warn("lasr: couldn't delete the chunks of a failed send", "id", hexID(key), "error", err)
+	}
+}
+
+// isChunked reports whether the body of the message id is stored in chunks.
+func (q *Q) isChunked(tx *bolt.Tx, id []byte) bool {
+	chunks := q.readBucket(tx, chunksBucket)
+	return chunks != nil && chunks.Bucket(id) != nil
+}
+
+// Open returns a reader for the body of m. For messages that were received
+// with a nil Body, see WithStreamedBodies, the body is read from the database
+// a chunk at a time, and reading it fails with ErrNotFound once m has been
+// acked, or has otherwise left its Q. Otherwise, the reader reads Body.
+func (m *Message) Open() io.ReadCloser {
+	if m.chunksOf == nil {
+		return io.NopCloser(bytes.NewReader(m.Body))
+	}
+	return &chunkReader{q: m.chunksOf, id: m.ID}
+}
+
+// chunkReader reads the chunks of a message body in order, in a transaction
+// per chunk.
+type chunkReader struct {
+	q      *Q
+	id     []byte
+	next   uint32
+	sum    uint32
+	buf    []byte
+	err    error
+	closed bool
+}
+
+func (c *chunkReader) Read(p []byte) (int, error) {
+	if c.closed {
+		return 0, errors.New("lasr: read from closed body")
+	}
+	for len(c.buf) == 0 {
+		if c.err != nil {
+			return 0, c.err
+		}
+		c.buf, c.err = c.readChunk()
+	}
+	n := copy(p, c.buf)
+	c.buf = c.buf[n:]
+	return n, nil
+}
+
+// readChunk returns the next chunk, or io.EOF after the last one, once the
+// body is verified.
+func (c *chunkReader) readChunk() ([]byte, error) {
+	q := c.q
+	if q.isClosed() {
+		return nil, ErrQClosed
+	}
+	var chunk []byte
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	err := q.db.View(func(tx *bolt.Tx) error {
+		chunks := q.readBucket(tx, chunksBucket)
+		if chunks == nil || chunks.Bucket(c.id) == nil {
+			return ErrNotFound
+		}
+		var k [4]byte
+		binary.BigEndian.PutUint32(k[:], c.next)
+		if v := chunks.Bucket(c.id).Get(k[:]); v != nil {
+			chunk = cloneBytes(v)
+			return nil
+		}
+		if want, ok := q.getChecksum(tx, c.id); ok && want != c.sum {
+			return ErrCorruptMessage
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if chunk == nil {
+		return nil, io.EOF
+	}
+	c.sum = crc32.Update(c.sum, checksumTable, chunk)
+	c.next++
+	return chunk, nil
+}
+
+func (c *chunkReader) Close() error {
+	c.closed = true
+	return nil
+}
@@ -0,0 +1,102 @@
+package lasr
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"testing"
+	"testing/iotest"
+)
+
+func TestSendFrom(t *testing.T) {
+	q, cleanup := newQ(t, WithChunking(16), WithStreamedBodies())
+	defer cleanup()
+
+	large := bytes.Repeat([]byte("0123456789"), 10)
+	if _, err := q.SendFrom(iotest.OneByteReader(bytes.NewReader(large))); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := q.SendFrom(bytes.NewReader([]byte("small"))); err != nil {
+		t.Fatal(err)
+	}
+	if got := chunkCount(t, q); got != 1 {
+		t.Fatalf("got %d chunked messages, want 1", got)
+	}
+
+	msgs := receiveN(t, q, 2)
+	if msgs[0].Body != nil {
+		t.Errorf("chunked body was loaded: %q", msgs[0].Body)
+	}
+	r := msgs[0].Open()
+	body, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(body, large) {
+		t.Errorf("got %q, want %q", body, large)
+	}
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+	body, err = io.ReadAll(msgs[1].Open())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(body); got != "small" || string(msgs[1].Body) != "small" {
+		t.Errorf("got %q, want %q", got, "small")
+	}
+
+	for _, msg := range msgs {
+		if err := msg.Ack(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := io.ReadAll(msgs[0].Open()); err != ErrNotFound {
+		t.Errorf("got %v, want ErrNotFound", err)
+	}
+	if got := chunkCount(t, q); got != 0 {
+		t.Errorf("got %d chunked messages after ack, want 0", got)
+	}
+}
+
+func TestSendFromReadError(t *testing.T) {
+	q, cleanup := newQ(t, WithChunking(4))
+	defer cleanup()
+	r := io.MultiReader(bytes.NewReader([]byte("0123456789")), iotest.ErrReader(io.ErrClosedPipe))
+	if _, err := q.SendFrom(r); err == nil {
+		t.Fatal("expected an error")
+	}
+	if got := chunkCount(t, q); got != 0 {
+		t.Errorf("got %d chunked messages after failed send, want 0", got)
+	}
+	stats, err := q.Stats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Ready != 0 {
+		t.Errorf("got %d ready messages, want 0", stats.Ready)
+	}
+}
+
+func TestSendFromSubscribed(t *testing.T) {
+	q, cleanup := newQ(t, WithChunking(4))
+	defer cleanup()
+	sub, err := q.Subscribe("sub")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sub.Close()
+	if _, err := q.SendFrom(bytes.NewReader([]byte("0123456789"))); err != nil {
+		t.Fatal(err)
+	}
+	msg, err := sub.Receive(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(msg.Body); got != "0123456789" {
+		t.Errorf("got %q, want %q", got, "0123456789")
+	}
+	if err := msg.Ack(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,274 @@
+package lasr
+
+import (
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// Subscription is a named subscription to a Q, created with Subscribe or
+// JoinGroup.
+//
+// A Subscription is a Q in its own right, with its own Ready, Unacked and
+// dead-letter states. Receive, Ack and Nack on a Subscription do not affect
+// the parent Q or any of its other subscriptions.
+type Subscription struct {
+	*Q
+	topic  *Q
+	sub    string
+	closed int32
+}
+
+// openSubscription is a subscription that is open in this process, shared by
+// all of its Subscription values.
+type openSubscription struct {
+	q       *Q
+	group   bool
+	members int
+}
+
+// Subscribe creates a subscription to q named name, or resumes it if it was
+// created previously. Subscriptions are persisted, so messages sent while a
+// subscription is not open are kept until it is resumed.
+//
+// Once q has at least one subscription, every message sent to q is delivered
+// to each of its subscriptions instead of to q itself. A message sent with
+// Wait waits, in each subscription, for that subscription's copies of the
+// messages it waits on to be acked.
+//
+// The options are applied to the subscription, except that it always uses the
+// Sequencer and encryption settings of q. A subscription can only be open
+// once at a time; Subscribe returns an error if it is already open.
+//
+// The subscription's bucket is named after q and the subscription, separated
+// by a slash, so no other queue in the same db should use that name.
+func (q *Q) Subscribe(name string, options ...Option) (*Subscription, error) {
+	return q.subscribe(name, false, options)
+}
+
+// JoinGroup joins the consumer group named name on q, creating it if needed.
+//
+// A consumer group is a subscription that can be opened any number of times.
+// Every group receives its own copy of each message sent to q, and the
+// messages of a group are load-balanced across its members: each message is
+// received by only one of them. Members share Ready, Unacked and dead-letter
+// state, and a member may Ack or Nack messages received by another.
+//
+// The options are only applied when the group is opened by its first member
+// in this process. The group stays open until all of its members are closed.
+// Consumer groups and subscriptions share a namespace, so a group cannot be
+// joined while a subscription of the same name is open.
+func (q *Q) JoinGroup(name string, options ...Option) (*Subscription, error) {
+	return q.subscribe(name, true, options)
+}
+
+func (q *Q) subscribe(name string, group bool, options []Option) (*Subscription, error) {
+	if q.isClosed() {
+		return nil, ErrQClosed
+	}
+	if q.readOnly {
+		return nil, ErrReadOnly
+	}
+	if name == "" {
+		return nil, errors.New("lasr: subscription name required")
+	}
+	q.subsMu.Lock()
+	defer q.subsMu.Unlock()
+	if open, ok := q.subs[name]; ok {
+		if !group || !open.group {
+			return nil, fmt.Errorf("lasr: subscription %q is already open", name)
+		}
+		open.members++
+		return &Subscription{Q: open.q, topic: q, sub: name}, nil
+	}
+	q.mu.RLock()
+	db := q.db
+	err := db.Update(func(tx *bolt.Tx) error {
+		bucket, err := q.bucket(tx, q.keys.subscriptions)
+		if err != nil {
+			return err
+		}
+		return bucket.Put([]byte(name), []byte{})
+	})
+	q.mu.RUnlock()
+	if err != nil {
+		return nil, err
+	}
+	options = append(options[:len(options):len(options)], subscriptionOf(q))
+	sq, err := NewQ(db, string(subscriptionName(q.name, name)), options...)
+	if err != nil {
+		return nil, err
+	}
+	if q.subs == nil {
+		q.subs = make(map[string]*openSubscription)
+	}
+	q.subs[name] = &openSubscription{q: sq, group: group, members: 1}
+	return &Subscription{Q: sq, topic: q, sub: name}, nil
+}
+
+// subscriptionOf makes a Q use the Sequencer and encryption settings of topic.
+// It is applied after the caller's options, so that it takes precedence.
+func subscriptionOf(topic *Q) Option {
+	return func(q *Q) error {
+		if q.optsApplied {
+			return ErrOptionsApplied
+		}
+		q.seq = topic.seq
+		q.seqName = topic.seqName
+		q.aead = topic.aead
+		q.keyring = topic.keyring
+		q.keyID = topic.keyID
+		q.blobs = topic.blobs
+		q.blobThreshold = topic.blobThreshold
+		q.checksums = topic.checksums
+		return nil
+	}
+}
+
+// Close closes the subscription. Messages that are sent to the parent Q while
+// the subscription is closed are kept until it is resumed.
+//
+// Closing a member of a consumer group only closes the underlying queue once
+// every member of the group has been closed.
+func (s *Subscription) Close() error {
+	if !atomic.CompareAndSwapInt32(&s.closed, 0, 1) {
+		return ErrQClosed
+	}
+	s.topic.subsMu.Lock()
+	open, ok := s.topic.subs[s.sub]
+	if ok && open.q == s.Q {
+		open.members--
+		if open.members > 0 {
+			s.topic.subsMu.Unlock()
+			return nil
+		}
+		delete(s.topic.subs, s.sub)
+	}
+	s.topic.subsMu.Unlock()
+	return s.Q.Close()
+}
+
+// Unsubscribe deletes the subscription named name, along with all of the
+// messages it holds. The subscription must not be open.
+func (q *Q) Unsubscribe(name string) error {
+	if q.readOnly {
+		return ErrReadOnly
+	}
+	q.subsMu.Lock()
+	defer q.subsMu.Unlock()
+	if _, ok := q.subs[name]; ok {
+		return fmt.Errorf("lasr: subscription %q is open", name)
+	}
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	return q.db.Update(func(tx *bolt.Tx) error {
+		bucket, err := q.bucket(tx, q.keys.subscriptions)
+		if err != nil {
+			return err
+		}
+		if bucket.Get([]byte(name)) == nil {
+			return fmt.Errorf("lasr: no such subscription: %q", name)
+		}
+		if err := bucket.Delete([]byte(name)); err != nil {
+			return err
+		}
+		err = tx.DeleteBucket(subscriptionName(q.name, name))
+		if err == bolt.ErrBucketNotFound {
+			err = nil
+		}
+		return err
+	})
+}
+
+// Subscriptions returns the names of the subscriptions of q.
+func (q *Q) Subscriptions() ([]string, error) {
+	var names []string
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	err := q.db.View(func(tx *bolt.Tx) error {
+		bucket := q.readBucket(tx, q.keys.subscriptions)
+		if bucket == nil {
+			return nil
+		}
+		return bucket.ForEach(func(k, _ []byte) error {
+			names = append(names, string(k))
+			return nil
+		})
+	})
+	return names, err
+}
+
+func subscriptionName(topic []byte, name string) []byte {
+	return []byte(fmt.Sprintf("%s/%s", topic, name))
+}
+
+// fanOut delivers a message to each of the subscriptions of q. It reports
+// whether q has any subscriptions.
+func (q *Q) fanOut(id ID, body []byte, md *metadata, tx *bolt.Tx) (bool, error) {
+	subs, err := q.subscribers(tx)
+	if err != nil {
+		return false, err
+	}
+	for _, sub := range subs {
+		if err := sub.send(id, body, md, tx); err != nil {
+			return true, err
+		}
+	}
+	return len(subs) > 0, nil
+}
+
+// subscribers returns a Q for each subscription of q, open or not, that can
+// be used to store messages in the subscription within tx.
+func (q *Q) subscribers(tx *bolt.Tx) ([]*Q, error) {
+	bucket := q.readBucket(tx, q.keys.subscriptions)
+	if bucket == nil {
+		return nil, nil
+	}
+	var subs []*Q
+	err := bucket.ForEach(func(k, _ []byte) error {
+		subs = append(subs, q.storageFor(subscriptionName(q.name, string(k))))
+		return nil
+	})
+	return subs, err
+}
+
+// storageFor returns a Q that can be used to store messages in the queue
+// named name within a transaction, with the storage settings of q.
+func (q *Q) storageFor(name []byte) *Q {
+	return &Q{
+		name:          name,
+		keys:          defaultKeys(),
+		aead:          q.aead,
+		keyring:       q.keyring,
+		keyID:         q.keyID,
+		chunkSize:     q.chunkSize,
+		blobs:         q.blobs,
+		blobThreshold: q.blobThreshold,
+		checksums:     q.checksums,
+	}
+}
+
+// wakeSubscriptions wakes every open subscription of q.
+func (q *Q) wakeSubscriptions() {
+	q.subsMu.Lock()
+	defer q.subsMu.Unlock()
+	for _, sub := range q.subs {
+		if !sub.q.isClosed() {
+			sub.q.waker.Wake()
+		}
+	}
+}
+
+// wakeSubscriptionsAt wakes every open subscription of q at t.
+func (q *Q) wakeSubscriptionsAt(t time.Time) {
+	q.subsMu.Lock()
+	defer q.subsMu.Unlock()
+	for _, sub := range q.subs {
+		if !sub.q.isClosed() {
+			sub.q.waker.WakeAt(t)
+		}
+	}
+}
@@ -0,0 +1,326 @@
+package lasr
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSubscribe(t *testing.T) {
+	q, cleanup := newQ(t)
+	defer cleanup()
+
+	audit, err := q.Subscribe("audit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	workers, err := q.Subscribe("workers", WithDeadLetters())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := q.Subscribe("audit"); err == nil {
+		t.Error("expected error subscribing twice")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	received := make(chan []string)
+	for _, sub := range []*Subscription{audit, workers} {
+		go func(sub *Subscription) {
+			var bodies []string
+			for i := 0; i < 3; i++ {
+				m, err := sub.Receive(ctx)
+				if err != nil {
+					t.Error(err)
+					break
+				}
+				bodies = append(bodies, string(m.Body))
+				if err := m.Ack(); err != nil {
+					t.Error(err)
+				}
+			}
+			received <- bodies
+		}(sub)
+	}
+	for _, body := range []string{"a", "b", "c"} {
+		if _, err := q.Send([]byte(body)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want := []string{"a", "b", "c"}
+	for i := 0; i < 2; i++ {
+		if got := <-received; !reflect.DeepEqual(got, want) {
+			t.Errorf("bad messages: got %v, want %v", got, want)
+		}
+	}
+
+	// messages sent to a topic are not kept by the topic itself
+	stats, err := q.Stats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := stats.Ready, 0; got != want {
+		t.Errorf("bad ready count on topic: got %d, want %d", got, want)
+	}
+	if got, want := stats.Sent, uint64(3); got != want {
+		t.Errorf("bad sent count on topic: got %d, want %d", got, want)
+	}
+
+	// a closed subscription keeps receiving messages
+	if err := audit.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := q.Send([]byte("d")); err != nil {
+		t.Fatal(err)
+	}
+	audit, err = q.Subscribe("audit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer audit.Close()
+	m, err := audit.Receive(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(m.Body), "d"; got != want {
+		t.Errorf("bad body: got %q, want %q", got, want)
+	}
+	if err := m.Ack(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := q.Unsubscribe("workers"); err == nil {
+		t.Error("expected error unsubscribing an open subscription")
+	}
+	if err := workers.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := q.Unsubscribe("workers"); err != nil {
+		t.Fatal(err)
+	}
+	names, err := q.Subscriptions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := names, []string{"audit"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("bad subscriptions: got %v, want %v", got, want)
+	}
+}
+
+func TestSubscriptionIDs(t *testing.T) {
+	q, cleanup := newQ(t)
+	defer cleanup()
+
+	sub, err := q.Subscribe("sub")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sub.Close()
+	first, err := q.Send([]byte("foo"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// sending directly to a subscription must not reuse the topic's IDs
+	second, err := sub.Send([]byte("bar"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first == second {
+		t.Errorf("duplicate id: %v", first)
+	}
+}
+
+func TestConsumerGroups(t *testing.T) {
+	q, cleanup := newQ(t)
+	defer cleanup()
+
+	var processors []*Subscription
+	for i := 0; i < 3; i++ {
+		member, err := q.JoinGroup("processors", WithMessageBufferSize(1))
+		if err != nil {
+			t.Fatal(err)
+		}
+		processors = append(processors, member)
+	}
+	auditor, err := q.JoinGroup("audit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer auditor.Close()
+	if _, err := q.Subscribe("processors"); err == nil {
+		t.Error("expected error subscribing to an open group")
+	}
+
+	const n = 30
+	for i := 0; i < n; i++ {
+		if _, err := q.Send([]byte{byte(i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	seen := make(chan byte, n)
+	done := make(chan struct{})
+	for _, member := range processors {
+		go func(member *Subscription) {
+			for {
+				m, err := member.Receive(ctx)
+				if err != nil {
+					return
+				}
+				seen <- m.Body[0]
+				if err := m.Ack(); err != nil {
+					t.Error(err)
+				}
+			}
+		}(member)
+	}
+	go func() {
+		counts := make(map[byte]int)
+		for len(counts) < n {
+			counts[<-seen]++
+		}
+		for b, c := range counts {
+			if c != 1 {
+				t.Errorf("message %d received %d times", b, c)
+			}
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		t.Fatal("group did not receive every message")
+	}
+	cancel()
+
+	// the auditing group gets its own copy of the stream
+	stats, err := auditor.Stats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := stats.Ready, n; got != want {
+		t.Errorf("bad ready count for audit group: got %d, want %d", got, want)
+	}
+
+	// the group stays open until its last member leaves
+	for i, member := range processors {
+		if err := member.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if i < len(processors)-1 && member.isClosed() {
+			t.Fatal("group closed before its last member left")
+		}
+	}
+	if err := processors[0].Close(); err != ErrQClosed {
+		t.Errorf("bad error closing member twice: %v", err)
+	}
+	if !processors[0].isClosed() {
+		t.Error("group not closed after its last member left")
+	}
+}
+
+func TestSubscribeDelay(t *testing.T) {
+	q, cleanup := newQ(t)
+	defer cleanup()
+
+	open, err := q.Subscribe("open")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closed, err := q.Subscribe("closed")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := closed.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	id, err := q.Delay([]byte("foo"), time.Now().Add(10*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	closed, err = q.Subscribe("closed")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, _ := id.MarshalBinary()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	for _, sub := range []*Subscription{open, closed} {
+		m, err := sub.Receive(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(m.ID, want) || string(m.Body) != "foo" {
+			t.Errorf("bad message: got %x %q, want %x %q", m.ID, m.Body, want, "foo")
+		}
+		if err := m.Ack(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	stats, err := q.Stats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := stats.Delayed, 0; got != want {
+		t.Errorf("bad delayed count on topic: got %d, want %d", got, want)
+	}
+}
+
+func TestSubscribeWait(t *testing.T) {
+	q, cleanup := newQ(t)
+	defer cleanup()
+
+	a, err := q.Subscribe("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := q.Subscribe("b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	first, err := q.Send([]byte("first"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := q.Wait([]byte("second"), first); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	for _, sub := range []*Subscription{a, b} {
+		m, err := sub.Receive(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := string(m.Body), "first"; got != want {
+			t.Fatalf("bad body: got %q, want %q", got, want)
+		}
+		stats, err := sub.Stats()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := stats.Waiting, 1; got != want {
+			t.Errorf("bad waiting count: got %d, want %d", got, want)
+		}
+		if err := m.Ack(); err != nil {
+			t.Fatal(err)
+		}
+		m, err = sub.Receive(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := string(m.Body), "second"; got != want {
+			t.Errorf("bad body: got %q, want %q", got, want)
+		}
+		if err := m.Ack(); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package lasr
+
+import (
+	"fmt"
+	"time"
+)
+
+// SyncPolicy decides when the writes of a Q are flushed to disk.
+//
+// Without a flush after every write, the messages that were sent, acked or
+// nacked since the last flush can be lost or come back if the machine
+// crashes, and the bolt database itself can be corrupted. A crash of the
+// process alone loses nothing, since the writes are already with the
+// operating system.
+type SyncPolicy struct {
+	interval time.Duration
+	never    bool
+}
+
+var (
+	// SyncAlways flushes every write to disk before the call that made it
+	// returns. It is the default.
+	SyncAlways = SyncPolicy{}
+
+	// SyncNever leaves flushing to the operating system.
+	SyncNever = SyncPolicy{never: true}
+)
+
+// SyncEvery flushes the writes of the last interval to disk together, once
+// per interval, and when the Q is closed.
+func SyncEvery(interval time.Duration) SyncPolicy {
+	return SyncPolicy{interval: interval}
+}
+
+// WithSyncPolicy sets when writes are flushed to disk. Flushing is controlled
+// by bolt for the whole database, so every Q that shares a database should use
+// the same policy, and the policy must be set before the database is used by
+// another Q.
+func WithSyncPolicy(policy SyncPolicy) Option {
+	return func(q *Q) error {
+		if q.optsApplied {
+			return ErrOptionsApplied
+		}
+		if policy.interval < 0 {
+			return fmt.Errorf("lasr: invalid sync interval: %s", policy.interval)
+		}
+		q.syncPolicy = policy
+		return nil
+	}
+}
+
+func (p SyncPolicy) always() bool {
+	return !p.never && p.interval == 0
+}
+
+// startSync applies the sync policy of q to its database.
+func (q *Q) startSync() {
+	if q.syncPolicy.always() || q.readOnly {
+		return
+	}
+	q.db.NoSync = true
+	if q.syncPolicy.interval > 0 {
+		q.syncDone = make(chan struct{})
+		go q.syncLoop(q.syncPolicy.interval)
+	}
+}
+
+// syncLoop flushes the database every interval until q is closed.
+func (q *Q) syncLoop(interval time.Duration) {
+	defer close(q.syncDone)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			q.sync()
+		case <-q.closed:
+			return
+		}
+	}
+}
+
+// stopSync waits for the sync loop of q to stop, once q is closed, and then
+// flushes the database for the last time.
+func (q *Q) stopSync() error {
+	if q.syncDone == nil {
+		return nil
+	}
+	<-q.syncDone
+	return q.sync()
+}
+
+func (q *Q) sync() error {
+	if err := q.syncWAL(); err != nil {
+		return err
+	}
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	return q.db.Sync()
+}
@@ -0,0 +1,43 @@
+package lasr
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSyncPolicy(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy SyncPolicy
+		noSync bool
+	}{
+		{"always", SyncAlways, false},
+		{"never", SyncNever, true},
+		{"every", SyncEvery(time.Millisecond), true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			q, cleanup := newQ(t, WithSyncPolicy(test.policy))
+			defer cleanup()
+			if got, want := q.db.NoSync, test.noSync; got != want {
+				t.Errorf("bad NoSync: got %v, want %v", got, want)
+			}
+			for i := 0; i < 10; i++ {
+				if _, err := q.Send([]byte("foo")); err != nil {
+					t.Fatal(err)
+				}
+				time.Sleep(time.Millisecond)
+			}
+			if err := q.Close(); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestSyncPolicyInvalid(t *testing.T) {
+	q := &Q{}
+	if err := WithSyncPolicy(SyncEvery(-time.Second))(q); err == nil {
+		t.Error("expected an error")
+	}
+}
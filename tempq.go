@@ -0,0 +1,43 @@
+package lasr
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// NewTempQ creates a new Q that is not meant to outlive the process, for
+// use in tests and for ephemeral queues. It behaves exactly like a Q created
+// with NewQ, including Ack, Nack, dead-lettering and message buffering.
+//
+// The Q is backed by a bolt database file in a temporary directory, which is
+// opened without fsync and removed when the Q is closed. It is not an
+// in-memory queue: it still writes to disk, and needs a writable temporary
+// directory (see os.TempDir). Callers do not need to manage the file.
+func NewTempQ(name string, options ...Option) (*Q, error) {
+	td, err := ioutil.TempDir("", "lasr")
+	if err != nil {
+		return nil, err
+	}
+	db, err := bolt.Open(filepath.Join(td, "lasr.db"), 0600, &bolt.Options{
+		NoSync:         true,
+		NoFreelistSync: true,
+	})
+	if err != nil {
+		os.RemoveAll(td)
+		return nil, err
+	}
+	q, err := NewQ(db, name, options...)
+	if err != nil {
+		db.Close()
+		os.RemoveAll(td)
+		return nil, err
+	}
+	q.release = func() error {
+		defer os.RemoveAll(td)
+		return q.db.Close()
+	}
+	return q, nil
+}
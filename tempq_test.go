@@ -0,0 +1,70 @@
+package lasr
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestTempQ(t *testing.T) {
+	q, err := NewTempQ("testing", WithDeadLetters(), WithMessageBufferSize(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := q.db.Path()
+	if _, err := os.Stat(path); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, body := range []string{"foo", "bar"} {
+		if _, err := q.Send([]byte(body)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	m, err := q.Receive(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := m.Body, []byte("foo"); !bytes.Equal(got, want) {
+		t.Errorf("bad body: got %q, want %q", got, want)
+	}
+	if err := m.Nack(false); err != nil {
+		t.Fatal(err)
+	}
+	m, err = q.Receive(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Ack(); err != nil {
+		t.Fatal(err)
+	}
+
+	d, err := DeadLetters(q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err = d.Receive(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := m.Body, []byte("foo"); !bytes.Equal(got, want) {
+		t.Errorf("bad dead letter: got %q, want %q", got, want)
+	}
+	if err := m.Ack(); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := q.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("database not removed on Close: %v", err)
+	}
+}
@@ -0,0 +1,54 @@
+package lasr
+
+import "context"
+
+// TypedQ is a Q of values of type T, which are encoded into message bodies
+// by a Codec.
+type TypedQ[T any] struct {
+	q     *Q
+	codec Codec
+}
+
+// TypedMessage is a message received from a TypedQ. Value holds the decoded
+// body of the message, which must be acked or nacked like any other.
+type TypedMessage[T any] struct {
+	*Message
+	Value T
+}
+
+// NewTypedQ returns a TypedQ that sends and receives values of type T on q,
+// encoded with codec. If codec is nil, the Codec of q is used, see WithCodec.
+func NewTypedQ[T any](q *Q, codec Codec) *TypedQ[T] {
+	if codec == nil {
+		codec = q.codec
+	}
+	if codec == nil {
+		codec = JSON
+	}
+	return &TypedQ[T]{q: q, codec: codec}
+}
+
+// Q returns the Q that t sends to and receives from.
+func (t *TypedQ[T]) Q() *Q {
+	return t.q
+}
+
+// Send encodes v and sends it, like Q.Send. The content type of the Codec
+// is recorded in the ContentTypeHeader header of the message.
+func (t *TypedQ[T]) Send(v T) (ID, error) {
+	return t.q.sendValue(t.codec, v)
+}
+
+// Receive receives a message like Q.Receive, and decodes its body. If the
+// body can't be decoded, or was encoded by a Codec with another content
+// type, Receive returns the message along with the error, so that it can be
+// nacked.
+func (t *TypedQ[T]) Receive(ctx context.Context) (TypedMessage[T], error) {
+	msg, err := t.q.Receive(ctx)
+	if err != nil {
+		return TypedMessage[T]{}, err
+	}
+	tm := TypedMessage[T]{Message: msg}
+	err = decode(t.codec, msg, &tm.Value)
+	return tm, err
+}
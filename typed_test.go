@@ -0,0 +1,55 @@
+package lasr
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+type point struct {
+	X, Y int
+}
+
+func TestTypedQ(t *testing.T) {
+	q, cleanup := newQ(t)
+	defer cleanup()
+	tq := NewTypedQ[point](q, JSON)
+
+	if _, err := tq.Send(point{X: 1, Y: 2}); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	msg, err := tq.Receive(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := msg.Value, (point{X: 1, Y: 2}); got != want {
+		t.Errorf("bad value: got %v, want %v", got, want)
+	}
+	if err := msg.Ack(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTypedQBadBody(t *testing.T) {
+	q, cleanup := newQ(t)
+	defer cleanup()
+	tq := NewTypedQ[point](q, JSON)
+
+	if _, err := q.Send([]byte("not json")); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	msg, err := tq.Receive(ctx)
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	if msg.Message == nil {
+		t.Fatal("expected the message")
+	}
+	if err := msg.Nack(false); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,361 @@
+package lasr
+
+import (
+	"encoding/json"
+	"fmt"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// Problem is an inconsistency in a Q, found by Verify.
+type Problem struct {
+	// ID is the ID of the message that the problem concerns, if any.
+	ID []byte
+
+	// Description says what is wrong.
+	Description string
+
+	// Fixable reports whether Repair fixes the problem.
+	Fixable bool
+}
+
+func (p Problem) String() string {
+	if p.ID == nil {
+		return p.Description
+	}
+	return fmt.Sprintf("%x: %s", p.ID, p.Description)
+}
+
+// Verify checks the bolt pages of the database of q, and then every message in
+// q: that its key and metadata can be decoded, that its body can be read and
+// matches its checksum, and that it is in one state only. It also finds the
+// records that refer to messages that are gone, like metadata, chunks, blobs,
+// checksums, index entries and the links between waiting messages, and checks
+// the depth of q.
+//
+// Verify only reads q. It returns the problems it found, or an error if it
+// couldn't read the database.
+func (q *Q) Verify() ([]Problem, error) {
+	if q.isClosed() {
+		return nil, ErrQClosed
+	}
+	var problems []Problem
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	err := q.db.View(func(tx *bolt.Tx) error {
+		for err := range tx.Check() {
+			problems = append(problems, Problem{Description: fmt.Sprintf("database: %s", err)})
+		}
+		var err error
+		problems, _, err = q.findProblems(tx, problems)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("lasr: couldn't verify queue: %s", err)
+	}
+	return problems, nil
+}
+
+// Repair fixes the problems that Verify reports as fixable, and returns every
+// problem it found, fixed or not. Records that refer to messages that are gone
+// are deleted, waiting messages that have nothing left to wait on are made
+// Ready, and the depth of q is recounted. Corrupt messages, and messages that
+// are in two states, are left for the caller to deal with, with Purge or by
+// exporting q.
+func (q *Q) Repair() ([]Problem, error) {
+	if q.isClosed() {
+		return nil, ErrQClosed
+	}
+	if q.readOnly {
+		return nil, ErrReadOnly
+	}
+	var problems []Problem
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	err := q.db.Update(func(tx *bolt.Tx) error {
+		var fixes []func() error
+		var err error
+		problems, fixes, err = q.findProblems(tx, nil)
+		if err != nil {
+			return err
+		}
+		for _, fix := range fixes {
+			if err := fix(); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("lasr: couldn't repair queue: %s", err)
+	}
+	if !q.isClosed() {
+		q.waker.Wake()
+	}
+	return problems, nil
+}
+
+// findProblems appends the problems with q to problems, along with the fixes for
+// the fixable ones, which can only be applied if tx is writable.
+func (q *Q) findProblems(tx *bolt.Tx, problems []Problem) ([]Problem, []func() error, error) {
+	var fixes []func() error
+	report := func(id []byte, fix func() error, format string, args ...interface{}) {
+		p := Problem{Description: fmt.Sprintf(format, args...)}
+		if id != nil {
+			p.ID = cloneBytes(id)
+		}
+		if fix != nil {
+			p.Fixable = true
+			fixes = append(fixes, fix)
+		}
+		problems = append(problems, p)
+	}
+	root := tx.Bucket(q.name)
+	if root == nil {
+		return problems, nil, nil
+	}
+
+	// Every message, and its state.
+	states := make(map[string]Status)
+	var depth uint64
+	for _, state := range []Status{Ready, Unacked, Delayed, Waiting, Retrying, Returned} {
+		bucket := q.readBucket(tx, q.stateBucketKey(state))
+		if bucket == nil {
+			continue
+		}
+		err := bucket.ForEach(func(k, v []byte) error {
+			if state == Retrying && len(k) <= 8 {
+				report(k, nil, "retry key is too short")
+				return nil
+			}
+			id := messageID(state, k)
+			if other, ok := states[string(id)]; ok {
+				report(id, nil, "message is both %s and %s", other, state)
+				return nil
+			}
+			states[string(id)] = state
+			if state != Returned {
+				depth++
+			}
+			if v == nil {
+				report(id, nil, "%s message is a bucket", state)
+				return nil
+			}
+			if _, err := q.openBody(tx, id, v); err != nil {
+				report(id, nil, "%s message can't be read: %s", state, err)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	exists := func(id []byte) bool {
+		_, ok := states[string(id)]
+		return ok
+	}
+	isWaiting := func(id []byte) bool {
+		state, ok := states[string(id)]
+		return ok && state == Waiting
+	}
+
+	// Records that are keyed by message ID.
+	for _, key := range [][]byte{q.keys.meta, checksumsBucket, blobsBucket} {
+		bucket := q.readBucket(tx, key)
+		if bucket == nil {
+			continue
+		}
+		key := key
+		err := bucket.ForEach(func(k, v []byte) error {
+			if string(key) == string(q.keys.meta) && v != nil {
+				var md metadata
+				if err := json.Unmarshal(v, &md); err != nil {
+					report(k, nil, "metadata can't be decoded: %s", err)
+				}
+			}
+			if exists(k) {
+				return nil
+			}
+			id := cloneBytes(k)
+			report(id, func() error {
+				b := tx.Bucket(q.name).Bucket(key)
+				if string(key) == string(blobsBucket) {
+					return q.deleteBlob(tx, id)
+				}
+				return b.Delete(id)
+			}, "%s of a message that is gone", key)
+			return nil
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	if chunks := q.readBucket(tx, chunksBucket); chunks != nil {
+		err := chunks.ForEach(func(k, _ []byte) error {
+			if exists(k) {
+				return nil
+			}
+			id := cloneBytes(k)
+			report(id, func() error {
+				return tx.Bucket(q.name).Bucket(chunksBucket).DeleteBucket(id)
+			}, "chunks of a message that is gone")
+			return nil
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	// The links between waiting messages and the messages they wait on.
+	if blockedOn := q.readBucket(tx, q.keys.blockedOn); blockedOn != nil {
+		err := blockedOn.ForEach(func(k, _ []byte) error {
+			id := cloneBytes(k)
+			if !isWaiting(id) {
+				report(id, func() error {
+					return tx.Bucket(q.name).Bucket(q.keys.blockedOn).DeleteBucket(id)
+				}, "waits on messages, but isn't waiting")
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	if waiting := q.readBucket(tx, q.keys.waiting); waiting != nil {
+		blockedOn := q.readBucket(tx, q.keys.blockedOn)
+		err := waiting.ForEach(func(k, _ []byte) error {
+			if !isWaiting(k) {
+				return nil
+			}
+			// A message that only waits on messages that are gone
+			// would wait forever.
+			if blockedOn != nil && blockedOn.Bucket(k) != nil {
+				cur := blockedOn.Bucket(k).Cursor()
+				for blocker, _ := cur.First(); blocker != nil; blocker, _ = cur.Next() {
+					if exists(blocker) {
+						return nil
+					}
+				}
+			}
+			id := cloneBytes(k)
+			report(id, func() error {
+				return q.releaseWaiting(tx, id)
+			}, "waiting, but on nothing")
+			return nil
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	if blocking := q.readBucket(tx, q.keys.blocking); blocking != nil {
+		err := blocking.ForEach(func(k, _ []byte) error {
+			blocker := cloneBytes(k)
+			b := blocking.Bucket(k)
+			if b == nil {
+				return nil
+			}
+			if !exists(blocker) {
+				report(blocker, func() error {
+					return tx.Bucket(q.name).Bucket(q.keys.blocking).DeleteBucket(blocker)
+				}, "blocks messages, but is gone")
+				return nil
+			}
+			return b.ForEach(func(w, _ []byte) error {
+				if isWaiting(w) {
+					return nil
+				}
+				id := cloneBytes(w)
+				report(id, func() error {
+					return tx.Bucket(q.name).Bucket(q.keys.blocking).Bucket(blocker).Delete(id)
+				}, "blocked on %x, but isn't waiting", blocker)
+				return nil
+			})
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	// Selector indexes and message groups.
+	if index := q.readBucket(tx, q.keys.selectors); index != nil {
+		err := index.ForEach(func(header, _ []byte) error {
+			selector := index.Bucket(header)
+			if selector == nil {
+				return nil
+			}
+			return selector.ForEach(func(value, _ []byte) error {
+				values := selector.Bucket(value)
+				if values == nil {
+					return nil
+				}
+				header, value := cloneBytes(header), cloneBytes(value)
+				return values.ForEach(func(k, _ []byte) error {
+					if exists(k) {
+						return nil
+					}
+					id := cloneBytes(k)
+					report(id, func() error {
+						return tx.Bucket(q.name).Bucket(q.keys.selectors).Bucket(header).Bucket(value).Delete(id)
+					}, "indexed for selector %q, but gone", header)
+					return nil
+				})
+			})
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	if groups := q.readBucket(tx, q.keys.groups); groups != nil {
+		err := groups.ForEach(func(k, v []byte) error {
+			if v == nil || exists(v) {
+				return nil
+			}
+			group := cloneBytes(k)
+			report(v, func() error {
+				return tx.Bucket(q.name).Bucket(q.keys.groups).Delete(group)
+			}, "holds group %q, but is gone", group[1:])
+			return nil
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	// Counters.
+	if counters := q.readBucket(tx, q.keys.counters); counters != nil {
+		err := counters.ForEach(func(k, v []byte) error {
+			if len(v) != 8 {
+				report(nil, nil, "counter %q has a bad encoding", k)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	if got := q.counter(tx, depthCounter); got != depth && q.readBucket(tx, q.keys.counters) != nil {
+		report(nil, func() error {
+			return q.resetDepth(tx)
+		}, "depth is %d, but %d messages count towards it", got, depth)
+	}
+	return problems, fixes, nil
+}
+
+// releaseWaiting moves the waiting message id to Ready.
+func (q *Q) releaseWaiting(tx *bolt.Tx, id []byte) error {
+	waiting := tx.Bucket(q.name).Bucket(q.keys.waiting)
+	ready, err := q.bucket(tx, q.keys.ready)
+	if err != nil {
+		return err
+	}
+	if err := ready.Put(id, waiting.Get(id)); err != nil {
+		return err
+	}
+	if blockedOn := q.readBucket(tx, q.keys.blockedOn); blockedOn != nil && blockedOn.Bucket(id) != nil {
+		if err := blockedOn.DeleteBucket(id); err != nil {
+			return err
+		}
+	}
+	q.touch(tx, id)
+	return waiting.Delete(id)
+}
@@ -0,0 +1,111 @@
+package lasr
+
+import (
+	"testing"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+func TestVerify(t *testing.T) {
+	q, cleanup := newQ(t, WithDeadLetters(), WithChecksums(), WithChunking(4), WithSelector("k"))
+	defer cleanup()
+
+	blocker, err := q.SendWithHeaders([]byte("blocker"), map[string][]byte{"k": []byte("v")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := q.Wait([]byte("waiting"), blocker); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := q.Delay([]byte("delayed"), time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := q.Send([]byte("dead letter")); err != nil {
+		t.Fatal(err)
+	}
+	msgs := receiveN(t, q, 2)
+	if err := msgs[1].Nack(false); err != nil {
+		t.Fatal(err)
+	}
+	if err := msgs[0].Nack(true); err != nil {
+		t.Fatal(err)
+	}
+	problems, err := q.Verify()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(problems) > 0 {
+		t.Fatalf("problems with a sound queue: %v", problems)
+	}
+
+	// Break the queue the way a crash or a bug could.
+	key, _ := blocker.MarshalBinary()
+	err = q.db.Update(func(tx *bolt.Tx) error {
+		if err := q.putMeta(tx, []byte("gone"), &metadata{Attempts: 1}); err != nil {
+			return err
+		}
+		chunks, err := q.bucket(tx, chunksBucket)
+		if err != nil {
+			return err
+		}
+		if _, err := chunks.CreateBucket([]byte("gone")); err != nil {
+			return err
+		}
+		// The blocker disappears without releasing the waiting message.
+		return q.readBucket(tx, q.keys.ready).Delete(key)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	problems, err = q.Verify()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range problems {
+		if !p.Fixable {
+			t.Errorf("problem should be fixable: %s", p)
+		}
+	}
+	// The orphaned metadata, chunks, checksum, index entry and links of the
+	// blocker, the stray metadata and chunks, the waiting message that
+	// waits on it, and the depth.
+	if got, want := len(problems), 9; got != want {
+		t.Errorf("got %d problems, want %d: %v", got, want, problems)
+	}
+
+	if _, err := q.Repair(); err != nil {
+		t.Fatal(err)
+	}
+	problems, err = q.Verify()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(problems) > 0 {
+		t.Errorf("problems left after repair: %v", problems)
+	}
+	stats, err := q.Stats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Ready != 1 || stats.Waiting != 0 {
+		t.Errorf("waiting message not released: %+v", stats)
+	}
+}
+
+func TestVerifyCorrupt(t *testing.T) {
+	q, cleanup := newQ(t, WithChecksums())
+	defer cleanup()
+	id, err := q.Send([]byte("hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	corrupt(t, q, id)
+	problems, err := q.Verify()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(problems) != 1 || problems[0].Fixable {
+		t.Errorf("got %v, want one unfixable problem", problems)
+	}
+}
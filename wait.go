@@ -1,6 +1,11 @@
 package lasr
 
-import bolt "go.etcd.io/bbolt"
+import (
+	"errors"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
 
 // Wait causes a message to wait for other messages to Ack, before entering the
 // Ready state.
@@ -13,10 +18,11 @@ func (q *Q) Wait(msg []byte, on ...ID) (ID, error) {
 	if len(on) < 1 {
 		return q.Send(msg)
 	}
+	if q.partitions > 0 {
+		return nil, errors.New("lasr: Wait is not supported on a partitioned Q")
+	}
 	var id ID
-	q.mu.RLock()
-	defer q.mu.RUnlock()
-	return id, q.db.Update(func(tx *bolt.Tx) error {
+	err := q.admit(func(tx *bolt.Tx) error {
 		var err error
 		id, err = q.nextSequence(tx)
 		if err != nil {
@@ -26,38 +32,73 @@ func (q *Q) Wait(msg []byte, on ...ID) (ID, error) {
 		if err != nil {
 			return err
 		}
-		blockedOn, err := q.bucket(tx, q.keys.blockedOn)
+		subs, err := q.subscribers(tx)
 		if err != nil {
 			return err
 		}
-		blockedMsg, err := blockedOn.CreateBucketIfNotExists(idb)
-		if err != nil {
-			return err
+		if len(subs) == 0 {
+			return q.wait(tx, idb, msg, on)
 		}
-		blocking, err := q.bucket(tx, q.keys.blocking)
+		for _, sub := range subs {
+			if err := sub.wait(tx, idb, msg, on); err != nil {
+				return err
+			}
+		}
+		return q.countSent(tx, idb)
+	})
+	if err == nil {
+		q.onSend(id)
+	}
+	return id, err
+}
+
+// wait stores a message with ID idb that waits for the messages in on.
+func (q *Q) wait(tx *bolt.Tx, idb, msg []byte, on []ID) error {
+	blockedOn, err := q.bucket(tx, q.keys.blockedOn)
+	if err != nil {
+		return err
+	}
+	blockedMsg, err := blockedOn.CreateBucketIfNotExists(idb)
+	if err != nil {
+		return err
+	}
+	blocking, err := q.bucket(tx, q.keys.blocking)
+	if err != nil {
+		return err
+	}
+	for _, id := range on {
+		idc, err := id.MarshalBinary()
 		if err != nil {
 			return err
 		}
-		for _, id := range on {
-			idc, err := id.MarshalBinary()
-			if err != nil {
-				return err
-			}
-			if err := blockedMsg.Put(idc, nil); err != nil {
-				return err
-			}
-			blockerMsg, err := blocking.CreateBucketIfNotExists(idc)
-			if err != nil {
-				return err
-			}
-			if err := blockerMsg.Put(idb, nil); err != nil {
-				return err
-			}
+		if err := blockedMsg.Put(idc, nil); err != nil {
+			return err
 		}
-		waiting, err := q.bucket(tx, q.keys.waiting)
+		blockerMsg, err := blocking.CreateBucketIfNotExists(idc)
 		if err != nil {
 			return err
 		}
-		return waiting.Put(idb, msg)
-	})
+		if err := blockerMsg.Put(idb, nil); err != nil {
+			return err
+		}
+	}
+	waiting, err := q.bucket(tx, q.keys.waiting)
+	if err != nil {
+		return err
+	}
+	sealed, err := q.sealBody(tx, idb, msg)
+	if err != nil {
+		return err
+	}
+	if err := waiting.Put(idb, sealed); err != nil {
+		return err
+	}
+	q.touch(tx, idb)
+	if err := q.putMeta(tx, idb, &metadata{Enqueued: time.Now().UnixNano()}); err != nil {
+		return err
+	}
+	if err := q.adjustDepth(tx, 1); err != nil {
+		return err
+	}
+	return q.countSent(tx, idb)
 }
@@ -52,6 +52,8 @@ type waker struct {
 	reset    chan struct{}
 	nextWake time.Time
 	wakes    timeHeap
+	// changed, if not nil, is closed on the next wake.
+	changed chan struct{}
 	sync.Mutex
 }
 
@@ -65,12 +67,21 @@ func newWaker(closed chan struct{}) *waker {
 		for {
 			w.Lock()
 			nextWake := w.wakes.PopTime()
+			// nextWake is what the timer below is waiting for, so
+			// that WakeAt only resets it for earlier times.
+			w.nextWake = nextWake
 			w.Unlock()
 			at := nextWake.Sub(time.Now())
 			timer := time.NewTimer(at)
 			select {
 			case <-timer.C:
-				w.Wake()
+				// w may have been closed while the timer fired, so
+				// don't use Wake, which panics on closed wakers.
+				select {
+				case w.C <- struct{}{}:
+				default:
+				}
+				w.broadcast()
 				timer.Stop()
 			case <-w.closed:
 				timer.Stop()
@@ -93,6 +104,27 @@ func (w *waker) Wake() {
 	case w.C <- struct{}{}:
 	default:
 	}
+	w.broadcast()
+}
+
+// Changed returns a channel that is closed the next time w wakes. Unlike C,
+// it wakes every goroutine that is waiting on it.
+func (w *waker) Changed() <-chan struct{} {
+	w.Lock()
+	defer w.Unlock()
+	if w.changed == nil {
+		w.changed = make(chan struct{})
+	}
+	return w.changed
+}
+
+func (w *waker) broadcast() {
+	w.Lock()
+	defer w.Unlock()
+	if w.changed != nil {
+		close(w.changed)
+		w.changed = nil
+	}
 }
 
 func (w *waker) WakeAt(t time.Time) {
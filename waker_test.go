@@ -33,3 +33,32 @@ func TestWakeAt(t *testing.T) {
 		t.Errorf("waited too long: %d > %d", got, want)
 	}
 }
+
+func TestWakeAtAfterFired(t *testing.T) {
+	done := make(chan struct{})
+	defer func() {
+		close(done)
+	}()
+	w := newWaker(done)
+	w.WakeAt(time.Now().Add(10 * time.Millisecond))
+	<-w.C
+	// The first wake has fired, so a later one must still be scheduled.
+	w.WakeAt(time.Now().Add(10 * time.Millisecond))
+	select {
+	case <-w.C:
+	case <-time.After(time.Second):
+		t.Fatal("second WakeAt never fired")
+	}
+}
+
+func TestWakeAtClosed(t *testing.T) {
+	// A timer that fires as the waker is closed must not panic.
+	for i := 0; i < 100; i++ {
+		done := make(chan struct{})
+		w := newWaker(done)
+		w.WakeAt(time.Now().Add(time.Millisecond))
+		time.Sleep(time.Millisecond)
+		close(done)
+	}
+	time.Sleep(10 * time.Millisecond)
+}
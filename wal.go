@@ -0,0 +1,578 @@
+package lasr
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// walBucket holds the ID of the last message that was folded from the
+// write-ahead log into the root bucket of a Q.
+var (
+	walBucket    = []byte("wal")
+	walFoldedKey = []byte("folded")
+)
+
+// walRetryInterval is how long the write-ahead log waits to fold again after
+// a fold failed.
+var walRetryInterval = time.Second
+
+const walSegmentExt = ".wal"
+
+var walCRC = crc32.MakeTable(crc32.Castagnoli)
+
+// WithWAL makes Send and SendWithHeaders append messages to a write-ahead log
+// in dir, instead of committing a bolt transaction for each of them. The log
+// is folded into the database in the background, in transactions that hold
+// every message appended since the last fold, and the part of the log that
+// was folded is then removed.
+//
+// This makes sends much cheaper, but the messages in the log are not visible
+// until they are folded: Receive, Stats, Peek and the like see them a moment
+// later than usual. The log is flushed to disk according to the sync policy
+// of q, see WithSyncPolicy. If the process stops before a fold, the messages
+// that are left in the log are folded when the Q is opened again.
+//
+// The other ways of adding messages, like Delay and Wait, are not logged.
+// WithWAL can't be combined with WithMaxDepth, or with a PersistentSequencer
+// or a TxSequencer, whose state must be saved along with the messages.
+func WithWAL(dir string) Option {
+	return func(q *Q) error {
+		if q.optsApplied {
+			return ErrOptionsApplied
+		}
+		if dir == "" {
+			return errors.New("lasr: WAL directory required")
+		}
+		q.wal = &wal{dir: dir}
+		return nil
+	}
+}
+
+// wal is the write-ahead log of a Q. It is made of segment files, named by
+// their generation, that are replaced on every fold.
+type wal struct {
+	dir string
+
+	// mu guards the current segment and the records that are not folded
+	// yet.
+	mu      sync.Mutex
+	f       *os.File
+	gen     uint64
+	size    int64
+	pending []*walRecord
+	closed  bool
+	buf     []byte
+	// stale holds the segments whose fold failed, to be removed with the
+	// next segment that is folded.
+	stale []uint64
+
+	// written counts every byte appended to the log, across segments, and
+	// synced how many of them are known to be on disk.
+	written int64
+	syncMu  sync.Mutex
+	synced  int64
+
+	// seq is the last ID handed out by the default sequencer.
+	seq uint64
+
+	signal chan struct{}
+	done   chan struct{}
+}
+
+type walRecord struct {
+	id       []byte
+	enqueued int64
+	body     []byte
+	headers  map[string][]byte
+}
+
+// openWAL folds what was left in the log of q by the last process, and
+// starts the log.
+func (q *Q) openWAL() error {
+	w := q.wal
+	if w == nil || q.readOnly {
+		return nil
+	}
+	if q.maxDepth > 0 {
+		return errors.New("lasr: couldn't open WAL: WithMaxDepth is not supported")
+	}
+	if _, ok := q.seq.(PersistentSequencer); ok {
+		return errors.New("lasr: couldn't open WAL: PersistentSequencer is not supported")
+	}
+	if _, ok := q.seq.(TxSequencer); ok {
+		return errors.New("lasr: couldn't open WAL: TxSequencer is not supported")
+	}
+	if err := os.MkdirAll(w.dir, 0700); err != nil {
+		return fmt.Errorf("lasr: couldn't open WAL: %s", err)
+	}
+	if err := q.replayWAL(); err != nil {
+		return fmt.Errorf("lasr: couldn't replay WAL: %s", err)
+	}
+	q.mu.RLock()
+	err := q.db.View(func(tx *bolt.Tx) error {
+		w.seq = tx.Bucket(q.seqName).Sequence()
+		return nil
+	})
+	q.mu.RUnlock()
+	if err != nil {
+		return err
+	}
+	f, err := w.create(w.gen + 1)
+	if err != nil {
+		return fmt.Errorf("lasr: couldn't open WAL: %s", err)
+	}
+	w.f = f
+	w.gen++
+	w.signal = make(chan struct{}, 1)
+	w.done = make(chan struct{})
+	go q.walLoop()
+	return nil
+}
+
+// segments returns the generations of the segments in the log, in order.
+func (w *wal) segments() ([]uint64, error) {
+	entries, err := os.ReadDir(w.dir)
+	if err != nil {
+		return nil, err
+	}
+	var gens []uint64
+	for _, e := range entries {
+		name := e.Name()
+		if !strings.HasSuffix(name, walSegmentExt) {
+			continue
+		}
+		var gen uint64
+		if _, err := fmt.Sscanf(strings.TrimSuffix(name, walSegmentExt), "%016x", &gen); err != nil {
+			continue
+		}
+		gens = append(gens, gen)
+	}
+	sort.Slice(gens, func(i, j int) bool { return gens[i] < gens[j] })
+	return gens, nil
+}
+
+func (w *wal) segmentPath(gen uint64) string {
+	return filepath.Join(w.dir, fmt.Sprintf("%016x%s", gen, walSegmentExt))
+}
+
+func (w *wal) create(gen uint64) (*os.File, error) {
+	return os.OpenFile(w.segmentPath(gen), os.O_RDWR|os.O_CREATE|os.O_APPEND|os.O_TRUNC, 0600)
+}
+
+// replayWAL folds the records of every segment that were not folded yet, and
+// removes the segments.
+func (q *Q) replayWAL() error {
+	w := q.wal
+	gens, err := w.segments()
+	if err != nil {
+		return err
+	}
+	if len(gens) == 0 {
+		return nil
+	}
+	var recs []*walRecord
+	for _, gen := range gens {
+		b, err := os.ReadFile(w.segmentPath(gen))
+		if err != nil {
+			return err
+		}
+		// A torn record at the end of a segment was never acknowledged
+		// to the sender, so the segment ends there.
+		for len(b) > 0 {
+			rec, n, err := decodeWALRecord(b)
+			if err != nil {
+				q.logger().Warn("lasr: WAL segment ends with a damaged record", "segment", gen, "error", err)
+				break
+			}
+			recs = append(recs, rec)
+			b = b[n:]
+		}
+	}
+	if len(recs) > 0 {
+		q.logger().Warn("lasr: replaying WAL", "records", len(recs))
+	}
+	if err := q.foldRecords(recs); err != nil {
+		return err
+	}
+	for _, gen := range gens {
+		if err := os.Remove(w.segmentPath(gen)); err != nil {
+			return err
+		}
+	}
+	w.gen = gens[len(gens)-1]
+	return nil
+}
+
+// sendWAL appends a message to the log of q.
+func (q *Q) sendWAL(message []byte, headers map[string][]byte) (ID, error) {
+	if q.readOnly {
+		return nil, ErrReadOnly
+	}
+	w := q.wal
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return nil, ErrQClosed
+	}
+	// IDs are handed out under the lock, so that they increase along the
+	// log.
+	var id ID
+	if q.seq != nil {
+		var err error
+		if id, err = q.seq.NextSequence(); err != nil {
+			w.mu.Unlock()
+			return nil, err
+		}
+	} else {
+		w.seq++
+		id = Uint64ID(w.seq)
+	}
+	key, err := id.MarshalBinary()
+	if err != nil {
+		w.mu.Unlock()
+		return nil, err
+	}
+	body, err := q.seal(key, message)
+	if err != nil {
+		w.mu.Unlock()
+		return nil, err
+	}
+	rec := &walRecord{id: key, enqueued: time.Now().UnixNano(), body: body, headers: headers}
+	w.buf = appendWALRecord(w.buf[:0], rec)
+	if _, err := w.f.Write(w.buf); err != nil {
+		// Don't leave a partial record for the next one to follow.
+		w.f.Truncate(w.size)
+		w.mu.Unlock()
+		return nil, fmt.Errorf("lasr: couldn't append to WAL: %s", err)
+	}
+	w.size += int64(len(w.buf))
+	w.written += int64(len(w.buf))
+	end := w.written
+	w.pending = append(w.pending, rec)
+	w.mu.Unlock()
+
+	if q.syncPolicy.always() {
+		if err := w.syncTo(end); err != nil {
+			return nil, fmt.Errorf("lasr: couldn't sync WAL: %s", err)
+		}
+	}
+	select {
+	case w.signal <- struct{}{}:
+	default:
+	}
+	q.onSend(id)
+	return id, nil
+}
+
+// nextID hands out the next ID of the default sequencer in the log's mode,
+// and keeps the bolt sequence of q up to date with it.
+func (w *wal) nextID(tx *bolt.Tx, seqName []byte) (Uint64ID, error) {
+	w.mu.Lock()
+	w.seq++
+	id := w.seq
+	w.mu.Unlock()
+	bucket := tx.Bucket(seqName)
+	if bucket.Sequence() < id {
+		if err := bucket.SetSequence(id); err != nil {
+			return 0, err
+		}
+	}
+	return Uint64ID(id), nil
+}
+
+// syncTo flushes the log to disk, unless a concurrent flush already covered
+// the first end bytes. Segments are flushed before they are replaced, so only
+// the current one needs it.
+func (w *wal) syncTo(end int64) error {
+	w.syncMu.Lock()
+	defer w.syncMu.Unlock()
+	if w.synced >= end {
+		return nil
+	}
+	w.mu.Lock()
+	f, written := w.f, w.written
+	w.mu.Unlock()
+	if err := f.Sync(); err != nil {
+		return err
+	}
+	w.synced = written
+	return nil
+}
+
+func (q *Q) walLoop() {
+	w := q.wal
+	defer close(w.done)
+	for {
+		select {
+		case <-w.signal:
+		case <-q.closed:
+			return
+		}
+		if err := q.foldWAL(); err != nil {
+			q.logger().Warn("lasr: couldn't fold WAL, retrying", "error", err)
+			select {
+			case <-time.After(walRetryInterval):
+			case <-q.closed:
+				return
+			}
+			select {
+			case w.signal <- struct{}{}:
+			default:
+			}
+		}
+	}
+}
+
+// foldWAL commits the records that were appended to the log since the last
+// fold, and removes the segment that held them.
+func (q *Q) foldWAL() error {
+	w := q.wal
+	w.mu.Lock()
+	batch := w.pending
+	if len(batch) == 0 {
+		w.mu.Unlock()
+		return nil
+	}
+	f, err := w.create(w.gen + 1)
+	if err != nil {
+		w.mu.Unlock()
+		return err
+	}
+	old, oldGen := w.f, w.gen
+	if !q.syncPolicy.never {
+		if err := old.Sync(); err != nil {
+			w.mu.Unlock()
+			f.Close()
+			os.Remove(w.segmentPath(w.gen + 1))
+			return err
+		}
+	}
+	w.f, w.gen, w.size = f, w.gen+1, 0
+	w.pending = nil
+	w.mu.Unlock()
+
+	if err := q.foldRecords(batch); err != nil {
+		// The old segment stays, and its records are folded again with
+		// the next batch.
+		w.mu.Lock()
+		w.pending = append(batch, w.pending...)
+		w.stale = append(w.stale, oldGen)
+		w.mu.Unlock()
+		w.closeSegment(old)
+		return err
+	}
+	w.closeSegment(old)
+	w.mu.Lock()
+	gens := append(w.stale, oldGen)
+	w.stale = nil
+	w.mu.Unlock()
+	for _, gen := range gens {
+		if err := os.Remove(w.segmentPath(gen)); err != nil {
+			q.logger().Warn("lasr: couldn't remove WAL segment", "segment", gen, "error", err)
+		}
+	}
+	if !q.isClosed() {
+		q.waker.Wake()
+	}
+	q.wakeSubscriptions()
+	return nil
+}
+
+// closeSegment closes a segment that was replaced, once a flush that is still
+// running is done with it.
+func (w *wal) closeSegment(f *os.File) {
+	w.syncMu.Lock()
+	defer w.syncMu.Unlock()
+	f.Close()
+}
+
+// foldRecords sends the messages of recs in one transaction, skipping the
+// ones that were folded before.
+func (q *Q) foldRecords(recs []*walRecord) error {
+	if len(recs) == 0 {
+		return nil
+	}
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	return q.db.Update(func(tx *bolt.Tx) error {
+		root, err := tx.CreateBucketIfNotExists(q.name)
+		if err != nil {
+			return err
+		}
+		bucket, err := root.CreateBucketIfNotExists(walBucket)
+		if err != nil {
+			return err
+		}
+		folded := cloneBytes(bucket.Get(walFoldedKey))
+		for _, rec := range recs {
+			if folded != nil && bytes.Compare(rec.id, folded) <= 0 {
+				continue
+			}
+			body, err := q.unseal(rec.id, rec.body)
+			if err != nil {
+				return err
+			}
+			md := &metadata{Headers: rec.headers, Enqueued: rec.enqueued}
+			if err := q.send(rawID(rec.id), body, md, tx); err != nil {
+				return err
+			}
+			if q.seq == nil && len(rec.id) == 8 {
+				seq := tx.Bucket(q.seqName)
+				if id := binary.BigEndian.Uint64(rec.id); seq.Sequence() < id {
+					if err := seq.SetSequence(id); err != nil {
+						return err
+					}
+				}
+			}
+			folded = rec.id
+		}
+		return bucket.Put(walFoldedKey, folded)
+	})
+}
+
+// stopWAL stops the log of q once q is closed, and folds what is left of it.
+func (q *Q) stopWAL() error {
+	w := q.wal
+	if w == nil || w.done == nil {
+		return nil
+	}
+	<-w.done
+	w.mu.Lock()
+	w.closed = true
+	w.mu.Unlock()
+	err := q.foldWAL()
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if cerr := w.f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil && len(w.pending) == 0 {
+		err = os.Remove(w.segmentPath(w.gen))
+	}
+	return err
+}
+
+// syncWAL flushes the log of q to disk, for the sync loop.
+func (q *Q) syncWAL() error {
+	w := q.wal
+	if w == nil || w.done == nil {
+		return nil
+	}
+	w.mu.Lock()
+	end := w.written
+	w.mu.Unlock()
+	return w.syncTo(end)
+}
+
+// appendWALRecord appends the encoding of rec to b: its length and checksum,
+// followed by the ID, the time it was enqueued, the body and the headers.
+func appendWALRecord(b []byte, rec *walRecord) []byte {
+	start := len(b)
+	b = append(b, make([]byte, 8)...)
+	b = appendBytes(b, rec.id)
+	b = binary.AppendVarint(b, rec.enqueued)
+	b = appendBytes(b, rec.body)
+	b = binary.AppendUvarint(b, uint64(len(rec.headers)))
+	for k, v := range rec.headers {
+		b = appendBytes(b, []byte(k))
+		b = appendBytes(b, v)
+	}
+	payload := b[start+8:]
+	binary.BigEndian.PutUint32(b[start:], uint32(len(payload)))
+	binary.BigEndian.PutUint32(b[start+4:], crc32.Checksum(payload, walCRC))
+	return b
+}
+
+func appendBytes(b, v []byte) []byte {
+	b = binary.AppendUvarint(b, uint64(len(v)))
+	return append(b, v...)
+}
+
+// decodeWALRecord decodes the record at the start of b, and returns it with
+// its encoded length.
+func decodeWALRecord(b []byte) (*walRecord, int, error) {
+	if len(b) < 8 {
+		return nil, 0, io.ErrUnexpectedEOF
+	}
+	n := int(binary.BigEndian.Uint32(b))
+	if len(b)-8 < n {
+		return nil, 0, io.ErrUnexpectedEOF
+	}
+	payload := b[8 : 8+n]
+	if crc32.Checksum(payload, walCRC) != binary.BigEndian.Uint32(b[4:]) {
+		return nil, 0, errors.New("checksum mismatch")
+	}
+	r := &walReader{b: payload}
+	rec := &walRecord{id: r.bytes()}
+	rec.enqueued = r.varint()
+	rec.body = r.bytes()
+	if count := r.uvarint(); count > 0 && r.err == nil {
+		rec.headers = make(map[string][]byte)
+		for i := uint64(0); i < count && r.err == nil; i++ {
+			k := r.bytes()
+			rec.headers[string(k)] = r.bytes()
+		}
+	}
+	if r.err != nil {
+		return nil, 0, r.err
+	}
+	return rec, 8 + n, nil
+}
+
+// walReader reads the fields of a record, and remembers the first error.
+type walReader struct {
+	b   []byte
+	err error
+}
+
+func (r *walReader) uvarint() uint64 {
+	if r.err != nil {
+		return 0
+	}
+	v, n := binary.Uvarint(r.b)
+	if n <= 0 {
+		r.err = io.ErrUnexpectedEOF
+		return 0
+	}
+	r.b = r.b[n:]
+	return v
+}
+
+func (r *walReader) varint() int64 {
+	if r.err != nil {
+		return 0
+	}
+	v, n := binary.Varint(r.b)
+	if n <= 0 {
+		r.err = io.ErrUnexpectedEOF
+		return 0
+	}
+	r.b = r.b[n:]
+	return v
+}
+
+func (r *walReader) bytes() []byte {
+	n := r.uvarint()
+	if r.err != nil {
+		return nil
+	}
+	if uint64(len(r.b)) < n {
+		r.err = io.ErrUnexpectedEOF
+		return nil
+	}
+	v := cloneBytes(r.b[:n])
+	r.b = r.b[n:]
+	return v
+}
@@ -0,0 +1,123 @@
+package lasr
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestWAL(t *testing.T) {
+	q, cleanup := newQ(t, WithWAL(t.TempDir()))
+	defer cleanup()
+
+	sendBodies(t, q, "a", "b")
+	if _, err := q.SendWithHeaders([]byte("c"), map[string][]byte{"k": []byte("v")}); err != nil {
+		t.Fatal(err)
+	}
+	msgs := receiveN(t, q, 3)
+	for i, want := range []string{"a", "b", "c"} {
+		if got := string(msgs[i].Body); got != want {
+			t.Errorf("message %d: got %q, want %q", i, got, want)
+		}
+		if msgs[i].EnqueuedAt.IsZero() {
+			t.Errorf("message %d: no enqueue time", i)
+		}
+		if err := msgs[i].Ack(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := string(msgs[2].Headers["k"]); got != "v" {
+		t.Errorf("bad header: got %q, want %q", got, "v")
+	}
+
+	// Sends that commit a transaction draw IDs from the same sequence.
+	id, err := q.Wait([]byte("d"), rawID(msgs[0].ID))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := id.(Uint64ID), Uint64ID(4); got != want {
+		t.Errorf("got ID %d, want %d", got, want)
+	}
+}
+
+func TestWALReplay(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(t.TempDir(), "lasr.db")
+
+	// A segment left behind by a process that stopped before folding it,
+	// with a record that it didn't finish writing.
+	var segment []byte
+	for i, body := range []string{"a", "b"} {
+		key, _ := Uint64ID(i + 1).MarshalBinary()
+		segment = appendWALRecord(segment, &walRecord{id: key, enqueued: time.Now().UnixNano(), body: []byte(body)})
+	}
+	good := len(segment)
+	key, _ := Uint64ID(3).MarshalBinary()
+	segment = appendWALRecord(segment, &walRecord{id: key, body: []byte("torn")})
+	segment = segment[:len(segment)-2]
+	if err := os.WriteFile(filepath.Join(dir, "0000000000000001.wal"), segment, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	q, err := OpenQ(path, "testing", WithWAL(dir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	stats, err := q.Stats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Ready != 2 {
+		t.Errorf("got %d ready messages, want 2", stats.Ready)
+	}
+	id, err := q.Send([]byte("c"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := id.(Uint64ID), Uint64ID(3); got != want {
+		t.Errorf("got ID %d, want %d", got, want)
+	}
+	if err := q.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if gens, err := q.wal.segments(); err != nil || len(gens) != 0 {
+		t.Errorf("segments left after close: %v, %v", gens, err)
+	}
+
+	// Records that were folded before aren't folded twice.
+	if err := os.WriteFile(filepath.Join(dir, "0000000000000001.wal"), segment[:good], 0600); err != nil {
+		t.Fatal(err)
+	}
+	q, err = OpenQ(path, "testing", WithWAL(dir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer q.Close()
+	var bodies []string
+	for i := 0; i < 3; i++ {
+		msg, err := q.Receive(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		bodies = append(bodies, string(msg.Body))
+		if err := msg.Ack(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got, want := bodies, []string{"a", "b", "c"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if _, err := q.TryReceive(); err != ErrEmpty {
+		t.Errorf("got %v, want ErrEmpty", err)
+	}
+}
+
+func TestWALMaxDepth(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "lasr.db")
+	if _, err := OpenQ(path, "testing", WithWAL(t.TempDir()), WithMaxDepth(10)); err == nil {
+		t.Fatal("expected an error")
+	}
+}
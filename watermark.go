@@ -0,0 +1,107 @@
+package lasr
+
+import (
+	"fmt"
+	"sync"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// WatermarkEvent reports that the depth of a Q crossed one of its watermarks,
+// see WithDepthWatermarks.
+type WatermarkEvent struct {
+	// High is true when the depth rose to the high watermark, and false
+	// when it fell back to the low watermark.
+	High bool
+
+	// Depth is the number of Ready messages in the Q after the change
+	// that crossed the watermark.
+	Depth int
+}
+
+// WithDepthWatermarks calls fn when the number of Ready messages in q rises to
+// high, and again when it falls back to low, so that producers can throttle,
+// or autoscalers can add consumers, without polling Stats. Messages that are
+// Unacked, Delayed, Waiting or Retrying don't count.
+//
+// Events alternate: after a high event, fn is not called again until the
+// depth has fallen to low. If q already holds high Ready messages or more when
+// it is created, fn is called with a high event before NewQ returns. The Ready
+// messages are counted once each transaction that changes q is committed.
+//
+// fn is called after the change has been committed, by the goroutine that
+// made it, so like Hooks, it should return quickly and must not wait for q.
+func WithDepthWatermarks(high, low int, fn func(WatermarkEvent)) Option {
+	return func(q *Q) error {
+		if q.optsApplied {
+			return ErrOptionsApplied
+		}
+		if low < 0 || high <= low {
+			return fmt.Errorf("lasr: invalid watermarks: high %d, low %d", high, low)
+		}
+		q.watermarks = &watermarks{high: uint64(high), low: uint64(low), fn: fn}
+		return nil
+	}
+}
+
+type watermarks struct {
+	high, low uint64
+	fn        func(WatermarkEvent)
+
+	mu    sync.Mutex
+	above bool
+	// txid is the transaction that last changed the depth. Commit
+	// handlers can run out of order, so older changes are ignored.
+	txid int
+	// watched is the last transaction that watchReady was called in.
+	watched *bolt.Tx
+}
+
+// watchReady checks the watermarks of q against its Ready messages once tx is
+// committed. It is called for each change to q, and checks once per
+// transaction.
+func (q *Q) watchReady(tx *bolt.Tx) {
+	w := q.watermarks
+	if w == nil {
+		return
+	}
+	w.mu.Lock()
+	if w.watched == tx {
+		w.mu.Unlock()
+		return
+	}
+	w.watched = tx
+	w.mu.Unlock()
+	txid := tx.ID()
+	tx.OnCommit(func() {
+		// The caller holds q.mu, so the database can't be replaced.
+		var depth int
+		err := q.db.View(func(tx *bolt.Tx) error {
+			depth = q.keyCount(tx, q.keys.ready)
+			return nil
+		})
+		if err != nil {
+			q.logger().Warn("lasr: couldn't count Ready messages", "error", err)
+			return
+		}
+		w.check(txid, uint64(depth))
+	})
+}
+
+func (w *watermarks) check(txid int, depth uint64) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if txid < w.txid {
+		return
+	}
+	w.txid = txid
+	switch {
+	case !w.above && depth >= w.high:
+		w.above = true
+	case w.above && depth <= w.low:
+		w.above = false
+	default:
+		return
+	}
+	w.fn(WatermarkEvent{High: w.above, Depth: int(depth)})
+}
@@ -0,0 +1,93 @@
+package lasr
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestDepthWatermarks(t *testing.T) {
+	var (
+		mu     sync.Mutex
+		events []WatermarkEvent
+	)
+	record := func(e WatermarkEvent) {
+		mu.Lock()
+		defer mu.Unlock()
+		events = append(events, e)
+	}
+	q, cleanup := newQ(t, WithDepthWatermarks(3, 1, record))
+	defer cleanup()
+
+	sendBodies(t, q, "a", "b", "c", "d")
+	msgs := receiveN(t, q, 4)
+	for _, msg := range msgs {
+		if err := msg.Ack(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	sendBodies(t, q, "e", "f", "g")
+
+	want := []WatermarkEvent{
+		{High: true, Depth: 3},
+		{High: false, Depth: 1},
+		{High: true, Depth: 3},
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if !reflect.DeepEqual(events, want) {
+		t.Errorf("bad events: got %+v, want %+v", events, want)
+	}
+}
+
+func TestDepthWatermarksAtStart(t *testing.T) {
+	q, cleanup := newQ(t)
+	defer cleanup()
+	sendBodies(t, q, "a", "b")
+	if err := q.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	var events []WatermarkEvent
+	q, err := NewQ(q.db, "testing", WithDepthWatermarks(2, 0, func(e WatermarkEvent) {
+		events = append(events, e)
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer q.Close()
+	if want := []WatermarkEvent{{High: true, Depth: 2}}; !reflect.DeepEqual(events, want) {
+		t.Errorf("bad events: got %+v, want %+v", events, want)
+	}
+}
+
+func TestDepthWatermarksInvalid(t *testing.T) {
+	if err := WithDepthWatermarks(1, 1, func(WatermarkEvent) {})(&Q{}); err == nil {
+		t.Error("expected an error")
+	}
+}
+
+func TestDepthWatermarksReadyOnly(t *testing.T) {
+	var events []WatermarkEvent
+	q, cleanup := newQ(t, WithDepthWatermarks(2, 0, func(e WatermarkEvent) {
+		events = append(events, e)
+	}))
+	defer cleanup()
+	for i := 0; i < 3; i++ {
+		if _, err := q.Delay([]byte("later"), time.Now().Add(time.Hour)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	sendBodies(t, q, "a")
+	msg := receiveN(t, q, 1)[0]
+	defer msg.Ack()
+	sendBodies(t, q, "b")
+	if len(events) != 0 {
+		t.Errorf("got events %+v for one Ready message", events)
+	}
+	sendBodies(t, q, "c")
+	if want := []WatermarkEvent{{High: true, Depth: 2}}; !reflect.DeepEqual(events, want) {
+		t.Errorf("bad events: got %+v, want %+v", events, want)
+	}
+}